@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"svc-customer/middleware"
+)
+
+// APIKeyHeader header carrying service API keys
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyVerifier resolves a plain API key presented from ip into a Principal
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string, ip string) (*Principal, error)
+}
+
+// APIKeyAuthenticator authenticates service callers by X-API-Key
+type APIKeyAuthenticator struct {
+	Verifier APIKeyVerifier
+}
+
+// Authenticate ErrNoCredentials without the header, ErrInvalidCredentials when
+// the verifier rejects the key or the caller address.
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := strings.TrimSpace(r.Header.Get(APIKeyHeader))
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	principal, err := a.Verifier.VerifyAPIKey(r.Context(), key, middleware.ClientIP(r))
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return principal, nil
+}
@@ -0,0 +1,287 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"svc-customer/auth"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeJWKS stores key's public half as a single entry JWK Set file
+func writeJWKS(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	n := base64.RawURLEncoding.EncodeToString(key.N.Bytes())
+	e := base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes())
+	doc := fmt.Sprintf(`{"keys":[{"kty":"RSA","use":"sig","kid":%q,"n":%q,"e":%q}]}`, kid, n, e)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := ioutil.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// sign issues an RS256 token with claims and kid
+func sign(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"sub":   "039d69ee-f9cb-4a3d-87e4-6eb63c302579",
+		"iss":   "https://id.muble.app",
+		"aud":   "svc-customer",
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"roles": []string{"customer"},
+		"scope": "customers:read:self customers:update:self",
+	}
+}
+
+func TestJWTAuthenticatorWithJWKSFile(t *testing.T) {
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	authenticator := auth.NewJWTAuthenticator(auth.NewJWKS(writeJWKS(t, key, "k1")), "https://id.muble.app", "svc-customer")
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(t, key, "k1", validClaims()))
+
+	principal, err := authenticator.Authenticate(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "039d69ee-f9cb-4a3d-87e4-6eb63c302579", principal.Subject)
+	assert.True(t, principal.HasRole("customer"))
+	assert.True(t, principal.HasScope("customers:read:self"))
+}
+
+func TestJWKSServesLastKeysDuringRefresh(t *testing.T) {
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	doc, err := ioutil.ReadFile(writeJWKS(t, key, "k1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	release := make(chan struct{})
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			<-release
+		}
+		w.Write(doc) //nolint
+	}))
+	defer server.Close()
+	defer close(release)
+
+	jwks := auth.NewJWKS(server.URL)
+	_, err = jwks.Key("k1")
+	assert.NoError(t, err)
+
+	// The set is stale from now on, a slow refresh must not hold up lookups
+	jwks.TTL, jwks.MinRefresh = time.Nanosecond, 0
+	found := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			_, err := jwks.Key("k1")
+			found <- err
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		select {
+		case err := <-found:
+			assert.NoError(t, err)
+		case <-time.After(2 * time.Second):
+			t.Fatal("lookup blocked on the refresh")
+		}
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&fetches) == 2 }, time.Second, 10*time.Millisecond)
+	_, err = jwks.Key("k1")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&fetches), "one refresh in flight at a time")
+}
+
+func TestJWTAuthenticatorRejectsInvalidTokens(t *testing.T) {
+
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	authenticator := auth.NewJWTAuthenticator(auth.StaticKeys{"k1": &key.PublicKey}, "https://id.muble.app", "svc-customer")
+
+	expired := validClaims()
+	expired["exp"] = time.Now().Add(-time.Minute).Unix()
+
+	wrongAudience := validClaims()
+	wrongAudience["aud"] = "svc-billing"
+
+	noExpiry := validClaims()
+	delete(noExpiry, "exp")
+
+	cases := map[string]string{
+		"expired":        sign(t, key, "k1", expired),
+		"wrong audience": sign(t, key, "k1", wrongAudience),
+		"no expiry":      sign(t, key, "k1", noExpiry),
+		"wrong key":      sign(t, other, "k1", validClaims()),
+		"unknown kid":    sign(t, key, "k2", validClaims()),
+	}
+
+	for name, raw := range cases {
+		_, err := authenticator.Verify(raw)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestJWTAuthenticatorStaticSecret(t *testing.T) {
+
+	authenticator := auth.NewJWTAuthenticator(auth.StaticKeys{"": []byte("local-secret")}, "", "")
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+	raw, _ := token.SignedString([]byte("local-secret"))
+
+	principal, err := authenticator.Verify(raw)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt", principal.Method)
+}
+
+func TestMiddlewareRejectsMissingToken(t *testing.T) {
+
+	authenticator := auth.NewJWTAuthenticator(auth.StaticKeys{"": []byte("local-secret")}, "", "")
+	handler := auth.Middleware(auth.Chain{authenticator})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not run")
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("DELETE", "/039d69ee-f9cb-4a3d-87e4-6eb63c302579", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	assert.Equal(t, `Bearer realm="svc-customer"`, rr.Header().Get("WWW-Authenticate"))
+}
+
+func TestMiddlewareStoresPrincipal(t *testing.T) {
+
+	authenticator := auth.NewJWTAuthenticator(auth.StaticKeys{"": []byte("local-secret")}, "", "")
+
+	var subject string
+	handler := auth.Middleware(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal, _ := auth.FromContext(r.Context())
+		subject = principal.Subject
+	}))
+
+	raw, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("local-secret"))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "039d69ee-f9cb-4a3d-87e4-6eb63c302579", subject)
+}
+
+// verifierFunc adapts a function to auth.APIKeyVerifier
+type verifierFunc func(ctx context.Context, key string, ip string) (*auth.Principal, error)
+
+func (f verifierFunc) VerifyAPIKey(ctx context.Context, key string, ip string) (*auth.Principal, error) {
+	return f(ctx, key, ip)
+}
+
+func TestChainFallsBackToAPIKey(t *testing.T) {
+
+	var seenIP string
+	apiKeys := &auth.APIKeyAuthenticator{Verifier: verifierFunc(func(ctx context.Context, key string, ip string) (*auth.Principal, error) {
+		seenIP = ip
+		if key != "sk_0123456789ab_secret" {
+			return nil, fmt.Errorf("unknown key")
+		}
+		return &auth.Principal{Subject: "apikey:billing", Method: "apikey"}, nil
+	})}
+	chain := auth.Chain{auth.NewJWTAuthenticator(auth.StaticKeys{"": []byte("local-secret")}, "", ""), apiKeys}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.1.2.3:4567"
+	req.Header.Set(auth.APIKeyHeader, "sk_0123456789ab_secret")
+
+	principal, err := chain.Authenticate(req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey", principal.Method)
+	assert.Equal(t, "10.1.2.3", seenIP)
+
+	req.Header.Set(auth.APIKeyHeader, "sk_0123456789ab_wrong")
+	_, err = chain.Authenticate(req)
+
+	assert.Equal(t, auth.ErrInvalidCredentials, err)
+}
+
+func TestTokenIssuerRoundTrip(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &auth.TokenIssuer{Method: jwt.SigningMethodRS256, Key: key, KeyID: "k1", Issuer: "svc-customer", Roles: []string{"customer"}}
+
+	raw, ttl, err := issuer.Issue("039d69ee-f9cb-4a3d-87e4-6eb63c302579", "session-1")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.DefaultAccessTokenTTL, ttl)
+
+	verifier := auth.NewJWTAuthenticator(auth.StaticKeys{"k1": &key.PublicKey}, "svc-customer", "")
+	principal, err := verifier.Verify(raw)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "039d69ee-f9cb-4a3d-87e4-6eb63c302579", principal.Subject)
+	assert.True(t, principal.HasRole("customer"))
+	assert.Equal(t, "session-1", principal.Claims["sid"])
+}
+
+// sessionCheckerFunc adapts a function to auth.SessionChecker
+type sessionCheckerFunc func(ctx context.Context, sessionID string) (bool, error)
+
+func (f sessionCheckerFunc) SessionActive(ctx context.Context, sessionID string) (bool, error) {
+	return f(ctx, sessionID)
+}
+
+func TestJWTAuthenticatorRejectsRevokedSession(t *testing.T) {
+
+	issuer := &auth.TokenIssuer{Method: jwt.SigningMethodHS256, Key: []byte("local-secret")}
+	revoked := map[string]bool{"session-2": true}
+
+	authenticator := auth.NewJWTAuthenticator(auth.StaticKeys{"": []byte("local-secret")}, "", "")
+	authenticator.Sessions = sessionCheckerFunc(func(ctx context.Context, sessionID string) (bool, error) {
+		return !revoked[sessionID], nil
+	})
+
+	authenticate := func(sessionID string) error {
+		raw, _, _ := issuer.Issue("039d69ee-f9cb-4a3d-87e4-6eb63c302579", sessionID)
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+raw)
+		_, err := authenticator.Authenticate(req)
+		return err
+	}
+
+	assert.NoError(t, authenticate("session-1"))
+	assert.ErrorIs(t, authenticate("session-2"), auth.ErrInvalidCredentials)
+
+	// Tokens of other issuers carry no sid and are not tied to a session
+	raw, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims()).SignedString([]byte("local-secret"))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	_, err := authenticator.Authenticate(req)
+	assert.NoError(t, err)
+}
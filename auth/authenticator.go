@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"svc-customer/logging"
+	"svc-customer/problem"
+)
+
+var (
+	// ErrNoCredentials the request carries no credential this authenticator understands
+	ErrNoCredentials = errors.New("no credentials")
+
+	// ErrInvalidCredentials a credential was sent but could not be verified
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator turns request credentials into a Principal
+type Authenticator interface {
+	// Authenticate returns ErrNoCredentials when the request has nothing for
+	// this authenticator so the next one in a Chain can try.
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+// AuthenticatorFunc adapts an ordinary function to the Authenticator interface
+type AuthenticatorFunc func(r *http.Request) (*Principal, error)
+
+// Authenticate calls f(r)
+func (f AuthenticatorFunc) Authenticate(r *http.Request) (*Principal, error) {
+	return f(r)
+}
+
+// Chain tries every authenticator in order until one finds credentials
+type Chain []Authenticator
+
+// Authenticate first authenticator that does not answer ErrNoCredentials wins
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if err == ErrNoCredentials {
+			continue
+		}
+		return principal, err
+	}
+	return nil, ErrNoCredentials
+}
+
+// BearerToken token from "Authorization: Bearer <token>", empty when absent
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+// Middleware rejects unauthenticated requests with 401 and stores the
+// authenticated Principal in the request context otherwise.
+func Middleware(authenticator Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				logging.FromContext(r.Context()).WithError(err).Info("Authenticate: rejected")
+
+				challenge := `Bearer realm="svc-customer"`
+				if err != ErrNoCredentials {
+					challenge += `, error="invalid_token"`
+				}
+				w.Header().Set("WWW-Authenticate", challenge)
+				problem.Write(w, r, http.StatusUnauthorized, "Valid credentials are required")
+				return
+			}
+
+			ctx := NewContext(r.Context(), principal)
+			ctx = logging.NewContext(ctx, logging.FromContext(r.Context()).WithField("subject", principal.Subject))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
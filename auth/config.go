@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrNotConfigured no verification key source was configured
+var ErrNotConfigured = errors.New("auth: set AUTH_JWKS, AUTH_JWT_PUBLIC_KEY, AUTH_JWT_SECRET or AUTH_JWT_PRIVATE_KEY")
+
+// KeySetFromEnv builds the JWT key set from env:
+//
+//	AUTH_JWKS            JWK Set file path or http(s) URL
+//	AUTH_JWT_PUBLIC_KEY  PEM encoded RSA or EC public key file (static)
+//	AUTH_JWT_SECRET      shared HMAC secret (static, local development)
+//	AUTH_JWT_PRIVATE_KEY PEM private key signing this service's own tokens
+func KeySetFromEnv() (KeySet, error) {
+	if source := os.Getenv("AUTH_JWKS"); source != "" {
+		jwks := NewJWKS(source)
+		// Fail fast on a bad source instead of on the first request
+		if _, err := jwks.Key(""); err != nil && err != ErrUnknownKey {
+			return nil, err
+		}
+		return jwks, nil
+	}
+
+	if path := os.Getenv("AUTH_JWT_PUBLIC_KEY"); path != "" {
+		pem, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+		if key, err := jwt.ParseRSAPublicKeyFromPEM(pem); err == nil {
+			return StaticKeys{"": key}, nil
+		}
+		key, err := jwt.ParseECPublicKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s is neither an RSA nor an EC public key", path)
+		}
+		return StaticKeys{"": key}, nil
+	}
+
+	if secret := os.Getenv("AUTH_JWT_SECRET"); secret != "" {
+		return StaticKeys{"": []byte(secret)}, nil
+	}
+
+	// Tokens issued by this service only, verified with the signing key's public half
+	if path := os.Getenv("AUTH_JWT_PRIVATE_KEY"); path != "" {
+		_, key, err := loadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		return StaticKeys{os.Getenv("AUTH_JWT_KEY_ID"): key.Public()}, nil
+	}
+
+	return nil, ErrNotConfigured
+}
+
+// JWTAuthenticatorFromEnv JWT authenticator using KeySetFromEnv, AUTH_JWT_ISSUER and AUTH_JWT_AUDIENCE
+func JWTAuthenticatorFromEnv() (*JWTAuthenticator, error) {
+	keys, err := KeySetFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return NewJWTAuthenticator(keys, os.Getenv("AUTH_JWT_ISSUER"), os.Getenv("AUTH_JWT_AUDIENCE")), nil
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// ErrNoSigningKey no key to sign access tokens was configured
+var ErrNoSigningKey = errors.New("auth: set AUTH_JWT_PRIVATE_KEY or AUTH_JWT_SECRET to issue tokens")
+
+// DefaultAccessTokenTTL issued access tokens expire after 15 minutes
+const DefaultAccessTokenTTL = 15 * time.Minute
+
+// TokenIssuer signs short lived access tokens for customer sessions, the
+// JWTAuthenticator of the same service verifies them.
+type TokenIssuer struct {
+	Method jwt.SigningMethod
+	// Key private key or HMAC secret matching Method
+	Key interface{}
+	// KeyID kid header, omitted when empty
+	KeyID    string
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	// Roles granted to every issued token
+	Roles []string
+}
+
+// Issue signs a token for subject within session, returns it with its lifetime
+func (i *TokenIssuer) Issue(subject string, sessionID string) (string, time.Duration, error) {
+	ttl := i.TTL
+	if ttl == 0 {
+		ttl = DefaultAccessTokenTTL
+	}
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"sub":   subject,
+		"sid":   sessionID,
+		"roles": i.Roles,
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	}
+	if i.Issuer != "" {
+		claims["iss"] = i.Issuer
+	}
+	if i.Audience != "" {
+		claims["aud"] = i.Audience
+	}
+
+	token := jwt.NewWithClaims(i.Method, claims)
+	if i.KeyID != "" {
+		token.Header["kid"] = i.KeyID
+	}
+	signed, err := token.SignedString(i.Key)
+	if err != nil {
+		return "", 0, fmt.Errorf("auth: %w", err)
+	}
+	return signed, ttl, nil
+}
+
+// TokenIssuerFromEnv issuer signing with AUTH_JWT_PRIVATE_KEY (PEM RSA or EC
+// private key, kid AUTH_JWT_KEY_ID) or AUTH_JWT_SECRET (HS256). Tokens carry
+// AUTH_JWT_ISSUER, AUTH_JWT_AUDIENCE and the "customer" role, and live
+// AUTH_ACCESS_TOKEN_TTL (default 15m).
+func TokenIssuerFromEnv() (*TokenIssuer, error) {
+	issuer := &TokenIssuer{
+		KeyID:    os.Getenv("AUTH_JWT_KEY_ID"),
+		Issuer:   os.Getenv("AUTH_JWT_ISSUER"),
+		Audience: os.Getenv("AUTH_JWT_AUDIENCE"),
+		TTL:      DefaultAccessTokenTTL,
+		Roles:    []string{"customer"},
+	}
+	if value, ok := os.LookupEnv("AUTH_ACCESS_TOKEN_TTL"); ok {
+		ttl, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("auth: AUTH_ACCESS_TOKEN_TTL: %w", err)
+		}
+		issuer.TTL = ttl
+	}
+
+	if path := os.Getenv("AUTH_JWT_PRIVATE_KEY"); path != "" {
+		method, key, err := loadPrivateKey(path)
+		if err != nil {
+			return nil, err
+		}
+		issuer.Method, issuer.Key = method, key
+		return issuer, nil
+	}
+	if secret := os.Getenv("AUTH_JWT_SECRET"); secret != "" {
+		issuer.Method, issuer.Key = jwt.SigningMethodHS256, []byte(secret)
+		return issuer, nil
+	}
+	return nil, ErrNoSigningKey
+}
+
+// loadPrivateKey PEM RSA or EC private key and the algorithm signing with it
+func loadPrivateKey(path string) (jwt.SigningMethod, crypto.Signer, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("auth: %w", err)
+	}
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(pem); err == nil {
+		return jwt.SigningMethodRS256, key, nil
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, nil, fmt.Errorf("auth: %s is neither an RSA nor an EC private key", path)
+	}
+	return ecdsaMethod(key), key, nil
+}
+
+// ecdsaMethod ES algorithm matching the curve of key
+func ecdsaMethod(key *ecdsa.PrivateKey) jwt.SigningMethod {
+	switch key.Curve.Params().BitSize {
+	case 384:
+		return jwt.SigningMethodES384
+	case 521:
+		return jwt.SigningMethodES512
+	default:
+		return jwt.SigningMethodES256
+	}
+}
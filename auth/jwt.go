@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// signingMethods algorithms accepted on incoming tokens, "none" is never accepted
+var signingMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"HS256", "HS384", "HS512",
+}
+
+// JWTAuthenticator validates bearer JWTs against a KeySet
+type JWTAuthenticator struct {
+	Keys KeySet
+	// Issuer expected iss claim, skipped when empty
+	Issuer string
+	// Audience expected aud claim, skipped when empty
+	Audience string
+	// Sessions rejects tokens whose sid session was revoked or expired, skipped when nil
+	Sessions SessionChecker
+}
+
+// SessionChecker reports whether the session an access token was issued for is still active
+type SessionChecker interface {
+	SessionActive(ctx context.Context, sessionID string) (bool, error)
+}
+
+// NewJWTAuthenticator bearer JWT authenticator verifying signatures with keys
+func NewJWTAuthenticator(keys KeySet, issuer string, audience string) *JWTAuthenticator {
+	return &JWTAuthenticator{Keys: keys, Issuer: issuer, Audience: audience}
+}
+
+// Authenticate verifies the bearer token and maps its claims to a Principal
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	raw := BearerToken(r)
+	// Opaque (non JWT) bearer tokens belong to other authenticators
+	if raw == "" || strings.Count(raw, ".") != 2 {
+		return nil, ErrNoCredentials
+	}
+	principal, err := a.Verify(raw)
+	if err != nil {
+		return nil, err
+	}
+	if err = a.checkSession(r.Context(), principal); err != nil {
+		return nil, err
+	}
+	return principal, nil
+}
+
+// checkSession ends access tokens together with their session, a revoked
+// session or a changed password must not leave tokens valid until expiry
+func (a *JWTAuthenticator) checkSession(ctx context.Context, principal *Principal) error {
+	sessionID, _ := principal.Claims["sid"].(string)
+	if a.Sessions == nil || sessionID == "" {
+		return nil
+	}
+	active, err := a.Sessions.SessionActive(ctx, sessionID)
+	if err != nil {
+		return fmt.Errorf("%w: session check: %s", ErrInvalidCredentials, err.Error())
+	}
+	if !active {
+		return fmt.Errorf("%w: session revoked or expired", ErrInvalidCredentials)
+	}
+	return nil
+}
+
+// Verify checks signature, expiry, issuer and audience of raw
+func (a *JWTAuthenticator) Verify(raw string) (*Principal, error) {
+	parser := jwt.NewParser(jwt.WithValidMethods(signingMethods))
+
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return a.Keys.Key(kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err.Error())
+	}
+
+	if _, ok := claims["exp"]; !ok {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidCredentials)
+	}
+	if a.Issuer != "" && !claims.VerifyIssuer(a.Issuer, true) {
+		return nil, fmt.Errorf("%w: unexpected issuer", ErrInvalidCredentials)
+	}
+	if a.Audience != "" && !claims.VerifyAudience(a.Audience, true) {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidCredentials)
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	return &Principal{
+		Subject: subject,
+		Method:  "jwt",
+		Roles:   stringList(claims["roles"]),
+		Scopes:  scopes(claims),
+		Claims:  claims,
+	}, nil
+}
+
+// scopes OAuth2 "scope" (space separated) or "scp" (array) claim
+func scopes(claims jwt.MapClaims) []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return stringList(claims["scp"])
+}
+
+// stringList converts a JSON array claim into []string, ignoring non strings
+func stringList(value interface{}) []string {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		if str, ok := item.(string); ok {
+			list = append(list, str)
+		}
+	}
+	return list
+}
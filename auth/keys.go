@@ -0,0 +1,254 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey no verification key matches the token's kid
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// errRefreshThrottled the key set was refreshed too recently
+var errRefreshThrottled = errors.New("jwks: refresh throttled")
+
+// KeySet resolves the key used to verify a token signature
+type KeySet interface {
+	// Key returns *rsa.PublicKey, *ecdsa.PublicKey or []byte (HMAC) for kid
+	Key(kid string) (interface{}, error)
+}
+
+// StaticKeys fixed keys by kid, the empty kid matches tokens without one
+type StaticKeys map[string]interface{}
+
+// Key static lookup by kid
+func (keys StaticKeys) Key(kid string) (interface{}, error) {
+	if key, ok := keys[kid]; ok {
+		return key, nil
+	}
+	if len(keys) == 1 && kid == "" {
+		for _, key := range keys {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// jwk single JSON Web Key (RFC 7517), only fields needed for verification
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS decodes a JWK Set document into StaticKeys, encryption keys are skipped
+func ParseJWKS(r io.Reader) (StaticKeys, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := StaticKeys{}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("jwks: kid %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+// publicKey converts the JWK into a Go crypto key
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// decodeBigInt base64url (unpadded) big endian integer
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
+
+// JWKS keys fetched from a file or URL and refreshed periodically, or
+// immediately (at most once per MinRefresh) when an unknown kid shows up.
+// Lookups keep using the last good key set while a refresh is in flight, only
+// one fetch runs at a time.
+type JWKS struct {
+	// Source file path or http(s) URL of the JWK Set
+	Source     string
+	TTL        time.Duration
+	MinRefresh time.Duration
+	Client     *http.Client
+
+	mu          sync.RWMutex
+	keys        StaticKeys
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// refreshing closed once the fetch in flight finished, nil without one
+	refreshing chan struct{}
+	refreshErr error
+}
+
+// NewJWKS key set loaded from source (file path or http(s) URL)
+func NewJWKS(source string) *JWKS {
+	return &JWKS{
+		Source:     source,
+		TTL:        time.Hour,
+		MinRefresh: time.Minute,
+		Client:     &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Key looks kid up in the last good key set. A stale set is refreshed in the
+// background, an unknown kid or a missing set waits for a refresh.
+func (set *JWKS) Key(kid string) (interface{}, error) {
+	set.mu.RLock()
+	keys, stale := set.keys, time.Since(set.fetchedAt) > set.TTL
+	set.mu.RUnlock()
+
+	if keys == nil {
+		err := set.refresh()
+		set.mu.RLock()
+		keys = set.keys
+		set.mu.RUnlock()
+		if keys == nil {
+			return nil, err
+		}
+	} else if stale && set.refreshDue() {
+		go set.refresh() //nolint
+	}
+
+	key, err := keys.Key(kid)
+	if err == ErrUnknownKey && set.refresh() == nil {
+		set.mu.RLock()
+		defer set.mu.RUnlock()
+		return set.keys.Key(kid)
+	}
+	return key, err
+}
+
+// refreshDue no fetch is in flight and the last one started MinRefresh ago
+func (set *JWKS) refreshDue() bool {
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.refreshing == nil && (set.attemptedAt.IsZero() || time.Since(set.attemptedAt) >= set.MinRefresh)
+}
+
+// refresh fetches the key set at most once per MinRefresh so bogus kids cannot
+// hammer the source. Callers arriving during a fetch wait for its result
+// instead of starting another one, the lock is not held while fetching.
+func (set *JWKS) refresh() error {
+	set.mu.Lock()
+	if done := set.refreshing; done != nil {
+		set.mu.Unlock()
+		<-done
+		set.mu.RLock()
+		defer set.mu.RUnlock()
+		return set.refreshErr
+	}
+	if !set.attemptedAt.IsZero() && time.Since(set.attemptedAt) < set.MinRefresh {
+		set.mu.Unlock()
+		return errRefreshThrottled
+	}
+	done := make(chan struct{})
+	set.refreshing, set.attemptedAt = done, time.Now()
+	set.mu.Unlock()
+
+	keys, err := set.fetch()
+
+	set.mu.Lock()
+	if err == nil {
+		set.keys, set.fetchedAt = keys, time.Now()
+	}
+	set.refreshing, set.refreshErr = nil, err
+	set.mu.Unlock()
+	close(done)
+	return err
+}
+
+// fetch reads and parses the key set from Source
+func (set *JWKS) fetch() (StaticKeys, error) {
+	var body io.ReadCloser
+
+	if strings.HasPrefix(set.Source, "http://") || strings.HasPrefix(set.Source, "https://") {
+		resp, err := set.Client.Get(set.Source)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("jwks: %s answered %d", set.Source, resp.StatusCode)
+		}
+		body = resp.Body
+	} else {
+		file, err := os.Open(set.Source)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: %w", err)
+		}
+		body = file
+	}
+	defer body.Close() //nolint
+
+	return ParseJWKS(body)
+}
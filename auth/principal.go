@@ -0,0 +1,51 @@
+package auth
+
+import "context"
+
+// Principal authenticated caller placed in the request context
+type Principal struct {
+	// Subject customer UUID for end users, client name for services
+	Subject string
+	// Method credential that authenticated the caller (jwt, apikey)
+	Method string
+	Roles  []string
+	Scopes []string
+	// Claims raw token claims, empty for non JWT credentials
+	Claims map[string]interface{}
+}
+
+// HasRole reports whether the principal was granted role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the principal was granted scope
+func (p *Principal) HasScope(scope string) bool {
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// contextKey private type so no other package can collide with our keys
+type contextKey int
+
+const principalKey contextKey = iota
+
+// NewContext returns a copy of ctx carrying principal
+func NewContext(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// FromContext authenticated principal, ok is false for anonymous requests
+func FromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey).(*Principal)
+	return principal, ok && principal != nil
+}
@@ -0,0 +1,63 @@
+package authz_test
+
+import (
+	"svc-customer/auth"
+	"svc-customer/authz"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const self = "039d69ee-f9cb-4a3d-87e4-6eb63c302579"
+const other = "f48ac180-e8ad-4837-a3c3-66b0e96f19bf"
+
+// loadPolicy uses the policy shipped with the service so config mistakes fail here
+func loadPolicy(t *testing.T) *authz.Policy {
+	policy, err := authz.LoadPolicy("../config/policies.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestCustomerRoleOnlyReachesOwnRecord(t *testing.T) {
+
+	policy := loadPolicy(t)
+	customer := &auth.Principal{Subject: self, Roles: []string{"customer"}}
+
+	assert.NoError(t, policy.Authorize(customer, authz.ActionGet, self))
+	assert.NoError(t, policy.Authorize(customer, authz.ActionUpdate, self))
+	assert.Equal(t, authz.ErrForbidden, policy.Authorize(customer, authz.ActionGet, other))
+	assert.Equal(t, authz.ErrForbidden, policy.Authorize(customer, authz.ActionList, ""))
+	assert.Equal(t, authz.ErrForbidden, policy.Authorize(customer, authz.ActionDelete, self))
+	assert.Equal(t, authz.ErrForbidden, policy.Authorize(customer, authz.ActionManageAPIKeys, ""))
+}
+
+func TestAdminRoleReachesEveryRecord(t *testing.T) {
+
+	policy := loadPolicy(t)
+	admin := &auth.Principal{Subject: "backoffice-user", Roles: []string{"admin"}}
+
+	assert.NoError(t, policy.Authorize(admin, authz.ActionGet, other))
+	assert.NoError(t, policy.Authorize(admin, authz.ActionList, ""))
+	assert.NoError(t, policy.Authorize(admin, authz.ActionDelete, other))
+	assert.NoError(t, policy.Authorize(admin, authz.ActionManageAPIKeys, ""))
+}
+
+func TestScopesGrantPermissionsWithoutRoles(t *testing.T) {
+
+	policy := loadPolicy(t)
+	service := &auth.Principal{Subject: "billing", Scopes: []string{"customers:read:any"}}
+
+	assert.NoError(t, policy.Authorize(service, authz.ActionGet, other))
+	assert.Equal(t, authz.ErrForbidden, policy.Authorize(service, authz.ActionUpdate, other))
+}
+
+func TestUnknownActionAndAnonymousAreDenied(t *testing.T) {
+
+	policy := loadPolicy(t)
+	admin := &auth.Principal{Subject: "backoffice-user", Roles: []string{"admin"}}
+
+	assert.Equal(t, authz.ErrForbidden, policy.Authorize(admin, "customers.purge", ""))
+	assert.Equal(t, authz.ErrUnauthenticated, policy.Authorize(nil, authz.ActionGet, self))
+}
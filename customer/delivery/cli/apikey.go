@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+)
+
+// ErrUsage the command line could not be understood
+var ErrUsage = errors.New("usage: apikey create -name NAME -permissions P1,P2 [-allowed-ips IP,CIDR] | list | rotate KEY_UUID | revoke KEY_UUID")
+
+// APIKeyCommand "apikey" subcommand, operators run it against the service
+// database without going through the HTTP admin endpoints.
+type APIKeyCommand struct {
+	APIKeyUsecase usecase.APIKeyUsecase
+	Out           io.Writer
+}
+
+// Run executes args, the words following "apikey" on the command line
+func (cmd *APIKeyCommand) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+
+	switch args[0] {
+	case "create":
+		flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+		name := flags.String("name", "", "client name, e.g. billing")
+		permissions := flags.String("permissions", "", "comma separated permissions")
+		allowedIPs := flags.String("allowed-ips", "", "comma separated IPs or CIDR ranges")
+		if err := flags.Parse(args[1:]); err != nil {
+			return ErrUsage
+		}
+
+		created, err := cmd.APIKeyUsecase.Create(ctx, entity.APIKeyRequest{
+			Name:        *name,
+			Permissions: splitFlag(*permissions),
+			AllowedIPs:  splitFlag(*allowedIPs),
+		})
+		if err != nil {
+			return err
+		}
+		return cmd.print(created)
+
+	case "list":
+		keys, err := cmd.APIKeyUsecase.Fetch(ctx)
+		if err != nil {
+			return err
+		}
+		return cmd.print(keys)
+
+	case "rotate":
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		rotated, err := cmd.APIKeyUsecase.Rotate(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		return cmd.print(rotated)
+
+	case "revoke":
+		if len(args) != 2 {
+			return ErrUsage
+		}
+		if err := cmd.APIKeyUsecase.Revoke(ctx, args[1]); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(cmd.Out, "API key %s revoked\n", args[1])
+		return err
+	}
+
+	return ErrUsage
+}
+
+// print writes value as indented JSON
+func (cmd *APIKeyCommand) print(value interface{}) error {
+	encoder := json.NewEncoder(cmd.Out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// splitFlag comma separated flag value, empty items dropped
+func splitFlag(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
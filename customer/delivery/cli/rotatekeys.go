@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"svc-customer/customer/usecase"
+)
+
+// ErrRotateKeysUsage the rotate-keys command line could not be understood
+var ErrRotateKeysUsage = errors.New("usage: rotate-keys [-table TABLE -after ROW_ID]")
+
+// RotateKeysCommand "rotate-keys" subcommand, re-encrypts customer PII and its
+// sealed copies under the active master key of PII_KEYFILE once a new key was
+// made active.
+type RotateKeysCommand struct {
+	KeyRotationUsecase usecase.KeyRotationUsecase
+	Out                io.Writer
+}
+
+// Run executes args, the words following "rotate-keys" on the command line,
+// and prints the rows read and re-encrypted. After a failure the progress is
+// printed too, its table and last_id resume the rotation with -table and -after.
+func (cmd *RotateKeysCommand) Run(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("rotate-keys", flag.ContinueOnError)
+	flags.SetOutput(ioutil.Discard)
+	table := flags.String("table", "", "resume with this table")
+	after := flags.Int64("after", 0, "resume after this row ID of -table")
+	if err := flags.Parse(args); err != nil || flags.NArg() != 0 || (*after != 0 && *table == "") {
+		return ErrRotateKeysUsage
+	}
+
+	rotation, err := cmd.KeyRotationUsecase.Rotate(ctx, *table, *after)
+	encoder := json.NewEncoder(cmd.Out)
+	encoder.SetIndent("", "  ")
+	if printErr := encoder.Encode(rotation); err == nil {
+		err = printErr
+	}
+	return err
+}
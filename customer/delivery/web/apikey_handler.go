@@ -0,0 +1,128 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// APIKeyHandler admin endpoints managing service API keys
+type APIKeyHandler struct {
+	APIKeyUsecase usecase.APIKeyUsecase
+}
+
+/*CreateAPIKey swagger:route POST /customer/admin/api-keys CreateAPIKey
+  Create a service API key, the plain key is only returned in this response
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.APIKeyRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	created, err := handler.APIKeyUsecase.Create(r.Context(), request)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "API key was created.", created, w, http.StatusOK)
+}
+
+/*FetchAPIKeys swagger:route GET /customer/admin/api-keys FetchAPIKeys
+  List service API keys without their secrets
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *APIKeyHandler) FetchAPIKeys(w http.ResponseWriter, r *http.Request) {
+
+	keys, err := handler.APIKeyUsecase.Fetch(r.Context())
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Successfull
+	Response(true, "API keys Found", keys, w, http.StatusOK)
+}
+
+/*RotateAPIKey swagger:route POST /customer/admin/api-keys/{key_uuid}/rotate RotateAPIKey
+  Replace the secret of a service API key, the previous key stops working
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	keyUUID := mux.Vars(r)["key_uuid"]
+
+	if !entity.IsValidUUID(keyUUID) {
+		Response(false, "API key UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	rotated, err := handler.APIKeyUsecase.Rotate(r.Context(), keyUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "API key was rotated.", rotated, w, http.StatusOK)
+}
+
+/*RevokeAPIKey swagger:route DELETE /customer/admin/api-keys/{key_uuid} RevokeAPIKey
+  Revoke a service API key permanently
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+
+	keyUUID := mux.Vars(r)["key_uuid"]
+
+	if !entity.IsValidUUID(keyUUID) {
+		Response(false, "API key UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.APIKeyUsecase.Revoke(r.Context(), keyUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "API key was revoked.", nil, w, http.StatusOK)
+}
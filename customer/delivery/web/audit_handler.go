@@ -0,0 +1,45 @@
+package web
+
+import (
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// AuditHandler audit trail endpoints
+type AuditHandler struct {
+	AuditUsecase usecase.AuditUsecase
+}
+
+/*History swagger:route GET /customer/{uuid}/history History
+  Audit trail of a customer newest first, who changed which fields and when, ?page=&limit= paginate it
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *AuditHandler) History(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	page, limit := pagination(r)
+	history, err := handler.AuditUsecase.History(r.Context(), customerUUID, page, limit)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "History Found", history, w, http.StatusOK)
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+	"svc-customer/logging"
+)
+
+// EmailVerificationHandler public email verification endpoints
+type EmailVerificationHandler struct {
+	EmailVerificationUsecase usecase.EmailVerificationUsecase
+}
+
+/*VerifyEmail swagger:route POST /customer/verify-email VerifyEmail
+  Confirm the email address a verification token was sent to, the token works once
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *EmailVerificationHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.VerifyEmailRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.EmailVerificationUsecase.VerifyEmail(r.Context(), request.Token)
+
+	// Return Error Response
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Email was verified.", nil, w, http.StatusOK)
+}
+
+/*ResendVerification swagger:route POST /customer/verify-email/resend ResendVerification
+  Send a new verification link, answers 202 whether or not the email is registered
+
+responses:
+   202: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *EmailVerificationHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.ResendVerificationRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	// Failures are only logged, any other answer would reveal the email exists
+	if err := handler.EmailVerificationUsecase.ResendVerification(r.Context(), request.Email); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("ResendVerification: verification token not sent")
+	}
+	Response(true, "If the email is registered and not verified a link was sent.", nil, w, http.StatusAccepted)
+}
@@ -0,0 +1,53 @@
+package web
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// ErasureHandler right-to-erasure endpoints
+type ErasureHandler struct {
+	ErasureUsecase usecase.ErasureUsecase
+}
+
+/*Erase swagger:route POST /customer/{uuid}/erase Erase
+  Erase the personal data of a customer, {"mode": "anonymise"} (default) keeps the anonymised record, {"mode": "hard_delete"} removes it
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *ErasureHandler) Erase(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	// The body is optional, without one the customer is anonymised
+	var request entity.ErasureRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.ErasureUsecase.Erase(r.Context(), customerUUID, request)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Customer erased", nil, w, http.StatusOK)
+}
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// ExportHandler data subject access export endpoints
+type ExportHandler struct {
+	ExportUsecase usecase.ExportUsecase
+}
+
+/*Export swagger:route GET /customer/{uuid}/export Export
+  Download everything held on a customer, ?format=json|zip picks the bundle, ?async=true queues it and answers 202 with the export job
+
+responses:
+   200: swaggerResponse
+   202: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *ExportHandler) Export(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	keys := r.URL.Query()
+	format := keys.Get("format")
+	if format == "" {
+		format = entity.ExportJSON
+	}
+	async := false
+	if value := keys.Get("async"); value != "" {
+		var err error
+		if async, err = strconv.ParseBool(value); err != nil {
+			Response(false, entity.ErrBadParamInput.Error(), nil, w, http.StatusNotFound)
+			return
+		}
+	}
+
+	if async {
+		job, err := handler.ExportUsecase.RequestExport(r.Context(), customerUUID, format)
+		if accessDenied(w, r, err) {
+			return
+		}
+		if err != nil {
+			Response(false, err.Error(), nil, w, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/export")+"/exports/"+job.ExportUUID)
+		Response(true, "Export requested", job, w, http.StatusAccepted)
+		return
+	}
+
+	bundle, err := handler.ExportUsecase.Export(r.Context(), customerUUID, format)
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	writeBundle(w, bundle)
+}
+
+/*GetExport swagger:route GET /customer/{uuid}/exports/{export_uuid} GetExport
+  Status of an asynchronous export, pending, ready or failed
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *ExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+
+	job, err := handler.ExportUsecase.GetExport(r.Context(), params["uuid"], params["export_uuid"])
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	Response(true, "Export Found", job, w, http.StatusOK)
+}
+
+/*DownloadExport swagger:route GET /customer/{uuid}/exports/{export_uuid}/download DownloadExport
+  Download the bundle of a ready asynchronous export
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *ExportHandler) DownloadExport(w http.ResponseWriter, r *http.Request) {
+
+	params := mux.Vars(r)
+
+	bundle, err := handler.ExportUsecase.DownloadExport(r.Context(), params["uuid"], params["export_uuid"])
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err == entity.ErrExportNotReady {
+		Response(false, err.Error(), nil, w, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	writeBundle(w, bundle)
+}
+
+// writeBundle sends bundle as a file download, it holds personal data so it is never cached
+func writeBundle(w http.ResponseWriter, bundle *entity.ExportBundle) {
+	w.Header().Set("Content-Type", bundle.ContentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", bundle.Filename))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(bundle.Data)
+}
@@ -6,6 +6,7 @@ import (
 	"owner/owner/usecase"
 	"strconv"
 	"svc-customer/customer/entity"
+	"svc-customer/problem"
 
 	"github.com/gorilla/mux"
 )
@@ -24,7 +25,7 @@ responses:
 */
 func (handler *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 
-	err := handler.GetCustomerUsecase.HealthCheck()
+	err := handler.GetCustomerUsecase.HealthCheck(r.Context())
 	// Return Error Response
 	if err != nil {
 		Response(false, "Database unaccessible", nil, w, http.StatusNotFound)
@@ -53,9 +54,12 @@ func (handler *Handler) GetByUUID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	customr, err := handler.GetCustomerUsecase.GetByUUID(customerUUID)
+	customr, err := handler.GetCustomerUsecase.GetByUUID(r.Context(), customerUUID)
 
 	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
 	if err != nil {
 		// Verify if it was a 404
 		Response(false, err.Error(), nil, w, http.StatusNotFound)
@@ -67,6 +71,11 @@ func (handler *Handler) GetByUUID(w http.ResponseWriter, r *http.Request) {
 
 // Pagination Generate Pagination calculations
 func (handler *Handler) Pagination(r *http.Request) (int, int) {
+	return pagination(r)
+}
+
+// pagination ?page= and ?limit= of r, page 1 of 10 when either is missing
+func pagination(r *http.Request) (int, int) {
 	keys := r.URL.Query()
 
 	if keys.Get("page") == "" {
@@ -91,8 +100,43 @@ func (handler *Handler) Pagination(r *http.Request) (int, int) {
 	return page, pageLimit
 }
 
+// Filter reads Fetch filters from the query string, ?verified=true|false,
+// ?include_deleted=true or ?only_deleted=true
+func (handler *Handler) Filter(r *http.Request) (entity.CustomerFilter, error) {
+	filter := entity.CustomerFilter{}
+	keys := r.URL.Query()
+
+	if value := keys.Get("verified"); value != "" {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, entity.ErrBadParamInput
+		}
+		filter.Verified = &verified
+	}
+
+	for key, deleted := range map[string]string{"include_deleted": entity.DeletedInclude, "only_deleted": entity.DeletedOnly} {
+		value := keys.Get(key)
+		if value == "" {
+			continue
+		}
+		set, err := strconv.ParseBool(value)
+		if err != nil {
+			return filter, entity.ErrBadParamInput
+		}
+		if !set {
+			continue
+		}
+		// Both options at once are contradictory
+		if filter.Deleted != entity.DeletedExclude {
+			return filter, entity.ErrBadParamInput
+		}
+		filter.Deleted = deleted
+	}
+	return filter, nil
+}
+
 /*Fetch swagger:route GET /customer Fetch
-  Get all registered customer's in a list
+  Get all registered customer's in a list, ?verified=true|false filters by verified email, ?include_deleted=true or ?only_deleted=true list deleted customers with their deleted_at
 responses:
    200: swaggerResponseArray
    404: swaggerResponseFail
@@ -102,7 +146,16 @@ func (handler *Handler) Fetch(w http.ResponseWriter, r *http.Request) {
 	page, pageLimit := handler.Pagination(r)
 	//log.Infof("Current Page: %d, limit %d\n", page, pageLimit)
 
-	customers, totalrows, pages, page, err := handler.GetCustomerUsecase.Fetch(page, pageLimit)
+	filter, err := handler.Filter(r)
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+
+	customers, totalrows, pages, page, err := handler.GetCustomerUsecase.Fetch(r.Context(), filter, page, pageLimit)
+	if accessDenied(w, r, err) {
+		return
+	}
 	if err != nil {
 		// Verify if it was a 404
 		Response(false, err.Error(), nil, w, http.StatusNotFound)
@@ -121,6 +174,35 @@ func (handler *Handler) Fetch(w http.ResponseWriter, r *http.Request) {
 
 }
 
+/*Changes swagger:route GET /customer/changes Changes
+  Customers inserted, updated or deleted after ?since=<token> in change order, ?limit= caps the page
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *Handler) Changes(w http.ResponseWriter, r *http.Request) {
+
+	keys := r.URL.Query()
+	limit := 0
+	if value := keys.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			Response(false, entity.ErrBadParamInput.Error(), nil, w, http.StatusNotFound)
+			return
+		}
+	}
+
+	feed, err := handler.GetCustomerUsecase.Changes(r.Context(), keys.Get("since"), limit)
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	Response(true, "Changes Found", feed, w, http.StatusOK)
+}
+
 /*UpdateByUUID swagger:route PUT /customer/{uuid} UpdateByUUID
 Update existing Customer's Information by passing a valid customer UUID
 responses:
@@ -153,8 +235,11 @@ func (handler *Handler) UpdateByUUID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// Update customer Information
-	usecaseError := handler.GetCustomerUsecase.UpdateByUUID(customer, customerUUID)
+	usecaseError := handler.GetCustomerUsecase.UpdateByUUID(r.Context(), customer, customerUUID)
 	// Return error if it fails
+	if accessDenied(w, r, usecaseError) {
+		return
+	}
 	if usecaseError != nil {
 		// msg := fmt.Sprintf("Information Could not be created. %s", usecaseError.Error())
 		Response(false, usecaseError.Error(), nil, w, http.StatusNotFound)
@@ -186,9 +271,12 @@ func (handler *Handler) DeleteByUUID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Delete Customer information using UUID
-	err := handler.GetCustomerUsecase.DeleteByUUID(customerUUID)
+	err := handler.GetCustomerUsecase.DeleteByUUID(r.Context(), customerUUID)
 
 	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
 	if err != nil {
 		Response(false, err.Error(), nil, w, http.StatusNotFound)
 		return
@@ -197,6 +285,37 @@ func (handler *Handler) DeleteByUUID(w http.ResponseWriter, r *http.Request) {
 	Response(true, "Customer records deleted", nil, w, http.StatusOK)
 }
 
+/*RestoreByUUID swagger:route POST /customer/{uuid}/restore RestoreByUUID
+  Restore a deleted customer unless an active customer took its email or phone
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *Handler) RestoreByUUID(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	// Validate UUID from comming customer request.
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.GetCustomerUsecase.RestoreByUUID(r.Context(), customerUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Customer restored", nil, w, http.StatusOK)
+}
+
 /*Store swagger:route POST /customer/ Store
   Store new Customer's information
 
@@ -225,9 +344,12 @@ func (handler *Handler) Store(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	usecaseError := handler.GetCustomerUsecase.Store(customer)
+	usecaseError := handler.GetCustomerUsecase.Store(r.Context(), customer)
 
 	// Return Error Response
+	if accessDenied(w, r, usecaseError) {
+		return
+	}
 	if usecaseError != nil {
 		Response(false, usecaseError.Error(), nil, w, http.StatusNotFound)
 		return
@@ -252,6 +374,19 @@ func Response(status bool, desc string, data interface{}, w http.ResponseWriter,
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// accessDenied writes a 401/403 problem response when err is an authorization failure
+func accessDenied(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch err {
+	case entity.ErrUnauthorized:
+		problem.Write(w, r, http.StatusUnauthorized, err.Error())
+	case entity.ErrForbidden:
+		problem.Write(w, r, http.StatusForbidden, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
 func validateUpdate(customer entity.Customer) []string {
 	var errs []string
 	// var fields []string
@@ -281,6 +416,10 @@ func validateUpdate(customer entity.Customer) []string {
 	if len(customer.LastName) > 30 {
 		errs = append(errs, "last_name should be less than 30 chars long")
 	}
+
+	if customer.Password != "" {
+		errs = append(errs, "password cannot be updated here, use POST /{uuid}/password")
+	}
 	return errs
 }
 
@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"svc-customer/auth"
+	"svc-customer/authz"
 	"svc-customer/customer/entity"
 	"svc-customer/customer/usecase"
 	mocks "svc-customer/customermocks"
+	"svc-customer/problem"
 	"testing"
 
 	"github.com/gorilla/mux"
@@ -359,10 +362,9 @@ func TestUpdateByUUIDSuccess(t *testing.T) {
 	mock.LastName = mocks.CLastName
 	mock.Phone = mocks.CPhone
 	mock.Email = mocks.CMail
-	mock.Password = mocks.CPassword
 	mock.Country = "CL"
 
-	jsonParam := `{"name": "Bad","last_name": "Bunny","email": "baby@gmail.com","password": "conejomalo","phone": "+130698569","country":"CL"}`
+	jsonParam := `{"name": "Bad","last_name": "Bunny","email": "baby@gmail.com","phone": "+130698569","country":"CL"}`
 
 	path := fmt.Sprintf("/%s", mocks.CustomerUUIDValid)
 	req, err := http.NewRequest("PUT", path, strings.NewReader(jsonParam))
@@ -426,7 +428,7 @@ func TestUpdateByUUIDMissingField(t *testing.T) {
 
 	assert.Equal(t, http.StatusNotFound, rr.Code)
 
-	expected := "{\"status\":false,\"description\":\"Information Could not be sent. Please Check Errors\",\"data\":[\"name cannot be empty\",\"last_name cannot be empty\"]}\n"
+	expected := "{\"status\":false,\"description\":\"Information Could not be sent. Please Check Errors\",\"data\":[\"name cannot be empty\",\"last_name cannot be empty\",\"password cannot be updated here, use POST /{uuid}/password\"]}\n"
 	assert.Equal(t, rr.Body.String(), expected)
 }
 
@@ -547,3 +549,61 @@ func TestHandlerHealthCheckValid(t *testing.T) {
 	expected := "{\"status\":true,\"description\":\"UP\",\"data\":null}\n"
 	assert.Equal(t, rr.Body.String(), expected)
 }
+
+// AUTHORIZATION
+
+func TestHandlerDeleteByUUIDForbidden(t *testing.T) {
+
+	req, err := http.NewRequest("DELETE", "/039d69ee-f9cb-4a3d-87e4-6eb63c302579", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{
+		Subject: "039d69ee-f9cb-4a3d-87e4-6eb63c302579",
+		Roles:   []string{"customer"},
+	}))
+
+	mockCase := new(mocks.MockedRepository)
+
+	handler := &Handler{
+		GetCustomerUsecase: &usecase.AuthorizedUsecase{
+			Next: &usecase.GetCustomerImpl{
+				Repo: mockCase,
+			},
+			Policy: &authz.Policy{
+				Roles:   map[string][]string{"admin": {"customers:delete"}},
+				Actions: map[string]authz.Rule{authz.ActionDelete: {Any: []string{"customers:delete"}}},
+			},
+		},
+	}
+
+	rr := httptest.NewRecorder()
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{uuid}", handler.DeleteByUUID).Methods("DELETE")
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+	assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+	mockCase.AssertNotCalled(t, "DeleteByUUID", "039d69ee-f9cb-4a3d-87e4-6eb63c302579")
+}
+
+func TestHandlerFilterDeleted(t *testing.T) {
+
+	handler := &Handler{}
+	for query, deleted := range map[string]string{
+		"":                      entity.DeletedExclude,
+		"?include_deleted=true": entity.DeletedInclude,
+		"?only_deleted=1":       entity.DeletedOnly,
+		"?only_deleted=false":   entity.DeletedExclude,
+	} {
+		req := httptest.NewRequest("GET", "/"+query, nil)
+		filter, err := handler.Filter(req)
+		assert.NoError(t, err)
+		assert.Equal(t, deleted, filter.Deleted, query)
+	}
+
+	req := httptest.NewRequest("GET", "/?include_deleted=true&only_deleted=true", nil)
+	_, err := handler.Filter(req)
+	assert.Equal(t, entity.ErrBadParamInput, err)
+}
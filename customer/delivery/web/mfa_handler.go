@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// MFAHandler two-factor enrollment endpoints of the authenticated customer
+type MFAHandler struct {
+	MFAUsecase usecase.MFAUsecase
+}
+
+/*EnrollTOTP swagger:route POST /customer/{uuid}/mfa/totp EnrollTOTP
+  Create a TOTP secret and its otpauth URI, logins only require it after confirmation
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *MFAHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	enrollment, err := handler.MFAUsecase.EnrollTOTP(r.Context(), customerUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "TOTP secret was created.", enrollment, w, http.StatusOK)
+}
+
+/*ConfirmTOTP swagger:route POST /customer/{uuid}/mfa/totp/confirm ConfirmTOTP
+  Enable two-factor authentication with a first TOTP code, returns single-use recovery codes
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *MFAHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	var request entity.ConfirmTOTPRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Code == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	codes, err := handler.MFAUsecase.ConfirmTOTP(r.Context(), customerUUID, request.Code)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Two-factor authentication was enabled.", codes, w, http.StatusOK)
+}
@@ -0,0 +1,121 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+	"svc-customer/logging"
+	"svc-customer/problem"
+
+	"github.com/gorilla/mux"
+)
+
+// PasswordHandler public password recovery endpoints
+type PasswordHandler struct {
+	PasswordUsecase usecase.PasswordUsecase
+}
+
+/*ForgotPassword swagger:route POST /customer/password/forgot ForgotPassword
+  Send a password reset link, answers 202 whether or not the email is registered
+
+responses:
+   202: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *PasswordHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.ForgotPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	// Failures are only logged, any other answer would reveal the email exists
+	if err := handler.PasswordUsecase.ForgotPassword(r.Context(), request.Email); err != nil {
+		logging.FromContext(r.Context()).WithError(err).Error("ForgotPassword: reset token not sent")
+	}
+	Response(true, "If the email is registered a reset link was sent.", nil, w, http.StatusAccepted)
+}
+
+/*ResetPassword swagger:route POST /customer/password/reset ResetPassword
+  Choose a new password with a reset token, the token works once
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *PasswordHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.ResetPasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Token == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.PasswordUsecase.ResetPassword(r.Context(), request.Token, request.Password)
+
+	// Return Error Response
+	var policyErr *entity.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		Response(false, "Information Could not be sent. Please Check Errors", policyErr.Violations, w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Password was reset.", nil, w, http.StatusOK)
+}
+
+/*ChangePassword swagger:route POST /customer/{uuid}/password ChangePassword
+  Change the password of the authenticated customer, the current password is required
+
+responses:
+   200: swaggerResponse
+   401: problem
+   404: swaggerResponseFail
+*/
+func (handler *PasswordHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	var request entity.ChangePasswordRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.CurrentPassword == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.PasswordUsecase.ChangePassword(r.Context(), customerUUID, request.CurrentPassword, request.NewPassword)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	// 401 so repeated guesses count towards the client lockout
+	if err == entity.ErrPasswordMismatch {
+		problem.Write(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	var policyErr *entity.PasswordPolicyError
+	if errors.As(err, &policyErr) {
+		Response(false, "Information Could not be sent. Please Check Errors", policyErr.Violations, w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Password was changed.", nil, w, http.StatusOK)
+}
@@ -0,0 +1,64 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/customer/usecase"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestForgotPasswordAcceptsUnknownEmail(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("GetUUIDByEmail", "nobody@gmail.com").Return("", entity.ErrNotFound)
+
+	handler := &PasswordHandler{PasswordUsecase: &usecase.PasswordImpl{Repo: mockRepo}}
+
+	req := httptest.NewRequest("POST", "/password/forgot", strings.NewReader(`{"email":"nobody@gmail.com"}`))
+	rr := httptest.NewRecorder()
+	handler.ForgotPassword(rr, req)
+
+	assert.Equal(t, http.StatusAccepted, rr.Code)
+}
+
+func TestResetPasswordInvalidToken(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("FindResetToken", entity.HashToken("expired")).Return("", "", entity.ErrResetTokenInvalid)
+
+	handler := &PasswordHandler{PasswordUsecase: &usecase.PasswordImpl{Repo: mockRepo}}
+
+	req := httptest.NewRequest("POST", "/password/reset", strings.NewReader(`{"token":"expired","password":"a-new-password"}`))
+	rr := httptest.NewRecorder()
+	handler.ResetPassword(rr, req)
+
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), entity.ErrResetTokenInvalid.Error())
+}
+
+func TestChangePasswordWrongCurrentIsUnauthorized(t *testing.T) {
+
+	customerUUID := "039d69ee-f9cb-4a3d-87e4-6eb63c302579"
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("GetPasswordHash", customerUUID).Return(entity.EncryptPassword("old-password"), mocks.CMail, nil)
+
+	handler := &PasswordHandler{PasswordUsecase: &usecase.PasswordImpl{Repo: mockRepo, Hasher: entity.SHA1Hasher{}}}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{uuid}/password", handler.ChangePassword).Methods("POST")
+
+	body := `{"current_password":"guess","new_password":"a-new-password"}`
+	req := httptest.NewRequest("POST", "/"+customerUUID+"/password", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	mockRepo.AssertNotCalled(t, "UpdatePassword", customerUUID, entity.EncryptPassword("a-new-password"))
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// PhoneVerificationHandler phone verification endpoints of the authenticated customer
+type PhoneVerificationHandler struct {
+	PhoneVerificationUsecase usecase.PhoneVerificationUsecase
+}
+
+/*SendPhoneCode swagger:route POST /customer/{uuid}/phone/verify SendPhoneCode
+  Text a one-time code to the customer phone, replacing any pending code
+
+responses:
+   202: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *PhoneVerificationHandler) SendPhoneCode(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.PhoneVerificationUsecase.SendPhoneCode(r.Context(), customerUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Verification code was sent.", nil, w, http.StatusAccepted)
+}
+
+/*VerifyPhone swagger:route POST /customer/{uuid}/phone/verify/confirm VerifyPhone
+  Confirm the customer phone with the code sent to it, each code allows a few attempts
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *PhoneVerificationHandler) VerifyPhone(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	var request entity.VerifyPhoneRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Code == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.PhoneVerificationUsecase.VerifyPhone(r.Context(), customerUUID, request.Code)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Phone was verified.", nil, w, http.StatusOK)
+}
@@ -0,0 +1,183 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+	"svc-customer/middleware"
+	"svc-customer/problem"
+
+	"github.com/gorilla/mux"
+)
+
+// maxUserAgent longest user agent recorded on a session
+const maxUserAgent = 255
+
+// SessionHandler login, refresh and session management endpoints
+type SessionHandler struct {
+	SessionUsecase usecase.SessionUsecase
+}
+
+/*Login swagger:route POST /customer/sessions Login
+  Open a session with email, password and the TOTP or recovery code of customers with two-factor authentication, returns an access and a refresh token
+
+responses:
+   200: swaggerResponse
+   401: problem
+   404: swaggerResponseFail
+*/
+func (handler *SessionHandler) Login(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.LoginRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Email == "" || request.Password == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	tokens, err := handler.SessionUsecase.Login(r.Context(), request, sessionMeta(r))
+
+	// 401 so repeated guesses count towards the client lockout
+	if err == entity.ErrInvalidLogin || err == entity.ErrMFARequired || err == entity.ErrMFACodeInvalid {
+		problem.Write(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Session was created.", tokens, w, http.StatusOK)
+}
+
+/*Refresh swagger:route POST /customer/sessions/refresh Refresh
+  Exchange a refresh token for new tokens, the sent refresh token stops working
+
+responses:
+   200: swaggerResponse
+   401: problem
+   404: swaggerResponseFail
+*/
+func (handler *SessionHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.RefreshRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.RefreshToken == "" {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	tokens, err := handler.SessionUsecase.Refresh(r.Context(), request.RefreshToken, sessionMeta(r))
+
+	if err == entity.ErrRefreshTokenInvalid {
+		problem.Write(w, r, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Session was refreshed.", tokens, w, http.StatusOK)
+}
+
+/*FetchSessions swagger:route GET /customer/{uuid}/sessions FetchSessions
+  List the active sessions of a customer with their device and IP
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *SessionHandler) FetchSessions(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	sessions, err := handler.SessionUsecase.FetchSessions(r.Context(), customerUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Successfull
+	Response(true, "Sessions Found", sessions, w, http.StatusOK)
+}
+
+/*RevokeSession swagger:route DELETE /customer/{uuid}/sessions/{session_uuid} RevokeSession
+  Revoke one session, its refresh token stops working
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	customerUUID, sessionUUID := vars["uuid"], vars["session_uuid"]
+
+	if !entity.IsValidUUID(customerUUID) || !entity.IsValidUUID(sessionUUID) {
+		Response(false, "Customer or session UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.SessionUsecase.RevokeSession(r.Context(), customerUUID, sessionUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Session was revoked.", nil, w, http.StatusOK)
+}
+
+/*RevokeSessions swagger:route DELETE /customer/{uuid}/sessions RevokeSessions
+  Revoke every session of a customer
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *SessionHandler) RevokeSessions(w http.ResponseWriter, r *http.Request) {
+
+	customerUUID := mux.Vars(r)["uuid"]
+
+	if !entity.IsValidUUID(customerUUID) {
+		Response(false, "Customer UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.SessionUsecase.RevokeSessions(r.Context(), customerUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Sessions were revoked.", nil, w, http.StatusOK)
+}
+
+// sessionMeta device information of the request
+func sessionMeta(r *http.Request) entity.SessionMeta {
+	userAgent := r.UserAgent()
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+	return entity.SessionMeta{UserAgent: userAgent, IP: middleware.ClientIP(r)}
+}
@@ -0,0 +1,108 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"svc-customer/customer/usecase"
+	"svc-customer/events"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"time"
+)
+
+// DefaultStreamHeartbeat pause between keep-alive comments of an idle stream
+const DefaultStreamHeartbeat = 15 * time.Second
+
+// EventStreamHandler Server-Sent Events stream of customer events
+type EventStreamHandler struct {
+	StreamUsecase usecase.StreamUsecase
+	// Heartbeat keep-alive interval, DefaultStreamHeartbeat when zero
+	Heartbeat time.Duration
+}
+
+/*StreamEvents swagger:route GET /customer/events StreamEvents
+  Server-Sent Events stream of customer created, updated and deleted events, Last-Event-ID resumes it
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *EventStreamHandler) StreamEvents(w http.ResponseWriter, r *http.Request) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		Response(false, "Streaming is not supported", nil, w, http.StatusInternalServerError)
+		return
+	}
+
+	subscription, err := handler.StreamUsecase.Subscribe(r.Context(), r.Header.Get("Last-Event-ID"))
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	defer subscription.Close()
+
+	metrics.StreamSubscribers.Inc()
+	defer metrics.StreamSubscribers.Dec()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Proxies such as nginx would otherwise buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	// The requested event left the buffer, the client resyncs through GET /changes
+	if subscription.Reset {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n") //nolint
+	}
+	for _, event := range subscription.Backlog {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := handler.Heartbeat
+	if heartbeat == 0 {
+		heartbeat = DefaultStreamHeartbeat
+	}
+	ticker := time.NewTicker(heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				// Dropped for falling behind, the client reconnects with Last-Event-ID
+				logging.FromContext(r.Context()).Warn("StreamEvents: subscriber fell behind, stream closed")
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent one SSE message, the event ID lets clients resume after it
+func writeEvent(w io.Writer, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
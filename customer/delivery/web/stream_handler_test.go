@@ -0,0 +1,89 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"svc-customer/auth"
+	"svc-customer/authz"
+	"svc-customer/customer/usecase"
+	"svc-customer/events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamEventsResumesAndFollows(t *testing.T) {
+
+	broker := &events.Broker{}
+	assert.NoError(t, broker.Publish(context.Background(), events.Event{ID: "event-1", Type: events.CustomerCreated, CustomerUUID: "customer-1"}))
+	assert.NoError(t, broker.Publish(context.Background(), events.Event{ID: "event-2", Type: events.CustomerUpdated, CustomerUUID: "customer-1"}))
+
+	handler := &EventStreamHandler{
+		StreamUsecase: &usecase.StreamImpl{Broker: broker},
+		Heartbeat:     10 * time.Millisecond,
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamEvents))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	assert.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "event-1")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close() //nolint
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+	assert.Equal(t, "id: event-2\nevent: CustomerUpdated\n", readMessage(t, reader)[:35])
+
+	assert.NoError(t, broker.Publish(context.Background(), events.Event{ID: "event-3", Type: events.CustomerDeleted, CustomerUUID: "customer-1"}))
+	for {
+		message := readMessage(t, reader)
+		if strings.HasPrefix(message, ": heartbeat") {
+			continue
+		}
+		assert.True(t, strings.HasPrefix(message, "id: event-3\nevent: CustomerDeleted\n"))
+		break
+	}
+}
+
+func TestStreamEventsRequiresAuthentication(t *testing.T) {
+
+	handler := &EventStreamHandler{
+		StreamUsecase: &usecase.AuthorizedStreamUsecase{
+			Next: &usecase.StreamImpl{Broker: &events.Broker{}},
+			Policy: &authz.Policy{
+				Roles:   map[string][]string{"admin": {"customers:read:any"}},
+				Actions: map[string]authz.Rule{authz.ActionEvents: {Any: []string{"customers:read:any"}}},
+			},
+		},
+	}
+
+	rr := httptest.NewRecorder()
+	handler.StreamEvents(rr, httptest.NewRequest("GET", "/events", nil))
+	assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "customer-1", Roles: []string{"nobody"}})
+	rr = httptest.NewRecorder()
+	handler.StreamEvents(rr, httptest.NewRequest("GET", "/events", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusForbidden, rr.Code)
+}
+
+// readMessage one SSE message up to its blank line
+func readMessage(t *testing.T, reader *bufio.Reader) string {
+	message := ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == "\n" {
+			return message
+		}
+		message += line
+	}
+}
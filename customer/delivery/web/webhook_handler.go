@@ -0,0 +1,188 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/usecase"
+
+	"github.com/gorilla/mux"
+)
+
+// WebhookHandler admin endpoints managing webhooks and their deliveries
+type WebhookHandler struct {
+	WebhookUsecase usecase.WebhookUsecase
+}
+
+/*CreateWebhook swagger:route POST /customer/admin/webhooks CreateWebhook
+  Subscribe a URL to customer events, the signing secret is only returned in this response
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+
+	var request entity.WebhookRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		Response(false, "Invalid JSON object", nil, w, http.StatusNotFound)
+		return
+	}
+
+	created, err := handler.WebhookUsecase.Create(r.Context(), request)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Webhook was created.", created, w, http.StatusOK)
+}
+
+/*FetchWebhooks swagger:route GET /customer/admin/webhooks FetchWebhooks
+  List active webhooks without their secrets
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *WebhookHandler) FetchWebhooks(w http.ResponseWriter, r *http.Request) {
+
+	webhooks, err := handler.WebhookUsecase.Fetch(r.Context())
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Successfull
+	Response(true, "Webhooks Found", webhooks, w, http.StatusOK)
+}
+
+/*DeleteWebhook swagger:route DELETE /customer/admin/webhooks/{webhook_uuid} DeleteWebhook
+  Delete a webhook, pending deliveries are no longer sent
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+
+	webhookUUID := mux.Vars(r)["webhook_uuid"]
+
+	if !entity.IsValidUUID(webhookUUID) {
+		Response(false, "Webhook UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.WebhookUsecase.Delete(r.Context(), webhookUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Webhook was deleted.", nil, w, http.StatusOK)
+}
+
+/*FetchWebhookDeliveries swagger:route GET /customer/admin/webhooks/{webhook_uuid}/deliveries FetchWebhookDeliveries
+  Latest deliveries of a webhook with their status and attempt count
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *WebhookHandler) FetchWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+
+	webhookUUID := mux.Vars(r)["webhook_uuid"]
+
+	if !entity.IsValidUUID(webhookUUID) {
+		Response(false, "Webhook UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	deliveries, err := handler.WebhookUsecase.Deliveries(r.Context(), webhookUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Successfull
+	Response(true, "Webhook deliveries Found", deliveries, w, http.StatusOK)
+}
+
+/*GetWebhookDelivery swagger:route GET /customer/admin/webhook-deliveries/{delivery_uuid} GetWebhookDelivery
+  One webhook delivery with the log of its HTTP attempts
+
+responses:
+   200: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *WebhookHandler) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+
+	deliveryUUID := mux.Vars(r)["delivery_uuid"]
+
+	if !entity.IsValidUUID(deliveryUUID) {
+		Response(false, "Delivery UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	delivery, err := handler.WebhookUsecase.Delivery(r.Context(), deliveryUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Successfull
+	Response(true, "Webhook delivery Found", delivery, w, http.StatusOK)
+}
+
+/*ReplayWebhookDelivery swagger:route POST /customer/admin/webhook-deliveries/{delivery_uuid}/replay ReplayWebhookDelivery
+  Send a delivery again with a fresh attempt budget, dead-lettered ones included
+
+responses:
+   202: swaggerResponse
+   404: swaggerResponseFail
+*/
+func (handler *WebhookHandler) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+
+	deliveryUUID := mux.Vars(r)["delivery_uuid"]
+
+	if !entity.IsValidUUID(deliveryUUID) {
+		Response(false, "Delivery UUID is not valid", nil, w, http.StatusNotFound)
+		return
+	}
+
+	err := handler.WebhookUsecase.Replay(r.Context(), deliveryUUID)
+
+	// Return Error Response
+	if accessDenied(w, r, err) {
+		return
+	}
+	if err != nil {
+		Response(false, err.Error(), nil, w, http.StatusNotFound)
+		return
+	}
+	// Return Success Response
+	Response(true, "Webhook delivery was queued again.", nil, w, http.StatusAccepted)
+}
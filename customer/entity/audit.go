@@ -0,0 +1,119 @@
+package entity
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Audited actions on a customer record
+const (
+	AuditCreate          = "create"
+	AuditUpdate          = "update"
+	AuditDelete          = "delete"
+	AuditRestore         = "restore"
+	AuditAnonymised      = "anonymised"
+	AuditHardDeleted     = "hard_deleted"
+	AuditEmailVerified   = "email_verified"
+	AuditPhoneVerified   = "phone_verified"
+	AuditPasswordChanged = "password_changed"
+)
+
+// AuditRedacted replaces secret values in field changes
+const AuditRedacted = "[REDACTED]"
+
+// AuditAnonymous actor of changes made without an authenticated principal, such as password resets
+const AuditAnonymous = "anonymous"
+
+// auditSecretFields columns whose values never reach the audit trail, secrets
+// and the personal data identifying the customer. Their changes are still listed.
+var auditSecretFields = map[string]bool{
+	"password":  true,
+	"name":      true,
+	"last_name": true,
+	"dni":       true,
+	"email":     true,
+	"phone":     true,
+}
+
+// FieldChange value of one field before and after a change, null when unset
+type FieldChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditEntry who changed a customer record, how and when
+type AuditEntry struct {
+	AuditUUID    string `json:"id"`
+	CustomerUUID string `json:"customer_id"`
+	Action       string `json:"action"`
+	// Actor principal subject, AuditAnonymous when unauthenticated
+	Actor string `json:"actor"`
+	// ActorMethod credential of the actor (jwt, apikey)
+	ActorMethod string        `json:"actor_method,omitempty"`
+	RequestID   string        `json:"request_id,omitempty"`
+	Changes     []FieldChange `json:"changes"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+// AuditPage one page of a customer history, newest first
+type AuditPage struct {
+	Entries    []*AuditEntry `json:"entries"`
+	Pagination Pagination    `json:"pagination"`
+}
+
+// DiffCustomers fields that differ between before and after, secrets are
+// redacted and the customer UUID, which never changes, is skipped
+func DiffCustomers(before Customer, after Customer) []FieldChange {
+	changes := []FieldChange{}
+
+	fields := reflect.TypeOf(before)
+	beforeValues := reflect.ValueOf(before)
+	afterValues := reflect.ValueOf(after)
+
+	for i := 0; i < fields.NumField(); i++ {
+		field := fields.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" || field.Tag.Get("sql") == "customer_uuid" {
+			continue
+		}
+
+		old, current := auditValue(beforeValues.Field(i)), auditValue(afterValues.Field(i))
+		if reflect.DeepEqual(old, current) {
+			continue
+		}
+		if auditSecretFields[name] {
+			old, current = redact(old), redact(current)
+		}
+		changes = append(changes, FieldChange{Field: name, Before: old, After: current})
+	}
+	return changes
+}
+
+// auditValue comparable value of a string or *time.Time field, nil when unset
+func auditValue(value reflect.Value) interface{} {
+	switch value.Kind() {
+	case reflect.String:
+		if value.String() == "" {
+			return nil
+		}
+		return value.String()
+	case reflect.Ptr:
+		if value.IsNil() {
+			return nil
+		}
+		if at, ok := value.Interface().(*time.Time); ok {
+			return at.UTC()
+		}
+	}
+	return nil
+}
+
+// redact hides a secret value but keeps whether it was set
+func redact(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	return AuditRedacted
+}
@@ -0,0 +1,56 @@
+package entity
+
+import (
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// Operations of the customer change feed
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+// CustomerChange latest state of a customer that changed after the feed token,
+// a customer changed several times only appears once with its last operation
+type CustomerChange struct {
+	Sequence     int64  `json:"sequence"`
+	Operation    string `json:"operation"`
+	CustomerUUID string `json:"id"`
+	// Customer current record, nil for deletions
+	Customer  *Customer  `json:"customer,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// DeletedAt tombstone of soft-deleted customers
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// ChangeFeed one page of the change feed, NextToken resumes after its last change
+type ChangeFeed struct {
+	Changes   []*CustomerChange `json:"changes"`
+	NextToken string            `json:"next_token"`
+	HasMore   bool              `json:"has_more"`
+}
+
+// EncodeChangeToken opaque continuation token resuming after sequence
+func EncodeChangeToken(sequence int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(sequence, 10)))
+}
+
+// DecodeChangeToken sequence of a token, an empty token starts from the beginning
+func DecodeChangeToken(token string) (int64, error) {
+	if token == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, ErrBadParamInput
+	}
+	sequence, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || sequence < 0 {
+		return 0, ErrBadParamInput
+	}
+	return sequence, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -19,9 +20,33 @@ type Customer struct {
 	Country      string `json:"country,omitempty" sql:"country"`   // validate:"min=2,max=2"
 	Email        string `json:"email,omitempty" sql:"email"`       // validate:"nonzero,min=6,max=50,regexp=^[0-9a-z]+@[0-9a-z]+(\\.[0-9a-z]+)+$"
 	Password     string `json:"password,omitempty" sql:"password"` // validate:"nonzero,max=15"
+	// EmailVerifiedAt set once the customer confirmed owning Email, read only
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" sql:"-"`
+	// PhoneVerifiedAt set once the customer confirmed a code sent to Phone, read only
+	PhoneVerifiedAt *time.Time `json:"phone_verified_at,omitempty" sql:"-"`
+	// DeletedAt set on soft-deleted customers, only read when Fetch includes them
+	DeletedAt *time.Time `json:"deleted_at,omitempty" sql:"-"`
 	// validate:"nonzero,min=6,max=50,regexp=^[0-9a-z]+@[0-9a-z]+(\\.[0-9a-z]+)+$" gorm:"unique"
 }
 
+// Soft-deleted customers returned by Fetch
+const (
+	// DeletedExclude active customers only, the default
+	DeletedExclude = ""
+	// DeletedInclude active and deleted customers
+	DeletedInclude = "include"
+	// DeletedOnly deleted customers only
+	DeletedOnly = "only"
+)
+
+// CustomerFilter narrows Fetch results, nil fields do not filter
+type CustomerFilter struct {
+	// Verified customers with (true) or without (false) a verified email
+	Verified *bool
+	// Deleted DeletedExclude, DeletedInclude or DeletedOnly
+	Deleted string
+}
+
 // BeforeCreate executes when customer is about to store Create() GORM method
 func (c Customer) BeforeCreate() {
 	// Generate UUID for customer_uuid field
@@ -34,15 +59,6 @@ func (c Customer) BeforeCreate() {
 	}
 }
 
-// BeforeUpdate executes when customer is about to update Update() GORM Method
-// func (c *Customer) BeforeUpdate(scope *gorm.Scope) error {
-func (c *Customer) BeforeUpdate() {
-	// Generate Sha1 encrypted password for password field
-	if len(c.Password) != 0 {
-		c.Password = EncryptPassword(c.Password)
-	}
-}
-
 // SQLUpdate Update SQL generated on only the fields that not empty.
 func SQLUpdate(c Customer) (string, []interface{}) {
 	// Local
@@ -59,6 +75,11 @@ func SQLUpdate(c Customer) (string, []interface{}) {
 		field := fields.Field(i)
 		value := values.Field(i)
 
+		// Only plain string columns are written, read only fields are skipped
+		if value.Kind() != reflect.String {
+			continue
+		}
+
 		if value.String() != "" { // len(value.String()) != 0
 			// split := strings.Split(field.Tag.Get("sql"), ",")
 			column := fmt.Sprintf(" %s = ? ", field.Tag.Get("sql"))
@@ -87,5 +108,9 @@ func (c *Customer) Safe() Customer {
 		Phone:        c.Phone,
 		Email:        c.Email,
 		Country:      c.Country,
+
+		EmailVerifiedAt: c.EmailVerifiedAt,
+		PhoneVerifiedAt: c.PhoneVerifiedAt,
+		DeletedAt:       c.DeletedAt,
 	}
 }
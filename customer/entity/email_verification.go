@@ -0,0 +1,11 @@
+package entity
+
+// VerifyEmailRequest body of POST /verify-email
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ResendVerificationRequest body of POST /verify-email/resend
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
@@ -0,0 +1,43 @@
+package entity
+
+// Erasure modes of a customer record
+const (
+	// ErasureAnonymise blanks the personal data in place, the UUID stays for
+	// referential integrity and the change feed reports a deletion
+	ErasureAnonymise = "anonymise"
+	// ErasureHardDelete removes the customer row altogether
+	ErasureHardDelete = "hard_delete"
+)
+
+// ErasedFields personal data columns cleared by an erasure
+var ErasedFields = []string{"name", "last_name", "dni", "email", "phone", "password"}
+
+// ErasureRequest how to erase a customer, ErasureAnonymise when Mode is empty
+type ErasureRequest struct {
+	Mode string `json:"mode"`
+}
+
+// IsValidErasureMode mode is ErasureAnonymise or ErasureHardDelete
+func IsValidErasureMode(mode string) bool {
+	return mode == ErasureAnonymise || mode == ErasureHardDelete
+}
+
+// ErasureChanges audit changes of an erasure, they name the erased fields
+// but never their values
+func ErasureChanges() []FieldChange {
+	changes := make([]FieldChange, 0, len(ErasedFields))
+	for _, field := range ErasedFields {
+		changes = append(changes, FieldChange{Field: field, Before: AuditRedacted, After: nil})
+	}
+	return changes
+}
+
+// RedactChanges changes with every value replaced by AuditRedacted, unset
+// values stay null
+func RedactChanges(changes []FieldChange) []FieldChange {
+	redacted := make([]FieldChange, 0, len(changes))
+	for _, change := range changes {
+		redacted = append(redacted, FieldChange{Field: change.Field, Before: redact(change.Before), After: redact(change.After)})
+	}
+	return redacted
+}
@@ -40,6 +40,48 @@ var (
 	// ErrSQLError Internal Database Error
 	ErrSQLError = errors.New("Internal Database Error, Try Later")
 
+	// ErrUnauthorized the request carries no authenticated caller
+	ErrUnauthorized = errors.New("Authentication required")
+
+	// ErrForbidden the authenticated caller is not allowed to perform the action
+	ErrForbidden = errors.New("Not allowed to perform this action")
+
+	// ErrResetTokenInvalid the password reset token is unknown, used or expired
+	ErrResetTokenInvalid = errors.New("Reset token is invalid or expired")
+
+	// ErrPasswordMismatch the current password sent to change it is wrong
+	ErrPasswordMismatch = errors.New("Current password is not correct")
+
+	// ErrVerificationTokenInvalid the email verification token is unknown, used or expired
+	ErrVerificationTokenInvalid = errors.New("Verification token is invalid or expired")
+
+	// ErrVerificationCodeInvalid the phone code is wrong, expired or out of attempts
+	ErrVerificationCodeInvalid = errors.New("Verification code is invalid or expired")
+
+	// ErrPhoneAlreadyVerified the customer phone needs no new code
+	ErrPhoneAlreadyVerified = errors.New("Phone is already verified")
+
+	// ErrInvalidLogin unknown email or wrong password, deliberately not told apart
+	ErrInvalidLogin = errors.New("Email or password is not correct")
+
+	// ErrRefreshTokenInvalid the refresh token is unknown, rotated or its session ended
+	ErrRefreshTokenInvalid = errors.New("Refresh token is invalid or expired")
+
+	// ErrRefreshTokenReused the refresh token was rotated before, the session is compromised
+	ErrRefreshTokenReused = errors.New("Refresh token was already used")
+
+	// ErrMFARequired the customer has MFA enabled and the login carries no code
+	ErrMFARequired = errors.New("Authentication code required")
+
+	// ErrMFACodeInvalid the TOTP or recovery code is wrong or was already used
+	ErrMFACodeInvalid = errors.New("Authentication code is not correct")
+
+	// ErrMFAAlreadyEnabled the customer already confirmed a TOTP factor
+	ErrMFAAlreadyEnabled = errors.New("Two-factor authentication is already enabled")
+
+	// ErrMFANotConfigured the service has no key to encrypt TOTP secrets
+	ErrMFANotConfigured = errors.New("Two-factor authentication is not available")
+
 	//
 )
 
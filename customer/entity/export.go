@@ -0,0 +1,70 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+// Export bundle formats
+const (
+	ExportJSON = "json"
+	// ExportZip one JSON file per section plus a manifest.json
+	ExportZip = "zip"
+)
+
+// Export job statuses
+const (
+	ExportPending = "pending"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+)
+
+// ErrExportNotReady the export job has not produced its bundle (yet)
+var ErrExportNotReady = errors.New("Export is not ready")
+
+// CustomerExport everything the service holds on one customer
+type CustomerExport struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Customer    Customer      `json:"customer"`
+	Sessions    []*Session    `json:"sessions"`
+	History     []*AuditEntry `json:"history"`
+}
+
+// ExportManifest table of contents of a zipped export
+type ExportManifest struct {
+	CustomerUUID string       `json:"customer_id"`
+	GeneratedAt  time.Time    `json:"generated_at"`
+	Files        []ExportFile `json:"files"`
+}
+
+// ExportFile one file of a zipped export and its checksum
+type ExportFile struct {
+	Name   string `json:"name"`
+	Bytes  int    `json:"bytes"`
+	SHA256 string `json:"sha256"`
+}
+
+// ExportBundle encoded export ready to download
+type ExportBundle struct {
+	ContentType string
+	Filename    string
+	Data        []byte
+}
+
+// ExportJob export generated in the background, the bundle is downloaded
+// separately until ExpiresAt
+type ExportJob struct {
+	ExportUUID   string     `json:"id"`
+	CustomerUUID string     `json:"customer_id"`
+	Format       string     `json:"format"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsValidExportFormat format is ExportJSON or ExportZip
+func IsValidExportFormat(format string) bool {
+	return format == ExportJSON || format == ExportZip
+}
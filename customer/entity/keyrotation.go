@@ -0,0 +1,10 @@
+package entity
+
+// KeyRotation progress of re-encrypting customer PII under the active master key
+type KeyRotation struct {
+	// Table and LastID last row read, a resumed rotation starts after it
+	Table     string `json:"table"`
+	LastID    int64  `json:"last_id"`
+	Scanned   int    `json:"scanned"`
+	Rewritten int    `json:"rewritten"`
+}
@@ -0,0 +1,55 @@
+package entity
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// RecoveryCodeCount recovery codes issued when TOTP is confirmed
+const RecoveryCodeCount = 10
+
+// TOTPFactor stored TOTP factor of a customer, Secret is still encrypted
+type TOTPFactor struct {
+	Secret       string
+	Confirmed    bool
+	LastUsedStep int64
+}
+
+// TOTPEnrollment returned once by POST /{uuid}/mfa/totp
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// ConfirmTOTPRequest body of POST /{uuid}/mfa/totp/confirm
+type ConfirmTOTPRequest struct {
+	Code string `json:"code"`
+}
+
+// RecoveryCodesResponse returned once when TOTP is confirmed
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// GenerateRecoveryCodes RecoveryCodeCount random codes like "a1b2c-3d4e5" and their hashes
+func GenerateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, RecoveryCodeCount)
+	hashes := make([]string, 0, RecoveryCodeCount)
+	for i := 0; i < RecoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+		code := hex.EncodeToString(raw)
+		code = code[:5] + "-" + code[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode SHA-256 hex of a recovery code, case and dash insensitive
+func HashRecoveryCode(code string) string {
+	return HashToken(strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1)))
+}
@@ -0,0 +1,136 @@
+package entity
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PasswordHasher hashes passwords before they reach the database
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches a hash produced by Hash
+	Verify(hash string, password string) bool
+}
+
+// SHA1Hasher scheme customers.password has always been stored with, see EncryptPassword
+type SHA1Hasher struct{}
+
+// Hash hex SHA-1 of password
+func (SHA1Hasher) Hash(password string) (string, error) {
+	return EncryptPassword(password), nil
+}
+
+// Verify constant time comparison against the stored hash
+func (SHA1Hasher) Verify(hash string, password string) bool {
+	return subtle.ConstantTimeCompare([]byte(EncryptPassword(password)), []byte(hash)) == 1
+}
+
+// PasswordPolicy rules a new password must satisfy
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+	// Breached lower-cased passwords known from public leaks
+	Breached map[string]struct{}
+}
+
+// DefaultPasswordPolicy used when no policy is configured
+var DefaultPasswordPolicy = PasswordPolicy{MinLength: 8, MaxLength: 72}
+
+// PasswordPolicyError lists every rule the password broke
+type PasswordPolicyError struct {
+	Violations []string
+}
+
+// Error joins the violations
+func (e *PasswordPolicyError) Error() string {
+	return "Password does not meet the policy: " + strings.Join(e.Violations, ", ")
+}
+
+// Validate nil when password is acceptable for the customer owning email
+func (p PasswordPolicy) Validate(password string, email string) error {
+	var violations []string
+
+	if len(password) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("password must have at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("password must have at most %d characters", p.MaxLength))
+	}
+	if email != "" && strings.EqualFold(password, email) {
+		violations = append(violations, "password cannot be your email")
+	}
+	if _, ok := p.Breached[strings.ToLower(password)]; ok {
+		violations = append(violations, "password appears in a list of breached passwords")
+	}
+
+	if len(violations) != 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// DefaultBreachedPasswordsFile list shipped with the service, used when present
+const DefaultBreachedPasswordsFile = "config/breached-passwords.txt"
+
+// PasswordPolicyFromEnv DefaultPasswordPolicy adjusted by PASSWORD_MIN_LENGTH,
+// PASSWORD_MAX_LENGTH and PASSWORD_BREACHED_FILE (one password per line, empty
+// disables the check).
+func PasswordPolicyFromEnv() (PasswordPolicy, error) {
+	policy := DefaultPasswordPolicy
+
+	for name, target := range map[string]*int{"PASSWORD_MIN_LENGTH": &policy.MinLength, "PASSWORD_MAX_LENGTH": &policy.MaxLength} {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		length, err := strconv.Atoi(value)
+		if err != nil || length < 1 {
+			return policy, fmt.Errorf("%s: %q is not a positive number", name, value)
+		}
+		*target = length
+	}
+
+	path, ok := os.LookupEnv("PASSWORD_BREACHED_FILE")
+	if !ok {
+		path = DefaultBreachedPasswordsFile
+		if _, err := os.Stat(path); err != nil {
+			return policy, nil
+		}
+	}
+	if path != "" {
+		breached, err := LoadBreachedPasswords(path)
+		if err != nil {
+			return policy, err
+		}
+		policy.Breached = breached
+	}
+	return policy, nil
+}
+
+// LoadBreachedPasswords reads one password per line, blank lines and lines
+// starting with # are skipped.
+func LoadBreachedPasswords(path string) (map[string]struct{}, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("breached passwords: %w", err)
+	}
+	defer file.Close() //nolint
+
+	breached := map[string]struct{}{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		breached[strings.ToLower(line)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("breached passwords: %w", err)
+	}
+	return breached, nil
+}
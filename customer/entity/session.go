@@ -0,0 +1,51 @@
+package entity
+
+import "time"
+
+// Session one login of a customer on one device
+type Session struct {
+	SessionUUID  string    `json:"session_uuid"`
+	CustomerUUID string    `json:"customer_uuid"`
+	UserAgent    string    `json:"user_agent"`
+	IP           string    `json:"ip"`
+	CreatedAt    time.Time `json:"created_at"`
+	LastUsedAt   time.Time `json:"last_used_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// SessionMeta device information recorded on login and refresh
+type SessionMeta struct {
+	UserAgent string
+	IP        string
+}
+
+// RefreshTokenRecord session a refresh token belongs to
+type RefreshTokenRecord struct {
+	SessionUUID  string
+	CustomerUUID string
+	// Used the token was already rotated, presenting it again means it leaked
+	Used bool
+}
+
+// LoginRequest body of POST /sessions
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	// Code TOTP or recovery code, required when the customer enabled MFA
+	Code string `json:"code,omitempty"`
+}
+
+// RefreshRequest body of POST /sessions/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenResponse tokens issued on login and refresh, the refresh token is
+// only returned here and must replace the one that was sent.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	SessionUUID  string `json:"session_uuid"`
+}
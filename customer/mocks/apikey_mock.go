@@ -0,0 +1,59 @@
+package mocks
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedAPIKeyRepository mocked APIKeyRepository, the context argument is not recorded
+type MockedAPIKeyRepository struct {
+	mock.Mock
+}
+
+// Store records the key and its hash
+func (m *MockedAPIKeyRepository) Store(ctx context.Context, key entity.APIKey, keyHash string) error {
+	args := m.Called(key, keyHash)
+	return args.Error(0)
+}
+
+// GetByPrefix returns the stubbed key and hash
+func (m *MockedAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*entity.APIKey, string, error) {
+	args := m.Called(prefix)
+
+	var key *entity.APIKey
+	if args.Get(0) != nil {
+		key = args.Get(0).(*entity.APIKey)
+	}
+	return key, args.String(1), args.Error(2)
+}
+
+// Fetch returns the stubbed keys
+func (m *MockedAPIKeyRepository) Fetch(ctx context.Context) ([]*entity.APIKey, error) {
+	args := m.Called()
+
+	var keys []*entity.APIKey
+	if args.Get(0) != nil {
+		keys = args.Get(0).([]*entity.APIKey)
+	}
+	return keys, args.Error(1)
+}
+
+// Rotate records the new prefix and hash
+func (m *MockedAPIKeyRepository) Rotate(ctx context.Context, keyUUID string, prefix string, keyHash string) error {
+	args := m.Called(keyUUID, prefix, keyHash)
+	return args.Error(0)
+}
+
+// Revoke records the revoked key
+func (m *MockedAPIKeyRepository) Revoke(ctx context.Context, keyUUID string) error {
+	args := m.Called(keyUUID)
+	return args.Error(0)
+}
+
+// TouchLastUsed records key usage
+func (m *MockedAPIKeyRepository) TouchLastUsed(ctx context.Context, keyUUID string) error {
+	args := m.Called(keyUUID)
+	return args.Error(0)
+}
@@ -0,0 +1,20 @@
+package mocks
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedAuditRepository mocked AuditRepository, the context argument is not recorded
+type MockedAuditRepository struct {
+	mock.Mock
+}
+
+// FetchHistory returns the stubbed entries and total
+func (m *MockedAuditRepository) FetchHistory(ctx context.Context, customerUUID string, offset int, limit int) ([]*entity.AuditEntry, int, error) {
+	args := m.Called(customerUUID, offset, limit)
+	entries, _ := args.Get(0).([]*entity.AuditEntry)
+	return entries, args.Int(1), args.Error(2)
+}
@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedEmailVerificationRepository mocked EmailVerificationRepository, the context argument is not recorded
+type MockedEmailVerificationRepository struct {
+	mock.Mock
+}
+
+// EmailVerified returns the stubbed verification status
+func (m *MockedEmailVerificationRepository) EmailVerified(ctx context.Context, email string) (bool, error) {
+	args := m.Called(email)
+	return args.Bool(0), args.Error(1)
+}
+
+// RecentEmailToken returns the stubbed throttling status
+func (m *MockedEmailVerificationRepository) RecentEmailToken(ctx context.Context, email string, window time.Duration) (bool, error) {
+	args := m.Called(email, window)
+	return args.Bool(0), args.Error(1)
+}
+
+// StoreEmailToken records the token hash
+func (m *MockedEmailVerificationRepository) StoreEmailToken(ctx context.Context, email string, tokenHash string, ttl time.Duration) error {
+	args := m.Called(email, tokenHash, ttl)
+	return args.Error(0)
+}
+
+// FindEmailToken returns the stubbed customer UUID and email
+func (m *MockedEmailVerificationRepository) FindEmailToken(ctx context.Context, tokenHash string) (string, string, error) {
+	args := m.Called(tokenHash)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+// ConsumeEmailToken records the spent token
+func (m *MockedEmailVerificationRepository) ConsumeEmailToken(ctx context.Context, tokenHash string) error {
+	args := m.Called(tokenHash)
+	return args.Error(0)
+}
+
+// MarkEmailVerified records the verified address
+func (m *MockedEmailVerificationRepository) MarkEmailVerified(ctx context.Context, customerUUID string, email string) error {
+	args := m.Called(customerUUID, email)
+	return args.Error(0)
+}
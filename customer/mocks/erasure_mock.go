@@ -0,0 +1,26 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedErasureRepository mocked ErasureRepository, the context argument is not recorded
+type MockedErasureRepository struct {
+	mock.Mock
+}
+
+// EraseCustomer records the erased customer and mode
+func (m *MockedErasureRepository) EraseCustomer(ctx context.Context, customerUUID string, mode string) error {
+	args := m.Called(customerUUID, mode)
+	return args.Error(0)
+}
+
+// ErasableCustomers returns the stubbed customer UUIDs
+func (m *MockedErasureRepository) ErasableCustomers(ctx context.Context, deletedBefore time.Time, limit int) ([]string, error) {
+	args := m.Called(deletedBefore, limit)
+	customerUUIDs, _ := args.Get(0).([]string)
+	return customerUUIDs, args.Error(1)
+}
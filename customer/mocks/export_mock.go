@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedExportRepository mocked ExportRepository, the context argument is not recorded
+type MockedExportRepository struct {
+	mock.Mock
+}
+
+// StoreExport records the queued job
+func (m *MockedExportRepository) StoreExport(ctx context.Context, job entity.ExportJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+// GetExport returns the stubbed job
+func (m *MockedExportRepository) GetExport(ctx context.Context, customerUUID string, exportUUID string) (*entity.ExportJob, error) {
+	args := m.Called(customerUUID, exportUUID)
+	job, _ := args.Get(0).(*entity.ExportJob)
+	return job, args.Error(1)
+}
+
+// GetExportBundle returns the stubbed bundle
+func (m *MockedExportRepository) GetExportBundle(ctx context.Context, customerUUID string, exportUUID string) ([]byte, error) {
+	args := m.Called(customerUUID, exportUUID)
+	bundle, _ := args.Get(0).([]byte)
+	return bundle, args.Error(1)
+}
+
+// PendingExports returns the stubbed jobs
+func (m *MockedExportRepository) PendingExports(ctx context.Context, limit int) ([]*entity.ExportJob, error) {
+	args := m.Called(limit)
+	jobs, _ := args.Get(0).([]*entity.ExportJob)
+	return jobs, args.Error(1)
+}
+
+// CompleteExport records the stored bundle
+func (m *MockedExportRepository) CompleteExport(ctx context.Context, exportUUID string, bundle []byte, expiresAt time.Time) error {
+	args := m.Called(exportUUID, bundle, expiresAt)
+	return args.Error(0)
+}
+
+// FailExport records the failure
+func (m *MockedExportRepository) FailExport(ctx context.Context, exportUUID string, message string, expiresAt time.Time) error {
+	args := m.Called(exportUUID, message, expiresAt)
+	return args.Error(0)
+}
+
+// DeleteExpiredExports returns the stubbed count
+func (m *MockedExportRepository) DeleteExpiredExports(ctx context.Context) (int64, error) {
+	args := m.Called()
+	deleted, _ := args.Get(0).(int64)
+	return deleted, args.Error(1)
+}
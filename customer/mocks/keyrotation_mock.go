@@ -0,0 +1,19 @@
+package mocks
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedKeyRotationRepository mocked KeyRotationRepository, the context argument is not recorded
+type MockedKeyRotationRepository struct {
+	mock.Mock
+}
+
+// ReencryptPII returns the stubbed batch
+func (m *MockedKeyRotationRepository) ReencryptPII(ctx context.Context, table string, afterID int64, limit int) (entity.KeyRotation, error) {
+	args := m.Called(table, afterID, limit)
+	return args.Get(0).(entity.KeyRotation), args.Error(1)
+}
@@ -0,0 +1,44 @@
+package mocks
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedMFARepository mocked MFARepository, the context argument is not recorded
+type MockedMFARepository struct {
+	mock.Mock
+}
+
+// GetTOTP returns the stubbed factor
+func (m *MockedMFARepository) GetTOTP(ctx context.Context, customerUUID string) (*entity.TOTPFactor, error) {
+	args := m.Called(customerUUID)
+	factor, _ := args.Get(0).(*entity.TOTPFactor)
+	return factor, args.Error(1)
+}
+
+// StoreTOTP records the encrypted secret
+func (m *MockedMFARepository) StoreTOTP(ctx context.Context, customerUUID string, secret string) error {
+	args := m.Called(customerUUID, secret)
+	return args.Error(0)
+}
+
+// ConfirmTOTP records the confirmation step and recovery code hashes
+func (m *MockedMFARepository) ConfirmTOTP(ctx context.Context, customerUUID string, step int64, recoveryHashes []string) error {
+	args := m.Called(customerUUID, step, recoveryHashes)
+	return args.Error(0)
+}
+
+// UseTOTPStep records the used step
+func (m *MockedMFARepository) UseTOTPStep(ctx context.Context, customerUUID string, step int64) error {
+	args := m.Called(customerUUID, step)
+	return args.Error(0)
+}
+
+// UseRecoveryCode records the spent recovery code
+func (m *MockedMFARepository) UseRecoveryCode(ctx context.Context, customerUUID string, codeHash string) error {
+	args := m.Called(customerUUID, codeHash)
+	return args.Error(0)
+}
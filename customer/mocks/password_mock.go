@@ -0,0 +1,55 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedPasswordRepository mocked PasswordRepository, the context argument is not recorded
+type MockedPasswordRepository struct {
+	mock.Mock
+}
+
+// GetUUIDByEmail returns the stubbed customer UUID
+func (m *MockedPasswordRepository) GetUUIDByEmail(ctx context.Context, email string) (string, error) {
+	args := m.Called(email)
+	return args.String(0), args.Error(1)
+}
+
+// GetPasswordHash returns the stubbed hash and email
+func (m *MockedPasswordRepository) GetPasswordHash(ctx context.Context, customerUUID string) (string, string, error) {
+	args := m.Called(customerUUID)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+// UpdatePassword records the new hash
+func (m *MockedPasswordRepository) UpdatePassword(ctx context.Context, customerUUID string, passwordHash string) error {
+	args := m.Called(customerUUID, passwordHash)
+	return args.Error(0)
+}
+
+// StoreResetToken records the token hash
+func (m *MockedPasswordRepository) StoreResetToken(ctx context.Context, customerUUID string, tokenHash string, ttl time.Duration) error {
+	args := m.Called(customerUUID, tokenHash, ttl)
+	return args.Error(0)
+}
+
+// FindResetToken returns the stubbed customer UUID and email
+func (m *MockedPasswordRepository) FindResetToken(ctx context.Context, tokenHash string) (string, string, error) {
+	args := m.Called(tokenHash)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+// ConsumeResetToken records the spent token
+func (m *MockedPasswordRepository) ConsumeResetToken(ctx context.Context, tokenHash string) error {
+	args := m.Called(tokenHash)
+	return args.Error(0)
+}
+
+// RevokeResetTokens records the revocation
+func (m *MockedPasswordRepository) RevokeResetTokens(ctx context.Context, customerUUID string) error {
+	args := m.Called(customerUUID)
+	return args.Error(0)
+}
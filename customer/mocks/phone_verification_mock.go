@@ -0,0 +1,49 @@
+package mocks
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedPhoneVerificationRepository mocked PhoneVerificationRepository, the context argument is not recorded
+type MockedPhoneVerificationRepository struct {
+	mock.Mock
+}
+
+// GetPhone returns the stubbed phone and verification status
+func (m *MockedPhoneVerificationRepository) GetPhone(ctx context.Context, customerUUID string) (string, bool, error) {
+	args := m.Called(customerUUID)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+// StorePhoneCode records the code hash
+func (m *MockedPhoneVerificationRepository) StorePhoneCode(ctx context.Context, customerUUID string, phone string, codeHash string, ttl time.Duration) error {
+	args := m.Called(customerUUID, phone, codeHash, ttl)
+	return args.Error(0)
+}
+
+// RegisterPhoneCodeAttempt records the guess
+func (m *MockedPhoneVerificationRepository) RegisterPhoneCodeAttempt(ctx context.Context, customerUUID string, maxAttempts int) error {
+	args := m.Called(customerUUID, maxAttempts)
+	return args.Error(0)
+}
+
+// FindPhoneCode returns the stubbed phone and code hash
+func (m *MockedPhoneVerificationRepository) FindPhoneCode(ctx context.Context, customerUUID string) (string, string, error) {
+	args := m.Called(customerUUID)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+// ConsumePhoneCode records the spent code
+func (m *MockedPhoneVerificationRepository) ConsumePhoneCode(ctx context.Context, customerUUID string) error {
+	args := m.Called(customerUUID)
+	return args.Error(0)
+}
+
+// MarkPhoneVerified records the verified phone
+func (m *MockedPhoneVerificationRepository) MarkPhoneVerified(ctx context.Context, customerUUID string, phone string) error {
+	args := m.Called(customerUUID, phone)
+	return args.Error(0)
+}
@@ -3,10 +3,10 @@ package mocks
 import "svc-customer/customer/entity"
 
 // MockQuerySelectByUUID Test Repository SQL Mocks to select valid customer
-var MockQuerySelectByUUID = "SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country FROM customers WHERE customer_uuid = \\? AND deleted_at IS NULL LIMIT 1"
+var MockQuerySelectByUUID = "SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country, email_verified_at, phone_verified_at FROM customers WHERE customer_uuid = \\? AND deleted_at IS NULL LIMIT 1"
 
 // MockColumnsSelectByUUID Test Repository Column Mocks for select customer
-var MockColumnsSelectByUUID = []string{"name", "last_name", "dni", "dni_type", "email", "phone", "customer_uuid", "country"}
+var MockColumnsSelectByUUID = []string{"name", "last_name", "dni", "dni_type", "email", "phone", "customer_uuid", "country", "email_verified_at", "phone_verified_at"}
 
 // MockQueryStore is a Customer entity model pointer less
 var MockQueryStore = entity.Customer{
@@ -35,5 +35,17 @@ var MockSQLQueryDelete = "UPDATE customers SET deleted_at = CURRENT_TIMESTAMP WH
 // MockUpdateCustomerSQL Update Test Repository SQL mock to Update valid customer data
 var MockUpdateCustomerSQL = "UPDATE customers SET name = \\? , last_name = \\? ,updated_at = CURRENT_TIMESTAMP WHERE customer_uuid = \\? AND deleted_at IS NULL LIMIT 1"
 
+// MockInsertOutboxSQL outbox row queued in the same transaction as a customer change
+var MockInsertOutboxSQL = "INSERT INTO outbox \\(event_uuid, event_type, customer_uuid, payload, occurred_at\\)"
+
+// MockNextChangeSequenceSQL change sequence bumped by every customer write
+var MockNextChangeSequenceSQL = "UPDATE change_sequence SET value = LAST_INSERT_ID\\(value \\+ 1\\) WHERE id = 1"
+
+// MockStampChangeSQL customer row stamped with the change sequence
+var MockStampChangeSQL = "UPDATE customers SET change_seq = \\?, change_op = \\? WHERE customer_uuid = \\? LIMIT 1"
+
+// MockInsertAuditSQL audit row written in the same transaction as a customer change
+var MockInsertAuditSQL = "INSERT INTO customer_audit \\(audit_uuid, customer_uuid, action, actor, actor_method, request_id, changes, created_at\\)"
+
 // MockFetchCustomerSQL Fetch All Test Repository SQL mock to Fetch all valid customers
-var MockFetchCustomerSQL = "SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country FROM customers WHERE deleted_at IS NULL ORDER BY id DESC LIMIT ? OFFSET ?"
+var MockFetchCustomerSQL = "SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country, email_verified_at, phone_verified_at FROM customers WHERE deleted_at IS NULL ORDER BY id DESC LIMIT ? OFFSET ?"
@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"svc-customer/customer/entity"
 
 	"github.com/stretchr/testify/mock"
@@ -8,6 +9,7 @@ import (
 
 // MockedRepository is a mocked object that implements an interface
 // that describes an object that the code I am testing relies on.
+// The context argument is not recorded so expectations only list business arguments.
 type MockedRepository struct {
 	mock.Mock
 }
@@ -19,19 +21,25 @@ type MockedRepository struct {
 // is a mocked object - we're just going to stub it out.
 //
 // NOTE: This method is not being tested here, code that uses this object is.
-func (m *MockedRepository) DeleteByUUID(customerUUID string) error {
+func (m *MockedRepository) DeleteByUUID(ctx context.Context, customerUUID string) error {
+	args := m.Called(customerUUID)
+	return args.Error(0)
+}
+
+// RestoreByUUID records the restored customer UUID
+func (m *MockedRepository) RestoreByUUID(ctx context.Context, customerUUID string) error {
 	args := m.Called(customerUUID)
 	return args.Error(0)
 }
 
 // HealthCheck Check Database Connection
-func (m *MockedRepository) HealthCheck() error {
+func (m *MockedRepository) HealthCheck(ctx context.Context) error {
 	args := m.Called()
 	return args.Error(0)
 }
 
 // GetByUUID a
-func (m *MockedRepository) GetByUUID(customerUUID string) (*entity.Customer, error) {
+func (m *MockedRepository) GetByUUID(ctx context.Context, customerUUID string) (*entity.Customer, error) {
 
 	var customer *entity.Customer
 
@@ -56,7 +64,7 @@ func (m *MockedRepository) GetByUUID(customerUUID string) (*entity.Customer, err
 }
 
 // Fetch a
-func (m *MockedRepository) Fetch(page int, limit int) ([]*entity.Customer, int, int, int, error) {
+func (m *MockedRepository) Fetch(ctx context.Context, filter entity.CustomerFilter, page int, limit int) ([]*entity.Customer, int, int, int, error) {
 
 	mockCustomer := &entity.Customer{
 		CustomerUUID: "039d69ee-f9cb-4a3d-87e4-6eb63c302579",
@@ -80,7 +88,7 @@ func (m *MockedRepository) Fetch(page int, limit int) ([]*entity.Customer, int,
 }
 
 // Store s
-func (m *MockedRepository) Store(customer entity.Customer) error {
+func (m *MockedRepository) Store(ctx context.Context, customer entity.Customer) error {
 	args := m.Called(customer)
 	var r0 error
 	if rf, ok := args.Get(0).(func(entity.Customer) error); ok {
@@ -92,7 +100,7 @@ func (m *MockedRepository) Store(customer entity.Customer) error {
 }
 
 // UpdateByUUID a
-func (m *MockedRepository) UpdateByUUID(customer entity.Customer, customerUUID string) error {
+func (m *MockedRepository) UpdateByUUID(ctx context.Context, customer entity.Customer, customerUUID string) error {
 
 	args := m.Called(customer, customerUUID)
 
@@ -105,3 +113,10 @@ func (m *MockedRepository) UpdateByUUID(customer entity.Customer, customerUUID s
 	}
 	return r0
 }
+
+// Changes returns the stubbed changes
+func (m *MockedRepository) Changes(ctx context.Context, since int64, limit int) ([]*entity.CustomerChange, error) {
+	args := m.Called(since, limit)
+	changes, _ := args.Get(0).([]*entity.CustomerChange)
+	return changes, args.Error(1)
+}
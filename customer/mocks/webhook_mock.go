@@ -0,0 +1,79 @@
+package mocks
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// MockedWebhookRepository mocked WebhookRepository, the context argument is not recorded
+type MockedWebhookRepository struct {
+	mock.Mock
+}
+
+// StoreWebhook records the webhook and its secret
+func (m *MockedWebhookRepository) StoreWebhook(ctx context.Context, webhook entity.Webhook, secret string) error {
+	args := m.Called(webhook, secret)
+	return args.Error(0)
+}
+
+// GetWebhook returns the stubbed webhook
+func (m *MockedWebhookRepository) GetWebhook(ctx context.Context, webhookUUID string) (*entity.Webhook, error) {
+	args := m.Called(webhookUUID)
+	webhook, _ := args.Get(0).(*entity.Webhook)
+	return webhook, args.Error(1)
+}
+
+// FetchWebhooks returns the stubbed webhooks
+func (m *MockedWebhookRepository) FetchWebhooks(ctx context.Context) ([]*entity.Webhook, error) {
+	args := m.Called()
+	webhooks, _ := args.Get(0).([]*entity.Webhook)
+	return webhooks, args.Error(1)
+}
+
+// DeleteWebhook records the deleted webhook
+func (m *MockedWebhookRepository) DeleteWebhook(ctx context.Context, webhookUUID string) error {
+	args := m.Called(webhookUUID)
+	return args.Error(0)
+}
+
+// EnqueueDeliveries records the queued event
+func (m *MockedWebhookRepository) EnqueueDeliveries(ctx context.Context, eventID string, eventType string, payload string) error {
+	args := m.Called(eventID, eventType, payload)
+	return args.Error(0)
+}
+
+// ClaimDueDeliveries returns the stubbed deliveries
+func (m *MockedWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*entity.WebhookDelivery, error) {
+	args := m.Called(limit, lease)
+	deliveries, _ := args.Get(0).([]*entity.WebhookDelivery)
+	return deliveries, args.Error(1)
+}
+
+// RecordAttempt records the attempt, next status and retry pause
+func (m *MockedWebhookRepository) RecordAttempt(ctx context.Context, deliveryUUID string, attempt entity.WebhookAttempt, status string, retryIn time.Duration) error {
+	args := m.Called(deliveryUUID, attempt, status, retryIn)
+	return args.Error(0)
+}
+
+// FetchDeliveries returns the stubbed deliveries
+func (m *MockedWebhookRepository) FetchDeliveries(ctx context.Context, webhookUUID string, limit int) ([]*entity.WebhookDelivery, error) {
+	args := m.Called(webhookUUID, limit)
+	deliveries, _ := args.Get(0).([]*entity.WebhookDelivery)
+	return deliveries, args.Error(1)
+}
+
+// GetDelivery returns the stubbed delivery
+func (m *MockedWebhookRepository) GetDelivery(ctx context.Context, deliveryUUID string) (*entity.WebhookDelivery, error) {
+	args := m.Called(deliveryUUID)
+	delivery, _ := args.Get(0).(*entity.WebhookDelivery)
+	return delivery, args.Error(1)
+}
+
+// ReplayDelivery records the replayed delivery
+func (m *MockedWebhookRepository) ReplayDelivery(ctx context.Context, deliveryUUID string) error {
+	args := m.Called(deliveryUUID)
+	return args.Error(0)
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+)
+
+// MySQLAPIKeyRepository api_keys table access
+type MySQLAPIKeyRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLAPIKeyRepository API key repository sharing the customers connection pool
+func NewMySQLAPIKeyRepository(db *sql.DB) *MySQLAPIKeyRepository {
+	return &MySQLAPIKeyRepository{db}
+}
+
+// apiKeyColumns columns scanned by scanAPIKey, in order
+const apiKeyColumns = "key_uuid, name, prefix, key_hash, permissions, allowed_ips, created_at, rotated_at, last_used_at, revoked_at"
+
+// Store inserts a new API key with its hash
+func (repo *MySQLAPIKeyRepository) Store(ctx context.Context, key entity.APIKey, keyHash string) error {
+	query := `INSERT INTO api_keys (key_uuid, name, prefix, key_hash, permissions, allowed_ips, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`
+
+	ctx, span := tracing.StartSQL(ctx, "StoreAPIKey", query)
+	_, err := repo.db.ExecContext(ctx, query,
+		key.KeyUUID,
+		key.Name,
+		key.Prefix,
+		keyHash,
+		joinList(key.Permissions),
+		joinList(key.AllowedIPs),
+	)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StoreAPIKey: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// GetByPrefix active (not revoked) key and its hash by public prefix
+func (repo *MySQLAPIKeyRepository) GetByPrefix(ctx context.Context, prefix string) (*entity.APIKey, string, error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys WHERE prefix = ? AND revoked_at IS NULL LIMIT 1"
+
+	ctx, span := tracing.StartSQL(ctx, "GetAPIKeyByPrefix", query)
+	key, keyHash, err := scanAPIKey(repo.db.QueryRowContext(ctx, query, prefix))
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetAPIKeyByPrefix: %s", err.Error())
+		return nil, "", entity.ErrSQLError
+	}
+	return key, keyHash, nil
+}
+
+// Fetch every API key, revoked ones included, newest first
+func (repo *MySQLAPIKeyRepository) Fetch(ctx context.Context) (keys []*entity.APIKey, err error) {
+	query := "SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id DESC"
+
+	ctx, span := tracing.StartSQL(ctx, "FetchAPIKeys", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("FetchAPIKeys: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	keys = []*entity.APIKey{}
+	for rows.Next() {
+		key, _, err := scanAPIKey(rows)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("FetchAPIKeys: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		keys = append(keys, key)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("FetchAPIKeys: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return keys, nil
+}
+
+// Rotate replaces prefix and hash of an active key, the old key stops working immediately
+func (repo *MySQLAPIKeyRepository) Rotate(ctx context.Context, keyUUID string, prefix string, keyHash string) error {
+	query := `UPDATE api_keys SET prefix = ?, key_hash = ?, rotated_at = CURRENT_TIMESTAMP WHERE key_uuid = ? AND revoked_at IS NULL LIMIT 1`
+	return repo.exec(ctx, "RotateAPIKey", query, prefix, keyHash, keyUUID)
+}
+
+// Revoke disables an active key permanently
+func (repo *MySQLAPIKeyRepository) Revoke(ctx context.Context, keyUUID string) error {
+	query := `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE key_uuid = ? AND revoked_at IS NULL LIMIT 1`
+	return repo.exec(ctx, "RevokeAPIKey", query, keyUUID)
+}
+
+// TouchLastUsed records key usage, at most once a minute to spare writes on hot keys
+func (repo *MySQLAPIKeyRepository) TouchLastUsed(ctx context.Context, keyUUID string) error {
+	query := `UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+	          WHERE key_uuid = ? AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL 1 MINUTE)`
+
+	ctx, span := tracing.StartSQL(ctx, "TouchAPIKey", query)
+	_, err := repo.db.ExecContext(ctx, query, keyUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("TouchAPIKey: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// exec runs an UPDATE that must affect exactly one row
+func (repo *MySQLAPIKeyRepository) exec(ctx context.Context, operation string, query string, args ...interface{}) error {
+	ctx, span := tracing.StartSQL(ctx, operation, query)
+	result, err := repo.db.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("%s: %s", operation, err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+// rowScanner common interface of *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAPIKey reads apiKeyColumns from row
+func scanAPIKey(row rowScanner) (*entity.APIKey, string, error) {
+	key := &entity.APIKey{}
+
+	var keyHash, permissions, allowedIPs string
+	var rotatedAt, lastUsedAt, revokedAt sql.NullTime
+
+	err := row.Scan(
+		&key.KeyUUID,
+		&key.Name,
+		&key.Prefix,
+		&keyHash,
+		&permissions,
+		&allowedIPs,
+		&key.CreatedAt,
+		&rotatedAt,
+		&lastUsedAt,
+		&revokedAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key.Permissions = splitList(permissions)
+	key.AllowedIPs = splitList(allowedIPs)
+	key.RotatedAt = nullTime(rotatedAt)
+	key.LastUsedAt = nullTime(lastUsedAt)
+	key.RevokedAt = nullTime(revokedAt)
+	return key, keyHash, nil
+}
+
+// joinList stores a string list as a comma separated column
+func joinList(values []string) string {
+	return strings.Join(values, ",")
+}
+
+// splitList reads a comma separated column back into a list
+func splitList(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+// nullTime maps a nullable column to an optional timestamp
+func nullTime(value sql.NullTime) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"svc-customer/auth"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MySQLAuditRepository read access to the customer_audit trail, rows are
+// written by the customer repositories inside their own transactions
+type MySQLAuditRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLAuditRepository audit repository sharing the customers connection pool
+func NewMySQLAuditRepository(db *sql.DB) *MySQLAuditRepository {
+	return &MySQLAuditRepository{db}
+}
+
+// FetchHistory audit entries of a customer newest first and their total
+func (repo *MySQLAuditRepository) FetchHistory(ctx context.Context, customerUUID string, offset int, limit int) (entries []*entity.AuditEntry, total int, err error) {
+	query := `SELECT COUNT(id) FROM customer_audit WHERE customer_uuid = ?`
+
+	spanCtx, span := tracing.StartSQL(ctx, "CountHistory", query)
+	err = repo.db.QueryRowContext(spanCtx, query, customerUUID).Scan(&total)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("CountHistory: %s", err.Error())
+		return nil, 0, entity.ErrSQLError
+	}
+
+	query = `SELECT audit_uuid, customer_uuid, action, actor, actor_method, request_id, changes, created_at
+	         FROM customer_audit WHERE customer_uuid = ? ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	ctx, span = tracing.StartSQL(ctx, "FetchHistory", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, customerUUID, limit, offset)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("FetchHistory: %s", err.Error())
+		return nil, 0, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	entries = []*entity.AuditEntry{}
+	for rows.Next() {
+		entry, err := scanAuditEntry(rows)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("FetchHistory: %s", err.Error())
+			return nil, 0, entity.ErrSQLError
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("FetchHistory: %s", err.Error())
+		return nil, 0, entity.ErrSQLError
+	}
+	return entries, total, nil
+}
+
+// scanAuditEntry reads one customer_audit row
+func scanAuditEntry(row rowScanner) (*entity.AuditEntry, error) {
+	entry := &entity.AuditEntry{}
+	var actorMethod, requestID sql.NullString
+	var changes string
+
+	err := row.Scan(
+		&entry.AuditUUID,
+		&entry.CustomerUUID,
+		&entry.Action,
+		&entry.Actor,
+		&actorMethod,
+		&requestID,
+		&changes,
+		&entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	entry.ActorMethod = actorMethod.String
+	entry.RequestID = requestID.String
+	if err = json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// insertAudit records action on the customer within tx, the actor and request
+// ID come from ctx
+func insertAudit(ctx context.Context, tx *sql.Tx, customerUUID string, action string, changes []entity.FieldChange) error {
+	if changes == nil {
+		changes = []entity.FieldChange{}
+	}
+	body, err := json.Marshal(changes)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("insertAudit: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	auditUUID, err := uuid.NewRandom()
+	if err != nil {
+		return err
+	}
+
+	actor, method := entity.AuditAnonymous, sql.NullString{}
+	if principal, ok := auth.FromContext(ctx); ok {
+		actor, method = principal.Subject, sql.NullString{String: principal.Method, Valid: principal.Method != ""}
+	}
+	requestID := logging.RequestID(ctx)
+
+	query := `INSERT INTO customer_audit (audit_uuid, customer_uuid, action, actor, actor_method, request_id, changes, created_at)
+	          VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	return txExec(ctx, tx, "insertAudit", query, auditUUID.String(), customerUUID, action, actor, method,
+		sql.NullString{String: requestID, Valid: requestID != ""}, string(body), time.Now().UTC())
+}
+
+// fieldSet change of a field from unset to now, for flags set by the database clock
+func fieldSet(field string) []entity.FieldChange {
+	return []entity.FieldChange{{Field: field, Before: nil, After: time.Now().UTC()}}
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+)
+
+// stampChange gives the customer row the next change sequence
+func stampChange(ctx context.Context, tx *sql.Tx, customerUUID string, operation string) error {
+	sequence, err := nextChangeSequence(ctx, tx)
+	if err != nil {
+		return err
+	}
+	query := `UPDATE customers SET change_seq = ?, change_op = ? WHERE customer_uuid = ? LIMIT 1`
+	return txExec(ctx, tx, "StampChange", query, sequence, operation, customerUUID)
+}
+
+// tombstone records the deletion of a customer row about to be removed for
+// good under the next change sequence, Changes reports it like a soft deletion
+func tombstone(ctx context.Context, tx *sql.Tx, customerUUID string) error {
+	sequence, err := nextChangeSequence(ctx, tx)
+	if err != nil {
+		return err
+	}
+	query := `INSERT INTO customer_tombstones (customer_uuid, change_seq, created_at, deleted_at)
+	          SELECT customer_uuid, ?, created_at, CURRENT_TIMESTAMP FROM customers WHERE customer_uuid = ? LIMIT 1`
+	return txExec(ctx, tx, "Tombstone", query, sequence, customerUUID)
+}
+
+// nextChangeSequence increments the change counter. The counter row stays
+// locked until tx ends, so a reader resuming after a sequence never misses a
+// lower one that was still uncommitted.
+func nextChangeSequence(ctx context.Context, tx *sql.Tx) (int64, error) {
+	query := `UPDATE change_sequence SET value = LAST_INSERT_ID(value + 1) WHERE id = 1`
+
+	spanCtx, span := tracing.StartSQL(ctx, "NextChangeSequence", query)
+	result, err := tx.ExecContext(spanCtx, query)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("NextChangeSequence: %s", err.Error())
+		return 0, entity.ErrSQLError
+	}
+	// LAST_INSERT_ID(expr) reports the incremented value as the insert id
+	sequence, err := result.LastInsertId()
+	if err != nil {
+		logging.FromContext(ctx).Errorf("NextChangeSequence: %s", err.Error())
+		return 0, entity.ErrSQLError
+	}
+	return sequence, nil
+}
+
+// Changes customers changed after sequence since in change order, deleted
+// ones included and hard deleted ones as their tombstone
+func (repo *MySQLCustomersRepository) Changes(ctx context.Context, since int64, limit int) (changes []*entity.CustomerChange, err error) {
+	query := `SELECT change_seq, change_op, customer_uuid, name, last_name, dni, dni_type, email, phone, country,
+	          email_verified_at, phone_verified_at, created_at, updated_at, deleted_at
+	          FROM customers WHERE change_seq > ?
+	          UNION ALL
+	          SELECT change_seq, 'delete', customer_uuid, '', '', '', '', '', '', '',
+	          NULL, NULL, created_at, NULL, deleted_at
+	          FROM customer_tombstones WHERE change_seq > ?
+	          ORDER BY change_seq LIMIT ?`
+
+	ctx, span := tracing.StartSQL(ctx, "Changes", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, since, since, limit)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Changes: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	changes = []*entity.CustomerChange{}
+	for rows.Next() {
+		change, err := scanChange(rows)
+		if err == nil && change.Customer != nil {
+			err = repo.PII.open(ctx, change.Customer)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Errorf("Changes: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		changes = append(changes, change)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("Changes: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return changes, nil
+}
+
+// scanChange reads one row of Changes, deletions only keep their tombstone
+func scanChange(row rowScanner) (*entity.CustomerChange, error) {
+	change := &entity.CustomerChange{}
+	customer := &entity.Customer{}
+	var emailVerifiedAt, phoneVerifiedAt, updatedAt, deletedAt sql.NullTime
+
+	err := row.Scan(
+		&change.Sequence,
+		&change.Operation,
+		&customer.CustomerUUID,
+		&customer.Name,
+		&customer.LastName,
+		&customer.Dni,
+		&customer.DniType,
+		&customer.Email,
+		&customer.Phone,
+		&customer.Country,
+		&emailVerifiedAt,
+		&phoneVerifiedAt,
+		&change.CreatedAt,
+		&updatedAt,
+		&deletedAt)
+	if err != nil {
+		return nil, err
+	}
+	change.CustomerUUID = customer.CustomerUUID
+	change.UpdatedAt = nullTime(updatedAt)
+	change.DeletedAt = nullTime(deletedAt)
+	if change.DeletedAt == nil {
+		customer.EmailVerifiedAt = nullTime(emailVerifiedAt)
+		customer.PhoneVerifiedAt = nullTime(phoneVerifiedAt)
+		change.Customer = customer
+	}
+	return change, nil
+}
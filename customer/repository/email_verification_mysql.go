@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+)
+
+// MySQLEmailVerificationRepository customers.email_verified_at and email_verifications access
+type MySQLEmailVerificationRepository struct {
+	db *sql.DB
+
+	// PII matches the encrypted customers email and seals the address a token
+	// was sent to, plain text when nil
+	PII *PII
+}
+
+// NewMySQLEmailVerificationRepository email verification repository sharing the customers connection pool
+func NewMySQLEmailVerificationRepository(db *sql.DB) *MySQLEmailVerificationRepository {
+	return &MySQLEmailVerificationRepository{db: db}
+}
+
+// EmailVerified reports whether the active customer owning email verified it
+func (repo *MySQLEmailVerificationRepository) EmailVerified(ctx context.Context, email string) (bool, error) {
+	condition, args := repo.PII.match("email", email)
+	query := `SELECT email_verified_at IS NOT NULL FROM customers WHERE ` + condition + ` AND deleted_at IS NULL LIMIT 1`
+
+	var verified bool
+
+	ctx, span := tracing.StartSQL(ctx, "EmailVerified", query)
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(&verified)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("EmailVerified: %s", err.Error())
+		return false, entity.ErrSQLError
+	}
+	return verified, nil
+}
+
+// RecentEmailToken reports whether a token was issued for email within window, using the database clock
+func (repo *MySQLEmailVerificationRepository) RecentEmailToken(ctx context.Context, email string, window time.Duration) (bool, error) {
+	condition, args := repo.PII.match("email", email)
+	query := `SELECT exists (SELECT id FROM email_verifications WHERE ` + condition + ` AND created_at > DATE_SUB(CURRENT_TIMESTAMP, INTERVAL ? SECOND))`
+
+	var recent bool
+
+	ctx, span := tracing.StartSQL(ctx, "RecentEmailToken", query)
+	err := repo.db.QueryRowContext(ctx, query, append(args, int(window.Seconds()))...).Scan(&recent)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RecentEmailToken: %s", err.Error())
+		return false, entity.ErrSQLError
+	}
+	return recent, nil
+}
+
+// StoreEmailToken saves a token hash for the active customer owning email, valid for ttl
+func (repo *MySQLEmailVerificationRepository) StoreEmailToken(ctx context.Context, email string, tokenHash string, ttl time.Duration) error {
+	// The token keeps the address it was sent to, sealed with its own blind
+	// index, not the customers column
+	sealed, index, err := repo.PII.sealColumn(ctx, "email", email)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StoreEmailToken: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	condition, args := repo.PII.match("email", email)
+	query := `INSERT INTO email_verifications (token_hash, customer_uuid, email, email_bidx, expires_at, created_at)
+	          SELECT ?, customer_uuid, ?, ?, DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND), CURRENT_TIMESTAMP
+	          FROM customers WHERE ` + condition + ` AND deleted_at IS NULL LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "StoreEmailToken", query)
+	result, err := repo.db.ExecContext(ctx, query, append([]interface{}{tokenHash, sealed, index, int(ttl.Seconds())}, args...)...)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StoreEmailToken: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+// FindEmailToken customer UUID and email of an unused, unexpired token
+func (repo *MySQLEmailVerificationRepository) FindEmailToken(ctx context.Context, tokenHash string) (string, string, error) {
+	query := `SELECT customer_uuid, email FROM email_verifications
+	          WHERE token_hash = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	          LIMIT 1`
+
+	var customerUUID, email string
+
+	ctx, span := tracing.StartSQL(ctx, "FindEmailToken", query)
+	err := repo.db.QueryRowContext(ctx, query, tokenHash).Scan(&customerUUID, &email)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", entity.ErrVerificationTokenInvalid
+		}
+		logging.FromContext(ctx).Errorf("FindEmailToken: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	if email, err = repo.PII.openValue(ctx, email); err != nil {
+		logging.FromContext(ctx).Errorf("FindEmailToken: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	return customerUUID, email, nil
+}
+
+// ConsumeEmailToken spends the token, only one concurrent caller succeeds
+func (repo *MySQLEmailVerificationRepository) ConsumeEmailToken(ctx context.Context, tokenHash string) error {
+	query := `UPDATE email_verifications SET used_at = CURRENT_TIMESTAMP
+	          WHERE token_hash = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "ConsumeEmailToken", query)
+	result, err := repo.db.ExecContext(ctx, query, tokenHash)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ConsumeEmailToken: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrVerificationTokenInvalid
+	}
+	return nil
+}
+
+// MarkEmailVerified sets email_verified_at, a token sent to a since replaced address verifies nothing
+func (repo *MySQLEmailVerificationRepository) MarkEmailVerified(ctx context.Context, customerUUID string, email string) error {
+	condition, args := repo.PII.match("email", email)
+	query := `UPDATE customers SET email_verified_at = COALESCE(email_verified_at, CURRENT_TIMESTAMP)
+	          WHERE customer_uuid = ? AND ` + condition + ` AND deleted_at IS NULL LIMIT 1`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("MarkEmailVerified: %s", err.Error())
+		return entity.ErrSQLError
+	}
+
+	spanCtx, span := tracing.StartSQL(ctx, "MarkEmailVerified", query)
+	result, err := tx.ExecContext(spanCtx, query, append([]interface{}{customerUUID}, args...)...)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("MarkEmailVerified: %s", err.Error())
+		return finishTx(ctx, tx, "MarkEmailVerified", entity.ErrSQLError)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return finishTx(ctx, tx, "MarkEmailVerified", entity.ErrVerificationTokenInvalid)
+	}
+	// The verified flag is part of the record the change feed carries
+	if err = stampChange(ctx, tx, customerUUID, entity.ChangeUpdate); err != nil {
+		return finishTx(ctx, tx, "MarkEmailVerified", err)
+	}
+	return finishTx(ctx, tx, "MarkEmailVerified", insertAudit(ctx, tx, customerUUID, entity.AuditEmailVerified, fieldSet("email_verified_at")))
+}
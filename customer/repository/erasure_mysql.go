@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"svc-customer/customer/entity"
+	"svc-customer/events"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+)
+
+// erasureStatements remove what other tables hold about a customer, every
+// statement takes the customer UUID as its only argument
+var erasureStatements = []struct {
+	operation string
+	query     string
+}{
+	{"EraseRefreshTokens", `DELETE FROM refresh_tokens WHERE session_uuid IN (SELECT session_uuid FROM sessions WHERE customer_uuid = ?)`},
+	{"EraseSessions", `DELETE FROM sessions WHERE customer_uuid = ?`},
+	{"ErasePasswordResets", `DELETE FROM password_resets WHERE customer_uuid = ?`},
+	{"EraseEmailVerifications", `DELETE FROM email_verifications WHERE customer_uuid = ?`},
+	{"ErasePhoneVerifications", `DELETE FROM phone_verifications WHERE customer_uuid = ?`},
+	{"EraseRecoveryCodes", `DELETE FROM mfa_recovery_codes WHERE customer_uuid = ?`},
+	{"EraseTOTP", `DELETE FROM mfa_totp WHERE customer_uuid = ?`},
+	{"EraseExports", `DELETE FROM customer_exports WHERE customer_uuid = ?`},
+}
+
+// MySQLErasureRepository erases the personal data of customers
+type MySQLErasureRepository struct {
+	db *sql.DB
+
+	// PII seals the erasure event and opens the webhook payloads it scrubs,
+	// plain text when nil
+	PII *PII
+}
+
+// NewMySQLErasureRepository erasure repository sharing the customers connection pool
+func NewMySQLErasureRepository(db *sql.DB) *MySQLErasureRepository {
+	return &MySQLErasureRepository{db: db}
+}
+
+// EraseCustomer erases a customer not erased before, deleted or not, in one
+// transaction: its sessions, tokens, second factors and exports are removed, queued
+// and delivered events and the audit trail lose their personal values, then
+// the customer row is anonymised or deleted according to mode. The erasure is
+// audited without personal data and queues a CustomerErased event.
+func (repo *MySQLErasureRepository) EraseCustomer(ctx context.Context, customerUUID string, mode string) error {
+	logging.FromContext(ctx).Debug("EraseCustomer: executed normally")
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("EraseCustomer: %s", err.Error())
+		return entity.ErrSQLError
+	}
+
+	var id int64
+	query := `SELECT id FROM customers WHERE customer_uuid = ? AND erased_at IS NULL LIMIT 1 FOR UPDATE`
+	spanCtx, span := tracing.StartSQL(ctx, "EraseCustomer", query)
+	err = tx.QueryRowContext(spanCtx, query, customerUUID).Scan(&id)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err == sql.ErrNoRows {
+		logging.FromContext(ctx).Debugf("EraseCustomer: customer %s not found", customerUUID)
+		return finishTx(ctx, tx, "EraseCustomer", entity.ErrNotFound)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Errorf("EraseCustomer: %s", err.Error())
+		return finishTx(ctx, tx, "EraseCustomer", entity.ErrSQLError)
+	}
+
+	for _, statement := range erasureStatements {
+		if err = txExec(ctx, tx, statement.operation, statement.query, customerUUID); err != nil {
+			return finishTx(ctx, tx, "EraseCustomer", err)
+		}
+	}
+	if err = scrubEvents(ctx, tx, repo.PII, customerUUID); err != nil {
+		return finishTx(ctx, tx, "EraseCustomer", err)
+	}
+	if err = redactAudit(ctx, tx, customerUUID); err != nil {
+		return finishTx(ctx, tx, "EraseCustomer", err)
+	}
+
+	action := entity.AuditAnonymised
+	if mode == entity.ErasureHardDelete {
+		action = entity.AuditHardDeleted
+		// The tombstone tells feed readers the customer is gone
+		if err = tombstone(ctx, tx, customerUUID); err == nil {
+			query = `DELETE FROM customers WHERE customer_uuid = ? LIMIT 1`
+			err = txExec(ctx, tx, "HardDeleteCustomer", query, customerUUID)
+		}
+	} else {
+		query = `UPDATE customers SET name = '', last_name = '', dni = '', email = '', phone = '', password = '',
+		         dni_bidx = NULL, email_bidx = NULL, phone_bidx = NULL, email_verified_at = NULL, phone_verified_at = NULL,
+		         deleted_at = COALESCE(deleted_at, CURRENT_TIMESTAMP),
+		         erased_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP
+		         WHERE customer_uuid = ? LIMIT 1`
+		if err = txExec(ctx, tx, "AnonymiseCustomer", query, customerUUID); err == nil {
+			// Feed readers drop the customer like any other deletion
+			err = stampChange(ctx, tx, customerUUID, entity.ChangeDelete)
+		}
+	}
+	if err != nil {
+		return finishTx(ctx, tx, "EraseCustomer", err)
+	}
+	if err = insertAudit(ctx, tx, customerUUID, action, entity.ErasureChanges()); err != nil {
+		return finishTx(ctx, tx, "EraseCustomer", err)
+	}
+	return finishTx(ctx, tx, "EraseCustomer", insertEvent(ctx, tx, repo.PII, events.CustomerErased, entity.Customer{CustomerUUID: customerUUID}))
+}
+
+// ErasableCustomers UUIDs of customers deleted before deletedBefore and not
+// erased yet, longest deleted first
+func (repo *MySQLErasureRepository) ErasableCustomers(ctx context.Context, deletedBefore time.Time, limit int) (customerUUIDs []string, err error) {
+	query := `SELECT customer_uuid FROM customers WHERE deleted_at < ? AND erased_at IS NULL ORDER BY deleted_at LIMIT ?`
+
+	ctx, span := tracing.StartSQL(ctx, "ErasableCustomers", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, deletedBefore, limit)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ErasableCustomers: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	customerUUIDs = []string{}
+	for rows.Next() {
+		var customerUUID string
+		if err = rows.Scan(&customerUUID); err != nil {
+			logging.FromContext(ctx).Errorf("ErasableCustomers: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		customerUUIDs = append(customerUUIDs, customerUUID)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("ErasableCustomers: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return customerUUIDs, nil
+}
+
+// scrubEvents reduces the payload of the customer's events, queued or already
+// handed to webhooks, to the customer UUID like a deletion event. Webhook
+// bodies are sealed by pii, they are opened and rewritten here.
+func scrubEvents(ctx context.Context, tx *sql.Tx, pii *PII, customerUUID string) error {
+	payload, err := json.Marshal(entity.Customer{CustomerUUID: customerUUID})
+	if err != nil {
+		return err
+	}
+
+	query := `SELECT id, payload FROM webhook_deliveries
+	          WHERE event_uuid IN (SELECT event_uuid FROM outbox WHERE customer_uuid = ?) FOR UPDATE`
+
+	spanCtx, span := tracing.StartSQL(ctx, "ScrubWebhookDeliveries", query)
+	rows, err := tx.QueryContext(spanCtx, query, customerUUID)
+	if err != nil {
+		tracing.End(span, err)
+		logging.FromContext(ctx).Errorf("ScrubWebhookDeliveries: %s", err.Error())
+		return entity.ErrSQLError
+	}
+
+	type scrub struct {
+		id   int64
+		body string
+	}
+	var scrubbed []scrub
+	for rows.Next() {
+		var id int64
+		var body string
+		var event events.Event
+		if err = rows.Scan(&id, &body); err == nil {
+			body, err = pii.openValue(ctx, body)
+		}
+		if err == nil {
+			err = json.Unmarshal([]byte(body), &event)
+		}
+		if err != nil {
+			break
+		}
+		// Only the customer UUID is left, the body needs no sealing
+		event.Payload = payload
+		encoded, _ := json.Marshal(event)
+		scrubbed = append(scrubbed, scrub{id, string(encoded)})
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close() //nolint
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ScrubWebhookDeliveries: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	query = `UPDATE webhook_deliveries SET payload = ? WHERE id = ?`
+	for _, delivery := range scrubbed {
+		if err = txExec(ctx, tx, "ScrubWebhookDeliveries", query, delivery.body, delivery.id); err != nil {
+			return err
+		}
+	}
+	query = `UPDATE outbox SET payload = ? WHERE customer_uuid = ?`
+	return txExec(ctx, tx, "ScrubOutbox", query, string(payload), customerUUID)
+}
+
+// redactAudit replaces the values in the customer's audit trail with
+// entity.AuditRedacted, which fields changed when and by whom stays
+func redactAudit(ctx context.Context, tx *sql.Tx, customerUUID string) error {
+	query := `SELECT id, changes FROM customer_audit WHERE customer_uuid = ? FOR UPDATE`
+
+	spanCtx, span := tracing.StartSQL(ctx, "RedactAudit", query)
+	rows, err := tx.QueryContext(spanCtx, query, customerUUID)
+	if err != nil {
+		tracing.End(span, err)
+		logging.FromContext(ctx).Errorf("RedactAudit: %s", err.Error())
+		return entity.ErrSQLError
+	}
+
+	type redaction struct {
+		id      int64
+		changes string
+	}
+	var redacted []redaction
+	for rows.Next() {
+		var id int64
+		var body string
+		var changes []entity.FieldChange
+		if err = rows.Scan(&id, &body); err == nil {
+			err = json.Unmarshal([]byte(body), &changes)
+		}
+		if err != nil {
+			break
+		}
+		encoded, _ := json.Marshal(entity.RedactChanges(changes))
+		redacted = append(redacted, redaction{id, string(encoded)})
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close() //nolint
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RedactAudit: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	// The connection is free again once rows is closed
+	query = `UPDATE customer_audit SET changes = ? WHERE id = ?`
+	for _, entry := range redacted {
+		if err = txExec(ctx, tx, "RedactAudit", query, entry.changes, entry.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+)
+
+// maxExportError longest failure message kept on an export job
+const maxExportError = 255
+
+// exportColumns columns read by scanExport
+const exportColumns = "export_uuid, customer_uuid, format, status, error, created_at, completed_at, expires_at"
+
+// MySQLExportRepository customer_exports table access
+type MySQLExportRepository struct {
+	db *sql.DB
+
+	// PII seals the bundles, plain text when nil
+	PII *PII
+}
+
+// NewMySQLExportRepository export repository sharing the customers connection pool
+func NewMySQLExportRepository(db *sql.DB) *MySQLExportRepository {
+	return &MySQLExportRepository{db: db}
+}
+
+// StoreExport queues a pending export job
+func (repo *MySQLExportRepository) StoreExport(ctx context.Context, job entity.ExportJob) error {
+	query := `INSERT INTO customer_exports (export_uuid, customer_uuid, format, status, created_at) VALUES (?, ?, ?, ?, ?)`
+
+	ctx, span := tracing.StartSQL(ctx, "StoreExport", query)
+	_, err := repo.db.ExecContext(ctx, query, job.ExportUUID, job.CustomerUUID, job.Format, entity.ExportPending, job.CreatedAt)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StoreExport: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// GetExport unexpired export job of the customer
+func (repo *MySQLExportRepository) GetExport(ctx context.Context, customerUUID string, exportUUID string) (*entity.ExportJob, error) {
+	query := "SELECT " + exportColumns + ` FROM customer_exports
+	          WHERE export_uuid = ? AND customer_uuid = ? AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP) LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "GetExport", query)
+	job, err := scanExport(repo.db.QueryRowContext(ctx, query, exportUUID, customerUUID))
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetExport: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return job, nil
+}
+
+// GetExportBundle bundle of a ready, unexpired export job of the customer
+func (repo *MySQLExportRepository) GetExportBundle(ctx context.Context, customerUUID string, exportUUID string) ([]byte, error) {
+	query := `SELECT bundle FROM customer_exports
+	          WHERE export_uuid = ? AND customer_uuid = ? AND status = 'ready' AND expires_at > CURRENT_TIMESTAMP LIMIT 1`
+
+	var bundle []byte
+	ctx, span := tracing.StartSQL(ctx, "GetExportBundle", query)
+	err := repo.db.QueryRowContext(ctx, query, exportUUID, customerUUID).Scan(&bundle)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetExportBundle: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	opened, err := repo.PII.openValue(ctx, string(bundle))
+	if err != nil {
+		logging.FromContext(ctx).Errorf("GetExportBundle: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return []byte(opened), nil
+}
+
+// PendingExports export jobs waiting for their bundle, oldest first
+func (repo *MySQLExportRepository) PendingExports(ctx context.Context, limit int) (jobs []*entity.ExportJob, err error) {
+	query := "SELECT " + exportColumns + " FROM customer_exports WHERE status = 'pending' ORDER BY id LIMIT ?"
+
+	ctx, span := tracing.StartSQL(ctx, "PendingExports", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("PendingExports: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	jobs = []*entity.ExportJob{}
+	for rows.Next() {
+		job, err := scanExport(rows)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("PendingExports: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		jobs = append(jobs, job)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("PendingExports: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return jobs, nil
+}
+
+// CompleteExport stores the bundle of a pending job, a job completed
+// concurrently by another instance is left as it is
+func (repo *MySQLExportRepository) CompleteExport(ctx context.Context, exportUUID string, bundle []byte, expiresAt time.Time) error {
+	sealed, err := repo.PII.sealValue(ctx, string(bundle))
+	if err != nil {
+		logging.FromContext(ctx).Errorf("CompleteExport: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	query := `UPDATE customer_exports SET status = 'ready', bundle = ?, completed_at = CURRENT_TIMESTAMP, expires_at = ?
+	          WHERE export_uuid = ? AND status = 'pending' LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "CompleteExport", query)
+	_, err = repo.db.ExecContext(ctx, query, []byte(sealed), expiresAt, exportUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("CompleteExport: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// FailExport marks a pending job failed with message, it expires at expiresAt
+func (repo *MySQLExportRepository) FailExport(ctx context.Context, exportUUID string, message string, expiresAt time.Time) error {
+	if len(message) > maxExportError {
+		message = message[:maxExportError]
+	}
+	query := `UPDATE customer_exports SET status = 'failed', error = ?, completed_at = CURRENT_TIMESTAMP, expires_at = ?
+	          WHERE export_uuid = ? AND status = 'pending' LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "FailExport", query)
+	_, err := repo.db.ExecContext(ctx, query, message, expiresAt, exportUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("FailExport: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// DeleteExpiredExports removes the jobs and bundles past their expiry
+func (repo *MySQLExportRepository) DeleteExpiredExports(ctx context.Context) (int64, error) {
+	query := `DELETE FROM customer_exports WHERE expires_at <= CURRENT_TIMESTAMP`
+
+	ctx, span := tracing.StartSQL(ctx, "DeleteExpiredExports", query)
+	result, err := repo.db.ExecContext(ctx, query)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("DeleteExpiredExports: %s", err.Error())
+		return 0, entity.ErrSQLError
+	}
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// scanExport reads one export job row selected with exportColumns
+func scanExport(row rowScanner) (*entity.ExportJob, error) {
+	job := &entity.ExportJob{}
+	var message sql.NullString
+	var completedAt, expiresAt sql.NullTime
+
+	err := row.Scan(
+		&job.ExportUUID,
+		&job.CustomerUUID,
+		&job.Format,
+		&job.Status,
+		&message,
+		&job.CreatedAt,
+		&completedAt,
+		&expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Error = message.String
+	job.CompletedAt = nullTime(completedAt)
+	job.ExpiresAt = nullTime(expiresAt)
+	return job, nil
+}
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+)
+
+// MySQLMFARepository mfa_totp and mfa_recovery_codes access
+type MySQLMFARepository struct {
+	db *sql.DB
+}
+
+// NewMySQLMFARepository MFA repository sharing the customers connection pool
+func NewMySQLMFARepository(db *sql.DB) *MySQLMFARepository {
+	return &MySQLMFARepository{db}
+}
+
+// GetTOTP stored factor of the customer
+func (repo *MySQLMFARepository) GetTOTP(ctx context.Context, customerUUID string) (*entity.TOTPFactor, error) {
+	query := `SELECT secret, confirmed_at IS NOT NULL, last_used_step FROM mfa_totp WHERE customer_uuid = ? LIMIT 1`
+
+	factor := &entity.TOTPFactor{}
+
+	ctx, span := tracing.StartSQL(ctx, "GetTOTP", query)
+	err := repo.db.QueryRowContext(ctx, query, customerUUID).Scan(&factor.Secret, &factor.Confirmed, &factor.LastUsedStep)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetTOTP: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return factor, nil
+}
+
+// StoreTOTP saves a pending secret, a confirmed factor is never overwritten
+func (repo *MySQLMFARepository) StoreTOTP(ctx context.Context, customerUUID string, secret string) error {
+	query := `INSERT INTO mfa_totp (customer_uuid, secret, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+	          ON DUPLICATE KEY UPDATE
+	          created_at = IF(confirmed_at IS NULL, CURRENT_TIMESTAMP, created_at),
+	          secret = IF(confirmed_at IS NULL, VALUES(secret), secret)`
+
+	ctx, span := tracing.StartSQL(ctx, "StoreTOTP", query)
+	result, err := repo.db.ExecContext(ctx, query, customerUUID, secret)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StoreTOTP: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	// 1 inserted, 2 replaced a pending secret, 0 left a confirmed factor alone
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// ConfirmTOTP enables the pending factor and replaces the recovery codes in one transaction
+func (repo *MySQLMFARepository) ConfirmTOTP(ctx context.Context, customerUUID string, step int64, recoveryHashes []string) (err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ConfirmTOTP: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	defer func() { err = finishTx(ctx, tx, "ConfirmTOTP", err) }()
+
+	query := `UPDATE mfa_totp SET confirmed_at = CURRENT_TIMESTAMP, last_used_step = ? WHERE customer_uuid = ? AND confirmed_at IS NULL LIMIT 1`
+
+	spanCtx, span := tracing.StartSQL(ctx, "ConfirmTOTP", query)
+	result, err := tx.ExecContext(spanCtx, query, step, customerUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ConfirmTOTP: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrMFAAlreadyEnabled
+	}
+
+	query = `DELETE FROM mfa_recovery_codes WHERE customer_uuid = ?`
+	if err = txExec(ctx, tx, "DeleteRecoveryCodes", query, customerUUID); err != nil {
+		return err
+	}
+	query = `INSERT INTO mfa_recovery_codes (customer_uuid, code_hash, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`
+	for _, codeHash := range recoveryHashes {
+		if err = txExec(ctx, tx, "StoreRecoveryCode", query, customerUUID, codeHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UseTOTPStep moves last_used_step forward, only one concurrent use of a step succeeds
+func (repo *MySQLMFARepository) UseTOTPStep(ctx context.Context, customerUUID string, step int64) error {
+	query := `UPDATE mfa_totp SET last_used_step = ? WHERE customer_uuid = ? AND confirmed_at IS NOT NULL AND last_used_step < ? LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "UseTOTPStep", query)
+	result, err := repo.db.ExecContext(ctx, query, step, customerUUID, step)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("UseTOTPStep: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// UseRecoveryCode spends an unused recovery code of the customer
+func (repo *MySQLMFARepository) UseRecoveryCode(ctx context.Context, customerUUID string, codeHash string) error {
+	query := `UPDATE mfa_recovery_codes SET used_at = CURRENT_TIMESTAMP WHERE customer_uuid = ? AND code_hash = ? AND used_at IS NULL LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "UseRecoveryCode", query)
+	result, err := repo.db.ExecContext(ctx, query, customerUUID, codeHash)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("UseRecoveryCode: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrMFACodeInvalid
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/events"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/tracing"
+	"time"
+)
+
+// maxOutboxError longest publish error kept on an outbox_sinks row
+const maxOutboxError = 255
+
+// MySQLOutboxRepository outbox table access for the event relays and the tail
+type MySQLOutboxRepository struct {
+	db *sql.DB
+
+	// PII decrypts the event payloads, plain text when nil
+	PII *PII
+	// Sinks every record is queued for, each one relayed through Sink
+	Sinks []string
+}
+
+// NewMySQLOutboxRepository outbox repository sharing the customers connection pool
+func NewMySQLOutboxRepository(db *sql.DB) *MySQLOutboxRepository {
+	return &MySQLOutboxRepository{db: db}
+}
+
+// Sink events.Outbox of one of Sinks, with its own lease, attempts and dead
+// letters so a failing sink neither holds up nor repeats the others
+func (repo *MySQLOutboxRepository) Sink(name string) *MySQLOutboxSink {
+	return &MySQLOutboxSink{repo: repo, name: name}
+}
+
+// queue copies records not queued yet into outbox_sinks, one row per sink, and
+// stamps them queued_at. Relays of all sinks call it, the row locks and
+// INSERT IGNORE keep a record from being queued twice.
+func (repo *MySQLOutboxRepository) queue(ctx context.Context, limit int) (err error) {
+	if len(repo.Sinks) == 0 {
+		return nil
+	}
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("QueueOutbox: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	defer func() { err = finishTx(ctx, tx, "QueueOutbox", err) }()
+
+	query := `SELECT id, customer_uuid FROM outbox WHERE queued_at IS NULL ORDER BY id LIMIT ? FOR UPDATE`
+
+	spanCtx, span := tracing.StartSQL(ctx, "QueueOutbox", query)
+	rows, err := tx.QueryContext(spanCtx, query, limit)
+	tracing.End(span, err)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("QueueOutbox: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	var ids []interface{}
+	var values []string
+	var args []interface{}
+	for rows.Next() {
+		var id int64
+		var customerUUID string
+		if err = rows.Scan(&id, &customerUUID); err != nil {
+			rows.Close() //nolint
+			logging.FromContext(ctx).Errorf("QueueOutbox: %s", err.Error())
+			return entity.ErrSQLError
+		}
+		ids = append(ids, id)
+		for _, sink := range repo.Sinks {
+			values = append(values, "(?, ?, ?)")
+			args = append(args, sink, id, customerUUID)
+		}
+	}
+	err = rows.Err()
+	rows.Close() //nolint
+	if err != nil {
+		logging.FromContext(ctx).Errorf("QueueOutbox: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query = `INSERT IGNORE INTO outbox_sinks (sink, outbox_id, customer_uuid) VALUES ` + strings.Join(values, ", ")
+	if err = txExec(ctx, tx, "QueueOutboxSinks", query, args...); err != nil {
+		return err
+	}
+	query = `UPDATE outbox SET queued_at = CURRENT_TIMESTAMP WHERE id IN (?` + strings.Repeat(", ?", len(ids)-1) + `)`
+	return txExec(ctx, tx, "MarkOutboxQueued", query, ids...)
+}
+
+// LastID highest outbox record ID, zero when the outbox is empty
+func (repo *MySQLOutboxRepository) LastID(ctx context.Context) (id int64, err error) {
+	query := `SELECT COALESCE(MAX(id), 0) FROM outbox`
+
+	ctx, span := tracing.StartSQL(ctx, "LastID", query)
+	defer func() { tracing.End(span, err) }()
+
+	if err = repo.db.QueryRowContext(ctx, query).Scan(&id); err != nil {
+		logging.FromContext(ctx).Errorf("LastID: %s", err.Error())
+		return 0, entity.ErrSQLError
+	}
+	return id, nil
+}
+
+// After records with an ID above afterID whether published or not, oldest first.
+// Records whose payload cannot be decrypted are left out.
+func (repo *MySQLOutboxRepository) After(ctx context.Context, afterID int64, limit int) ([]events.Record, error) {
+	query := `SELECT id, event_uuid, event_type, customer_uuid, payload, occurred_at, attempts
+	          FROM outbox WHERE id > ? ORDER BY id LIMIT ?`
+	records, _, err := repo.queryRecords(ctx, "After", query, afterID, limit)
+	return records, err
+}
+
+// queryRecords runs a query selecting outbox records, the IDs of records
+// whose payload cannot be decrypted are returned apart instead of failing the batch
+func (repo *MySQLOutboxRepository) queryRecords(ctx context.Context, operation string, query string, args ...interface{}) (records []events.Record, unreadable []int64, err error) {
+	ctx, span := tracing.StartSQL(ctx, operation, query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("%s: %s", operation, err.Error())
+		return nil, nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	for rows.Next() {
+		var record events.Record
+		var payload string
+		if err = rows.Scan(&record.ID, &record.Event.ID, &record.Event.Type, &record.Event.CustomerUUID, &payload, &record.Event.OccurredAt, &record.Attempts); err != nil {
+			logging.FromContext(ctx).Errorf("%s: %s", operation, err.Error())
+			return nil, nil, entity.ErrSQLError
+		}
+		opened, openErr := repo.PII.openValue(ctx, payload)
+		if openErr != nil {
+			logging.FromContext(ctx).Errorf("%s: event %s: %s", operation, record.Event.ID, openErr.Error())
+			unreadable = append(unreadable, record.ID)
+			continue
+		}
+		record.Event.Payload = []byte(opened)
+		record.Event.OccurredAt = record.Event.OccurredAt.UTC()
+		records = append(records, record)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("%s: %s", operation, err.Error())
+		return nil, nil, entity.ErrSQLError
+	}
+	return records, unreadable, nil
+}
+
+// MySQLOutboxSink progress of one sink through the outbox, implements events.Outbox
+type MySQLOutboxSink struct {
+	repo *MySQLOutboxRepository
+	name string
+}
+
+// Claim takes the sink's relay lease for owner when it is free or expired, or
+// renews it when owner already holds it
+func (sink *MySQLOutboxSink) Claim(ctx context.Context, owner string, lease time.Duration) (held bool, err error) {
+	query := `UPDATE outbox_relay SET owner = ?, lease_until = DATE_ADD(NOW(6), INTERVAL ? MICROSECOND)
+	          WHERE sink = ? AND (owner = ? OR lease_until < NOW(6))`
+
+	ctx, span := tracing.StartSQL(ctx, "Claim", query)
+	defer func() { tracing.End(span, err) }()
+
+	result, err := sink.repo.db.ExecContext(ctx, query, owner, lease.Microseconds(), sink.name, owner)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Claim: %s", err.Error())
+		return false, entity.ErrSQLError
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Claim: %s", err.Error())
+		return false, entity.ErrSQLError
+	}
+	return affected == 1, nil
+}
+
+// Pending records the sink has not published yet that are due, oldest first,
+// leaving out the customers whose older record waits for a retry. New records
+// are queued first. Records whose payload cannot be decrypted are
+// dead-lettered, retrying would not help them.
+func (sink *MySQLOutboxSink) Pending(ctx context.Context, limit int) ([]events.Record, error) {
+	if err := sink.repo.queue(ctx, limit); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT o.id, o.event_uuid, o.event_type, o.customer_uuid, o.payload, o.occurred_at, s.attempts
+	          FROM outbox_sinks s
+	          JOIN outbox o ON o.id = s.outbox_id
+	          WHERE s.sink = ? AND s.status = 'pending' AND s.next_attempt_at <= NOW(6)
+	            AND NOT EXISTS (
+	              SELECT 1 FROM outbox_sinks f
+	              WHERE f.sink = s.sink AND f.customer_uuid = s.customer_uuid AND f.status = 'pending'
+	                AND f.attempts > 0 AND f.outbox_id < s.outbox_id)
+	          ORDER BY s.outbox_id LIMIT ?`
+	records, unreadable, err := sink.repo.queryRecords(ctx, "Pending", query, sink.name, limit)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range unreadable {
+		if err = sink.MarkDead(ctx, id, "payload cannot be decrypted"); err != nil {
+			return nil, err
+		}
+		metrics.OutboxEvents.WithLabelValues(sink.name, "dead").Inc()
+	}
+	return records, nil
+}
+
+// MarkPublished records that the sink accepted the record
+func (sink *MySQLOutboxSink) MarkPublished(ctx context.Context, id int64) error {
+	query := `UPDATE outbox_sinks SET status = 'published', attempts = attempts + 1, updated_at = CURRENT_TIMESTAMP
+	          WHERE sink = ? AND outbox_id = ? LIMIT 1`
+	return sink.repo.exec(ctx, "MarkPublished", query, sink.name, id)
+}
+
+// MarkFailed counts a failed publish, the record is due again after retryIn
+func (sink *MySQLOutboxSink) MarkFailed(ctx context.Context, id int64, reason string, retryIn time.Duration) error {
+	query := `UPDATE outbox_sinks SET attempts = attempts + 1, last_error = ?, next_attempt_at = DATE_ADD(NOW(6), INTERVAL ? MICROSECOND),
+	          updated_at = CURRENT_TIMESTAMP
+	          WHERE sink = ? AND outbox_id = ? LIMIT 1`
+	return sink.repo.exec(ctx, "MarkFailed", query, truncateOutboxError(reason), retryIn.Microseconds(), sink.name, id)
+}
+
+// MarkDead dead-letters the record, the sink never publishes it
+func (sink *MySQLOutboxSink) MarkDead(ctx context.Context, id int64, reason string) error {
+	query := `UPDATE outbox_sinks SET status = 'dead', attempts = attempts + 1, last_error = ?, updated_at = CURRENT_TIMESTAMP
+	          WHERE sink = ? AND outbox_id = ? LIMIT 1`
+	return sink.repo.exec(ctx, "MarkDead", query, truncateOutboxError(reason), sink.name, id)
+}
+
+// truncateOutboxError reason cut to the last_error column
+func truncateOutboxError(reason string) string {
+	if len(reason) > maxOutboxError {
+		return reason[:maxOutboxError]
+	}
+	return reason
+}
+
+// exec runs a statement that needs no result
+func (repo *MySQLOutboxRepository) exec(ctx context.Context, operation string, query string, args ...interface{}) error {
+	ctx, span := tracing.StartSQL(ctx, operation, query)
+	_, err := repo.db.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("%s: %s", operation, err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// insertEvent queues eventType about customer in tx, the payload never carries
+// the password and is sealed by pii like the customers columns
+func insertEvent(ctx context.Context, tx *sql.Tx, pii *PII, eventType string, customer entity.Customer) error {
+	event, err := events.New(eventType, customer.CustomerUUID, customer.Safe())
+	if err != nil {
+		logging.FromContext(ctx).Errorf("insertEvent: %s", err.Error())
+		return err
+	}
+	payload, err := pii.sealValue(ctx, string(event.Payload))
+	if err != nil {
+		logging.FromContext(ctx).Errorf("insertEvent: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	query := `INSERT INTO outbox (event_uuid, event_type, customer_uuid, payload, occurred_at) VALUES (?, ?, ?, ?, ?)`
+	return txExec(ctx, tx, "insertEvent", query, event.ID, event.Type, event.CustomerUUID, payload, event.OccurredAt)
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+)
+
+// MySQLPasswordRepository customers.password and password_resets access
+type MySQLPasswordRepository struct {
+	db *sql.DB
+
+	// PII decrypts the customers email, plain text when nil
+	PII *PII
+}
+
+// NewMySQLPasswordRepository password repository sharing the customers connection pool
+func NewMySQLPasswordRepository(db *sql.DB) *MySQLPasswordRepository {
+	return &MySQLPasswordRepository{db: db}
+}
+
+// GetUUIDByEmail active customer owning email
+func (repo *MySQLPasswordRepository) GetUUIDByEmail(ctx context.Context, email string) (string, error) {
+	condition, args := repo.PII.match("email", email)
+	query := `SELECT customer_uuid FROM customers WHERE ` + condition + ` AND deleted_at IS NULL LIMIT 1`
+
+	var customerUUID string
+
+	ctx, span := tracing.StartSQL(ctx, "GetUUIDByEmail", query)
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(&customerUUID)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetUUIDByEmail: %s", err.Error())
+		return "", entity.ErrSQLError
+	}
+	return customerUUID, nil
+}
+
+// GetPasswordHash stored password hash and email of an active customer
+func (repo *MySQLPasswordRepository) GetPasswordHash(ctx context.Context, customerUUID string) (string, string, error) {
+	query := `SELECT password, email FROM customers WHERE customer_uuid = ? AND deleted_at IS NULL LIMIT 1`
+
+	var passwordHash, email string
+
+	ctx, span := tracing.StartSQL(ctx, "GetPasswordHash", query)
+	err := repo.db.QueryRowContext(ctx, query, customerUUID).Scan(&passwordHash, &email)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetPasswordHash: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	if email, err = repo.PII.openValue(ctx, email); err != nil {
+		logging.FromContext(ctx).Errorf("GetPasswordHash: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	return passwordHash, email, nil
+}
+
+// UpdatePassword stores an already hashed password
+func (repo *MySQLPasswordRepository) UpdatePassword(ctx context.Context, customerUUID string, passwordHash string) error {
+	query := `UPDATE customers SET password = ?, updated_at = CURRENT_TIMESTAMP WHERE customer_uuid = ? AND deleted_at IS NULL LIMIT 1`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("UpdatePassword: %s", err.Error())
+		return entity.ErrSQLError
+	}
+
+	spanCtx, span := tracing.StartSQL(ctx, "UpdatePassword", query)
+	result, err := tx.ExecContext(spanCtx, query, passwordHash, customerUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("UpdatePassword: %s", err.Error())
+		return finishTx(ctx, tx, "UpdatePassword", entity.ErrSQLError)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return finishTx(ctx, tx, "UpdatePassword", entity.ErrNotFound)
+	}
+	changes := []entity.FieldChange{{Field: "password", Before: entity.AuditRedacted, After: entity.AuditRedacted}}
+	return finishTx(ctx, tx, "UpdatePassword", insertAudit(ctx, tx, customerUUID, entity.AuditPasswordChanged, changes))
+}
+
+// StoreResetToken saves a token hash valid for ttl, expiry uses the database clock
+func (repo *MySQLPasswordRepository) StoreResetToken(ctx context.Context, customerUUID string, tokenHash string, ttl time.Duration) error {
+	query := `INSERT INTO password_resets (token_hash, customer_uuid, expires_at, created_at)
+	          VALUES (?, ?, DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND), CURRENT_TIMESTAMP)`
+
+	ctx, span := tracing.StartSQL(ctx, "StoreResetToken", query)
+	_, err := repo.db.ExecContext(ctx, query, tokenHash, customerUUID, int(ttl.Seconds()))
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StoreResetToken: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// FindResetToken customer UUID and email of an unused, unexpired token
+func (repo *MySQLPasswordRepository) FindResetToken(ctx context.Context, tokenHash string) (string, string, error) {
+	query := `SELECT c.customer_uuid, c.email
+	          FROM password_resets r
+	          JOIN customers c ON c.customer_uuid = r.customer_uuid AND c.deleted_at IS NULL
+	          WHERE r.token_hash = ? AND r.used_at IS NULL AND r.expires_at > CURRENT_TIMESTAMP
+	          LIMIT 1`
+
+	var customerUUID, email string
+
+	ctx, span := tracing.StartSQL(ctx, "FindResetToken", query)
+	err := repo.db.QueryRowContext(ctx, query, tokenHash).Scan(&customerUUID, &email)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", entity.ErrResetTokenInvalid
+		}
+		logging.FromContext(ctx).Errorf("FindResetToken: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	if email, err = repo.PII.openValue(ctx, email); err != nil {
+		logging.FromContext(ctx).Errorf("FindResetToken: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	return customerUUID, email, nil
+}
+
+// ConsumeResetToken spends the token, only one concurrent caller succeeds
+func (repo *MySQLPasswordRepository) ConsumeResetToken(ctx context.Context, tokenHash string) error {
+	query := `UPDATE password_resets SET used_at = CURRENT_TIMESTAMP
+	          WHERE token_hash = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "ConsumeResetToken", query)
+	result, err := repo.db.ExecContext(ctx, query, tokenHash)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ConsumeResetToken: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrResetTokenInvalid
+	}
+	return nil
+}
+
+// RevokeResetTokens spends every pending token of the customer
+func (repo *MySQLPasswordRepository) RevokeResetTokens(ctx context.Context, customerUUID string) error {
+	query := `UPDATE password_resets SET used_at = CURRENT_TIMESTAMP WHERE customer_uuid = ? AND used_at IS NULL`
+
+	ctx, span := tracing.StartSQL(ctx, "RevokeResetTokens", query)
+	_, err := repo.db.ExecContext(ctx, query, customerUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RevokeResetTokens: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
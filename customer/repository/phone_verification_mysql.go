@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+)
+
+// MySQLPhoneVerificationRepository customers.phone_verified_at and phone_verifications access
+type MySQLPhoneVerificationRepository struct {
+	db *sql.DB
+
+	// PII decrypts and matches the customers phone and seals the phone a code
+	// was sent to, plain text when nil
+	PII *PII
+}
+
+// NewMySQLPhoneVerificationRepository phone verification repository sharing the customers connection pool
+func NewMySQLPhoneVerificationRepository(db *sql.DB) *MySQLPhoneVerificationRepository {
+	return &MySQLPhoneVerificationRepository{db: db}
+}
+
+// GetPhone phone of an active customer and whether it is verified
+func (repo *MySQLPhoneVerificationRepository) GetPhone(ctx context.Context, customerUUID string) (string, bool, error) {
+	query := `SELECT phone, phone_verified_at IS NOT NULL FROM customers WHERE customer_uuid = ? AND deleted_at IS NULL LIMIT 1`
+
+	var phone string
+	var verified bool
+
+	ctx, span := tracing.StartSQL(ctx, "GetPhone", query)
+	err := repo.db.QueryRowContext(ctx, query, customerUUID).Scan(&phone, &verified)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", false, entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetPhone: %s", err.Error())
+		return "", false, entity.ErrSQLError
+	}
+	if phone, err = repo.PII.openValue(ctx, phone); err != nil {
+		logging.FromContext(ctx).Errorf("GetPhone: %s", err.Error())
+		return "", false, entity.ErrSQLError
+	}
+	return phone, verified, nil
+}
+
+// StorePhoneCode replaces the pending code of the customer and resets its attempts, expiry uses the database clock
+func (repo *MySQLPhoneVerificationRepository) StorePhoneCode(ctx context.Context, customerUUID string, phone string, codeHash string, ttl time.Duration) error {
+	phone, err := repo.PII.sealValue(ctx, phone)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StorePhoneCode: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	query := `INSERT INTO phone_verifications (customer_uuid, phone, code_hash, attempts, expires_at, used_at, created_at)
+	          VALUES (?, ?, ?, 0, DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND), NULL, CURRENT_TIMESTAMP)
+	          ON DUPLICATE KEY UPDATE phone = VALUES(phone), code_hash = VALUES(code_hash), attempts = 0,
+	          expires_at = VALUES(expires_at), used_at = NULL, created_at = CURRENT_TIMESTAMP`
+
+	ctx, span := tracing.StartSQL(ctx, "StorePhoneCode", query)
+	_, err = repo.db.ExecContext(ctx, query, customerUUID, phone, codeHash, int(ttl.Seconds()))
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StorePhoneCode: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// RegisterPhoneCodeAttempt counts a guess before it is checked, so concurrent
+// guesses cannot exceed maxAttempts
+func (repo *MySQLPhoneVerificationRepository) RegisterPhoneCodeAttempt(ctx context.Context, customerUUID string, maxAttempts int) error {
+	query := `UPDATE phone_verifications SET attempts = attempts + 1
+	          WHERE customer_uuid = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP AND attempts < ? LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "RegisterPhoneCodeAttempt", query)
+	result, err := repo.db.ExecContext(ctx, query, customerUUID, maxAttempts)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RegisterPhoneCodeAttempt: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrVerificationCodeInvalid
+	}
+	return nil
+}
+
+// FindPhoneCode phone and code hash of the unused, unexpired code
+func (repo *MySQLPhoneVerificationRepository) FindPhoneCode(ctx context.Context, customerUUID string) (string, string, error) {
+	query := `SELECT phone, code_hash FROM phone_verifications
+	          WHERE customer_uuid = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	          LIMIT 1`
+
+	var phone, codeHash string
+
+	ctx, span := tracing.StartSQL(ctx, "FindPhoneCode", query)
+	err := repo.db.QueryRowContext(ctx, query, customerUUID).Scan(&phone, &codeHash)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", "", entity.ErrVerificationCodeInvalid
+		}
+		logging.FromContext(ctx).Errorf("FindPhoneCode: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	if phone, err = repo.PII.openValue(ctx, phone); err != nil {
+		logging.FromContext(ctx).Errorf("FindPhoneCode: %s", err.Error())
+		return "", "", entity.ErrSQLError
+	}
+	return phone, codeHash, nil
+}
+
+// ConsumePhoneCode spends the pending code, only one concurrent caller succeeds
+func (repo *MySQLPhoneVerificationRepository) ConsumePhoneCode(ctx context.Context, customerUUID string) error {
+	query := `UPDATE phone_verifications SET used_at = CURRENT_TIMESTAMP
+	          WHERE customer_uuid = ? AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "ConsumePhoneCode", query)
+	result, err := repo.db.ExecContext(ctx, query, customerUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ConsumePhoneCode: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrVerificationCodeInvalid
+	}
+	return nil
+}
+
+// MarkPhoneVerified sets phone_verified_at, a code sent to a since replaced phone verifies nothing
+func (repo *MySQLPhoneVerificationRepository) MarkPhoneVerified(ctx context.Context, customerUUID string, phone string) error {
+	condition, args := repo.PII.match("phone", phone)
+	query := `UPDATE customers SET phone_verified_at = COALESCE(phone_verified_at, CURRENT_TIMESTAMP)
+	          WHERE customer_uuid = ? AND ` + condition + ` AND deleted_at IS NULL LIMIT 1`
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("MarkPhoneVerified: %s", err.Error())
+		return entity.ErrSQLError
+	}
+
+	spanCtx, span := tracing.StartSQL(ctx, "MarkPhoneVerified", query)
+	result, err := tx.ExecContext(spanCtx, query, append([]interface{}{customerUUID}, args...)...)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("MarkPhoneVerified: %s", err.Error())
+		return finishTx(ctx, tx, "MarkPhoneVerified", entity.ErrSQLError)
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return finishTx(ctx, tx, "MarkPhoneVerified", entity.ErrVerificationCodeInvalid)
+	}
+	// The verified flag is part of the record the change feed carries
+	if err = stampChange(ctx, tx, customerUUID, entity.ChangeUpdate); err != nil {
+		return finishTx(ctx, tx, "MarkPhoneVerified", err)
+	}
+	return finishTx(ctx, tx, "MarkPhoneVerified", insertAudit(ctx, tx, customerUUID, entity.AuditPhoneVerified, fieldSet("phone_verified_at")))
+}
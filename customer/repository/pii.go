@@ -0,0 +1,302 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"svc-customer/customer/entity"
+	"svc-customer/encrypt"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+)
+
+// piiColumns customers columns encrypted by PII, each with a <column>_bidx
+// blind index
+var piiColumns = []string{"dni", "email", "phone"}
+
+// sealedCopies column of each table holding a copy of customer PII sealed
+// whole with the envelope
+var sealedCopies = map[string]string{
+	"outbox":              "payload",
+	"webhook_deliveries":  "payload",
+	"customer_exports":    "bundle",
+	"email_verifications": "email",
+	"phone_verifications": "phone",
+}
+
+// PIITables tables ReencryptPII rewrites, in the order a rotation walks them
+var PIITables = []string{"customers", "outbox", "webhook_deliveries", "customer_exports", "email_verifications", "phone_verifications"}
+
+// ErrUnknownPIITable the table is not one of PIITables
+var ErrUnknownPIITable = errors.New("unknown PII table")
+
+// PII encrypts the dni, email and phone columns of customers and keeps the
+// blind index equality lookups and uniqueness checks run against. A nil *PII
+// leaves the columns in plain text.
+type PII struct {
+	Envelope *encrypt.Envelope
+	Index    *encrypt.BlindIndex
+}
+
+// PIIFromEnv PII with the master keys of the PII_KEYFILE key file and the
+// blind index key PII_INDEX_KEY, encrypt.ErrNoKey when PII_KEYFILE is unset
+func PIIFromEnv() (*PII, error) {
+	path := os.Getenv("PII_KEYFILE")
+	if path == "" {
+		return nil, encrypt.ErrNoKey
+	}
+	keys, err := encrypt.LoadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+	index, err := encrypt.BlindIndexFromEnv("PII_INDEX_KEY")
+	if err != nil {
+		return nil, fmt.Errorf("PII_INDEX_KEY: %w", err)
+	}
+	return &PII{Envelope: encrypt.NewEnvelope(keys), Index: index}, nil
+}
+
+// piiFields the PII fields of customer in piiColumns order
+func piiFields(customer *entity.Customer) []*string {
+	return []*string{&customer.Dni, &customer.Email, &customer.Phone}
+}
+
+// match condition and arguments finding value in column. Rows written before
+// encryption was enabled have no blind index until rotate-keys runs, they
+// still compare in plain text.
+func (p *PII) match(column string, value string) (string, []interface{}) {
+	if p == nil {
+		return column + " = ?", []interface{}{value}
+	}
+	condition := fmt.Sprintf("(%[1]s_bidx = ? OR (%[1]s_bidx IS NULL AND %[1]s = ?))", column)
+	return condition, []interface{}{p.Index.Sum(column, value), value}
+}
+
+// seal customer with its PII fields encrypted, and their blind indexes in
+// piiColumns order, nil for fields that are not set
+func (p *PII) seal(ctx context.Context, customer entity.Customer) (entity.Customer, []interface{}, error) {
+	indexes := make([]interface{}, len(piiColumns))
+	if p == nil {
+		return customer, indexes, nil
+	}
+	for i, field := range piiFields(&customer) {
+		if *field == "" {
+			continue
+		}
+		sealed, index, err := p.sealColumn(ctx, piiColumns[i], *field)
+		if err != nil {
+			return customer, nil, err
+		}
+		*field, indexes[i] = sealed, index
+	}
+	return customer, indexes, nil
+}
+
+// sealColumn value of column encrypted with its blind index, value as it is
+// and a nil index when p is nil
+func (p *PII) sealColumn(ctx context.Context, column string, value string) (string, interface{}, error) {
+	if p == nil {
+		return value, nil, nil
+	}
+	sealed, err := p.Envelope.Encrypt(ctx, value)
+	if err != nil {
+		return "", nil, err
+	}
+	return sealed, p.Index.Sum(column, value), nil
+}
+
+// open decrypts the PII fields of customer in place
+func (p *PII) open(ctx context.Context, customer *entity.Customer) error {
+	for _, field := range piiFields(customer) {
+		value, err := p.openValue(ctx, *field)
+		if err != nil {
+			return err
+		}
+		*field = value
+	}
+	return nil
+}
+
+// sealValue encrypts a single value holding PII, such as an event payload
+func (p *PII) sealValue(ctx context.Context, value string) (string, error) {
+	if p == nil {
+		return value, nil
+	}
+	return p.Envelope.Encrypt(ctx, value)
+}
+
+// openValue decrypts a single PII column value
+func (p *PII) openValue(ctx context.Context, value string) (string, error) {
+	if p == nil {
+		return value, nil
+	}
+	return p.Envelope.Decrypt(ctx, value)
+}
+
+// ReencryptPII re-encrypts under the active master key the PII of up to limit
+// rows of table after the row afterID, plain text rows from before encryption
+// included. Rows already under the active key are only read.
+func (repo *MySQLCustomersRepository) ReencryptPII(ctx context.Context, table string, afterID int64, limit int) (entity.KeyRotation, error) {
+	if repo.PII == nil {
+		return entity.KeyRotation{Table: table, LastID: afterID}, encrypt.ErrNoKey
+	}
+	if table == "customers" {
+		return repo.reencryptCustomers(ctx, afterID, limit)
+	}
+	column, ok := sealedCopies[table]
+	if !ok {
+		return entity.KeyRotation{Table: table, LastID: afterID}, ErrUnknownPIITable
+	}
+	return repo.reencryptSealed(ctx, table, column, afterID, limit)
+}
+
+// reencryptCustomers re-encrypts the dni, email and phone columns of customers
+// and refreshes their blind indexes
+func (repo *MySQLCustomersRepository) reencryptCustomers(ctx context.Context, afterID int64, limit int) (rotation entity.KeyRotation, err error) {
+	rotation.Table, rotation.LastID = "customers", afterID
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ReencryptPII: %s", err.Error())
+		return rotation, entity.ErrSQLError
+	}
+
+	query := `SELECT id, dni, email, phone FROM customers WHERE id > ? ORDER BY id LIMIT ? FOR UPDATE`
+	spanCtx, span := tracing.StartSQL(ctx, "ReencryptPII", query)
+	rows, err := tx.QueryContext(spanCtx, query, afterID, limit)
+	tracing.End(span, err)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ReencryptPII: %s", err.Error())
+		return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+	}
+
+	// Rows are read completely before the connection runs the updates
+	ids := []int64{}
+	stale := map[int64]*entity.Customer{}
+	for rows.Next() {
+		var id int64
+		customer := &entity.Customer{}
+		if err = rows.Scan(&id, &customer.Dni, &customer.Email, &customer.Phone); err != nil {
+			break
+		}
+		ids = append(ids, id)
+		for _, field := range piiFields(customer) {
+			if repo.PII.Envelope.NeedsRotation(*field) {
+				stale[id] = customer
+			}
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close() //nolint
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ReencryptPII: %s", err.Error())
+		return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+	}
+
+	query = `UPDATE customers SET dni = ?, email = ?, phone = ?, dni_bidx = ?, email_bidx = ?, phone_bidx = ? WHERE id = ? LIMIT 1`
+	for _, id := range ids {
+		customer, ok := stale[id]
+		if !ok {
+			continue
+		}
+		if err = repo.PII.open(ctx, customer); err != nil {
+			logging.FromContext(ctx).Errorf("ReencryptPII: row %d: %s", id, err.Error())
+			return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+		}
+		sealed, indexes, err := repo.PII.seal(ctx, *customer)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("ReencryptPII: row %d: %s", id, err.Error())
+			return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+		}
+		args := append([]interface{}{sealed.Dni, sealed.Email, sealed.Phone}, indexes...)
+		if err = txExec(ctx, tx, "ReencryptPII", query, append(args, id)...); err != nil {
+			return rotation, finishTx(ctx, tx, "ReencryptPII", err)
+		}
+	}
+	if err = finishTx(ctx, tx, "ReencryptPII", nil); err != nil {
+		return rotation, err
+	}
+
+	if len(ids) > 0 {
+		rotation.LastID = ids[len(ids)-1]
+	}
+	rotation.Scanned, rotation.Rewritten = len(ids), len(stale)
+	return rotation, nil
+}
+
+// reencryptSealed re-encrypts column of table, a value sealed whole such as an
+// event payload or an export bundle
+func (repo *MySQLCustomersRepository) reencryptSealed(ctx context.Context, table string, column string, afterID int64, limit int) (rotation entity.KeyRotation, err error) {
+	rotation.Table, rotation.LastID = table, afterID
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ReencryptPII: %s", err.Error())
+		return rotation, entity.ErrSQLError
+	}
+
+	query := fmt.Sprintf(`SELECT id, %[2]s FROM %[1]s WHERE id > ? AND %[2]s IS NOT NULL ORDER BY id LIMIT ? FOR UPDATE`, table, column)
+	spanCtx, span := tracing.StartSQL(ctx, "ReencryptPII", query)
+	rows, err := tx.QueryContext(spanCtx, query, afterID, limit)
+	tracing.End(span, err)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ReencryptPII: %s: %s", table, err.Error())
+		return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+	}
+
+	// Rows are read completely before the connection runs the updates
+	ids := []int64{}
+	stale := map[int64]string{}
+	for rows.Next() {
+		var id int64
+		var value string
+		if err = rows.Scan(&id, &value); err != nil {
+			break
+		}
+		ids = append(ids, id)
+		if repo.PII.Envelope.NeedsRotation(value) {
+			stale[id] = value
+		}
+	}
+	if err == nil {
+		err = rows.Err()
+	}
+	rows.Close() //nolint
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ReencryptPII: %s: %s", table, err.Error())
+		return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+	}
+
+	query = fmt.Sprintf(`UPDATE %s SET %s = ? WHERE id = ? LIMIT 1`, table, column)
+	for _, id := range ids {
+		value, ok := stale[id]
+		if !ok {
+			continue
+		}
+		opened, err := repo.PII.openValue(ctx, value)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("ReencryptPII: %s row %d: %s", table, id, err.Error())
+			return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+		}
+		sealed, err := repo.PII.sealValue(ctx, opened)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("ReencryptPII: %s row %d: %s", table, id, err.Error())
+			return rotation, finishTx(ctx, tx, "ReencryptPII", entity.ErrSQLError)
+		}
+		if err = txExec(ctx, tx, "ReencryptPII", query, sealed, id); err != nil {
+			return rotation, finishTx(ctx, tx, "ReencryptPII", err)
+		}
+	}
+	if err = finishTx(ctx, tx, "ReencryptPII", nil); err != nil {
+		return rotation, err
+	}
+
+	if len(ids) > 0 {
+		rotation.LastID = ids[len(ids)-1]
+	}
+	rotation.Scanned, rotation.Rewritten = len(ids), len(stale)
+	return rotation, nil
+}
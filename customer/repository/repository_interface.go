@@ -1,14 +1,173 @@
 package repository
 
-import "svc-customer/customer/entity"
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"time"
+)
 
 // Repository interface to repository
 type Repository interface {
-	HealthCheck() error
-	GetByUUID(customerUUID string) (*entity.Customer, error)
-	Fetch(page int, limit int) ([]*entity.Customer, int, int, int, error)
-	Store(customer entity.Customer) error
-	UpdateByUUID(customer entity.Customer, customerUUID string) error
-	DeleteByUUID(customerUUID string) error
+	HealthCheck(ctx context.Context) error
+	GetByUUID(ctx context.Context, customerUUID string) (*entity.Customer, error)
+	Fetch(ctx context.Context, filter entity.CustomerFilter, page int, limit int) ([]*entity.Customer, int, int, int, error)
+	Store(ctx context.Context, customer entity.Customer) error
+	UpdateByUUID(ctx context.Context, customer entity.Customer, customerUUID string) error
+	DeleteByUUID(ctx context.Context, customerUUID string) error
+	// RestoreByUUID undoes a soft delete, ErrNotFound when the customer is not deleted
+	RestoreByUUID(ctx context.Context, customerUUID string) error
+	// Changes customers changed after sequence since in change order, deleted ones included
+	Changes(ctx context.Context, since int64, limit int) ([]*entity.CustomerChange, error)
 	// RequestCustomerToken(email string, password string) (entity.Customer, error)
 }
+
+// APIKeyRepository persistence of hashed service-to-service API keys
+type APIKeyRepository interface {
+	Store(ctx context.Context, key entity.APIKey, keyHash string) error
+	GetByPrefix(ctx context.Context, prefix string) (*entity.APIKey, string, error)
+	Fetch(ctx context.Context) ([]*entity.APIKey, error)
+	Rotate(ctx context.Context, keyUUID string, prefix string, keyHash string) error
+	Revoke(ctx context.Context, keyUUID string) error
+	TouchLastUsed(ctx context.Context, keyUUID string) error
+}
+
+// PasswordRepository customer credentials and password reset tokens
+type PasswordRepository interface {
+	// GetUUIDByEmail active customer owning email
+	GetUUIDByEmail(ctx context.Context, email string) (string, error)
+	// GetPasswordHash stored password hash and email of an active customer
+	GetPasswordHash(ctx context.Context, customerUUID string) (string, string, error)
+	UpdatePassword(ctx context.Context, customerUUID string, passwordHash string) error
+	StoreResetToken(ctx context.Context, customerUUID string, tokenHash string, ttl time.Duration) error
+	// FindResetToken customer UUID and email of an unused, unexpired token
+	FindResetToken(ctx context.Context, tokenHash string) (string, string, error)
+	// ConsumeResetToken spends the token, ErrResetTokenInvalid when it was spent concurrently
+	ConsumeResetToken(ctx context.Context, tokenHash string) error
+	// RevokeResetTokens spends every pending token of the customer
+	RevokeResetTokens(ctx context.Context, customerUUID string) error
+}
+
+// EmailVerificationRepository email ownership proofs of customers
+type EmailVerificationRepository interface {
+	// EmailVerified reports whether the active customer owning email verified it, ErrNotFound when nobody owns it
+	EmailVerified(ctx context.Context, email string) (bool, error)
+	// RecentEmailToken reports whether a token was issued for email within window
+	RecentEmailToken(ctx context.Context, email string, window time.Duration) (bool, error)
+	// StoreEmailToken saves a token hash for the customer owning email, valid for ttl
+	StoreEmailToken(ctx context.Context, email string, tokenHash string, ttl time.Duration) error
+	// FindEmailToken customer UUID and email of an unused, unexpired token
+	FindEmailToken(ctx context.Context, tokenHash string) (string, string, error)
+	// ConsumeEmailToken spends the token, ErrVerificationTokenInvalid when it was spent concurrently
+	ConsumeEmailToken(ctx context.Context, tokenHash string) error
+	// MarkEmailVerified sets email_verified_at when the customer still owns email
+	MarkEmailVerified(ctx context.Context, customerUUID string, email string) error
+}
+
+// PhoneVerificationRepository phone ownership codes of customers
+type PhoneVerificationRepository interface {
+	// GetPhone phone of an active customer and whether it is verified
+	GetPhone(ctx context.Context, customerUUID string) (string, bool, error)
+	// StorePhoneCode replaces the pending code of the customer, valid for ttl
+	StorePhoneCode(ctx context.Context, customerUUID string, phone string, codeHash string, ttl time.Duration) error
+	// RegisterPhoneCodeAttempt counts a guess, ErrVerificationCodeInvalid once
+	// the code is spent, expired or already had maxAttempts guesses
+	RegisterPhoneCodeAttempt(ctx context.Context, customerUUID string, maxAttempts int) error
+	// FindPhoneCode phone and code hash of the pending code
+	FindPhoneCode(ctx context.Context, customerUUID string) (string, string, error)
+	// ConsumePhoneCode spends the pending code
+	ConsumePhoneCode(ctx context.Context, customerUUID string) error
+	// MarkPhoneVerified sets phone_verified_at when the customer still owns phone
+	MarkPhoneVerified(ctx context.Context, customerUUID string, phone string) error
+}
+
+// SessionRepository customer sessions and their rotating refresh tokens
+type SessionRepository interface {
+	// CreateSession stores session with its first refresh token, valid for ttl
+	CreateSession(ctx context.Context, session entity.Session, tokenHash string, ttl time.Duration) error
+	// FindRefreshToken session of a token, used tokens included, ErrRefreshTokenInvalid
+	// when unknown or its session was revoked or expired
+	FindRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshTokenRecord, error)
+	// RotateRefreshToken marks oldHash used and stores newHash in its place,
+	// ErrRefreshTokenReused when oldHash was used concurrently
+	RotateRefreshToken(ctx context.Context, sessionUUID string, oldHash string, newHash string, meta entity.SessionMeta) error
+	// FetchSessions active sessions of the customer, most recently used first
+	FetchSessions(ctx context.Context, customerUUID string) ([]*entity.Session, error)
+	// RevokeSession ends one active session, ErrNotFound when there is none
+	RevokeSession(ctx context.Context, customerUUID string, sessionUUID string) error
+	// RevokeSessions ends every active session of the customer
+	RevokeSessions(ctx context.Context, customerUUID string) error
+	// SessionActive whether the session exists and was neither revoked nor expired
+	SessionActive(ctx context.Context, sessionUUID string) (bool, error)
+}
+
+// MFARepository TOTP factors and recovery codes of customers
+type MFARepository interface {
+	// GetTOTP stored factor, ErrNotFound when the customer never enrolled
+	GetTOTP(ctx context.Context, customerUUID string) (*entity.TOTPFactor, error)
+	// StoreTOTP saves an encrypted secret pending confirmation, replacing an unconfirmed one
+	StoreTOTP(ctx context.Context, customerUUID string, secret string) error
+	// ConfirmTOTP enables the factor at step and replaces the recovery codes
+	ConfirmTOTP(ctx context.Context, customerUUID string, step int64, recoveryHashes []string) error
+	// UseTOTPStep records step as used, ErrMFACodeInvalid when it is not newer than the last one
+	UseTOTPStep(ctx context.Context, customerUUID string, step int64) error
+	// UseRecoveryCode spends a recovery code, ErrMFACodeInvalid when unknown or used
+	UseRecoveryCode(ctx context.Context, customerUUID string, codeHash string) error
+}
+
+// WebhookRepository webhook subscriptions and their persistent delivery queue
+type WebhookRepository interface {
+	StoreWebhook(ctx context.Context, webhook entity.Webhook, secret string) error
+	GetWebhook(ctx context.Context, webhookUUID string) (*entity.Webhook, error)
+	FetchWebhooks(ctx context.Context) ([]*entity.Webhook, error)
+	// DeleteWebhook stops new deliveries, ErrNotFound when there is no active webhook
+	DeleteWebhook(ctx context.Context, webhookUUID string) error
+	// EnqueueDeliveries queues the event for every subscribed webhook, queuing it twice is a no-op
+	EnqueueDeliveries(ctx context.Context, eventID string, eventType string, payload string) error
+	// ClaimDueDeliveries pending deliveries whose next attempt is due, oldest
+	// first, hidden from other dispatchers for lease
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*entity.WebhookDelivery, error)
+	// RecordAttempt logs attempt and moves the delivery to status, retrying after retryIn when pending
+	RecordAttempt(ctx context.Context, deliveryUUID string, attempt entity.WebhookAttempt, status string, retryIn time.Duration) error
+	FetchDeliveries(ctx context.Context, webhookUUID string, limit int) ([]*entity.WebhookDelivery, error)
+	// GetDelivery delivery with its attempt log
+	GetDelivery(ctx context.Context, deliveryUUID string) (*entity.WebhookDelivery, error)
+	// ReplayDelivery queues a delivery again with a fresh attempt budget
+	ReplayDelivery(ctx context.Context, deliveryUUID string) error
+}
+
+// ErasureRepository right-to-erasure of customer personal data
+type ErasureRepository interface {
+	// EraseCustomer anonymises or hard-deletes a customer not erased before,
+	// ErrNotFound when there is none
+	EraseCustomer(ctx context.Context, customerUUID string, mode string) error
+	// ErasableCustomers customers deleted before deletedBefore and not erased yet
+	ErasableCustomers(ctx context.Context, deletedBefore time.Time, limit int) ([]string, error)
+}
+
+// ExportRepository asynchronous export jobs and their bundles
+type ExportRepository interface {
+	StoreExport(ctx context.Context, job entity.ExportJob) error
+	// GetExport unexpired job of the customer, ErrNotFound otherwise
+	GetExport(ctx context.Context, customerUUID string, exportUUID string) (*entity.ExportJob, error)
+	// GetExportBundle bundle of a ready, unexpired job of the customer
+	GetExportBundle(ctx context.Context, customerUUID string, exportUUID string) ([]byte, error)
+	// PendingExports jobs waiting for their bundle, oldest first
+	PendingExports(ctx context.Context, limit int) ([]*entity.ExportJob, error)
+	CompleteExport(ctx context.Context, exportUUID string, bundle []byte, expiresAt time.Time) error
+	FailExport(ctx context.Context, exportUUID string, message string, expiresAt time.Time) error
+	// DeleteExpiredExports removes expired jobs with their bundles
+	DeleteExpiredExports(ctx context.Context) (int64, error)
+}
+
+// KeyRotationRepository re-encryption of customer PII in batches of rows
+type KeyRotationRepository interface {
+	// ReencryptPII re-encrypts the rows of table, one of PIITables, after the
+	// row afterID, up to limit rows
+	ReencryptPII(ctx context.Context, table string, afterID int64, limit int) (entity.KeyRotation, error)
+}
+
+// AuditRepository read access to the append-only customer audit trail
+type AuditRepository interface {
+	// FetchHistory audit entries of a customer newest first and their total
+	FetchHistory(ctx context.Context, customerUUID string, offset int, limit int) ([]*entity.AuditEntry, int, error)
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/metrics"
+	"time"
+)
+
+// InstrumentedRepository decorates a Repository recording latency and
+// error count of every operation.
+type InstrumentedRepository struct {
+	next Repository
+}
+
+// NewInstrumentedRepository wraps next with Prometheus instrumentation
+func NewInstrumentedRepository(next Repository) *InstrumentedRepository {
+	return &InstrumentedRepository{next}
+}
+
+// observe records how long operation took and whether it failed
+func observe(operation string, start time.Time, err error) {
+	metrics.RepositoryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if isOperationalError(err) {
+		metrics.RepositoryErrors.WithLabelValues(operation).Inc()
+	}
+}
+
+// isOperationalError business outcomes (not found, duplicates, bad input) are not repository failures
+func isOperationalError(err error) bool {
+	switch err {
+	case nil, entity.ErrNotFound, entity.ErrBadParamInput, entity.ErrEmailExists, entity.ErrPhoneExists:
+		return false
+	}
+	return true
+}
+
+// HealthCheck instrumented Repository.HealthCheck
+func (repo *InstrumentedRepository) HealthCheck(ctx context.Context) (err error) {
+	defer func(start time.Time) { observe("HealthCheck", start, err) }(time.Now())
+	return repo.next.HealthCheck(ctx)
+}
+
+// GetByUUID instrumented Repository.GetByUUID
+func (repo *InstrumentedRepository) GetByUUID(ctx context.Context, customerUUID string) (customer *entity.Customer, err error) {
+	defer func(start time.Time) { observe("GetByUUID", start, err) }(time.Now())
+	return repo.next.GetByUUID(ctx, customerUUID)
+}
+
+// Fetch instrumented Repository.Fetch
+func (repo *InstrumentedRepository) Fetch(ctx context.Context, filter entity.CustomerFilter, page int, limit int) (customers []*entity.Customer, total int, pages int, current int, err error) {
+	defer func(start time.Time) { observe("Fetch", start, err) }(time.Now())
+	return repo.next.Fetch(ctx, filter, page, limit)
+}
+
+// Store instrumented Repository.Store
+func (repo *InstrumentedRepository) Store(ctx context.Context, customer entity.Customer) (err error) {
+	defer func(start time.Time) { observe("Store", start, err) }(time.Now())
+	return repo.next.Store(ctx, customer)
+}
+
+// UpdateByUUID instrumented Repository.UpdateByUUID
+func (repo *InstrumentedRepository) UpdateByUUID(ctx context.Context, customer entity.Customer, customerUUID string) (err error) {
+	defer func(start time.Time) { observe("UpdateByUUID", start, err) }(time.Now())
+	return repo.next.UpdateByUUID(ctx, customer, customerUUID)
+}
+
+// DeleteByUUID instrumented Repository.DeleteByUUID
+func (repo *InstrumentedRepository) DeleteByUUID(ctx context.Context, customerUUID string) (err error) {
+	defer func(start time.Time) { observe("DeleteByUUID", start, err) }(time.Now())
+	return repo.next.DeleteByUUID(ctx, customerUUID)
+}
+
+// RestoreByUUID instrumented Repository.RestoreByUUID
+func (repo *InstrumentedRepository) RestoreByUUID(ctx context.Context, customerUUID string) (err error) {
+	defer func(start time.Time) { observe("RestoreByUUID", start, err) }(time.Now())
+	return repo.next.RestoreByUUID(ctx, customerUUID)
+}
+
+// Changes instrumented Repository.Changes
+func (repo *InstrumentedRepository) Changes(ctx context.Context, since int64, limit int) (changes []*entity.CustomerChange, err error) {
+	defer func(start time.Time) { observe("Changes", start, err) }(time.Now())
+	return repo.next.Changes(ctx, since, limit)
+}
@@ -0,0 +1,42 @@
+package repository_test
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/customer/repository"
+	"svc-customer/metrics"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentedRepositoryCountsSQLErrors(t *testing.T) {
+
+	mockRepo := new(mocks.MockedRepository)
+	mockRepo.On("DeleteByUUID", "039d69ee-f9cb-4a3d-87e4-6eb63c302579").Return(entity.ErrSQLError)
+
+	before := testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("DeleteByUUID"))
+
+	repo := repository.NewInstrumentedRepository(mockRepo)
+	err := repo.DeleteByUUID(context.Background(), "039d69ee-f9cb-4a3d-87e4-6eb63c302579")
+
+	assert.Equal(t, entity.ErrSQLError, err)
+	assert.Equal(t, before+1, testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("DeleteByUUID")))
+}
+
+func TestInstrumentedRepositoryIgnoresNotFound(t *testing.T) {
+
+	mockRepo := new(mocks.MockedRepository)
+	mockRepo.On("GetByUUID", "039d69ee-f9cb-4a3d-87e4-6eb63c302579").Return(nil, entity.ErrNotFound)
+
+	before := testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("GetByUUID"))
+
+	repo := repository.NewInstrumentedRepository(mockRepo)
+	customer, err := repo.GetByUUID(context.Background(), "039d69ee-f9cb-4a3d-87e4-6eb63c302579")
+
+	assert.Nil(t, customer)
+	assert.Equal(t, entity.ErrNotFound, err)
+	assert.Equal(t, before, testutil.ToFloat64(metrics.RepositoryErrors.WithLabelValues("GetByUUID")))
+}
@@ -1,10 +1,15 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"os"
 	"svc-customer/customer/entity"
+	"svc-customer/events"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql" // MySQL driver for repository
 	"github.com/google/uuid"
@@ -14,11 +19,17 @@ import (
 // MySQLCustomersRepository structure for database connection
 type MySQLCustomersRepository struct {
 	db *sql.DB
+
+	// PII encrypts dni, email and phone, plain text when nil
+	PII *PII
 }
 
 // DATABASEDRIVER enables you to select your DBAS
 const DATABASEDRIVER = "mysql"
 
+// healthCheckTimeout maximum time HealthCheck waits for MySQL to answer
+const healthCheckTimeout = 2 * time.Second
+
 // OpenConnection between server and MySQL(driver) Server
 func OpenConnection() *sql.DB {
 
@@ -54,11 +65,11 @@ func OpenConnection() *sql.DB {
 	)
 
 	db, err := sql.Open(DATABASEDRIVER, connection)
-	log.Info("MySQL Connection: ")
-	log.Info(connection)
+	// Never log the DSN, it carries the password
+	log.WithFields(log.Fields{"host": mysqlHost, "database": mysqlDbName}).Info("MySQL Connection")
 
 	if err != nil {
-		//log.Errorf("OpenConnection: %s", err.Error())
+		log.Errorf("OpenConnection: %s", err.Error())
 		panic(err)
 	}
 	return db
@@ -66,34 +77,41 @@ func OpenConnection() *sql.DB {
 
 // NewMySQLCustomersRepository Database Connection
 func NewMySQLCustomersRepository(db *sql.DB) *MySQLCustomersRepository {
-	return &MySQLCustomersRepository{db}
+	return &MySQLCustomersRepository{db: db}
 }
 
 // Close database connection
 func (repo *MySQLCustomersRepository) Close() {
-	//log.Error("Close: executed normally")
+	log.Debug("Close: executed normally")
 	_ = repo.db.Close()
 }
 
 // HealthCheck Repository check Database Health
-func (repo *MySQLCustomersRepository) HealthCheck() error {
-	//log.Errorf("HealthCheck: executed normally.")
-	if err := repo.db.Ping(); err != nil {
-		defer repo.db.Close() //nolint
-		return err
-	}
-	return nil
+func (repo *MySQLCustomersRepository) HealthCheck(ctx context.Context) error {
+	logging.FromContext(ctx).Debug("HealthCheck: executed normally")
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+	return repo.Ping(ctx)
+}
+
+// Ping verifies the database is reachable, the pool is never closed on failure
+// so the connection can recover once MySQL is back.
+func (repo *MySQLCustomersRepository) Ping(ctx context.Context) error {
+	ctx, span := tracing.StartSQL(ctx, "Ping", "PING")
+	err := repo.db.PingContext(ctx)
+	tracing.End(span, err)
+	return err
 }
 
 // Store make a SQL Query to Insert customer's information
-func (repo *MySQLCustomersRepository) Store(customer entity.Customer) error {
-	//log.Info("Store: executed normally.")
+func (repo *MySQLCustomersRepository) Store(ctx context.Context, customer entity.Customer) error {
+	logging.FromContext(ctx).Debug("Store: executed normally")
 	// Email
-	if repo.rowExists("SELECT id FROM customers WHERE email = ?", customer.Email) {
+	if repo.taken(ctx, "email", customer.Email, "") {
 		return entity.ErrEmailExists
 	}
 	// Phone
-	if repo.rowExists("SELECT id FROM customers WHERE phone = ?", customer.Phone) {
+	if repo.taken(ctx, "phone", customer.Phone, "") {
 		return entity.ErrPhoneExists
 	}
 
@@ -103,31 +121,51 @@ func (repo *MySQLCustomersRepository) Store(customer entity.Customer) error {
 	// Generate Sha1 encrypted password for password field
 	customer.Password = entity.EncryptPassword(customer.Password)
 
-	query := fmt.Sprintf(`INSERT INTO customers (id, customer_uuid, name, last_name, dni, dni_type, email, phone, country, password, created_at, updated_at, deleted_at) 
-                          VALUES (NULL, "%s", ?, ?, ?, ?, ?, ?, ?, "%s", CURRENT_TIMESTAMP, NULL, NULL)`, customerUUID.String(), customer.Password)
-
-	_, err := repo.db.Exec(
-		query,
-		customer.Name,
-		customer.LastName,
-		customer.Dni,
-		customer.DniType,
-		customer.Email,
-		customer.Phone,
-		customer.Country,
-	)
+	stored, indexes, err := repo.PII.seal(ctx, customer)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("Store: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	values := []interface{}{stored.Name, stored.LastName, stored.Dni, stored.DniType, stored.Email, stored.Phone, stored.Country}
+	// Blind indexes only exist next to encrypted columns
+	var indexColumns, indexValues string
+	if repo.PII != nil {
+		indexColumns, indexValues = ", dni_bidx, email_bidx, phone_bidx", ", ?, ?, ?"
+		values = append(values, indexes...)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO customers (id, customer_uuid, name, last_name, dni, dni_type, email, phone, country, password, created_at, updated_at, deleted_at%s) 
+                          VALUES (NULL, "%s", ?, ?, ?, ?, ?, ?, ?, "%s", CURRENT_TIMESTAMP, NULL, NULL%s)`, indexColumns, customerUUID.String(), customer.Password, indexValues)
 
+	tx, err := repo.db.BeginTx(ctx, nil)
 	if err != nil {
-		// return err
-		log.Errorf("Store: %s", err.Error())
+		logging.FromContext(ctx).Errorf("Store: %s", err.Error())
 		return entity.ErrSQLError
 	}
-	return nil
+
+	spanCtx, span := tracing.StartSQL(ctx, "Store", query)
+	_, err = tx.ExecContext(spanCtx, query, values...)
+	tracing.End(span, err)
+
+	if err != nil {
+		// return err
+		logging.FromContext(ctx).Errorf("Store: %s", err.Error())
+		return finishTx(ctx, tx, "Store", entity.ErrSQLError)
+	}
+	// CustomerCreated commits or rolls back together with the row
+	customer.CustomerUUID = customerUUID.String()
+	if err = stampChange(ctx, tx, customer.CustomerUUID, entity.ChangeInsert); err != nil {
+		return finishTx(ctx, tx, "Store", err)
+	}
+	if err = insertAudit(ctx, tx, customer.CustomerUUID, entity.AuditCreate, entity.DiffCustomers(entity.Customer{}, customer)); err != nil {
+		return finishTx(ctx, tx, "Store", err)
+	}
+	return finishTx(ctx, tx, "Store", insertEvent(ctx, tx, repo.PII, events.CustomerCreated, customer))
 }
 
 // GetByUUID make a SQL Query to collect customer's information by ID key
-func (repo *MySQLCustomersRepository) GetByUUID(customerUUID string) (*entity.Customer, error) {
-	//log.Info("GetByUUID: executed normally")
+func (repo *MySQLCustomersRepository) GetByUUID(ctx context.Context, customerUUID string) (*entity.Customer, error) {
+	logging.FromContext(ctx).Debug("GetByUUID: executed normally")
 
 	okUUID := entity.IsValidUUID(customerUUID)
 
@@ -136,14 +174,30 @@ func (repo *MySQLCustomersRepository) GetByUUID(customerUUID string) (*entity.Cu
 		return nil, entity.ErrBadParamInput
 	}
 
+	return selectCustomer(ctx, repo.db, repo.PII, customerUUID, false)
+}
+
+// queryRower *sql.DB, or *sql.Tx to read a row written earlier in the transaction
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// selectCustomer active customer by UUID through q with its PII decrypted,
+// forUpdate locks the row until the transaction of q ends
+func selectCustomer(ctx context.Context, q queryRower, pii *PII, customerUUID string, forUpdate bool) (*entity.Customer, error) {
 	customer := &entity.Customer{}
+	var emailVerifiedAt, phoneVerifiedAt sql.NullTime
 
-	query := `SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country 
+	query := `SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country, email_verified_at, phone_verified_at 
 			  FROM customers 
 			  WHERE customer_uuid = ? 
 			  AND deleted_at IS NULL LIMIT 1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
 
-	row := repo.db.QueryRow(query, customerUUID)
+	ctx, span := tracing.StartSQL(ctx, "GetByUUID", query)
+	row := q.QueryRowContext(ctx, query, customerUUID)
 	err := row.Scan(
 		&customer.Name,
 		&customer.LastName,
@@ -152,25 +206,35 @@ func (repo *MySQLCustomersRepository) GetByUUID(customerUUID string) (*entity.Cu
 		&customer.Email,
 		&customer.Phone,
 		&customer.CustomerUUID,
-		&customer.Country)
+		&customer.Country,
+		&emailVerifiedAt,
+		&phoneVerifiedAt)
+	tracing.End(span, ignoreNoRows(err))
 
 	// SQL Error or Other More Critial Error Ocurred.
 	if err != nil {
-		//log.Errorf("GetByUUID: %s", err.Error())
 		// Customer Not found
 		if err == sql.ErrNoRows {
+			logging.FromContext(ctx).Debugf("GetByUUID: customer %s not found", customerUUID)
 			return nil, entity.ErrNotFound
 		}
+		logging.FromContext(ctx).Errorf("GetByUUID: %s", err.Error())
 		// SQL Error
 		return nil, entity.ErrSQLError
 	}
+	if err = pii.open(ctx, customer); err != nil {
+		logging.FromContext(ctx).Errorf("GetByUUID: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	customer.EmailVerifiedAt = nullTime(emailVerifiedAt)
+	customer.PhoneVerifiedAt = nullTime(phoneVerifiedAt)
 	return customer, nil
 }
 
 // Pagination calculator
-func (repo *MySQLCustomersRepository) Pagination(page int, limit int) (int, int, int, error) {
+func (repo *MySQLCustomersRepository) Pagination(ctx context.Context, filter entity.CustomerFilter, page int, limit int) (int, int, int, error) {
 	// Find out how many items are in the table
-	total, err := repo.getRowCount()
+	total, err := repo.getRowCount(ctx, filter)
 
 	if err != nil {
 		return 0, 0, 0, err //entity.ErrSQLError
@@ -194,25 +258,34 @@ func (repo *MySQLCustomersRepository) Pagination(page int, limit int) (int, int,
 }
 
 // Fetch make a SQL Query to collect customer's information by ID key
-func (repo *MySQLCustomersRepository) Fetch(page int, limit int) ([]*entity.Customer, int, int, int, error) {
-	//log.Info("Fetch: executed normally")
+func (repo *MySQLCustomersRepository) Fetch(ctx context.Context, filter entity.CustomerFilter, page int, limit int) (customers []*entity.Customer, total int, pages int, current int, err error) {
+	logging.FromContext(ctx).Debug("Fetch: executed normally")
 
-	begin, pages, total, err := repo.Pagination(page, limit)
+	begin, pages, total, err := repo.Pagination(ctx, filter, page, limit)
 
 	if err != nil {
 		return nil, begin, pages, 0, err
 	}
 
-	//log.Infof("Current Page: %d, pages: %d begin: %d total: %d limit: %d", page, pages, begin, total, limit)
+	logging.FromContext(ctx).Debugf("Current Page: %d, pages: %d begin: %d total: %d limit: %d", page, pages, begin, total, limit)
 
-	customers := []*entity.Customer{}
+	customers = []*entity.Customer{}
 
-	var SQLQuery = `SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country FROM customers WHERE deleted_at IS NULL ORDER BY id DESC LIMIT ? OFFSET ?`
+	// deleted_at is only read when deleted customers were asked for
+	columns := "name, last_name, dni, dni_type, email, phone, customer_uuid, country, email_verified_at, phone_verified_at"
+	withDeleted := filter.Deleted != entity.DeletedExclude
+	if withDeleted {
+		columns += ", deleted_at"
+	}
+	var SQLQuery = `SELECT ` + columns + ` FROM customers WHERE ` + customerFilterSQL(filter) + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+
+	ctx, span := tracing.StartSQL(ctx, "Fetch", SQLQuery)
+	defer func() { tracing.End(span, err) }()
 
-	row, err := repo.db.Query(SQLQuery, limit, begin)
+	row, err := repo.db.QueryContext(ctx, SQLQuery, limit, begin)
 
 	if err != nil {
-		//log.Errorf("Fetch: %s", err.Error())
+		logging.FromContext(ctx).Errorf("Fetch: %s", err.Error())
 		return nil, total, pages, page, err
 	}
 
@@ -221,8 +294,9 @@ func (repo *MySQLCustomersRepository) Fetch(page int, limit int) ([]*entity.Cust
 	for row.Next() {
 
 		customer := &entity.Customer{}
+		var emailVerifiedAt, phoneVerifiedAt, deletedAt sql.NullTime
 
-		err := row.Scan(
+		dest := []interface{}{
 			&customer.Name,
 			&customer.LastName,
 			&customer.Dni,
@@ -231,18 +305,31 @@ func (repo *MySQLCustomersRepository) Fetch(page int, limit int) ([]*entity.Cust
 			&customer.Phone,
 			&customer.CustomerUUID,
 			&customer.Country,
-		)
+			&emailVerifiedAt,
+			&phoneVerifiedAt,
+		}
+		if withDeleted {
+			dest = append(dest, &deletedAt)
+		}
+		err = row.Scan(dest...)
+		customer.EmailVerifiedAt = nullTime(emailVerifiedAt)
+		customer.PhoneVerifiedAt = nullTime(phoneVerifiedAt)
+		customer.DeletedAt = nullTime(deletedAt)
 
 		customers = append(customers, customer)
 
 		if err != nil {
-			//log.Errorf("Fetch: %s", err.Error())
+			logging.FromContext(ctx).Errorf("Fetch: %s", err.Error())
+			return nil, total, pages, page, err
+		}
+		if err = repo.PII.open(ctx, customer); err != nil {
+			logging.FromContext(ctx).Errorf("Fetch: %s", err.Error())
 			return nil, total, pages, page, err
 		}
 	}
 
 	if err = row.Err(); err != nil {
-		//log.Errorf("Fetch: %s", err.Error())
+		logging.FromContext(ctx).Errorf("Fetch: %s", err.Error())
 		return nil, total, pages, page, err
 	}
 
@@ -250,93 +337,258 @@ func (repo *MySQLCustomersRepository) Fetch(page int, limit int) ([]*entity.Cust
 }
 
 // UpdateByUUID Update customer information by UUID key
-func (repo *MySQLCustomersRepository) UpdateByUUID(customer entity.Customer, customerUUID string) error {
-	//log.Info("UpdateByUUID: executed normally")
+func (repo *MySQLCustomersRepository) UpdateByUUID(ctx context.Context, customer entity.Customer, customerUUID string) error {
+	logging.FromContext(ctx).Debug("UpdateByUUID: executed normally")
 
 	// Email
-	if repo.rowExists("SELECT id FROM customers WHERE email = ? AND customer_uuid != ?", customer.Email, customerUUID) {
+	if repo.taken(ctx, "email", customer.Email, " AND customer_uuid != ?", customerUUID) {
 		return entity.ErrEmailExists
 	}
 	// Phone
-	if repo.rowExists("SELECT id FROM customers WHERE phone = ? AND customer_uuid != ?", customer.Phone, customerUUID) {
+	if repo.taken(ctx, "phone", customer.Phone, " AND customer_uuid != ?", customerUUID) {
 		return entity.ErrPhoneExists
 	}
+	emailCondition, emailArgs := repo.PII.match("email", customer.Email)
+	phoneCondition, phoneArgs := repo.PII.match("phone", customer.Phone)
 
-	customer.BeforeUpdate()
+	// Passwords only change through MySQLPasswordRepository.UpdatePassword
+	customer.Password = ""
 
-	cols, vals := entity.SQLUpdate(customer)
+	stored, indexes, err := repo.PII.seal(ctx, customer)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("UpdateByUUID: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	cols, vals := entity.SQLUpdate(stored)
+	for i, column := range piiColumns {
+		if indexes[i] != nil {
+			cols += fmt.Sprintf(", %s_bidx = ? ", column)
+			vals = append(vals, indexes[i])
+		}
+	}
+	// A new email address or phone has to be verified again, SET runs left
+	// to right so both still hold the previous values when compared here.
+	if customer.Phone != "" {
+		cols = fmt.Sprintf(" phone_verified_at = IF(%s, phone_verified_at, NULL) ,", phoneCondition) + cols
+		vals = append(phoneArgs, vals...)
+	}
+	if customer.Email != "" {
+		cols = fmt.Sprintf(" email_verified_at = IF(%s, email_verified_at, NULL) ,", emailCondition) + cols
+		vals = append(emailArgs, vals...)
+	}
 	vals = append(vals, customerUUID)
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("UpdateByUUID: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	// The audit trail diffs the locked record against the committed one
+	before, err := selectCustomer(ctx, tx, repo.PII, customerUUID, true)
+	if err != nil {
+		return finishTx(ctx, tx, "UpdateByUUID", err)
+	}
 	// Update customer's account
 	query := fmt.Sprintf(`UPDATE customers SET %s ,updated_at = CURRENT_TIMESTAMP WHERE customer_uuid = ? AND deleted_at IS NULL LIMIT 1`, cols)
-	result, err := repo.db.Exec(query, vals...)
+	spanCtx, span := tracing.StartSQL(ctx, "UpdateByUUID", query)
+	result, err := tx.ExecContext(spanCtx, query, vals...)
+	tracing.End(span, err)
 
 	// SQL Error or Other More Critial Error Ocurred.
 	if err != nil {
 		// SQL Error
-		//log.Errorf("UpdateByUUID: %s", err.Error())
-		return entity.ErrSQLError
+		logging.FromContext(ctx).Errorf("UpdateByUUID: %s", err.Error())
+		return finishTx(ctx, tx, "UpdateByUUID", entity.ErrSQLError)
 	}
 	// Check if UPDATE Query actually affected customer's information by customerUUID
 	if rows, _ := result.RowsAffected(); rows == 0 {
-		//log.Errorf("UpdateByUUID: %s", err.Error())
+		logging.FromContext(ctx).Debugf("UpdateByUUID: customer %s not found", customerUUID)
 		// Customer Not found
-		return entity.ErrNotFound
+		return finishTx(ctx, tx, "UpdateByUUID", entity.ErrNotFound)
 	}
-	return nil
+	if err = stampChange(ctx, tx, customerUUID, entity.ChangeUpdate); err != nil {
+		return finishTx(ctx, tx, "UpdateByUUID", err)
+	}
+	// CustomerUpdated carries the whole record as committed
+	updated, err := selectCustomer(ctx, tx, repo.PII, customerUUID, false)
+	if err != nil {
+		return finishTx(ctx, tx, "UpdateByUUID", err)
+	}
+	if err = insertAudit(ctx, tx, customerUUID, entity.AuditUpdate, entity.DiffCustomers(*before, *updated)); err != nil {
+		return finishTx(ctx, tx, "UpdateByUUID", err)
+	}
+	return finishTx(ctx, tx, "UpdateByUUID", insertEvent(ctx, tx, repo.PII, events.CustomerUpdated, *updated))
 }
 
 // DeleteByUUID make a SQL Query to Delete customer's information by ID key
-func (repo *MySQLCustomersRepository) DeleteByUUID(customerUUID string) error {
-	//log.Info("DeleteByUUID: executed normally")
+func (repo *MySQLCustomersRepository) DeleteByUUID(ctx context.Context, customerUUID string) error {
+	logging.FromContext(ctx).Debug("DeleteByUUID: executed normally")
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("DeleteByUUID: %s", err.Error())
+		return entity.ErrSQLError
+	}
 
 	query := `UPDATE customers SET deleted_at = CURRENT_TIMESTAMP WHERE customer_uuid = ? AND deleted_at IS NULL LIMIT 1`
-	result, err := repo.db.Exec(query, customerUUID)
+	spanCtx, span := tracing.StartSQL(ctx, "DeleteByUUID", query)
+	result, err := tx.ExecContext(spanCtx, query, customerUUID)
+	tracing.End(span, err)
 
 	// SQL Error or Other More Critial Error Ocurred.
 	if err != nil {
-		//log.Errorf("DeleteByUUID: %s", err.Error())
+		logging.FromContext(ctx).Errorf("DeleteByUUID: %s", err.Error())
 		// SQL Error
-		return entity.ErrSQLError
+		return finishTx(ctx, tx, "DeleteByUUID", entity.ErrSQLError)
 	}
 	// Check if UPDATE Query actually affected customer's information by customerUUID
 	if rows, _ := result.RowsAffected(); rows == 0 {
-		//log.Errorf("DeleteByUUID: rows %d", rows)
+		logging.FromContext(ctx).Debugf("DeleteByUUID: customer %s not found", customerUUID)
 		// Customer Not found
-		return entity.ErrNotFound
+		return finishTx(ctx, tx, "DeleteByUUID", entity.ErrNotFound)
 	}
-	return nil
+	if err = stampChange(ctx, tx, customerUUID, entity.ChangeDelete); err != nil {
+		return finishTx(ctx, tx, "DeleteByUUID", err)
+	}
+	if err = insertAudit(ctx, tx, customerUUID, entity.AuditDelete, fieldSet("deleted_at")); err != nil {
+		return finishTx(ctx, tx, "DeleteByUUID", err)
+	}
+	return finishTx(ctx, tx, "DeleteByUUID", insertEvent(ctx, tx, repo.PII, events.CustomerDeleted, entity.Customer{CustomerUUID: customerUUID}))
 }
 
-func (repo *MySQLCustomersRepository) rowExists(query string, args ...interface{}) bool {
-	//log.Info("rowExists: executed normally")
+// RestoreByUUID undoes the soft delete of a customer that was not erased,
+// ErrEmailExists or ErrPhoneExists when an active customer took its email or
+// phone meanwhile
+func (repo *MySQLCustomersRepository) RestoreByUUID(ctx context.Context, customerUUID string) error {
+	logging.FromContext(ctx).Debug("RestoreByUUID: executed normally")
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RestoreByUUID: %s", err.Error())
+		return entity.ErrSQLError
+	}
+
+	var email, phone string
+	var deletedAt time.Time
+	query := `SELECT email, phone, deleted_at FROM customers WHERE customer_uuid = ? AND deleted_at IS NOT NULL AND erased_at IS NULL LIMIT 1 FOR UPDATE`
+	spanCtx, span := tracing.StartSQL(ctx, "RestoreByUUID", query)
+	err = tx.QueryRowContext(spanCtx, query, customerUUID).Scan(&email, &phone, &deletedAt)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err == sql.ErrNoRows {
+		logging.FromContext(ctx).Debugf("RestoreByUUID: deleted customer %s not found", customerUUID)
+		return finishTx(ctx, tx, "RestoreByUUID", entity.ErrNotFound)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RestoreByUUID: %s", err.Error())
+		return finishTx(ctx, tx, "RestoreByUUID", entity.ErrSQLError)
+	}
+	if email, err = repo.PII.openValue(ctx, email); err == nil {
+		phone, err = repo.PII.openValue(ctx, phone)
+	}
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RestoreByUUID: %s", err.Error())
+		return finishTx(ctx, tx, "RestoreByUUID", entity.ErrSQLError)
+	}
+	// Only active customers compete for the email and phone
+	if repo.taken(ctx, "email", email, " AND deleted_at IS NULL") {
+		return finishTx(ctx, tx, "RestoreByUUID", entity.ErrEmailExists)
+	}
+	if repo.taken(ctx, "phone", phone, " AND deleted_at IS NULL") {
+		return finishTx(ctx, tx, "RestoreByUUID", entity.ErrPhoneExists)
+	}
+
+	query = `UPDATE customers SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE customer_uuid = ? AND deleted_at IS NOT NULL LIMIT 1`
+	if err = txExec(ctx, tx, "RestoreByUUID", query, customerUUID); err != nil {
+		return finishTx(ctx, tx, "RestoreByUUID", err)
+	}
+	// Feed readers upsert the customer again over its tombstone
+	if err = stampChange(ctx, tx, customerUUID, entity.ChangeUpdate); err != nil {
+		return finishTx(ctx, tx, "RestoreByUUID", err)
+	}
+	restored, err := selectCustomer(ctx, tx, repo.PII, customerUUID, false)
+	if err != nil {
+		return finishTx(ctx, tx, "RestoreByUUID", err)
+	}
+	changes := []entity.FieldChange{{Field: "deleted_at", Before: deletedAt.UTC(), After: nil}}
+	if err = insertAudit(ctx, tx, customerUUID, entity.AuditRestore, changes); err != nil {
+		return finishTx(ctx, tx, "RestoreByUUID", err)
+	}
+	return finishTx(ctx, tx, "RestoreByUUID", insertEvent(ctx, tx, repo.PII, events.CustomerRestored, *restored))
+}
+
+// taken reports whether a customer matching scope already holds value in
+// column. Empty values never conflict, and erased customers, whose email and
+// phone are blank, never compete.
+func (repo *MySQLCustomersRepository) taken(ctx context.Context, column string, value string, scope string, args ...interface{}) bool {
+	if value == "" {
+		return false
+	}
+	condition, matchArgs := repo.PII.match(column, value)
+	query := "SELECT id FROM customers WHERE " + condition + " AND erased_at IS NULL" + scope
+	return repo.rowExists(ctx, query, append(matchArgs, args...)...)
+}
+
+func (repo *MySQLCustomersRepository) rowExists(ctx context.Context, query string, args ...interface{}) bool {
+	logging.FromContext(ctx).Debug("rowExists: executed normally")
 
 	var exists bool
 
 	query = fmt.Sprintf("SELECT exists (%s)", query)
 
-	err := repo.db.QueryRow(query, args...).Scan(&exists)
+	ctx, span := tracing.StartSQL(ctx, "rowExists", query)
+	err := repo.db.QueryRowContext(ctx, query, args...).Scan(&exists)
+	tracing.End(span, ignoreNoRows(err))
 
 	if err != nil && err != sql.ErrNoRows {
-		log.Errorf("rowExists: %s", err.Error())
+		logging.FromContext(ctx).Errorf("rowExists: %s", err.Error())
 		return false
 	}
 	return exists
 }
 
 // getRowCount counts how many rows are present in customer's table
-func (repo *MySQLCustomersRepository) getRowCount() (int, error) {
-	//log.Info("getRowCount: executed normally")
+func (repo *MySQLCustomersRepository) getRowCount(ctx context.Context, filter entity.CustomerFilter) (int, error) {
+	logging.FromContext(ctx).Debug("getRowCount: executed normally")
 
 	var total int
 
-	query := "SELECT COUNT(id) AS total FROM customers WHERE deleted_at IS NULL"
-	err := repo.db.QueryRow(query).Scan(&total)
-	//log.Infof("getRowCount: total %d", total)
+	query := "SELECT COUNT(id) AS total FROM customers WHERE " + customerFilterSQL(filter)
+	ctx, span := tracing.StartSQL(ctx, "getRowCount", query)
+	err := repo.db.QueryRowContext(ctx, query).Scan(&total)
+	tracing.End(span, ignoreNoRows(err))
+	logging.FromContext(ctx).Debugf("getRowCount: total %d", total)
 
 	if err != nil && err != sql.ErrNoRows {
-		log.Errorf("getRowCount: %s", err.Error())
+		logging.FromContext(ctx).Errorf("getRowCount: %s", err.Error())
 		return 0, err
 	}
 	return total, nil
 
 }
+
+// customerFilterSQL WHERE conditions for filter, built from constants only
+func customerFilterSQL(filter entity.CustomerFilter) string {
+	conditions := "deleted_at IS NULL"
+	switch filter.Deleted {
+	case entity.DeletedInclude:
+		conditions = "1 = 1"
+	case entity.DeletedOnly:
+		conditions = "deleted_at IS NOT NULL"
+	}
+	if filter.Verified != nil {
+		if *filter.Verified {
+			conditions += " AND email_verified_at IS NOT NULL"
+		} else {
+			conditions += " AND email_verified_at IS NULL"
+		}
+	}
+	return conditions
+}
+
+// ignoreNoRows an empty result is not a failed statement
+func ignoreNoRows(err error) error {
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
@@ -1,10 +1,19 @@
 package repository_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"svc-customer/auth"
 	"svc-customer/customer/entity"
 	"svc-customer/customer/mocks"
 	"svc-customer/customer/repository"
+	"svc-customer/encrypt"
+	"svc-customer/logging"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	sqlmock "gopkg.in/DATA-DOG/go-sqlmock.v1"
@@ -21,13 +30,13 @@ func TestRepositoryGetByUUIDValid(t *testing.T) {
 	defer db.Close() //nolint
 
 	rows := sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
-		AddRow("Jhon", "Doe", "264573076", "DNI", "jdoe@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL")
+		AddRow("Jhon", "Doe", "264573076", "DNI", "jdoe@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL", nil, nil)
 
 	mock.ExpectQuery(mocks.MockQuerySelectByUUID).WillReturnRows(rows)
 	h := repository.NewMySQLCustomersRepository(db)
 
 	customerUUID := "f48ac180-e8ad-4837-a3c3-66b0e96f19bf"
-	customer, err := h.GetByUUID(customerUUID)
+	customer, err := h.GetByUUID(context.Background(), customerUUID)
 	assert.NoError(t, err)
 	assert.NotNil(t, customer)
 }
@@ -43,17 +52,23 @@ func TestRepositoryGetByUUIDInvalid(t *testing.T) {
 	defer db.Close() //nolint
 
 	rows := sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
-		AddRow("Mike", "Tyson", "264573076", "DNI", "miketyson@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL")
+		AddRow("Mike", "Tyson", "264573076", "DNI", "miketyson@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL", nil, nil)
 
 	mock.ExpectQuery(mocks.MockQuerySelectByUUID).WillReturnRows(rows)
 	h := repository.NewMySQLCustomersRepository(db)
 
 	customerUUID := "" //"f48ac180-e8ad-4837-a3c3-66b0e96f19bf"
-	customer, err := h.GetByUUID(customerUUID)
+	customer, err := h.GetByUUID(context.Background(), customerUUID)
 	assert.EqualError(t, entity.ErrBadParamInput, err.Error())
 	assert.Nil(t, customer)
 }
 
+// expectChangeStamp change sequence bump and customer stamp of a customer write
+func expectChangeStamp(mock sqlmock.Sqlmock, customerUUID interface{}, operation string) {
+	mock.ExpectExec(mocks.MockNextChangeSequenceSQL).WillReturnResult(sqlmock.NewResult(7, 1))
+	mock.ExpectExec(mocks.MockStampChangeSQL).WithArgs(int64(7), operation, customerUUID).WillReturnResult(sqlmock.NewResult(0, 1))
+}
+
 // Test Store Valid
 func TestRepositoryStoreValid(t *testing.T) {
 	// Open Database Connection
@@ -76,13 +91,14 @@ func TestRepositoryStoreValid(t *testing.T) {
 	}
 
 	emailRow := sqlmock.NewRows([]string{"exists"})
-	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\?\\)").WillReturnRows(emailRow)
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\? AND erased_at IS NULL\\)").WillReturnRows(emailRow)
 
 	phoneRow := sqlmock.NewRows([]string{"exists"})
-	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE phone = \\?\\)").WillReturnRows(phoneRow)
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE phone = \\? AND erased_at IS NULL\\)").WillReturnRows(phoneRow)
 
 	SQLQuery := "INSERT INTO customers"
 
+	mock.ExpectBegin()
 	mock.
 		ExpectExec(SQLQuery).
 		WithArgs(
@@ -95,12 +111,22 @@ func TestRepositoryStoreValid(t *testing.T) {
 			mockCustomer.Country,
 		).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectChangeStamp(mock, sqlmock.AnyArg(), entity.ChangeInsert)
+	mock.ExpectExec(mocks.MockInsertAuditSQL).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), entity.AuditCreate, entity.AuditAnonymous, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(mocks.MockInsertOutboxSQL).
+		WithArgs(sqlmock.AnyArg(), "CustomerCreated", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	h := repository.NewMySQLCustomersRepository(db)
 
-	err = h.Store(mockCustomer)
+	err = h.Store(context.Background(), mockCustomer)
 
 	assert.Equal(t, nil, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestRepositoryDeleteByUUIDValid(t *testing.T) {
@@ -112,16 +138,25 @@ func TestRepositoryDeleteByUUIDValid(t *testing.T) {
 	// Migrate the schema
 	defer db.Close() //nolint
 
+	mock.ExpectBegin()
 	mock.
 		ExpectExec(mocks.MockSQLQueryDelete).
 		WithArgs(mocks.CustomerUUIDValid).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectChangeStamp(mock, mocks.CustomerUUIDValid, entity.ChangeDelete)
+	mock.ExpectExec(mocks.MockInsertAuditSQL).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(mocks.MockInsertOutboxSQL).
+		WithArgs(sqlmock.AnyArg(), "CustomerDeleted", mocks.CustomerUUIDValid, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	h := repository.NewMySQLCustomersRepository(db)
 
-	err = h.DeleteByUUID(mocks.CustomerUUIDValid)
+	err = h.DeleteByUUID(context.Background(), mocks.CustomerUUIDValid)
 
 	assert.Equal(t, nil, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestRepositoryDeleteByUUIDSQLError(t *testing.T) {
@@ -133,14 +168,16 @@ func TestRepositoryDeleteByUUIDSQLError(t *testing.T) {
 	// Migrate the schema
 	defer db.Close() //nolint
 
+	mock.ExpectBegin()
 	mock.
 		ExpectExec(mocks.MockSQLQueryDelete).
 		WithArgs("").
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
 	h := repository.NewMySQLCustomersRepository(db)
 
-	err = h.DeleteByUUID(mocks.CustomerUUIDValid)
+	err = h.DeleteByUUID(context.Background(), mocks.CustomerUUIDValid)
 
 	assert.Equal(t, entity.ErrSQLError, err)
 }
@@ -156,14 +193,16 @@ func TestRepositoryDeleteByUUIDNotFound(t *testing.T) {
 
 	// customerUUID := "f48ac180-e8ad-4837-a3c3-66b0e96f19bf"
 
+	mock.ExpectBegin()
 	mock.
 		ExpectExec(mocks.MockSQLQueryDelete).
 		WithArgs("").
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
 
 	h := repository.NewMySQLCustomersRepository(db)
 
-	err = h.DeleteByUUID("")
+	err = h.DeleteByUUID(context.Background(), "")
 
 	assert.Equal(t, entity.ErrNotFound, err)
 }
@@ -182,22 +221,37 @@ func TestRepositoryUpdateByUUIDValid(t *testing.T) {
 		LastName: mocks.CLastName,
 	}
 
-	emailRow := sqlmock.NewRows([]string{"exists"})
-	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\? AND customer_uuid != \\?\\)").WillReturnRows(emailRow)
-
-	phoneRow := sqlmock.NewRows([]string{"exists"})
-	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE phone = \\? AND customer_uuid != \\?\\)").WillReturnRows(phoneRow)
-
+	// No email nor phone sent, so no uniqueness check: erased customers have
+	// blank ones and would otherwise conflict with every partial update
+	mock.ExpectBegin()
+	before := sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
+		AddRow("Jhon", mocks.CLastName, "264573076", "DNI", "jdoe@gmail.com", "5600000000", mocks.CustomerUUIDValid, "CL", nil, nil)
+	mock.ExpectQuery(mocks.MockQuerySelectByUUID + " FOR UPDATE").WithArgs(mocks.CustomerUUIDValid).WillReturnRows(before)
 	mock.
 		ExpectExec(mocks.MockUpdateCustomerSQL).
 		WithArgs(mocks.CName, mocks.CLastName, mocks.CustomerUUIDValid).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectChangeStamp(mock, mocks.CustomerUUIDValid, entity.ChangeUpdate)
+	rows := sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
+		AddRow(mocks.CName, mocks.CLastName, "264573076", "DNI", "jdoe@gmail.com", "5600000000", mocks.CustomerUUIDValid, "CL", nil, nil)
+	mock.ExpectQuery(mocks.MockQuerySelectByUUID).WithArgs(mocks.CustomerUUIDValid).WillReturnRows(rows)
+	changes := `[{"field":"name","before":"[REDACTED]","after":"[REDACTED]"}]`
+	mock.ExpectExec(mocks.MockInsertAuditSQL).
+		WithArgs(sqlmock.AnyArg(), mocks.CustomerUUIDValid, entity.AuditUpdate, "admin-1", "jwt", "request-1", changes, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.
+		ExpectExec(mocks.MockInsertOutboxSQL).
+		WithArgs(sqlmock.AnyArg(), "CustomerUpdated", mocks.CustomerUUIDValid, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	h := repository.NewMySQLCustomersRepository(db)
 
-	err = h.UpdateByUUID(mockCustomer, mocks.CustomerUUIDValid)
+	ctx := auth.NewContext(logging.WithRequestID(context.Background(), "request-1"), &auth.Principal{Subject: "admin-1", Method: "jwt"})
+	err = h.UpdateByUUID(ctx, mockCustomer, mocks.CustomerUUIDValid)
 
 	assert.Equal(t, nil, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestRepositoryUpdateByUUIDNoUUID(t *testing.T) {
@@ -215,10 +269,10 @@ func TestRepositoryUpdateByUUIDNoUUID(t *testing.T) {
 	}
 
 	emailRow := sqlmock.NewRows([]string{"exists"})
-	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\?\\)").WillReturnRows(emailRow)
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\? AND erased_at IS NULL\\)").WillReturnRows(emailRow)
 
 	phoneRow := sqlmock.NewRows([]string{"exists"})
-	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE phone = \\?\\)").WillReturnRows(phoneRow)
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE phone = \\? AND erased_at IS NULL\\)").WillReturnRows(phoneRow)
 
 	mock.
 		ExpectExec(mocks.MockUpdateCustomerSQL).
@@ -227,7 +281,7 @@ func TestRepositoryUpdateByUUIDNoUUID(t *testing.T) {
 
 	h := repository.NewMySQLCustomersRepository(db)
 
-	err = h.UpdateByUUID(mockCustomer, "")
+	err = h.UpdateByUUID(context.Background(), mockCustomer, "")
 
 	assert.Equal(t, entity.ErrSQLError, err)
 }
@@ -255,16 +309,16 @@ func TestRepositoryFetchValid(t *testing.T) {
 	mock.ExpectQuery("SELECT COUNT\\(id\\) AS total FROM customers").WillReturnRows(row)
 
 	rows := sqlmock.NewRows(
-		[]string{"name", "last_name", "dni", "dni_type", "email", "phone", "customer_uuid", "country"}).
-		AddRow("Jhon", "Doe", "264573076", "DNI", "jdoe@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL")
+		[]string{"name", "last_name", "dni", "dni_type", "email", "phone", "customer_uuid", "country", "email_verified_at", "phone_verified_at"}).
+		AddRow("Jhon", "Doe", "264573076", "DNI", "jdoe@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL", nil, nil)
 
-	mock.ExpectQuery("SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country FROM customers WHERE deleted_at IS NULL ORDER BY id DESC LIMIT \\? OFFSET \\?").
+	mock.ExpectQuery("SELECT name, last_name, dni, dni_type, email, phone, customer_uuid, country, email_verified_at, phone_verified_at FROM customers WHERE deleted_at IS NULL ORDER BY id DESC LIMIT \\? OFFSET \\?").
 		WillReturnRows(rows).
 		WithArgs(10, 0)
 
 	h := repository.NewMySQLCustomersRepository(db)
 	// // begin, pages, total, err := repository.Pagination(page, limit)
-	customer, _, _, _, err := h.Fetch(page, limit)
+	customer, _, _, _, err := h.Fetch(context.Background(), entity.CustomerFilter{}, page, limit)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, customer)
@@ -283,7 +337,7 @@ func TestRepositoryFetchInvalid(t *testing.T) {
 	mock.ExpectQuery(mocks.MockFetchCustomerSQL).WillReturnError(entity.ErrSQLError)
 	h := repository.NewMySQLCustomersRepository(db)
 
-	_, _, _, _, err = h.Fetch(1, 10)
+	_, _, _, _, err = h.Fetch(context.Background(), entity.CustomerFilter{}, 1, 10)
 	assert.Error(t, entity.ErrSQLError, err.Error())
 }
 
@@ -297,7 +351,7 @@ func TestRepositoryHealthCheckValid(t *testing.T) {
 	defer db.Close() //nolint
 
 	h := repository.NewMySQLCustomersRepository(db)
-	check := h.HealthCheck()
+	check := h.HealthCheck(context.Background())
 
 	assert.Nil(t, check)
 
@@ -313,7 +367,913 @@ func TestRepositoryHealthCheckError(t *testing.T) {
 	db.Close() //nolint
 
 	h := repository.NewMySQLCustomersRepository(db)
-	check := h.HealthCheck()
+	check := h.HealthCheck(context.Background())
 
 	assert.Equal(t, "sql: database is closed", check.Error())
 }
+
+func TestRepositoryFetchVerifiedFilter(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectQuery("SELECT COUNT\\(id\\) AS total FROM customers WHERE deleted_at IS NULL AND email_verified_at IS NOT NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(1))
+
+	rows := sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
+		AddRow("Jhon", "Doe", "264573076", "DNI", "jdoe@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL", time.Now(), nil)
+	mock.ExpectQuery("FROM customers WHERE deleted_at IS NULL AND email_verified_at IS NOT NULL ORDER BY id DESC").
+		WillReturnRows(rows)
+
+	verified := true
+	h := repository.NewMySQLCustomersRepository(db)
+	customers, total, _, _, err := h.Fetch(context.Background(), entity.CustomerFilter{Verified: &verified}, 1, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.NotNil(t, customers[0].EmailVerifiedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryUpdateByUUIDEmailResetsVerification(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\? AND erased_at IS NULL AND customer_uuid != \\?\\)").WillReturnRows(sqlmock.NewRows([]string{"exists"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery(mocks.MockQuerySelectByUUID).WillReturnRows(sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
+		AddRow("Jhon", "Doe", "264573076", "DNI", "old@gmail.com", "5600000000", mocks.CustomerUUIDValid, "CL", time.Now(), nil))
+	mock.ExpectExec("UPDATE customers SET email_verified_at = IF\\(email = \\?, email_verified_at, NULL\\) , email = \\? ,updated_at").
+		WithArgs("new@gmail.com", "new@gmail.com", mocks.CustomerUUIDValid).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectChangeStamp(mock, mocks.CustomerUUIDValid, entity.ChangeUpdate)
+	mock.ExpectQuery(mocks.MockQuerySelectByUUID).WillReturnRows(sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
+		AddRow("Jhon", "Doe", "264573076", "DNI", "new@gmail.com", "5600000000", mocks.CustomerUUIDValid, "CL", nil, nil))
+	mock.ExpectExec(mocks.MockInsertAuditSQL).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(mocks.MockInsertOutboxSQL).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLCustomersRepository(db)
+	err = h.UpdateByUUID(context.Background(), entity.Customer{Email: "new@gmail.com"}, mocks.CustomerUUIDValid)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepositoryRotateReusedRollsBack(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = \\? AND session_uuid = \\? AND used_at IS NULL").
+		WithArgs("old-hash", "session-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	h := repository.NewMySQLSessionRepository(db)
+	err = h.RotateRefreshToken(context.Background(), "session-1", "old-hash", "new-hash", entity.SessionMeta{})
+
+	assert.Equal(t, entity.ErrRefreshTokenReused, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepositoryRotateCommits(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE refresh_tokens SET used_at").WithArgs("old-hash", "session-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WithArgs("new-hash", "session-1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE sessions SET last_used_at").WithArgs("curl", "10.0.0.1", "session-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLSessionRepository(db)
+	err = h.RotateRefreshToken(context.Background(), "session-1", "old-hash", "new-hash", entity.SessionMeta{UserAgent: "curl", IP: "10.0.0.1"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARepositoryStoreTOTPKeepsConfirmedFactor(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectExec("INSERT INTO mfa_totp").WithArgs("customer-1", "sealed").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	h := repository.NewMySQLMFARepository(db)
+	err = h.StoreTOTP(context.Background(), "customer-1", "sealed")
+
+	assert.Equal(t, entity.ErrMFAAlreadyEnabled, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMFARepositoryConfirmTOTPReplacesRecoveryCodes(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE mfa_totp SET confirmed_at").WithArgs(int64(42), "customer-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM mfa_recovery_codes").WithArgs("customer-1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO mfa_recovery_codes").WithArgs("customer-1", "hash-1").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO mfa_recovery_codes").WithArgs("customer-1", "hash-2").WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLMFARepository(db)
+	err = h.ConfirmTOTP(context.Background(), "customer-1", 42, []string{"hash-1", "hash-2"})
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepositoryRecordAttemptCommits(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO webhook_attempts").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE webhook_deliveries SET attempts = attempts \\+ 1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLWebhookRepository(db)
+	err = h.RecordAttempt(context.Background(), "delivery-1", entity.WebhookAttempt{StatusCode: 503, Error: "503 Service Unavailable"}, entity.WebhookPending, 30*time.Second)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepositoryClaimsBeforeReading(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectExec("UPDATE webhook_deliveries SET claim_token = \\?, next_attempt_at = DATE_ADD").
+		WithArgs(sqlmock.AnyArg(), int64(600), 50).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.webhook_uuid = d.webhook_uuid\\s+WHERE d.claim_token = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_uuid", "webhook_uuid", "event_uuid", "event_type", "status", "attempts",
+			"next_attempt_at", "last_status_code", "last_error", "created_at", "delivered_at", "url", "secret", "payload"}).
+			AddRow("delivery-1", "webhook-1", "event-1", "CustomerCreated", "pending", 0, created, nil, nil, created, nil, "https://example.com/hook", "whsec_test", "{}"))
+
+	h := repository.NewMySQLWebhookRepository(db)
+	deliveries, err := h.ClaimDueDeliveries(context.Background(), 50, 10*time.Minute)
+
+	assert.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, "delivery-1", deliveries[0].DeliveryUUID)
+		assert.Equal(t, "https://example.com/hook", deliveries[0].URL)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepositoryClaimsNothingWhenNoneDue(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectExec("UPDATE webhook_deliveries SET claim_token").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	h := repository.NewMySQLWebhookRepository(db)
+	deliveries, err := h.ClaimDueDeliveries(context.Background(), 50, 10*time.Minute)
+
+	assert.NoError(t, err)
+	assert.Empty(t, deliveries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepositoryReplayUnknownDelivery(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectExec("UPDATE webhook_deliveries SET status = 'pending'").WithArgs("delivery-1").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	h := repository.NewMySQLWebhookRepository(db)
+	err = h.ReplayDelivery(context.Background(), "delivery-1")
+
+	assert.Equal(t, entity.ErrNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryChangesIncludesTombstones(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	deleted := created.Add(time.Hour)
+	rows := sqlmock.NewRows([]string{"change_seq", "change_op", "customer_uuid", "name", "last_name", "dni", "dni_type", "email", "phone", "country",
+		"email_verified_at", "phone_verified_at", "created_at", "updated_at", "deleted_at"}).
+		AddRow(int64(11), entity.ChangeInsert, mocks.CustomerUUIDValid, "Jhon", "Doe", "264573076", "DNI", "jhond@gmail.com", "+56933375029", "CL", nil, nil, created, nil, nil).
+		AddRow(int64(12), entity.ChangeDelete, "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "Jane", "Doe", "264573077", "DNI", "janed@gmail.com", "+56933375030", "CL", nil, nil, created, nil, deleted)
+	mock.ExpectQuery("FROM customers WHERE change_seq > \\?\\s+UNION ALL.+FROM customer_tombstones WHERE change_seq > \\?\\s+ORDER BY change_seq LIMIT \\?").
+		WithArgs(int64(10), int64(10), 2).WillReturnRows(rows)
+
+	h := repository.NewMySQLCustomersRepository(db)
+	changes, err := h.Changes(context.Background(), 10, 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, changes, 2)
+	assert.Equal(t, "Jhon", changes[0].Customer.Name)
+	assert.Equal(t, int64(12), changes[1].Sequence)
+	assert.Nil(t, changes[1].Customer)
+	assert.Equal(t, deleted, *changes[1].DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryFetchOnlyDeleted(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	deleted := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT\\(id\\) AS total FROM customers WHERE deleted_at IS NOT NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"total"}).AddRow(1))
+	rows := sqlmock.NewRows(append(mocks.MockColumnsSelectByUUID, "deleted_at")).
+		AddRow("Jhon", "Doe", "264573076", "DNI", "jdoe@gmail.com", "5600000000", "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", "CL", nil, nil, deleted)
+	mock.ExpectQuery("phone_verified_at, deleted_at FROM customers WHERE deleted_at IS NOT NULL ORDER BY id DESC").
+		WillReturnRows(rows)
+
+	h := repository.NewMySQLCustomersRepository(db)
+	customers, total, _, _, err := h.Fetch(context.Background(), entity.CustomerFilter{Deleted: entity.DeletedOnly}, 1, 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, deleted, *customers[0].DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryRestoreByUUIDValid(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	deleted := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT email, phone, deleted_at FROM customers WHERE customer_uuid = \\? AND deleted_at IS NOT NULL AND erased_at IS NULL LIMIT 1 FOR UPDATE").
+		WithArgs(mocks.CustomerUUIDValid).
+		WillReturnRows(sqlmock.NewRows([]string{"email", "phone", "deleted_at"}).AddRow("jdoe@gmail.com", "5600000000", deleted))
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\? AND erased_at IS NULL AND deleted_at IS NULL\\)").
+		WithArgs("jdoe@gmail.com").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE phone = \\? AND erased_at IS NULL AND deleted_at IS NULL\\)").
+		WithArgs("5600000000").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UPDATE customers SET deleted_at = NULL").WithArgs(mocks.CustomerUUIDValid).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectChangeStamp(mock, mocks.CustomerUUIDValid, entity.ChangeUpdate)
+	mock.ExpectQuery(mocks.MockQuerySelectByUUID).WithArgs(mocks.CustomerUUIDValid).WillReturnRows(sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
+		AddRow("Jhon", "Doe", "264573076", "DNI", "jdoe@gmail.com", "5600000000", mocks.CustomerUUIDValid, "CL", nil, nil))
+	mock.ExpectExec(mocks.MockInsertAuditSQL).
+		WithArgs(sqlmock.AnyArg(), mocks.CustomerUUIDValid, entity.AuditRestore, entity.AuditAnonymous, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			`[{"field":"deleted_at","before":"2020-06-01T12:00:00Z","after":null}]`, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(mocks.MockInsertOutboxSQL).
+		WithArgs(sqlmock.AnyArg(), "CustomerRestored", mocks.CustomerUUIDValid, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLCustomersRepository(db)
+	err = h.RestoreByUUID(context.Background(), mocks.CustomerUUIDValid)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryRestoreByUUIDEmailTaken(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT email, phone, deleted_at FROM customers").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "phone", "deleted_at"}).AddRow("jdoe@gmail.com", "5600000000", time.Now()))
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE email = \\? AND erased_at IS NULL AND deleted_at IS NULL\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	h := repository.NewMySQLCustomersRepository(db)
+	err = h.RestoreByUUID(context.Background(), mocks.CustomerUUIDValid)
+
+	assert.Equal(t, entity.ErrEmailExists, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryRestoreByUUIDNotDeleted(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT email, phone, deleted_at FROM customers").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "phone", "deleted_at"}))
+	mock.ExpectRollback()
+
+	h := repository.NewMySQLCustomersRepository(db)
+	err = h.RestoreByUUID(context.Background(), mocks.CustomerUUIDValid)
+
+	assert.Equal(t, entity.ErrNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestAuditRepositoryFetchHistory(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("SELECT COUNT\\(id\\) FROM customer_audit WHERE customer_uuid = \\?").
+		WithArgs(mocks.CustomerUUIDValid).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	rows := sqlmock.NewRows([]string{"audit_uuid", "customer_uuid", "action", "actor", "actor_method", "request_id", "changes", "created_at"}).
+		AddRow("a5b7c9d1-1111-4222-8333-944455556666", mocks.CustomerUUIDValid, entity.AuditPasswordChanged, entity.AuditAnonymous, nil, nil,
+			`[{"field":"password","before":"[REDACTED]","after":"[REDACTED]"}]`, created)
+	mock.ExpectQuery("FROM customer_audit WHERE customer_uuid = \\? ORDER BY id DESC LIMIT \\? OFFSET \\?").
+		WithArgs(mocks.CustomerUUIDValid, 1, 2).
+		WillReturnRows(rows)
+
+	h := repository.NewMySQLAuditRepository(db)
+	entries, total, err := h.FetchHistory(context.Background(), mocks.CustomerUUIDValid, 2, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, entity.AuditAnonymous, entries[0].Actor)
+	assert.Empty(t, entries[0].RequestID)
+	assert.Equal(t, []entity.FieldChange{{Field: "password", Before: entity.AuditRedacted, After: entity.AuditRedacted}}, entries[0].Changes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// erasureDeliveryBody webhook delivery body of an update event carrying payload
+func erasureDeliveryBody(customerUUID string, payload string) string {
+	return fmt.Sprintf(`{"event_id":"d1e2f3a4-1111-4222-8333-944455556666","type":"CustomerUpdated","customer_uuid":%q,"occurred_at":"2020-06-01T10:00:00Z","payload":%s}`,
+		customerUUID, payload)
+}
+
+// expectErasureCleanup removal of the sessions, tokens and second factors of an
+// erased customer, and the scrub of its webhook delivery stored as delivery
+func expectErasureCleanup(mock sqlmock.Sqlmock, customerUUID string, delivery string) {
+	for _, table := range []string{"refresh_tokens", "sessions", "password_resets", "email_verifications", "phone_verifications", "mfa_recovery_codes", "mfa_totp", "customer_exports"} {
+		mock.ExpectExec("DELETE FROM " + table + " WHERE").WithArgs(customerUUID).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	payload := fmt.Sprintf(`{"id":%q}`, customerUUID)
+	mock.ExpectQuery("SELECT id, payload FROM webhook_deliveries WHERE event_uuid IN \\(SELECT event_uuid FROM outbox WHERE customer_uuid = \\?\\) FOR UPDATE").
+		WithArgs(customerUUID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}).AddRow(4, delivery))
+	mock.ExpectExec("UPDATE webhook_deliveries SET payload = \\? WHERE id = \\?").
+		WithArgs(erasureDeliveryBody(customerUUID, payload), int64(4)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE outbox SET payload = \\? WHERE customer_uuid = \\?").WithArgs(payload, customerUUID).WillReturnResult(sqlmock.NewResult(0, 2))
+}
+
+func TestErasureRepositoryAnonymise(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM customers WHERE customer_uuid = \\? AND erased_at IS NULL LIMIT 1 FOR UPDATE").
+		WithArgs(mocks.CustomerUUIDValid).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	expectErasureCleanup(mock, mocks.CustomerUUIDValid, erasureDeliveryBody(mocks.CustomerUUIDValid, `{"email":"old@gmail.com"}`))
+	mock.ExpectQuery("SELECT id, changes FROM customer_audit WHERE customer_uuid = \\?").
+		WithArgs(mocks.CustomerUUIDValid).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "changes"}).
+			AddRow(3, `[{"field":"email","before":"old@gmail.com","after":"new@gmail.com"},{"field":"phone","before":null,"after":"5600000000"}]`))
+	mock.ExpectExec("UPDATE customer_audit SET changes = \\? WHERE id = \\?").
+		WithArgs(`[{"field":"email","before":"[REDACTED]","after":"[REDACTED]"},{"field":"phone","before":null,"after":"[REDACTED]"}]`, int64(3)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE customers SET name = '', last_name = '', dni = '', email = '', phone = '', password = ''").
+		WithArgs(mocks.CustomerUUIDValid).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectChangeStamp(mock, mocks.CustomerUUIDValid, entity.ChangeDelete)
+	mock.ExpectExec(mocks.MockInsertAuditSQL).
+		WithArgs(sqlmock.AnyArg(), mocks.CustomerUUIDValid, entity.AuditAnonymised, entity.AuditAnonymous, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			`[{"field":"name","before":"[REDACTED]","after":null},{"field":"last_name","before":"[REDACTED]","after":null},{"field":"dni","before":"[REDACTED]","after":null},{"field":"email","before":"[REDACTED]","after":null},{"field":"phone","before":"[REDACTED]","after":null},{"field":"password","before":"[REDACTED]","after":null}]`,
+			sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(mocks.MockInsertOutboxSQL).
+		WithArgs(sqlmock.AnyArg(), "CustomerErased", mocks.CustomerUUIDValid, fmt.Sprintf(`{"id":%q}`, mocks.CustomerUUIDValid), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLErasureRepository(db)
+	err = h.EraseCustomer(context.Background(), mocks.CustomerUUIDValid, entity.ErasureAnonymise)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestErasureRepositoryHardDelete(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	// Deliveries are sealed, the scrubbed body only keeps the customer UUID
+	pii := newTestPII(t)
+	delivery, _ := pii.Envelope.Encrypt(context.Background(), erasureDeliveryBody(mocks.CustomerUUIDValid, `{"email":"old@gmail.com"}`))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM customers WHERE customer_uuid = \\?").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	expectErasureCleanup(mock, mocks.CustomerUUIDValid, delivery)
+	mock.ExpectQuery("SELECT id, changes FROM customer_audit").WillReturnRows(sqlmock.NewRows([]string{"id", "changes"}))
+	mock.ExpectExec(mocks.MockNextChangeSequenceSQL).WillReturnResult(sqlmock.NewResult(9, 1))
+	mock.ExpectExec("INSERT INTO customer_tombstones").WithArgs(int64(9), mocks.CustomerUUIDValid).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("DELETE FROM customers WHERE customer_uuid = \\? LIMIT 1").WithArgs(mocks.CustomerUUIDValid).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(mocks.MockInsertAuditSQL).
+		WithArgs(sqlmock.AnyArg(), mocks.CustomerUUIDValid, entity.AuditHardDeleted, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(mocks.MockInsertOutboxSQL).
+		WithArgs(sqlmock.AnyArg(), "CustomerErased", mocks.CustomerUUIDValid, sealedArg{pii, fmt.Sprintf(`{"id":%q}`, mocks.CustomerUUIDValid)}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLErasureRepository(db)
+	h.PII = pii
+	err = h.EraseCustomer(context.Background(), mocks.CustomerUUIDValid, entity.ErasureHardDelete)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestErasureRepositoryAlreadyErased(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM customers WHERE customer_uuid = \\?").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+
+	h := repository.NewMySQLErasureRepository(db)
+	err = h.EraseCustomer(context.Background(), mocks.CustomerUUIDValid, entity.ErasureAnonymise)
+
+	assert.Equal(t, entity.ErrNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportRepositoryPendingExports(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	created := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	rows := sqlmock.NewRows([]string{"export_uuid", "customer_uuid", "format", "status", "error", "created_at", "completed_at", "expires_at"}).
+		AddRow("e5b7c9d1-1111-4222-8333-944455556666", mocks.CustomerUUIDValid, entity.ExportZip, entity.ExportPending, nil, created, nil, nil)
+	mock.ExpectQuery("FROM customer_exports WHERE status = 'pending' ORDER BY id LIMIT \\?").
+		WithArgs(10).
+		WillReturnRows(rows)
+
+	h := repository.NewMySQLExportRepository(db)
+	jobs, err := h.PendingExports(context.Background(), 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 1)
+	assert.Equal(t, entity.ExportZip, jobs[0].Format)
+	assert.Nil(t, jobs[0].ExpiresAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestExportRepositoryGetExportNotFound(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectQuery("FROM customer_exports").
+		WithArgs("e5b7c9d1-1111-4222-8333-944455556666", mocks.CustomerUUIDValid).
+		WillReturnRows(sqlmock.NewRows([]string{"export_uuid"}))
+
+	h := repository.NewMySQLExportRepository(db)
+	_, err = h.GetExport(context.Background(), mocks.CustomerUUIDValid, "e5b7c9d1-1111-4222-8333-944455556666")
+
+	assert.Equal(t, entity.ErrNotFound, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// newTestPII PII with fixed keys, active master key "k2"
+func newTestPII(t *testing.T) *repository.PII {
+	keys, err := encrypt.NewLocalKeyProvider("k2", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, encrypt.KeySize),
+		"k2": bytes.Repeat([]byte{2}, encrypt.KeySize),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	index, _ := encrypt.NewBlindIndex(bytes.Repeat([]byte{3}, encrypt.KeySize))
+	return &repository.PII{Envelope: encrypt.NewEnvelope(keys), Index: index}
+}
+
+// sealedArg matches an envelope ciphertext of the active key opening to plaintext
+type sealedArg struct {
+	pii       *repository.PII
+	plaintext string
+}
+
+func (a sealedArg) Match(value driver.Value) bool {
+	sealed, ok := value.(string)
+	if !ok || a.pii.Envelope.NeedsRotation(sealed) {
+		return false
+	}
+	plaintext, err := a.pii.Envelope.Decrypt(context.Background(), sealed)
+	return err == nil && plaintext == a.plaintext
+}
+
+// sealedContainingArg matches an envelope ciphertext of the active key whose
+// plaintext contains part
+type sealedContainingArg struct {
+	pii  *repository.PII
+	part string
+}
+
+func (a sealedContainingArg) Match(value driver.Value) bool {
+	sealed, ok := value.(string)
+	if !ok || a.pii.Envelope.NeedsRotation(sealed) {
+		return false
+	}
+	plaintext, err := a.pii.Envelope.Decrypt(context.Background(), sealed)
+	return err == nil && strings.Contains(plaintext, a.part)
+}
+
+func TestRepositoryStoreEncryptsPII(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	pii := newTestPII(t)
+	customer := mocks.MockQueryStore
+
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE \\(email_bidx = \\? OR \\(email_bidx IS NULL AND email = \\?\\)\\) AND erased_at IS NULL\\)").
+		WithArgs(pii.Index.Sum("email", customer.Email), customer.Email).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}))
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM customers WHERE \\(phone_bidx = \\? OR \\(phone_bidx IS NULL AND phone = \\?\\)\\) AND erased_at IS NULL\\)").
+		WithArgs(pii.Index.Sum("phone", customer.Phone), customer.Phone).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO customers \\(.*, deleted_at, dni_bidx, email_bidx, phone_bidx\\)").
+		WithArgs(
+			customer.Name,
+			customer.LastName,
+			sealedArg{pii, customer.Dni},
+			customer.DniType,
+			sealedArg{pii, customer.Email},
+			sealedArg{pii, customer.Phone},
+			customer.Country,
+			pii.Index.Sum("dni", customer.Dni),
+			pii.Index.Sum("email", customer.Email),
+			pii.Index.Sum("phone", customer.Phone),
+		).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	expectChangeStamp(mock, sqlmock.AnyArg(), entity.ChangeInsert)
+	mock.ExpectExec(mocks.MockInsertAuditSQL).WillReturnResult(sqlmock.NewResult(1, 1))
+	// The event payload is sealed like the columns
+	mock.ExpectExec(mocks.MockInsertOutboxSQL).
+		WithArgs(sqlmock.AnyArg(), "CustomerCreated", sqlmock.AnyArg(), sealedContainingArg{pii, `"email":"` + customer.Email + `"`}, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLCustomersRepository(db)
+	h.PII = pii
+	err = h.Store(context.Background(), customer)
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryGetByUUIDDecryptsPII(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	pii := newTestPII(t)
+	email, _ := pii.Envelope.Encrypt(context.Background(), "jdoe@gmail.com")
+	phone, _ := pii.Envelope.Encrypt(context.Background(), "5600000000")
+	customerUUID := "f48ac180-e8ad-4837-a3c3-66b0e96f19bf"
+
+	// dni is still plain text, the row predates encryption
+	mock.ExpectQuery(mocks.MockQuerySelectByUUID).WithArgs(customerUUID).
+		WillReturnRows(sqlmock.NewRows(mocks.MockColumnsSelectByUUID).
+			AddRow("Jhon", "Doe", "264573076", "DNI", email, phone, customerUUID, "CL", nil, nil))
+
+	h := repository.NewMySQLCustomersRepository(db)
+	h.PII = pii
+	customer, err := h.GetByUUID(context.Background(), customerUUID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "264573076", customer.Dni)
+	assert.Equal(t, "jdoe@gmail.com", customer.Email)
+	assert.Equal(t, "5600000000", customer.Phone)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryReencryptPII(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	pii := newTestPII(t)
+	current, _ := pii.Envelope.Encrypt(context.Background(), "current@gmail.com")
+	old, _ := encrypt.NewLocalKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, encrypt.KeySize)})
+	stale, _ := encrypt.NewEnvelope(old).Encrypt(context.Background(), "5600000000")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, dni, email, phone FROM customers WHERE id > \\? ORDER BY id LIMIT \\? FOR UPDATE").
+		WithArgs(int64(10), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "dni", "email", "phone"}).
+			AddRow(11, "264573076", "jdoe@gmail.com", stale).
+			AddRow(12, "", current, ""))
+	mock.ExpectExec("UPDATE customers SET dni = \\?, email = \\?, phone = \\?, dni_bidx = \\?, email_bidx = \\?, phone_bidx = \\? WHERE id = \\?").
+		WithArgs(
+			sealedArg{pii, "264573076"},
+			sealedArg{pii, "jdoe@gmail.com"},
+			sealedArg{pii, "5600000000"},
+			pii.Index.Sum("dni", "264573076"),
+			pii.Index.Sum("email", "jdoe@gmail.com"),
+			pii.Index.Sum("phone", "5600000000"),
+			int64(11)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLCustomersRepository(db)
+	h.PII = pii
+	rotation, err := h.ReencryptPII(context.Background(), "customers", 10, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.KeyRotation{Table: "customers", LastID: 12, Scanned: 2, Rewritten: 1}, rotation)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRepositoryReencryptPIISealedCopies(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	pii := newTestPII(t)
+	current, _ := pii.Envelope.Encrypt(context.Background(), `{"id":"c-2"}`)
+	old, _ := encrypt.NewLocalKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, encrypt.KeySize)})
+	stale, _ := encrypt.NewEnvelope(old).Encrypt(context.Background(), `{"email":"jdoe@gmail.com"}`)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, payload FROM outbox WHERE id > \\? AND payload IS NOT NULL ORDER BY id LIMIT \\? FOR UPDATE").
+		WithArgs(int64(0), 3).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "payload"}).AddRow(1, stale).AddRow(2, current))
+	mock.ExpectExec("UPDATE outbox SET payload = \\? WHERE id = \\?").
+		WithArgs(sealedArg{pii, `{"email":"jdoe@gmail.com"}`}, int64(1)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	h := repository.NewMySQLCustomersRepository(db)
+	h.PII = pii
+	rotation, err := h.ReencryptPII(context.Background(), "outbox", 0, 3)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.KeyRotation{Table: "outbox", LastID: 2, Scanned: 2, Rewritten: 1}, rotation)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = h.ReencryptPII(context.Background(), "sessions", 0, 3)
+	assert.Equal(t, repository.ErrUnknownPIITable, err)
+}
+
+func TestEmailVerificationRepositorySealsTokenAddress(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	pii := newTestPII(t)
+	email := "jdoe@gmail.com"
+	sealed, _ := pii.Envelope.Encrypt(context.Background(), email)
+
+	mock.ExpectQuery("SELECT exists \\(SELECT id FROM email_verifications WHERE \\(email_bidx = \\? OR \\(email_bidx IS NULL AND email = \\?\\)\\) AND created_at >").
+		WithArgs(pii.Index.Sum("email", email), email, 60).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("INSERT INTO email_verifications \\(token_hash, customer_uuid, email, email_bidx, expires_at, created_at\\)").
+		WithArgs("hash", sealedArg{pii, email}, pii.Index.Sum("email", email), 3600, pii.Index.Sum("email", email), email).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT customer_uuid, email FROM email_verifications").
+		WithArgs("hash").
+		WillReturnRows(sqlmock.NewRows([]string{"customer_uuid", "email"}).AddRow(mocks.CustomerUUIDValid, sealed))
+
+	h := repository.NewMySQLEmailVerificationRepository(db)
+	h.PII = pii
+	recent, err := h.RecentEmailToken(context.Background(), email, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, recent)
+	assert.NoError(t, h.StoreEmailToken(context.Background(), email, "hash", time.Hour))
+	customerUUID, found, err := h.FindEmailToken(context.Background(), "hash")
+
+	assert.NoError(t, err)
+	assert.Equal(t, mocks.CustomerUUIDValid, customerUUID)
+	assert.Equal(t, email, found)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// sealedBytesArg sealedArg for a value written as bytes
+type sealedBytesArg sealedArg
+
+func (a sealedBytesArg) Match(value driver.Value) bool {
+	sealed, ok := value.([]byte)
+	return ok && sealedArg(a).Match(string(sealed))
+}
+
+func TestExportRepositorySealsBundle(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	pii := newTestPII(t)
+	bundle := `{"customer":{"email":"jdoe@gmail.com"}}`
+	sealed, _ := pii.Envelope.Encrypt(context.Background(), bundle)
+	exportUUID := "e5b7c9d1-1111-4222-8333-944455556666"
+	expiresAt := time.Date(2020, 6, 2, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec("UPDATE customer_exports SET status = 'ready', bundle = \\?").
+		WithArgs(sealedBytesArg{pii, bundle}, expiresAt, exportUUID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT bundle FROM customer_exports").
+		WithArgs(exportUUID, mocks.CustomerUUIDValid).
+		WillReturnRows(sqlmock.NewRows([]string{"bundle"}).AddRow([]byte(sealed)))
+
+	h := repository.NewMySQLExportRepository(db)
+	h.PII = pii
+	assert.NoError(t, h.CompleteExport(context.Background(), exportUUID, []byte(bundle), expiresAt))
+	opened, err := h.GetExportBundle(context.Background(), mocks.CustomerUUIDValid, exportUUID)
+
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, string(opened))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSessionRepositorySessionActive(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectQuery("SELECT EXISTS \\(SELECT 1 FROM sessions WHERE session_uuid = \\? AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP\\)").
+		WithArgs("session-1").
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(false))
+
+	h := repository.NewMySQLSessionRepository(db)
+	active, err := h.SessionActive(context.Background(), "session-1")
+
+	assert.NoError(t, err)
+	assert.False(t, active)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxSinkPendingQueuesForEverySink(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, customer_uuid FROM outbox WHERE queued_at IS NULL ORDER BY id LIMIT \\? FOR UPDATE").
+		WithArgs(10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_uuid"}).AddRow(1, "c-1").AddRow(2, "c-2"))
+	mock.ExpectExec("INSERT IGNORE INTO outbox_sinks \\(sink, outbox_id, customer_uuid\\) VALUES \\(\\?, \\?, \\?\\), \\(\\?, \\?, \\?\\), \\(\\?, \\?, \\?\\), \\(\\?, \\?, \\?\\)$").
+		WithArgs("publisher", 1, "c-1", "webhooks", 1, "c-1", "publisher", 2, "c-2", "webhooks", 2, "c-2").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("UPDATE outbox SET queued_at = CURRENT_TIMESTAMP WHERE id IN \\(\\?, \\?\\)").
+		WithArgs(1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT o.id, .* FROM outbox_sinks s JOIN outbox o ON o.id = s.outbox_id WHERE s.sink = \\? AND s.status = 'pending'").
+		WithArgs("webhooks", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_uuid", "event_type", "customer_uuid", "payload", "occurred_at", "attempts"}))
+
+	h := repository.NewMySQLOutboxRepository(db)
+	h.Sinks = []string{"publisher", "webhooks"}
+	records, err := h.Sink("webhooks").Pending(context.Background(), 10)
+
+	assert.NoError(t, err)
+	assert.Empty(t, records)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestOutboxSinkPendingDeadLettersUnreadablePayloads(t *testing.T) {
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close() //nolint
+
+	pii := newTestPII(t)
+	sealed, err := pii.Envelope.Encrypt(context.Background(), `{"id":"c-2"}`)
+	assert.NoError(t, err)
+	occurredAt := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, customer_uuid FROM outbox WHERE queued_at IS NULL").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_uuid"}))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT o.id, .* FROM outbox_sinks s JOIN outbox o ON o.id = s.outbox_id WHERE s.sink = \\? AND s.status = 'pending' AND s.next_attempt_at <= NOW\\(6\\) AND NOT EXISTS").
+		WithArgs("publisher", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_uuid", "event_type", "customer_uuid", "payload", "occurred_at", "attempts"}).
+			AddRow(1, "e-1", "CustomerCreated", "c-1", "enc:v1:k9:d3JhcHBlZA:Y2lwaGVy", occurredAt, 0).
+			AddRow(2, "e-2", "CustomerCreated", "c-2", sealed, occurredAt, 0))
+	mock.ExpectExec("UPDATE outbox_sinks SET status = 'dead', attempts = attempts \\+ 1, last_error = \\?, updated_at = CURRENT_TIMESTAMP WHERE sink = \\? AND outbox_id = \\?").
+		WithArgs("payload cannot be decrypted", "publisher", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	h := repository.NewMySQLOutboxRepository(db)
+	h.PII = pii
+	h.Sinks = []string{"publisher"}
+	records, err := h.Sink("publisher").Pending(context.Background(), 10)
+
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, int64(2), records[0].ID)
+	assert.JSONEq(t, `{"id":"c-2"}`, string(records[0].Event.Payload))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"svc-customer/customer/entity"
+	"sync"
+	"time"
+)
+
+// MemorySessionRepository SessionRepository kept in process memory, for
+// tests and single instance development runs. Sessions are lost on restart.
+type MemorySessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]*memorySession
+	tokens   map[string]*memoryRefreshToken
+	now      func() time.Time
+}
+
+// memorySession session and whether it was revoked
+type memorySession struct {
+	entity.Session
+	revoked bool
+}
+
+// memoryRefreshToken refresh token hash entry
+type memoryRefreshToken struct {
+	sessionUUID string
+	used        bool
+}
+
+// NewMemorySessionRepository empty in-memory session repository
+func NewMemorySessionRepository() *MemorySessionRepository {
+	return &MemorySessionRepository{
+		sessions: map[string]*memorySession{},
+		tokens:   map[string]*memoryRefreshToken{},
+		now:      time.Now,
+	}
+}
+
+// CreateSession stores session with its first refresh token
+func (repo *MemorySessionRepository) CreateSession(ctx context.Context, session entity.Session, tokenHash string, ttl time.Duration) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	now := repo.now().UTC()
+	session.CreatedAt = now
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(ttl)
+
+	repo.sessions[session.SessionUUID] = &memorySession{Session: session}
+	repo.tokens[tokenHash] = &memoryRefreshToken{sessionUUID: session.SessionUUID}
+	return nil
+}
+
+// FindRefreshToken session of a token belonging to an active session
+func (repo *MemorySessionRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshTokenRecord, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	token, ok := repo.tokens[tokenHash]
+	if !ok {
+		return nil, entity.ErrRefreshTokenInvalid
+	}
+	session, ok := repo.activeSession(token.sessionUUID)
+	if !ok {
+		return nil, entity.ErrRefreshTokenInvalid
+	}
+	return &entity.RefreshTokenRecord{
+		SessionUUID:  session.SessionUUID,
+		CustomerUUID: session.CustomerUUID,
+		Used:         token.used,
+	}, nil
+}
+
+// RotateRefreshToken spends oldHash and stores newHash
+func (repo *MemorySessionRepository) RotateRefreshToken(ctx context.Context, sessionUUID string, oldHash string, newHash string, meta entity.SessionMeta) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	token, ok := repo.tokens[oldHash]
+	if !ok || token.sessionUUID != sessionUUID || token.used {
+		return entity.ErrRefreshTokenReused
+	}
+	token.used = true
+	repo.tokens[newHash] = &memoryRefreshToken{sessionUUID: sessionUUID}
+
+	if session, ok := repo.sessions[sessionUUID]; ok {
+		session.LastUsedAt = repo.now().UTC()
+		session.UserAgent = meta.UserAgent
+		session.IP = meta.IP
+	}
+	return nil
+}
+
+// FetchSessions active sessions of the customer, most recently used first
+func (repo *MemorySessionRepository) FetchSessions(ctx context.Context, customerUUID string) ([]*entity.Session, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	sessions := []*entity.Session{}
+	for sessionUUID, stored := range repo.sessions {
+		if stored.CustomerUUID != customerUUID {
+			continue
+		}
+		if session, ok := repo.activeSession(sessionUUID); ok {
+			copied := session.Session
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+// RevokeSession ends one active session of the customer
+func (repo *MemorySessionRepository) RevokeSession(ctx context.Context, customerUUID string, sessionUUID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	session, ok := repo.sessions[sessionUUID]
+	if !ok || session.revoked || session.CustomerUUID != customerUUID {
+		return entity.ErrNotFound
+	}
+	session.revoked = true
+	return nil
+}
+
+// RevokeSessions ends every active session of the customer
+func (repo *MemorySessionRepository) RevokeSessions(ctx context.Context, customerUUID string) error {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	for _, session := range repo.sessions {
+		if session.CustomerUUID == customerUUID {
+			session.revoked = true
+		}
+	}
+	return nil
+}
+
+// SessionActive whether the session exists and was neither revoked nor expired
+func (repo *MemorySessionRepository) SessionActive(ctx context.Context, sessionUUID string) (bool, error) {
+	repo.mu.Lock()
+	defer repo.mu.Unlock()
+
+	_, ok := repo.activeSession(sessionUUID)
+	return ok, nil
+}
+
+// activeSession not revoked and not expired session, callers hold mu
+func (repo *MemorySessionRepository) activeSession(sessionUUID string) (*memorySession, bool) {
+	session, ok := repo.sessions[sessionUUID]
+	if !ok || session.revoked || !repo.now().Before(session.ExpiresAt) {
+		return nil, false
+	}
+	return session, true
+}
@@ -0,0 +1,214 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+)
+
+// MySQLSessionRepository sessions and refresh_tokens access
+type MySQLSessionRepository struct {
+	db *sql.DB
+}
+
+// NewMySQLSessionRepository session repository sharing the customers connection pool
+func NewMySQLSessionRepository(db *sql.DB) *MySQLSessionRepository {
+	return &MySQLSessionRepository{db}
+}
+
+// CreateSession inserts the session and its first refresh token in one transaction, expiry uses the database clock
+func (repo *MySQLSessionRepository) CreateSession(ctx context.Context, session entity.Session, tokenHash string, ttl time.Duration) (err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("CreateSession: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	defer func() { err = finishTx(ctx, tx, "CreateSession", err) }()
+
+	query := `INSERT INTO sessions (session_uuid, customer_uuid, user_agent, ip, created_at, last_used_at, expires_at)
+	          VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND))`
+	if err = txExec(ctx, tx, "CreateSession", query,
+		session.SessionUUID, session.CustomerUUID, session.UserAgent, session.IP, int(ttl.Seconds())); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO refresh_tokens (token_hash, session_uuid, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`
+	return txExec(ctx, tx, "StoreRefreshToken", query, tokenHash, session.SessionUUID)
+}
+
+// FindRefreshToken session of a token belonging to an active session
+func (repo *MySQLSessionRepository) FindRefreshToken(ctx context.Context, tokenHash string) (*entity.RefreshTokenRecord, error) {
+	query := `SELECT s.session_uuid, s.customer_uuid, t.used_at IS NOT NULL
+	          FROM refresh_tokens t
+	          JOIN sessions s ON s.session_uuid = t.session_uuid AND s.revoked_at IS NULL AND s.expires_at > CURRENT_TIMESTAMP
+	          WHERE t.token_hash = ?
+	          LIMIT 1`
+
+	record := &entity.RefreshTokenRecord{}
+
+	ctx, span := tracing.StartSQL(ctx, "FindRefreshToken", query)
+	err := repo.db.QueryRowContext(ctx, query, tokenHash).Scan(&record.SessionUUID, &record.CustomerUUID, &record.Used)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, entity.ErrRefreshTokenInvalid
+		}
+		logging.FromContext(ctx).Errorf("FindRefreshToken: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return record, nil
+}
+
+// RotateRefreshToken spends oldHash, stores newHash and touches the session in one transaction
+func (repo *MySQLSessionRepository) RotateRefreshToken(ctx context.Context, sessionUUID string, oldHash string, newHash string, meta entity.SessionMeta) (err error) {
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RotateRefreshToken: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	defer func() { err = finishTx(ctx, tx, "RotateRefreshToken", err) }()
+
+	query := `UPDATE refresh_tokens SET used_at = CURRENT_TIMESTAMP WHERE token_hash = ? AND session_uuid = ? AND used_at IS NULL LIMIT 1`
+
+	spanCtx, span := tracing.StartSQL(ctx, "SpendRefreshToken", query)
+	result, err := tx.ExecContext(spanCtx, query, oldHash, sessionUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("SpendRefreshToken: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrRefreshTokenReused
+	}
+
+	query = `INSERT INTO refresh_tokens (token_hash, session_uuid, created_at) VALUES (?, ?, CURRENT_TIMESTAMP)`
+	if err = txExec(ctx, tx, "StoreRefreshToken", query, newHash, sessionUUID); err != nil {
+		return err
+	}
+
+	query = `UPDATE sessions SET last_used_at = CURRENT_TIMESTAMP, user_agent = ?, ip = ? WHERE session_uuid = ? LIMIT 1`
+	return txExec(ctx, tx, "TouchSession", query, meta.UserAgent, meta.IP, sessionUUID)
+}
+
+// FetchSessions active sessions of the customer, most recently used first
+func (repo *MySQLSessionRepository) FetchSessions(ctx context.Context, customerUUID string) (sessions []*entity.Session, err error) {
+	query := `SELECT session_uuid, customer_uuid, user_agent, ip, created_at, last_used_at, expires_at
+	          FROM sessions
+	          WHERE customer_uuid = ? AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+	          ORDER BY last_used_at DESC`
+
+	ctx, span := tracing.StartSQL(ctx, "FetchSessions", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, customerUUID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("FetchSessions: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	sessions = []*entity.Session{}
+	for rows.Next() {
+		session := &entity.Session{}
+		err = rows.Scan(
+			&session.SessionUUID,
+			&session.CustomerUUID,
+			&session.UserAgent,
+			&session.IP,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.ExpiresAt,
+		)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("FetchSessions: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		sessions = append(sessions, session)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("FetchSessions: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one active session of the customer
+func (repo *MySQLSessionRepository) RevokeSession(ctx context.Context, customerUUID string, sessionUUID string) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE session_uuid = ? AND customer_uuid = ? AND revoked_at IS NULL LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "RevokeSession", query)
+	result, err := repo.db.ExecContext(ctx, query, sessionUUID, customerUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RevokeSession: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+// RevokeSessions ends every active session of the customer
+func (repo *MySQLSessionRepository) RevokeSessions(ctx context.Context, customerUUID string) error {
+	query := `UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE customer_uuid = ? AND revoked_at IS NULL`
+
+	ctx, span := tracing.StartSQL(ctx, "RevokeSessions", query)
+	_, err := repo.db.ExecContext(ctx, query, customerUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RevokeSessions: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// SessionActive whether the session exists and was neither revoked nor expired, checked on every access token
+func (repo *MySQLSessionRepository) SessionActive(ctx context.Context, sessionUUID string) (bool, error) {
+	query := `SELECT EXISTS (SELECT 1 FROM sessions WHERE session_uuid = ? AND revoked_at IS NULL AND expires_at > CURRENT_TIMESTAMP)`
+
+	var active bool
+
+	ctx, span := tracing.StartSQL(ctx, "SessionActive", query)
+	err := repo.db.QueryRowContext(ctx, query, sessionUUID).Scan(&active)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("SessionActive: %s", err.Error())
+		return false, entity.ErrSQLError
+	}
+	return active, nil
+}
+
+// txExec runs one statement of a transaction under its own span
+func txExec(ctx context.Context, tx *sql.Tx, operation string, query string, args ...interface{}) error {
+	ctx, span := tracing.StartSQL(ctx, operation, query)
+	_, err := tx.ExecContext(ctx, query, args...)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("%s: %s", operation, err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// finishTx commits when err is nil and rolls back otherwise
+func finishTx(ctx context.Context, tx *sql.Tx, operation string, err error) error {
+	if err != nil {
+		tx.Rollback() //nolint
+		return err
+	}
+	if err = tx.Commit(); err != nil {
+		logging.FromContext(ctx).Errorf("%s: %s", operation, err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
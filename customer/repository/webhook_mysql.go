@@ -0,0 +1,363 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxWebhookError longest attempt error kept
+const maxWebhookError = 255
+
+// MySQLWebhookRepository webhook_subscriptions, webhook_deliveries and webhook_attempts access
+type MySQLWebhookRepository struct {
+	db *sql.DB
+
+	// PII seals the delivery payloads, plain text when nil
+	PII *PII
+}
+
+// NewMySQLWebhookRepository webhook repository sharing the customers connection pool
+func NewMySQLWebhookRepository(db *sql.DB) *MySQLWebhookRepository {
+	return &MySQLWebhookRepository{db: db}
+}
+
+// webhookColumns columns scanned by scanWebhook, in order
+const webhookColumns = "webhook_uuid, url, event_types, created_at"
+
+// deliveryColumns columns scanned by scanDelivery, in order
+const deliveryColumns = `d.delivery_uuid, d.webhook_uuid, d.event_uuid, d.event_type, d.status, d.attempts,
+	d.next_attempt_at, d.last_status_code, d.last_error, d.created_at, d.delivered_at`
+
+// StoreWebhook inserts a new subscription with its signing secret
+func (repo *MySQLWebhookRepository) StoreWebhook(ctx context.Context, webhook entity.Webhook, secret string) error {
+	query := `INSERT INTO webhook_subscriptions (webhook_uuid, url, event_types, secret, created_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
+
+	ctx, span := tracing.StartSQL(ctx, "StoreWebhook", query)
+	_, err := repo.db.ExecContext(ctx, query, webhook.WebhookUUID, webhook.URL, joinList(webhook.EventTypes), secret)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("StoreWebhook: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// GetWebhook active subscription by UUID
+func (repo *MySQLWebhookRepository) GetWebhook(ctx context.Context, webhookUUID string) (*entity.Webhook, error) {
+	query := "SELECT " + webhookColumns + " FROM webhook_subscriptions WHERE webhook_uuid = ? AND deleted_at IS NULL LIMIT 1"
+
+	ctx, span := tracing.StartSQL(ctx, "GetWebhook", query)
+	webhook, err := scanWebhook(repo.db.QueryRowContext(ctx, query, webhookUUID))
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetWebhook: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return webhook, nil
+}
+
+// FetchWebhooks active subscriptions, newest first
+func (repo *MySQLWebhookRepository) FetchWebhooks(ctx context.Context) (webhooks []*entity.Webhook, err error) {
+	query := "SELECT " + webhookColumns + " FROM webhook_subscriptions WHERE deleted_at IS NULL ORDER BY id DESC"
+
+	ctx, span := tracing.StartSQL(ctx, "FetchWebhooks", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("FetchWebhooks: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	webhooks = []*entity.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("FetchWebhooks: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("FetchWebhooks: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook soft deletes the subscription, queued deliveries are abandoned
+func (repo *MySQLWebhookRepository) DeleteWebhook(ctx context.Context, webhookUUID string) error {
+	query := `UPDATE webhook_subscriptions SET deleted_at = CURRENT_TIMESTAMP WHERE webhook_uuid = ? AND deleted_at IS NULL LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "DeleteWebhook", query)
+	result, err := repo.db.ExecContext(ctx, query, webhookUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("DeleteWebhook: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+// EnqueueDeliveries one delivery per active subscription listing eventType,
+// the unique (webhook_uuid, event_uuid) key ignores events queued before.
+func (repo *MySQLWebhookRepository) EnqueueDeliveries(ctx context.Context, eventID string, eventType string, payload string) error {
+	payload, err := repo.PII.sealValue(ctx, payload)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("EnqueueDeliveries: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	query := `INSERT IGNORE INTO webhook_deliveries (delivery_uuid, webhook_uuid, event_uuid, event_type, payload, status, next_attempt_at, created_at)
+	          SELECT UUID(), webhook_uuid, ?, ?, ?, 'pending', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+	          FROM webhook_subscriptions WHERE deleted_at IS NULL AND FIND_IN_SET(?, event_types)`
+
+	ctx, span := tracing.StartSQL(ctx, "EnqueueDeliveries", query)
+	_, err = repo.db.ExecContext(ctx, query, eventID, eventType, payload, eventType)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("EnqueueDeliveries: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	return nil
+}
+
+// ClaimDueDeliveries claims pending deliveries of active subscriptions due by
+// the database clock: their next attempt moves lease ahead so other
+// dispatchers skip them, and a claim that is never recorded is retried then.
+func (repo *MySQLWebhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) (deliveries []*entity.WebhookDelivery, err error) {
+	claim := uuid.New().String()
+	query := `UPDATE webhook_deliveries SET claim_token = ?, next_attempt_at = DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND)
+	          WHERE status = 'pending' AND next_attempt_at <= CURRENT_TIMESTAMP
+	          AND webhook_uuid IN (SELECT webhook_uuid FROM webhook_subscriptions WHERE deleted_at IS NULL)
+	          ORDER BY id LIMIT ?`
+
+	spanCtx, span := tracing.StartSQL(ctx, "ClaimDueDeliveries", query)
+	result, err := repo.db.ExecContext(spanCtx, query, claim, int64(lease.Seconds()), limit)
+	tracing.End(span, err)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ClaimDueDeliveries: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	deliveries = []*entity.WebhookDelivery{}
+	if claimed, err := result.RowsAffected(); err != nil || claimed == 0 {
+		return deliveries, nil
+	}
+
+	query = "SELECT " + deliveryColumns + `, s.url, s.secret, d.payload
+	          FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.webhook_uuid = d.webhook_uuid
+	          WHERE d.claim_token = ? ORDER BY d.id`
+
+	ctx, span = tracing.StartSQL(ctx, "ClaimDueDeliveries", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, claim)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ClaimDueDeliveries: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	for rows.Next() {
+		delivery, err := scanDelivery(rows, true)
+		if err == nil {
+			delivery.Payload, err = repo.PII.openValue(ctx, delivery.Payload)
+		}
+		if err != nil {
+			logging.FromContext(ctx).Errorf("ClaimDueDeliveries: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("ClaimDueDeliveries: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return deliveries, nil
+}
+
+// RecordAttempt appends to the attempt log and updates the delivery in one transaction
+func (repo *MySQLWebhookRepository) RecordAttempt(ctx context.Context, deliveryUUID string, attempt entity.WebhookAttempt, status string, retryIn time.Duration) (err error) {
+	if len(attempt.Error) > maxWebhookError {
+		attempt.Error = attempt.Error[:maxWebhookError]
+	}
+	statusCode := sql.NullInt64{Int64: int64(attempt.StatusCode), Valid: attempt.StatusCode != 0}
+	attemptError := sql.NullString{String: attempt.Error, Valid: attempt.Error != ""}
+
+	tx, err := repo.db.BeginTx(ctx, nil)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("RecordAttempt: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	defer func() { err = finishTx(ctx, tx, "RecordAttempt", err) }()
+
+	query := `INSERT INTO webhook_attempts (delivery_uuid, status_code, error, duration_ms, attempted_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)`
+	if err = txExec(ctx, tx, "InsertWebhookAttempt", query, deliveryUUID, statusCode, attemptError, attempt.DurationMs); err != nil {
+		return err
+	}
+
+	query = `UPDATE webhook_deliveries SET attempts = attempts + 1, status = ?,
+	         next_attempt_at = DATE_ADD(CURRENT_TIMESTAMP, INTERVAL ? SECOND),
+	         last_status_code = ?, last_error = ?,
+	         delivered_at = IF(? = 'delivered', CURRENT_TIMESTAMP, NULL)
+	         WHERE delivery_uuid = ? LIMIT 1`
+	return txExec(ctx, tx, "UpdateWebhookDelivery", query, status, int64(retryIn.Seconds()), statusCode, attemptError, status, deliveryUUID)
+}
+
+// FetchDeliveries latest deliveries of a webhook, newest first
+func (repo *MySQLWebhookRepository) FetchDeliveries(ctx context.Context, webhookUUID string, limit int) (deliveries []*entity.WebhookDelivery, err error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries d WHERE d.webhook_uuid = ? ORDER BY d.id DESC LIMIT ?"
+
+	ctx, span := tracing.StartSQL(ctx, "FetchDeliveries", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, webhookUUID, limit)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("FetchDeliveries: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	deliveries = []*entity.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows, false)
+		if err != nil {
+			logging.FromContext(ctx).Errorf("FetchDeliveries: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("FetchDeliveries: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return deliveries, nil
+}
+
+// GetDelivery delivery by UUID with its attempt log, oldest attempt first
+func (repo *MySQLWebhookRepository) GetDelivery(ctx context.Context, deliveryUUID string) (delivery *entity.WebhookDelivery, err error) {
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries d WHERE d.delivery_uuid = ? LIMIT 1"
+
+	spanCtx, span := tracing.StartSQL(ctx, "GetDelivery", query)
+	delivery, err = scanDelivery(repo.db.QueryRowContext(spanCtx, query, deliveryUUID), false)
+	tracing.End(span, ignoreNoRows(err))
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, entity.ErrNotFound
+		}
+		logging.FromContext(ctx).Errorf("GetDelivery: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+
+	query = `SELECT status_code, error, duration_ms, attempted_at FROM webhook_attempts WHERE delivery_uuid = ? ORDER BY id`
+
+	ctx, span = tracing.StartSQL(ctx, "FetchWebhookAttempts", query)
+	defer func() { tracing.End(span, err) }()
+
+	rows, err := repo.db.QueryContext(ctx, query, deliveryUUID)
+	if err != nil {
+		logging.FromContext(ctx).Errorf("FetchWebhookAttempts: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	defer rows.Close() //nolint
+
+	delivery.AttemptLog = []*entity.WebhookAttempt{}
+	for rows.Next() {
+		attempt := &entity.WebhookAttempt{}
+		var statusCode sql.NullInt64
+		var attemptError sql.NullString
+		if err = rows.Scan(&statusCode, &attemptError, &attempt.DurationMs, &attempt.AttemptedAt); err != nil {
+			logging.FromContext(ctx).Errorf("FetchWebhookAttempts: %s", err.Error())
+			return nil, entity.ErrSQLError
+		}
+		attempt.StatusCode = int(statusCode.Int64)
+		attempt.Error = attemptError.String
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+	if err = rows.Err(); err != nil {
+		logging.FromContext(ctx).Errorf("FetchWebhookAttempts: %s", err.Error())
+		return nil, entity.ErrSQLError
+	}
+	return delivery, nil
+}
+
+// ReplayDelivery moves a delivery back to pending, due now with a fresh attempt budget
+func (repo *MySQLWebhookRepository) ReplayDelivery(ctx context.Context, deliveryUUID string) error {
+	query := `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, delivered_at = NULL
+	          WHERE delivery_uuid = ? LIMIT 1`
+
+	ctx, span := tracing.StartSQL(ctx, "ReplayDelivery", query)
+	result, err := repo.db.ExecContext(ctx, query, deliveryUUID)
+	tracing.End(span, err)
+
+	if err != nil {
+		logging.FromContext(ctx).Errorf("ReplayDelivery: %s", err.Error())
+		return entity.ErrSQLError
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		return entity.ErrNotFound
+	}
+	return nil
+}
+
+// scanWebhook reads the webhookColumns of one row
+func scanWebhook(row rowScanner) (*entity.Webhook, error) {
+	webhook := &entity.Webhook{}
+	var eventTypes string
+
+	if err := row.Scan(&webhook.WebhookUUID, &webhook.URL, &eventTypes, &webhook.CreatedAt); err != nil {
+		return nil, err
+	}
+	webhook.EventTypes = splitList(eventTypes)
+	return webhook, nil
+}
+
+// scanDelivery reads the deliveryColumns of one row, followed by URL, secret
+// and payload when withTarget is set
+func scanDelivery(row rowScanner, withTarget bool) (*entity.WebhookDelivery, error) {
+	delivery := &entity.WebhookDelivery{}
+	var statusCode sql.NullInt64
+	var lastError sql.NullString
+	var deliveredAt sql.NullTime
+
+	dest := []interface{}{
+		&delivery.DeliveryUUID,
+		&delivery.WebhookUUID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&statusCode,
+		&lastError,
+		&delivery.CreatedAt,
+		&deliveredAt,
+	}
+	if withTarget {
+		dest = append(dest, &delivery.URL, &delivery.Secret, &delivery.Payload)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+	delivery.LastStatusCode = int(statusCode.Int64)
+	delivery.LastError = lastError.String
+	delivery.DeliveredAt = nullTime(deliveredAt)
+	return delivery, nil
+}
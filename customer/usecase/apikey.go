@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"svc-customer/auth"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/tracing"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyImpl implementation
+type APIKeyImpl struct {
+	Repo repository.APIKeyRepository
+}
+
+// Create stores a new key and returns it in plain text, the only time it is visible
+func (uc *APIKeyImpl) Create(ctx context.Context, request entity.APIKeyRequest) (_ *entity.APIKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.CreateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	if !validAPIKeyRequest(request) {
+		return nil, entity.ErrBadParamInput
+	}
+
+	plain, prefix, err := entity.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	keyUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	key := entity.APIKey{
+		KeyUUID:     keyUUID.String(),
+		Name:        request.Name,
+		Prefix:      prefix,
+		Permissions: request.Permissions,
+		AllowedIPs:  request.AllowedIPs,
+	}
+	if key.AllowedIPs == nil {
+		key.AllowedIPs = []string{}
+	}
+	if err = uc.Repo.Store(ctx, key, entity.HashAPIKey(plain)); err != nil {
+		return nil, err
+	}
+
+	stored, _, err := uc.Repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithField("key_name", key.Name).Info("CreateAPIKey: key created")
+	return &entity.APIKeyResponse{Key: plain, APIKey: stored}, nil
+}
+
+// Fetch every key without secrets
+func (uc *APIKeyImpl) Fetch(ctx context.Context) (_ []*entity.APIKey, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.FetchAPIKeys")
+	defer func() { tracing.End(span, err) }()
+
+	return uc.Repo.Fetch(ctx)
+}
+
+// Rotate issues a new secret for keyUUID keeping its name, permissions and allowlist
+func (uc *APIKeyImpl) Rotate(ctx context.Context, keyUUID string) (_ *entity.APIKeyResponse, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.RotateAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	plain, prefix, err := entity.GenerateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	if err = uc.Repo.Rotate(ctx, keyUUID, prefix, entity.HashAPIKey(plain)); err != nil {
+		return nil, err
+	}
+
+	stored, _, err := uc.Repo.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithField("key_name", stored.Name).Info("RotateAPIKey: key rotated")
+	return &entity.APIKeyResponse{Key: plain, APIKey: stored}, nil
+}
+
+// Revoke disables keyUUID permanently
+func (uc *APIKeyImpl) Revoke(ctx context.Context, keyUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.RevokeAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	if err = uc.Repo.Revoke(ctx, keyUUID); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).WithField("key_uuid", keyUUID).Info("RevokeAPIKey: key revoked")
+	return nil
+}
+
+// VerifyAPIKey resolves a plain key presented from ip into a service principal
+// whose scopes are the key permissions.
+func (uc *APIKeyImpl) VerifyAPIKey(ctx context.Context, key string, ip string) (_ *auth.Principal, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.VerifyAPIKey")
+	defer func() { tracing.End(span, err) }()
+
+	prefix, ok := entity.ParseAPIKeyPrefix(key)
+	if !ok {
+		metrics.APIKeyRequests.WithLabelValues("unknown", "invalid").Inc()
+		return nil, entity.ErrAPIKeyInvalid
+	}
+
+	stored, keyHash, err := uc.Repo.GetByPrefix(ctx, prefix)
+	if err == entity.ErrNotFound {
+		metrics.APIKeyRequests.WithLabelValues("unknown", "invalid").Inc()
+		return nil, entity.ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !entity.APIKeyMatches(key, keyHash) {
+		metrics.APIKeyRequests.WithLabelValues(stored.Name, "invalid").Inc()
+		return nil, entity.ErrAPIKeyInvalid
+	}
+	if !stored.AllowsIP(ip) {
+		metrics.APIKeyRequests.WithLabelValues(stored.Name, "ip_denied").Inc()
+		logging.FromContext(ctx).WithField("key_name", stored.Name).WithField("ip", ip).Warn("VerifyAPIKey: address not allowed")
+		return nil, entity.ErrAPIKeyIPNotAllowed
+	}
+
+	metrics.APIKeyRequests.WithLabelValues(stored.Name, "ok").Inc()
+	if err := uc.Repo.TouchLastUsed(ctx, stored.KeyUUID); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("VerifyAPIKey: last used not recorded")
+	}
+
+	return &auth.Principal{
+		Subject: "apikey:" + stored.Name,
+		Method:  "apikey",
+		Scopes:  stored.Permissions,
+	}, nil
+}
+
+// validAPIKeyRequest name and permissions are required, permissions and
+// addresses are stored comma separated so they may not contain separators.
+func validAPIKeyRequest(request entity.APIKeyRequest) bool {
+	if strings.TrimSpace(request.Name) == "" || len(request.Permissions) == 0 {
+		return false
+	}
+	for _, permission := range request.Permissions {
+		if permission == "" || strings.ContainsAny(permission, ", ") {
+			return false
+		}
+	}
+	for _, ip := range request.AllowedIPs {
+		if !entity.ValidAllowedIP(ip) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// storedKey billing key limited to one network, matching plain
+func storedKey(t *testing.T) (*entity.APIKey, string, string) {
+	plain, prefix, err := entity.GenerateAPIKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &entity.APIKey{
+		KeyUUID:     "5b1f7c4e-2f7e-4e59-9a53-3b8f1c0f4d21",
+		Name:        "billing",
+		Prefix:      prefix,
+		Permissions: []string{"customers:read:any"},
+		AllowedIPs:  []string{"10.0.0.0/8"},
+	}
+	return key, plain, prefix
+}
+
+func TestAPIKeyCreateReturnsPlainKeyOnce(t *testing.T) {
+
+	mockRepo := new(mocks.MockedAPIKeyRepository)
+	mockRepo.On("Store", mock.AnythingOfType("entity.APIKey"), mock.AnythingOfType("string")).Return(nil)
+	mockRepo.On("GetByPrefix", mock.AnythingOfType("string")).Return(&entity.APIKey{Name: "billing"}, "", nil)
+
+	u := APIKeyImpl{Repo: mockRepo}
+	created, err := u.Create(context.Background(), entity.APIKeyRequest{Name: "billing", Permissions: []string{"customers:read:any"}})
+
+	assert.NoError(t, err)
+	prefix, ok := entity.ParseAPIKeyPrefix(created.Key)
+	assert.True(t, ok)
+
+	stored := mockRepo.Calls[0].Arguments.Get(0).(entity.APIKey)
+	assert.Equal(t, prefix, stored.Prefix)
+	assert.Equal(t, entity.HashAPIKey(created.Key), mockRepo.Calls[0].Arguments.String(1))
+}
+
+func TestAPIKeyCreateRejectsBadRequest(t *testing.T) {
+
+	mockRepo := new(mocks.MockedAPIKeyRepository)
+	u := APIKeyImpl{Repo: mockRepo}
+
+	_, err := u.Create(context.Background(), entity.APIKeyRequest{Name: "billing"})
+	assert.Equal(t, entity.ErrBadParamInput, err)
+
+	_, err = u.Create(context.Background(), entity.APIKeyRequest{Name: "billing", Permissions: []string{"customers:list"}, AllowedIPs: []string{"10.0.0.0/33"}})
+	assert.Equal(t, entity.ErrBadParamInput, err)
+
+	mockRepo.AssertNotCalled(t, "Store", mock.Anything, mock.Anything)
+}
+
+func TestVerifyAPIKeyGrantsPermissionsAsScopes(t *testing.T) {
+
+	key, plain, prefix := storedKey(t)
+
+	mockRepo := new(mocks.MockedAPIKeyRepository)
+	mockRepo.On("GetByPrefix", prefix).Return(key, entity.HashAPIKey(plain), nil)
+	mockRepo.On("TouchLastUsed", key.KeyUUID).Return(nil)
+
+	u := APIKeyImpl{Repo: mockRepo}
+	principal, err := u.VerifyAPIKey(context.Background(), plain, "10.20.30.40")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey:billing", principal.Subject)
+	assert.True(t, principal.HasScope("customers:read:any"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyAPIKeyRejectsWrongSecretAndAddress(t *testing.T) {
+
+	key, plain, prefix := storedKey(t)
+
+	mockRepo := new(mocks.MockedAPIKeyRepository)
+	mockRepo.On("GetByPrefix", prefix).Return(key, entity.HashAPIKey(plain), nil)
+
+	u := APIKeyImpl{Repo: mockRepo}
+
+	_, err := u.VerifyAPIKey(context.Background(), plain+"x", "10.20.30.40")
+	assert.Equal(t, entity.ErrAPIKeyInvalid, err)
+
+	_, err = u.VerifyAPIKey(context.Background(), plain, "192.168.1.10")
+	assert.Equal(t, entity.ErrAPIKeyIPNotAllowed, err)
+
+	_, err = u.VerifyAPIKey(context.Background(), "not-a-key", "10.20.30.40")
+	assert.Equal(t, entity.ErrAPIKeyInvalid, err)
+
+	mockRepo.AssertNotCalled(t, "TouchLastUsed", key.KeyUUID)
+}
@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/tracing"
+)
+
+// MaxHistoryLimit largest page of a customer history, also used when no limit is given
+const MaxHistoryLimit = 100
+
+// AuditImpl implementation
+type AuditImpl struct {
+	Repo repository.AuditRepository
+}
+
+// History one page of the audit trail of customerUUID, deleted customers included
+func (uc *AuditImpl) History(ctx context.Context, customerUUID string, page int, limit int) (_ *entity.AuditPage, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.History")
+	defer func() { tracing.End(span, err) }()
+
+	if !entity.IsValidUUID(customerUUID) {
+		return nil, entity.ErrBadParamInput
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > MaxHistoryLimit {
+		limit = MaxHistoryLimit
+	}
+
+	entries, total, err := uc.Repo.FetchHistory(ctx, customerUUID, (page-1)*limit, limit)
+	if err != nil {
+		return nil, err
+	}
+	pages := total / limit
+	if total%limit != 0 {
+		pages++
+	}
+	return &entity.AuditPage{
+		Entries:    entries,
+		Pagination: entity.Pagination{Pages: pages, CurrentPage: page, TotalRows: total},
+	}, nil
+}
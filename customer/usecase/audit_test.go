@@ -0,0 +1,63 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// historyUUID well formed customer UUID of the history tests
+const historyUUID = "f48ac180-e8ad-4837-a3c3-66b0e96f19bf"
+
+func TestHistoryPaginates(t *testing.T) {
+
+	mockRepo := new(mocks.MockedAuditRepository)
+	entries := []*entity.AuditEntry{{Action: entity.AuditUpdate, CustomerUUID: historyUUID}}
+	mockRepo.On("FetchHistory", historyUUID, 10, 10).Return(entries, 21, nil)
+
+	u := &AuditImpl{Repo: mockRepo}
+	history, err := u.History(context.Background(), historyUUID, 2, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, entries, history.Entries)
+	assert.Equal(t, entity.Pagination{Pages: 3, CurrentPage: 2, TotalRows: 21}, history.Pagination)
+}
+
+func TestHistoryCapsLimit(t *testing.T) {
+
+	mockRepo := new(mocks.MockedAuditRepository)
+	mockRepo.On("FetchHistory", historyUUID, 0, MaxHistoryLimit).Return([]*entity.AuditEntry{}, 0, nil)
+
+	u := &AuditImpl{Repo: mockRepo}
+	history, err := u.History(context.Background(), historyUUID, 0, 5000)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, history.Pagination.Pages)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestHistoryInvalidUUID(t *testing.T) {
+
+	mockRepo := new(mocks.MockedAuditRepository)
+	u := &AuditImpl{Repo: mockRepo}
+	_, err := u.History(context.Background(), "not-a-uuid", 1, 10)
+	assert.Equal(t, entity.ErrBadParamInput, err)
+	mockRepo.AssertNotCalled(t, "FetchHistory")
+}
+
+func TestDiffCustomersRedactsSecrets(t *testing.T) {
+
+	verifiedAt := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	before := entity.Customer{Name: "Jhon", LastName: "Doe", Password: "old-hash", Email: "jhon@gmail.com", Dni: "264573076", CustomerUUID: historyUUID}
+	after := entity.Customer{Name: "John", LastName: "Doe", Password: "new-hash", Email: "john@gmail.com", Dni: "264573076", Phone: "5600000000", EmailVerifiedAt: &verifiedAt}
+
+	changes := entity.DiffCustomers(before, after)
+	assert.Contains(t, changes, entity.FieldChange{Field: "name", Before: entity.AuditRedacted, After: entity.AuditRedacted})
+	assert.Contains(t, changes, entity.FieldChange{Field: "password", Before: entity.AuditRedacted, After: entity.AuditRedacted})
+	assert.Contains(t, changes, entity.FieldChange{Field: "email", Before: entity.AuditRedacted, After: entity.AuditRedacted})
+	assert.Contains(t, changes, entity.FieldChange{Field: "phone", Before: nil, After: entity.AuditRedacted})
+	assert.Contains(t, changes, entity.FieldChange{Field: "email_verified_at", Before: nil, After: verifiedAt})
+	assert.Len(t, changes, 5, "the unchanged dni is not listed")
+}
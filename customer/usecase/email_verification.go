@@ -0,0 +1,126 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/notify"
+	"svc-customer/tracing"
+	"time"
+)
+
+// DefaultVerificationTokenTTL verification links stop working after 24 hours
+const DefaultVerificationTokenTTL = 24 * time.Hour
+
+// DefaultResendInterval at most one resent verification email per minute and address
+const DefaultResendInterval = time.Minute
+
+// EmailVerifier issues verification tokens, GetCustomerImpl calls it after
+// a customer is stored or changes email.
+type EmailVerifier interface {
+	SendVerification(ctx context.Context, email string) error
+}
+
+// EmailVerificationImpl implementation
+type EmailVerificationImpl struct {
+	Repo     repository.EmailVerificationRepository
+	Notifier notify.Notifier
+	// VerifyURL page receiving the token as ?token=, e.g. https://app.example.com/verify-email
+	VerifyURL string
+	// TokenTTL lifetime of verification tokens, DefaultVerificationTokenTTL when zero
+	TokenTTL time.Duration
+	// ResendInterval minimum time between resends, DefaultResendInterval when zero
+	ResendInterval time.Duration
+}
+
+// SendVerification emails a verification link to the customer owning email
+func (uc *EmailVerificationImpl) SendVerification(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.SendVerification")
+	defer func() { tracing.End(span, err) }()
+
+	token, tokenHash, err := entity.GenerateToken()
+	if err != nil {
+		return err
+	}
+	ttl := uc.TokenTTL
+	if ttl == 0 {
+		ttl = DefaultVerificationTokenTTL
+	}
+	if err = uc.Repo.StoreEmailToken(ctx, email, tokenHash, ttl); err != nil {
+		return err
+	}
+
+	err = uc.Notifier.Notify(ctx, notify.Message{
+		Channel: notify.Email,
+		To:      email,
+		Subject: "Verify your email",
+		Body:    "Use this link to confirm your email address, it expires in " + ttl.String() + ": " + uc.VerifyURL + "?token=" + token,
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).WithField("email", email).Info("SendVerification: verification token sent")
+	return nil
+}
+
+// VerifyEmail marks the address token was sent to as verified and spends it
+func (uc *EmailVerificationImpl) VerifyEmail(ctx context.Context, token string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.VerifyEmail")
+	defer func() { tracing.End(span, err) }()
+
+	if token == "" {
+		return entity.ErrVerificationTokenInvalid
+	}
+	tokenHash := entity.HashToken(token)
+
+	customerUUID, email, err := uc.Repo.FindEmailToken(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	if err = uc.Repo.ConsumeEmailToken(ctx, tokenHash); err != nil {
+		return err
+	}
+	if err = uc.Repo.MarkEmailVerified(ctx, customerUUID, email); err != nil {
+		return err
+	}
+	metrics.EmailsVerified.Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("VerifyEmail: email verified")
+	return nil
+}
+
+// ResendVerification sends a new link unless email is unknown, already
+// verified or got one within ResendInterval. None of those cases is reported
+// so the endpoint cannot enumerate accounts.
+func (uc *EmailVerificationImpl) ResendVerification(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.ResendVerification")
+	defer func() { tracing.End(span, err) }()
+
+	verified, err := uc.Repo.EmailVerified(ctx, email)
+	if err == entity.ErrNotFound {
+		logging.FromContext(ctx).WithField("email", email).Info("ResendVerification: unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if verified {
+		logging.FromContext(ctx).WithField("email", email).Info("ResendVerification: already verified")
+		return nil
+	}
+
+	interval := uc.ResendInterval
+	if interval == 0 {
+		interval = DefaultResendInterval
+	}
+	recent, err := uc.Repo.RecentEmailToken(ctx, email, interval)
+	if err != nil {
+		return err
+	}
+	if recent {
+		logging.FromContext(ctx).WithField("email", email).Info("ResendVerification: throttled")
+		return nil
+	}
+	return uc.SendVerification(ctx, email)
+}
@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/notify"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendVerificationStoresHashOfSentToken(t *testing.T) {
+
+	mockRepo := new(mocks.MockedEmailVerificationRepository)
+	mockRepo.On("StoreEmailToken", "jhond@gmail.com", mock.AnythingOfType("string"), DefaultVerificationTokenTTL).Return(nil)
+
+	recorder := &notify.Recorder{}
+	u := EmailVerificationImpl{Repo: mockRepo, Notifier: recorder, VerifyURL: "https://app.example.com/verify-email"}
+
+	assert.NoError(t, u.SendVerification(context.Background(), "jhond@gmail.com"))
+
+	sent := recorder.Messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "jhond@gmail.com", sent[0].To)
+
+	token := sent[0].Body[strings.Index(sent[0].Body, "?token=")+len("?token="):]
+	assert.Equal(t, entity.HashToken(token), mockRepo.Calls[0].Arguments.String(1))
+}
+
+func TestVerifyEmailSpendsTokenAndMarksAddress(t *testing.T) {
+
+	tokenHash := entity.HashToken("verify-token")
+
+	mockRepo := new(mocks.MockedEmailVerificationRepository)
+	mockRepo.On("FindEmailToken", tokenHash).Return(resetCustomerUUID, "jhond@gmail.com", nil)
+	mockRepo.On("ConsumeEmailToken", tokenHash).Return(nil)
+	mockRepo.On("MarkEmailVerified", resetCustomerUUID, "jhond@gmail.com").Return(nil)
+
+	u := EmailVerificationImpl{Repo: mockRepo}
+
+	assert.NoError(t, u.VerifyEmail(context.Background(), "verify-token"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyEmailSpentToken(t *testing.T) {
+
+	tokenHash := entity.HashToken("verify-token")
+
+	mockRepo := new(mocks.MockedEmailVerificationRepository)
+	mockRepo.On("FindEmailToken", tokenHash).Return("", "", entity.ErrVerificationTokenInvalid)
+
+	u := EmailVerificationImpl{Repo: mockRepo}
+
+	assert.Equal(t, entity.ErrVerificationTokenInvalid, u.VerifyEmail(context.Background(), "verify-token"))
+	mockRepo.AssertNotCalled(t, "MarkEmailVerified", mock.Anything, mock.Anything)
+}
+
+func TestResendVerificationThrottled(t *testing.T) {
+
+	mockRepo := new(mocks.MockedEmailVerificationRepository)
+	mockRepo.On("EmailVerified", "jhond@gmail.com").Return(false, nil)
+	mockRepo.On("RecentEmailToken", "jhond@gmail.com", DefaultResendInterval).Return(true, nil)
+
+	recorder := &notify.Recorder{}
+	u := EmailVerificationImpl{Repo: mockRepo, Notifier: recorder}
+
+	assert.NoError(t, u.ResendVerification(context.Background(), "jhond@gmail.com"))
+	assert.Empty(t, recorder.Messages())
+	mockRepo.AssertNotCalled(t, "StoreEmailToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResendVerificationSkipsVerifiedAndUnknown(t *testing.T) {
+
+	mockRepo := new(mocks.MockedEmailVerificationRepository)
+	mockRepo.On("EmailVerified", "jhond@gmail.com").Return(true, nil)
+	mockRepo.On("EmailVerified", "nobody@gmail.com").Return(false, entity.ErrNotFound)
+
+	recorder := &notify.Recorder{}
+	u := EmailVerificationImpl{Repo: mockRepo, Notifier: recorder}
+
+	assert.NoError(t, u.ResendVerification(context.Background(), "jhond@gmail.com"))
+	assert.NoError(t, u.ResendVerification(context.Background(), "nobody@gmail.com"))
+	assert.Empty(t, recorder.Messages())
+}
+
+func TestResendVerificationSendsNewLink(t *testing.T) {
+
+	mockRepo := new(mocks.MockedEmailVerificationRepository)
+	mockRepo.On("EmailVerified", "jhond@gmail.com").Return(false, nil)
+	mockRepo.On("RecentEmailToken", "jhond@gmail.com", DefaultResendInterval).Return(false, nil)
+	mockRepo.On("StoreEmailToken", "jhond@gmail.com", mock.AnythingOfType("string"), DefaultVerificationTokenTTL).Return(nil)
+
+	recorder := &notify.Recorder{}
+	u := EmailVerificationImpl{Repo: mockRepo, Notifier: recorder}
+
+	assert.NoError(t, u.ResendVerification(context.Background(), "jhond@gmail.com"))
+	assert.Len(t, recorder.Messages(), 1)
+}
+
+// verifierFunc records addresses GetCustomerImpl asks to verify
+type verifierFunc func(ctx context.Context, email string) error
+
+func (f verifierFunc) SendVerification(ctx context.Context, email string) error {
+	return f(ctx, email)
+}
+
+func TestStoreSendsVerification(t *testing.T) {
+
+	customer := entity.Customer{Name: "Jhon", Email: "jhond@gmail.com"}
+
+	mockRepo := new(mocks.MockedRepository)
+	mockRepo.On("Store", customer).Return(nil)
+
+	var verified []string
+	u := GetCustomerImpl{Repo: mockRepo, Verifier: verifierFunc(func(ctx context.Context, email string) error {
+		verified = append(verified, email)
+		return nil
+	})}
+
+	assert.NoError(t, u.Store(context.Background(), customer))
+	assert.Equal(t, []string{"jhond@gmail.com"}, verified)
+}
+
+func TestUpdateByUUIDSendsVerificationOnlyOnEmailChange(t *testing.T) {
+
+	mockRepo := new(mocks.MockedRepository)
+	mockRepo.On("GetByUUID", resetCustomerUUID).Return(&entity.Customer{Email: "jhond@gmail.com"}, nil)
+	mockRepo.On("UpdateByUUID", mock.Anything, resetCustomerUUID).Return(nil)
+
+	var verified []string
+	u := GetCustomerImpl{Repo: mockRepo, Verifier: verifierFunc(func(ctx context.Context, email string) error {
+		verified = append(verified, email)
+		return nil
+	})}
+
+	assert.NoError(t, u.UpdateByUUID(context.Background(), entity.Customer{Email: "jhond@gmail.com"}, resetCustomerUUID))
+	assert.Empty(t, verified)
+
+	assert.NoError(t, u.UpdateByUUID(context.Background(), entity.Customer{Email: "new@gmail.com"}, resetCustomerUUID))
+	assert.Equal(t, []string{"new@gmail.com"}, verified)
+
+	assert.NoError(t, u.UpdateByUUID(context.Background(), entity.Customer{Name: "Jhon"}, resetCustomerUUID))
+	assert.Len(t, verified, 1)
+}
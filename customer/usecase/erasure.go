@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/auth"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/tracing"
+	"time"
+)
+
+// DefaultErasureRetention how long deleted customers keep their data before the purge erases it
+const DefaultErasureRetention = 30 * 24 * time.Hour
+
+// erasureBatchSize customers erased per purge query
+const erasureBatchSize = 100
+
+// purgePrincipal actor of the purge job in the audit trail
+var purgePrincipal = &auth.Principal{Subject: "retention-purge", Method: "system"}
+
+// ErasureImpl implementation
+type ErasureImpl struct {
+	Repo repository.ErasureRepository
+	// Retention time a deleted customer is kept, DefaultErasureRetention when zero
+	Retention time.Duration
+	// Mode erasure mode of the purge, entity.ErasureAnonymise when empty
+	Mode string
+	// Now clock, time.Now when nil
+	Now func() time.Time
+}
+
+// Erase erases the personal data of customerUUID as request.Mode says
+func (uc *ErasureImpl) Erase(ctx context.Context, customerUUID string, request entity.ErasureRequest) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Erase")
+	defer func() { tracing.End(span, err) }()
+
+	if request.Mode == "" {
+		request.Mode = entity.ErasureAnonymise
+	}
+	if !entity.IsValidUUID(customerUUID) || !entity.IsValidErasureMode(request.Mode) {
+		return entity.ErrBadParamInput
+	}
+	return uc.erase(ctx, customerUUID, request.Mode)
+}
+
+// Purge erases the customers deleted longer than the retention period and
+// returns how many were erased. It stops at the first failure, the customer
+// is retried on the next run.
+func (uc *ErasureImpl) Purge(ctx context.Context) (erased int, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Purge")
+	defer func() { tracing.End(span, err) }()
+
+	now := time.Now
+	if uc.Now != nil {
+		now = uc.Now
+	}
+	retention := uc.Retention
+	if retention == 0 {
+		retention = DefaultErasureRetention
+	}
+	mode := uc.Mode
+	if mode == "" {
+		mode = entity.ErasureAnonymise
+	}
+	ctx = auth.NewContext(ctx, purgePrincipal)
+
+	for {
+		customerUUIDs, err := uc.Repo.ErasableCustomers(ctx, now().Add(-retention), erasureBatchSize)
+		if err != nil {
+			return erased, err
+		}
+		for _, customerUUID := range customerUUIDs {
+			if err = uc.erase(ctx, customerUUID, mode); err != nil {
+				return erased, err
+			}
+			erased++
+		}
+		if len(customerUUIDs) < erasureBatchSize {
+			return erased, nil
+		}
+	}
+}
+
+// RunPurge purges every interval until ctx is done
+func (uc *ErasureImpl) RunPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if erased, err := uc.Purge(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("Purge: %s", err.Error())
+		} else if erased > 0 {
+			logging.FromContext(ctx).Infof("Purge: %d customers erased", erased)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// erase erases one customer and records it
+func (uc *ErasureImpl) erase(ctx context.Context, customerUUID string, mode string) error {
+	if err := uc.Repo.EraseCustomer(ctx, customerUUID, mode); err != nil {
+		return err
+	}
+	metrics.CustomersErased.WithLabelValues(mode).Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).WithField("mode", mode).Info("Erase: customer erased")
+	return nil
+}
@@ -0,0 +1,73 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// erasureNow fixed clock of the erasure tests
+var erasureNow = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func TestEraseDefaultsToAnonymise(t *testing.T) {
+
+	mockRepo := new(mocks.MockedErasureRepository)
+	mockRepo.On("EraseCustomer", historyUUID, entity.ErasureAnonymise).Return(nil)
+
+	u := &ErasureImpl{Repo: mockRepo}
+	err := u.Erase(context.Background(), historyUUID, entity.ErasureRequest{})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestEraseRejectsUnknownMode(t *testing.T) {
+
+	mockRepo := new(mocks.MockedErasureRepository)
+
+	u := &ErasureImpl{Repo: mockRepo}
+	err := u.Erase(context.Background(), historyUUID, entity.ErasureRequest{Mode: "shred"})
+
+	assert.Equal(t, entity.ErrBadParamInput, err)
+	mockRepo.AssertNotCalled(t, "EraseCustomer", mock.Anything, mock.Anything)
+}
+
+func TestPurgeErasesCustomersPastRetention(t *testing.T) {
+
+	mockRepo := new(mocks.MockedErasureRepository)
+	mockRepo.On("ErasableCustomers", erasureNow.Add(-48*time.Hour), erasureBatchSize).Return([]string{"a", "b"}, nil)
+	mockRepo.On("EraseCustomer", "a", entity.ErasureHardDelete).Return(nil)
+	mockRepo.On("EraseCustomer", "b", entity.ErasureHardDelete).Return(nil)
+
+	u := &ErasureImpl{
+		Repo:      mockRepo,
+		Retention: 48 * time.Hour,
+		Mode:      entity.ErasureHardDelete,
+		Now:       func() time.Time { return erasureNow },
+	}
+	erased, err := u.Purge(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, erased)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeStopsAtFirstFailure(t *testing.T) {
+
+	mockRepo := new(mocks.MockedErasureRepository)
+	mockRepo.On("ErasableCustomers", erasureNow.Add(-DefaultErasureRetention), erasureBatchSize).Return([]string{"a", "b"}, nil)
+	mockRepo.On("EraseCustomer", "a", entity.ErasureAnonymise).Return(errors.New("lock wait timeout"))
+
+	u := &ErasureImpl{Repo: mockRepo, Now: func() time.Time { return erasureNow }}
+	erased, err := u.Purge(context.Background())
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, erased)
+	mockRepo.AssertNotCalled(t, "EraseCustomer", "b", entity.ErasureAnonymise)
+}
@@ -0,0 +1,276 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultExportTTL how long the bundle of an asynchronous export can be downloaded
+const DefaultExportTTL = 24 * time.Hour
+
+// exportBatchSize export jobs generated per worker poll
+const exportBatchSize = 10
+
+// ExportImpl implementation, it aggregates every repository holding customer data
+type ExportImpl struct {
+	Customers repository.Repository
+	Sessions  repository.SessionRepository
+	Audit     repository.AuditRepository
+	Jobs      repository.ExportRepository
+	// TTL lifetime of asynchronous bundles, DefaultExportTTL when zero
+	TTL time.Duration
+	// Now clock, time.Now when nil
+	Now func() time.Time
+}
+
+// Export generates the export of customerUUID right away
+func (uc *ExportImpl) Export(ctx context.Context, customerUUID string, format string) (_ *entity.ExportBundle, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Export")
+	defer func() { tracing.End(span, err) }()
+
+	if !entity.IsValidUUID(customerUUID) || !entity.IsValidExportFormat(format) {
+		return nil, entity.ErrBadParamInput
+	}
+	data, err := uc.generate(ctx, customerUUID, format)
+	if err != nil {
+		return nil, err
+	}
+	metrics.CustomerExports.WithLabelValues("sync").Inc()
+	return exportBundle(customerUUID, format, data), nil
+}
+
+// RequestExport queues an export of customerUUID for the background worker
+func (uc *ExportImpl) RequestExport(ctx context.Context, customerUUID string, format string) (_ *entity.ExportJob, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.RequestExport")
+	defer func() { tracing.End(span, err) }()
+
+	if !entity.IsValidUUID(customerUUID) || !entity.IsValidExportFormat(format) {
+		return nil, entity.ErrBadParamInput
+	}
+	// Unknown customers fail now rather than in the worker
+	if _, err = uc.Customers.GetByUUID(ctx, customerUUID); err != nil {
+		return nil, err
+	}
+	exportUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	job := entity.ExportJob{
+		ExportUUID:   exportUUID.String(),
+		CustomerUUID: customerUUID,
+		Format:       format,
+		Status:       entity.ExportPending,
+		CreatedAt:    uc.now().UTC().Truncate(time.Second),
+	}
+	if err = uc.Jobs.StoreExport(ctx, job); err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).WithField("export_uuid", job.ExportUUID).Info("RequestExport: export queued")
+	return &job, nil
+}
+
+// GetExport status of an export job of customerUUID
+func (uc *ExportImpl) GetExport(ctx context.Context, customerUUID string, exportUUID string) (_ *entity.ExportJob, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetExport")
+	defer func() { tracing.End(span, err) }()
+
+	return uc.Jobs.GetExport(ctx, customerUUID, exportUUID)
+}
+
+// DownloadExport bundle of a ready export job of customerUUID
+func (uc *ExportImpl) DownloadExport(ctx context.Context, customerUUID string, exportUUID string) (_ *entity.ExportBundle, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.DownloadExport")
+	defer func() { tracing.End(span, err) }()
+
+	job, err := uc.Jobs.GetExport(ctx, customerUUID, exportUUID)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != entity.ExportReady {
+		return nil, entity.ErrExportNotReady
+	}
+	data, err := uc.Jobs.GetExportBundle(ctx, customerUUID, exportUUID)
+	if err != nil {
+		return nil, err
+	}
+	return exportBundle(customerUUID, job.Format, data), nil
+}
+
+// ProcessExports generates the bundles of pending jobs and deletes expired
+// ones, it returns how many jobs were completed
+func (uc *ExportImpl) ProcessExports(ctx context.Context) (int, error) {
+	if _, err := uc.Jobs.DeleteExpiredExports(ctx); err != nil {
+		return 0, err
+	}
+	jobs, err := uc.Jobs.PendingExports(ctx, exportBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	ttl := uc.TTL
+	if ttl == 0 {
+		ttl = DefaultExportTTL
+	}
+
+	completed := 0
+	for _, job := range jobs {
+		expiresAt := uc.now().Add(ttl)
+		data, err := uc.generate(ctx, job.CustomerUUID, job.Format)
+		if err != nil {
+			// The customer may have been deleted or erased since the request
+			logging.FromContext(ctx).WithField("export_uuid", job.ExportUUID).Warnf("ProcessExports: %s", err.Error())
+			if err = uc.Jobs.FailExport(ctx, job.ExportUUID, err.Error(), expiresAt); err != nil {
+				return completed, err
+			}
+			continue
+		}
+		if err = uc.Jobs.CompleteExport(ctx, job.ExportUUID, data, expiresAt); err != nil {
+			return completed, err
+		}
+		metrics.CustomerExports.WithLabelValues("async").Inc()
+		completed++
+	}
+	return completed, nil
+}
+
+// RunExports calls ProcessExports every interval until ctx is cancelled
+func (uc *ExportImpl) RunExports(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := uc.ProcessExports(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("ProcessExports: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// collect gathers the customer record, its sessions and its whole audit trail
+func (uc *ExportImpl) collect(ctx context.Context, customerUUID string) (*entity.CustomerExport, error) {
+	customer, err := uc.Customers.GetByUUID(ctx, customerUUID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := uc.Sessions.FetchSessions(ctx, customerUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []*entity.AuditEntry{}
+	for {
+		entries, total, err := uc.Audit.FetchHistory(ctx, customerUUID, len(history), MaxHistoryLimit)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, entries...)
+		if len(entries) == 0 || len(history) >= total {
+			break
+		}
+	}
+
+	return &entity.CustomerExport{
+		GeneratedAt: uc.now().UTC(),
+		Customer:    customer.Safe(),
+		Sessions:    sessions,
+		History:     history,
+	}, nil
+}
+
+// generate encodes the export of customerUUID in format
+func (uc *ExportImpl) generate(ctx context.Context, customerUUID string, format string) ([]byte, error) {
+	export, err := uc.collect(ctx, customerUUID)
+	if err != nil {
+		return nil, err
+	}
+	if format == entity.ExportZip {
+		return zipExport(export)
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+// now current time of the usecase clock
+func (uc *ExportImpl) now() time.Time {
+	if uc.Now != nil {
+		return uc.Now()
+	}
+	return time.Now()
+}
+
+// zipExport one JSON file per section and a manifest.json with their checksums
+func zipExport(export *entity.CustomerExport) ([]byte, error) {
+	sections := []struct {
+		name  string
+		value interface{}
+	}{
+		{"customer.json", export.Customer},
+		{"sessions.json", export.Sessions},
+		{"history.json", export.History},
+	}
+	manifest := entity.ExportManifest{
+		CustomerUUID: export.Customer.CustomerUUID,
+		GeneratedAt:  export.GeneratedAt,
+		Files:        []entity.ExportFile{},
+	}
+
+	buffer := &bytes.Buffer{}
+	archive := zip.NewWriter(buffer)
+	write := func(name string, value interface{}) ([]byte, error) {
+		data, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		file, err := archive.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		_, err = file.Write(data)
+		return data, err
+	}
+
+	for _, section := range sections {
+		data, err := write(section.name, section.value)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		manifest.Files = append(manifest.Files, entity.ExportFile{Name: section.name, Bytes: len(data), SHA256: hex.EncodeToString(sum[:])})
+	}
+	if _, err := write("manifest.json", manifest); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// exportBundle download of an encoded export
+func exportBundle(customerUUID string, format string, data []byte) *entity.ExportBundle {
+	bundle := &entity.ExportBundle{
+		ContentType: "application/json",
+		Filename:    fmt.Sprintf("customer-%s.json", customerUUID),
+		Data:        data,
+	}
+	if format == entity.ExportZip {
+		bundle.ContentType = "application/zip"
+		bundle.Filename = fmt.Sprintf("customer-%s.zip", customerUUID)
+	}
+	return bundle
+}
@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/customer/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// exportNow fixed clock of the export tests
+var exportNow = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newExportImpl(t *testing.T) (*ExportImpl, *mocks.MockedAuditRepository, *mocks.MockedExportRepository) {
+	customers := new(mocks.MockedRepository)
+	customers.On("GetByUUID", historyUUID).Return(&entity.Customer{CustomerUUID: historyUUID, Name: "Jhon", Password: "hash"}, nil)
+	customers.On("GetByUUID", resetCustomerUUID).Return(nil, entity.ErrNotFound)
+
+	sessions := repository.NewMemorySessionRepository()
+	err := sessions.CreateSession(context.Background(), entity.Session{SessionUUID: "s-1", CustomerUUID: historyUUID, UserAgent: "curl/7.68"}, "hash-1", time.Hour)
+	assert.NoError(t, err)
+
+	audit := new(mocks.MockedAuditRepository)
+	jobs := new(mocks.MockedExportRepository)
+	return &ExportImpl{
+		Customers: customers,
+		Sessions:  sessions,
+		Audit:     audit,
+		Jobs:      jobs,
+		Now:       func() time.Time { return exportNow },
+	}, audit, jobs
+}
+
+func TestExportJSONAggregatesEveryRepository(t *testing.T) {
+
+	u, audit, _ := newExportImpl(t)
+	page := make([]*entity.AuditEntry, MaxHistoryLimit)
+	for i := range page {
+		page[i] = &entity.AuditEntry{Action: entity.AuditUpdate}
+	}
+	audit.On("FetchHistory", historyUUID, 0, MaxHistoryLimit).Return(page, MaxHistoryLimit+1, nil)
+	audit.On("FetchHistory", historyUUID, MaxHistoryLimit, MaxHistoryLimit).Return([]*entity.AuditEntry{{Action: entity.AuditCreate}}, MaxHistoryLimit+1, nil)
+
+	bundle, err := u.Export(context.Background(), historyUUID, entity.ExportJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/json", bundle.ContentType)
+
+	var export entity.CustomerExport
+	assert.NoError(t, json.Unmarshal(bundle.Data, &export))
+	assert.Equal(t, "Jhon", export.Customer.Name)
+	assert.Empty(t, export.Customer.Password)
+	assert.Len(t, export.Sessions, 1)
+	assert.Len(t, export.History, MaxHistoryLimit+1)
+	assert.Equal(t, exportNow, export.GeneratedAt)
+}
+
+func TestExportZipHasManifest(t *testing.T) {
+
+	u, audit, _ := newExportImpl(t)
+	audit.On("FetchHistory", historyUUID, 0, MaxHistoryLimit).Return([]*entity.AuditEntry{}, 0, nil)
+
+	bundle, err := u.Export(context.Background(), historyUUID, entity.ExportZip)
+	assert.NoError(t, err)
+	assert.Equal(t, "application/zip", bundle.ContentType)
+
+	archive, err := zip.NewReader(bytes.NewReader(bundle.Data), int64(len(bundle.Data)))
+	assert.NoError(t, err)
+	files := map[string][]byte{}
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		assert.NoError(t, err)
+		files[file.Name], _ = ioutil.ReadAll(reader)
+		reader.Close() //nolint
+	}
+
+	var manifest entity.ExportManifest
+	assert.NoError(t, json.Unmarshal(files["manifest.json"], &manifest))
+	assert.Len(t, manifest.Files, 3)
+	for _, file := range manifest.Files {
+		sum := sha256.Sum256(files[file.Name])
+		assert.Equal(t, hex.EncodeToString(sum[:]), file.SHA256, file.Name)
+	}
+}
+
+func TestRequestExportUnknownCustomer(t *testing.T) {
+
+	u, _, jobs := newExportImpl(t)
+
+	_, err := u.RequestExport(context.Background(), resetCustomerUUID, entity.ExportJSON)
+	assert.Equal(t, entity.ErrNotFound, err)
+	jobs.AssertNotCalled(t, "StoreExport", mock.Anything)
+}
+
+func TestDownloadExportNotReady(t *testing.T) {
+
+	u, _, jobs := newExportImpl(t)
+	jobs.On("GetExport", historyUUID, "e-1").Return(&entity.ExportJob{Status: entity.ExportPending}, nil)
+
+	_, err := u.DownloadExport(context.Background(), historyUUID, "e-1")
+	assert.Equal(t, entity.ErrExportNotReady, err)
+	jobs.AssertNotCalled(t, "GetExportBundle", historyUUID, "e-1")
+}
+
+func TestProcessExportsCompletesAndFailsJobs(t *testing.T) {
+
+	u, audit, jobs := newExportImpl(t)
+	audit.On("FetchHistory", historyUUID, 0, MaxHistoryLimit).Return([]*entity.AuditEntry{}, 0, nil)
+	jobs.On("DeleteExpiredExports").Return(int64(0), nil)
+	jobs.On("PendingExports", exportBatchSize).Return([]*entity.ExportJob{
+		{ExportUUID: "e-1", CustomerUUID: historyUUID, Format: entity.ExportJSON},
+		{ExportUUID: "e-2", CustomerUUID: resetCustomerUUID, Format: entity.ExportJSON},
+	}, nil)
+	jobs.On("CompleteExport", "e-1", mock.AnythingOfType("[]uint8"), exportNow.Add(DefaultExportTTL)).Return(nil)
+	jobs.On("FailExport", "e-2", entity.ErrNotFound.Error(), exportNow.Add(DefaultExportTTL)).Return(nil)
+
+	completed, err := u.ProcessExports(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, completed)
+	jobs.AssertExpectations(t)
+}
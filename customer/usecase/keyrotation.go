@@ -0,0 +1,62 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/tracing"
+)
+
+// DefaultKeyRotationBatch customers re-encrypted per transaction
+const DefaultKeyRotationBatch = 500
+
+// KeyRotationImpl implementation
+type KeyRotationImpl struct {
+	Repo repository.KeyRotationRepository
+	// BatchSize rows per transaction, DefaultKeyRotationBatch when zero
+	BatchSize int
+}
+
+// Rotate re-encrypts the PII of every row after the row afterID of table
+// under the active master key batch by batch, then every row of the
+// repository.PIITables that follow it. An empty table starts with the first
+// one. A failed rotation is resumed from the Table and LastID it returns,
+// rows rewritten before stay rewritten.
+func (uc *KeyRotationImpl) Rotate(ctx context.Context, table string, afterID int64) (total entity.KeyRotation, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Rotate")
+	defer func() { tracing.End(span, err) }()
+
+	limit := uc.BatchSize
+	if limit <= 0 {
+		limit = DefaultKeyRotationBatch
+	}
+	tables := repository.PIITables
+	if table != "" {
+		for len(tables) > 0 && tables[0] != table {
+			tables = tables[1:]
+		}
+		if len(tables) == 0 {
+			return entity.KeyRotation{Table: table, LastID: afterID}, repository.ErrUnknownPIITable
+		}
+	}
+
+	for _, table := range tables {
+		total.Table, total.LastID = table, afterID
+		afterID = 0
+		for {
+			batch, err := uc.Repo.ReencryptPII(ctx, table, total.LastID, limit)
+			if err != nil {
+				return total, err
+			}
+			total.LastID = batch.LastID
+			total.Scanned += batch.Scanned
+			total.Rewritten += batch.Rewritten
+			logging.FromContext(ctx).Infof("Rotate: %d rows read, %d re-encrypted, last row %s %d", total.Scanned, total.Rewritten, table, total.LastID)
+			if batch.Scanned < limit {
+				break
+			}
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/customer/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRotateWalksEveryBatch(t *testing.T) {
+
+	repo := new(mocks.MockedKeyRotationRepository)
+	repo.On("ReencryptPII", "customers", int64(7), 2).Return(entity.KeyRotation{LastID: 9, Scanned: 2, Rewritten: 2}, nil)
+	repo.On("ReencryptPII", "customers", int64(9), 2).Return(entity.KeyRotation{LastID: 12, Scanned: 2, Rewritten: 0}, nil)
+	repo.On("ReencryptPII", "customers", int64(12), 2).Return(entity.KeyRotation{LastID: 13, Scanned: 1, Rewritten: 1}, nil)
+	repo.On("ReencryptPII", mock.Anything, int64(0), 2).Return(entity.KeyRotation{}, nil)
+
+	u := &KeyRotationImpl{Repo: repo, BatchSize: 2}
+	rotation, err := u.Rotate(context.Background(), "customers", 7)
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.KeyRotation{Table: "phone_verifications", Scanned: 5, Rewritten: 3}, rotation)
+	repo.AssertExpectations(t)
+}
+
+func TestRotateRewritesEverySealedCopy(t *testing.T) {
+
+	repo := new(mocks.MockedKeyRotationRepository)
+	for _, table := range repository.PIITables {
+		repo.On("ReencryptPII", table, int64(0), DefaultKeyRotationBatch).Return(entity.KeyRotation{LastID: 3, Scanned: 3, Rewritten: 1}, nil)
+	}
+
+	u := &KeyRotationImpl{Repo: repo}
+	rotation, err := u.Rotate(context.Background(), "", 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(repository.PIITables), rotation.Rewritten)
+	for _, table := range []string{"outbox", "webhook_deliveries", "customer_exports", "email_verifications", "phone_verifications"} {
+		repo.AssertCalled(t, "ReencryptPII", table, int64(0), DefaultKeyRotationBatch)
+	}
+}
+
+func TestRotateReturnsProgressOnFailure(t *testing.T) {
+
+	repo := new(mocks.MockedKeyRotationRepository)
+	repo.On("ReencryptPII", "customers", int64(0), DefaultKeyRotationBatch).Return(entity.KeyRotation{LastID: 40, Scanned: 40, Rewritten: 40}, nil)
+	repo.On("ReencryptPII", "outbox", int64(0), DefaultKeyRotationBatch).Return(entity.KeyRotation{LastID: 500, Scanned: DefaultKeyRotationBatch, Rewritten: 10}, nil)
+	repo.On("ReencryptPII", "outbox", int64(500), DefaultKeyRotationBatch).Return(entity.KeyRotation{LastID: 500}, entity.ErrSQLError)
+
+	u := &KeyRotationImpl{Repo: repo}
+	rotation, err := u.Rotate(context.Background(), "", 0)
+
+	assert.Equal(t, entity.ErrSQLError, err)
+	assert.Equal(t, "outbox", rotation.Table)
+	assert.Equal(t, int64(500), rotation.LastID, "resumes after the last committed batch")
+	assert.Equal(t, 50, rotation.Rewritten)
+}
+
+func TestRotateRejectsUnknownTable(t *testing.T) {
+
+	u := &KeyRotationImpl{Repo: new(mocks.MockedKeyRotationRepository)}
+	_, err := u.Rotate(context.Background(), "sessions", 10)
+
+	assert.Equal(t, repository.ErrUnknownPIITable, err)
+}
@@ -0,0 +1,199 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/encrypt"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/totp"
+	"svc-customer/tracing"
+	"time"
+)
+
+// DefaultMFAIssuer issuer shown by authenticator apps
+const DefaultMFAIssuer = "svc-customer"
+
+// DefaultTOTPSkew steps accepted before and after the current one
+const DefaultTOTPSkew = 1
+
+// MFAVerifier second factor check run by SessionImpl after the password
+type MFAVerifier interface {
+	MFAEnabled(ctx context.Context, customerUUID string) (bool, error)
+	VerifyMFA(ctx context.Context, customerUUID string, code string) error
+}
+
+// MFAImpl implementation
+type MFAImpl struct {
+	Repo      repository.MFARepository
+	Customers repository.Repository
+	// Cipher encrypts TOTP secrets at rest, enrollment and verification fail without it
+	Cipher encrypt.Cipher
+	// Issuer label of the otpauth URI, DefaultMFAIssuer when empty
+	Issuer string
+	// Skew steps of clock drift tolerated, DefaultTOTPSkew when zero
+	Skew int
+	// Now clock used to validate codes, time.Now when nil
+	Now func() time.Time
+}
+
+// EnrollTOTP creates a pending TOTP secret, it only protects logins once confirmed
+func (uc *MFAImpl) EnrollTOTP(ctx context.Context, customerUUID string) (_ *entity.TOTPEnrollment, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.EnrollTOTP")
+	defer func() { tracing.End(span, err) }()
+
+	if uc.Cipher == nil {
+		return nil, entity.ErrMFANotConfigured
+	}
+	customer, err := uc.Customers.GetByUUID(ctx, customerUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := uc.Cipher.Seal([]byte(secret))
+	if err != nil {
+		return nil, err
+	}
+	if err = uc.Repo.StoreTOTP(ctx, customerUUID, sealed); err != nil {
+		return nil, err
+	}
+
+	metrics.MFAEvents.WithLabelValues("enrolled").Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("EnrollTOTP: secret created")
+	return &entity.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(uc.issuer(), customer.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP enables the pending secret with a first valid code and returns new recovery codes
+func (uc *MFAImpl) ConfirmTOTP(ctx context.Context, customerUUID string, code string) (_ *entity.RecoveryCodesResponse, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.ConfirmTOTP")
+	defer func() { tracing.End(span, err) }()
+
+	factor, err := uc.factor(ctx, customerUUID)
+	if err != nil {
+		return nil, err
+	}
+	if factor.Confirmed {
+		return nil, entity.ErrMFAAlreadyEnabled
+	}
+	step, err := uc.validate(factor, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := entity.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err = uc.Repo.ConfirmTOTP(ctx, customerUUID, step, hashes); err != nil {
+		return nil, err
+	}
+
+	metrics.MFAEvents.WithLabelValues("confirmed").Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("ConfirmTOTP: two-factor authentication enabled")
+	return &entity.RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+// MFAEnabled reports whether logins of customerUUID need a second factor
+func (uc *MFAImpl) MFAEnabled(ctx context.Context, customerUUID string) (bool, error) {
+	factor, err := uc.Repo.GetTOTP(ctx, customerUUID)
+	if err == entity.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return factor.Confirmed, nil
+}
+
+// VerifyMFA accepts a TOTP code once per step or an unused recovery code
+func (uc *MFAImpl) VerifyMFA(ctx context.Context, customerUUID string, code string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.VerifyMFA")
+	defer func() { tracing.End(span, err) }()
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		factor, err := uc.factor(ctx, customerUUID)
+		if err != nil {
+			return err
+		}
+		step, err := uc.validate(factor, code)
+		if err == nil && step > factor.LastUsedStep {
+			err = uc.Repo.UseTOTPStep(ctx, customerUUID, step)
+		} else if err == nil {
+			err = entity.ErrMFACodeInvalid
+		}
+		return uc.verified(ctx, customerUUID, "totp", err)
+	}
+
+	err = uc.Repo.UseRecoveryCode(ctx, customerUUID, entity.HashRecoveryCode(code))
+	return uc.verified(ctx, customerUUID, "recovery_code", err)
+}
+
+// factor stored TOTP factor with its secret decrypted
+func (uc *MFAImpl) factor(ctx context.Context, customerUUID string) (*entity.TOTPFactor, error) {
+	if uc.Cipher == nil {
+		return nil, entity.ErrMFANotConfigured
+	}
+	factor, err := uc.Repo.GetTOTP(ctx, customerUUID)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := uc.Cipher.Open(factor.Secret)
+	if err != nil {
+		logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Errorf("MFA: %s", err.Error())
+		return nil, err
+	}
+	return &entity.TOTPFactor{
+		Secret:       string(secret),
+		Confirmed:    factor.Confirmed,
+		LastUsedStep: factor.LastUsedStep,
+	}, nil
+}
+
+// validate step matched by code against the configured clock
+func (uc *MFAImpl) validate(factor *entity.TOTPFactor, code string) (int64, error) {
+	now := time.Now
+	if uc.Now != nil {
+		now = uc.Now
+	}
+	skew := uc.Skew
+	if skew == 0 {
+		skew = DefaultTOTPSkew
+	}
+	step, ok := totp.Validate(factor.Secret, strings.TrimSpace(code), now(), skew)
+	if !ok {
+		return 0, entity.ErrMFACodeInvalid
+	}
+	return step, nil
+}
+
+// verified counts and logs the outcome of a second factor check
+func (uc *MFAImpl) verified(ctx context.Context, customerUUID string, method string, err error) error {
+	if err == entity.ErrMFACodeInvalid {
+		metrics.MFAEvents.WithLabelValues("rejected").Inc()
+		logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Warn("VerifyMFA: invalid " + method)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	metrics.MFAEvents.WithLabelValues(method).Inc()
+	return nil
+}
+
+// issuer otpauth issuer label
+func (uc *MFAImpl) issuer() string {
+	if uc.Issuer == "" {
+		return DefaultMFAIssuer
+	}
+	return uc.Issuer
+}
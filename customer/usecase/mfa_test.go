@@ -0,0 +1,166 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/encrypt"
+	"svc-customer/totp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// mfaNow fixed clock of the MFA tests
+var mfaNow = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+const mfaSecret = "JBSWY3DPEHPK3PXPJBSWY3DPEHPK3PXP"
+
+func newMFAImpl(t *testing.T, factor *entity.TOTPFactor) (*MFAImpl, *mocks.MockedMFARepository) {
+	cipher, err := encrypt.NewAESGCM([]byte(strings.Repeat("k", encrypt.KeySize)))
+	assert.NoError(t, err)
+
+	mockRepo := new(mocks.MockedMFARepository)
+	if factor != nil {
+		sealed, err := cipher.Seal([]byte(factor.Secret))
+		assert.NoError(t, err)
+		stored := *factor
+		stored.Secret = sealed
+		mockRepo.On("GetTOTP", resetCustomerUUID).Return(&stored, nil)
+	}
+	return &MFAImpl{
+		Repo:   mockRepo,
+		Cipher: cipher,
+		Now:    func() time.Time { return mfaNow },
+	}, mockRepo
+}
+
+func mfaCode(t *testing.T, at time.Time) string {
+	code, err := totp.Code(mfaSecret, totp.Step(at))
+	assert.NoError(t, err)
+	return code
+}
+
+func TestEnrollTOTPStoresEncryptedSecret(t *testing.T) {
+
+	u, mockRepo := newMFAImpl(t, nil)
+	customers := new(mocks.MockedRepository)
+	customers.On("GetByUUID", resetCustomerUUID).Return(&entity.Customer{CustomerUUID: resetCustomerUUID, Email: "jhond@gmail.com"}, nil)
+	u.Customers = customers
+	mockRepo.On("StoreTOTP", resetCustomerUUID, mock.AnythingOfType("string")).Return(nil)
+
+	enrollment, err := u.EnrollTOTP(context.Background(), resetCustomerUUID)
+	assert.NoError(t, err)
+	assert.Contains(t, enrollment.URI, "otpauth://totp/svc-customer:jhond@gmail.com?")
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	stored := mockRepo.Calls[0].Arguments.String(1)
+	assert.NotContains(t, stored, enrollment.Secret)
+	plain, err := u.Cipher.Open(stored)
+	assert.NoError(t, err)
+	assert.Equal(t, enrollment.Secret, string(plain))
+}
+
+func TestEnrollTOTPWithoutKey(t *testing.T) {
+
+	u := &MFAImpl{}
+
+	_, err := u.EnrollTOTP(context.Background(), resetCustomerUUID)
+	assert.Equal(t, entity.ErrMFANotConfigured, err)
+}
+
+func TestConfirmTOTPReturnsRecoveryCodes(t *testing.T) {
+
+	u, mockRepo := newMFAImpl(t, &entity.TOTPFactor{Secret: mfaSecret})
+	mockRepo.On("ConfirmTOTP", resetCustomerUUID, totp.Step(mfaNow), mock.Anything).Return(nil)
+
+	codes, err := u.ConfirmTOTP(context.Background(), resetCustomerUUID, mfaCode(t, mfaNow))
+	assert.NoError(t, err)
+	assert.Len(t, codes.RecoveryCodes, entity.RecoveryCodeCount)
+
+	hashes := mockRepo.Calls[1].Arguments.Get(2).([]string)
+	assert.Equal(t, entity.HashRecoveryCode(codes.RecoveryCodes[0]), hashes[0])
+}
+
+func TestConfirmTOTPRejectsWrongCode(t *testing.T) {
+
+	u, mockRepo := newMFAImpl(t, &entity.TOTPFactor{Secret: mfaSecret})
+
+	_, err := u.ConfirmTOTP(context.Background(), resetCustomerUUID, mfaCode(t, mfaNow.Add(5*time.Minute)))
+	assert.Equal(t, entity.ErrMFACodeInvalid, err)
+	mockRepo.AssertNotCalled(t, "ConfirmTOTP", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestConfirmTOTPAlreadyEnabled(t *testing.T) {
+
+	u, _ := newMFAImpl(t, &entity.TOTPFactor{Secret: mfaSecret, Confirmed: true})
+
+	_, err := u.ConfirmTOTP(context.Background(), resetCustomerUUID, mfaCode(t, mfaNow))
+	assert.Equal(t, entity.ErrMFAAlreadyEnabled, err)
+}
+
+func TestVerifyMFAToleratesOneStepOfDrift(t *testing.T) {
+
+	u, mockRepo := newMFAImpl(t, &entity.TOTPFactor{Secret: mfaSecret, Confirmed: true})
+	previous := mfaNow.Add(-totp.Period * time.Second)
+	mockRepo.On("UseTOTPStep", resetCustomerUUID, totp.Step(previous)).Return(nil)
+
+	assert.NoError(t, u.VerifyMFA(context.Background(), resetCustomerUUID, mfaCode(t, previous)))
+
+	tooOld := mfaNow.Add(-2 * totp.Period * time.Second)
+	assert.Equal(t, entity.ErrMFACodeInvalid, u.VerifyMFA(context.Background(), resetCustomerUUID, mfaCode(t, tooOld)))
+}
+
+func TestVerifyMFARejectsReplayedStep(t *testing.T) {
+
+	u, mockRepo := newMFAImpl(t, &entity.TOTPFactor{Secret: mfaSecret, Confirmed: true, LastUsedStep: totp.Step(mfaNow)})
+
+	err := u.VerifyMFA(context.Background(), resetCustomerUUID, mfaCode(t, mfaNow))
+	assert.Equal(t, entity.ErrMFACodeInvalid, err)
+	mockRepo.AssertNotCalled(t, "UseTOTPStep", mock.Anything, mock.Anything)
+}
+
+func TestVerifyMFAAcceptsRecoveryCode(t *testing.T) {
+
+	u, mockRepo := newMFAImpl(t, nil)
+	mockRepo.On("UseRecoveryCode", resetCustomerUUID, entity.HashRecoveryCode("a1b2c3d4e5")).Return(nil)
+
+	assert.NoError(t, u.VerifyMFA(context.Background(), resetCustomerUUID, "A1B2C-3D4E5"))
+}
+
+func TestLoginRequiresTOTPOnceEnabled(t *testing.T) {
+
+	mfa, mockRepo := newMFAImpl(t, &entity.TOTPFactor{Secret: mfaSecret, Confirmed: true})
+	mockRepo.On("UseTOTPStep", resetCustomerUUID, totp.Step(mfaNow)).Return(nil)
+
+	u, _ := newSessionImpl()
+	u.MFA = mfa
+	request := entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}
+
+	_, err := u.Login(context.Background(), request, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrMFARequired, err)
+
+	request.Code = "000000"
+	_, err = u.Login(context.Background(), request, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrMFACodeInvalid, err)
+
+	request.Code = mfaCode(t, mfaNow)
+	tokens, err := u.Login(context.Background(), request, entity.SessionMeta{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, tokens.AccessToken)
+}
+
+func TestLoginWithoutEnrollmentSkipsTOTP(t *testing.T) {
+
+	mfa, mockRepo := newMFAImpl(t, nil)
+	mockRepo.On("GetTOTP", resetCustomerUUID).Return(nil, entity.ErrNotFound)
+
+	u, _ := newSessionImpl()
+	u.MFA = mfa
+
+	_, err := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,174 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/notify"
+	"svc-customer/tracing"
+	"sync"
+	"time"
+)
+
+// DefaultResetTokenTTL reset links stop working after 30 minutes
+const DefaultResetTokenTTL = 30 * time.Minute
+
+// SessionRevoker ends every session of a customer after a credential change
+type SessionRevoker interface {
+	RevokeCustomerSessions(ctx context.Context, customerUUID string) error
+}
+
+// PasswordImpl implementation
+type PasswordImpl struct {
+	Repo     repository.PasswordRepository
+	Hasher   entity.PasswordHasher
+	Policy   entity.PasswordPolicy
+	Notifier notify.Notifier
+	// ResetURL page receiving the token as ?token=, e.g. https://app.example.com/reset
+	ResetURL string
+	// ResetTTL lifetime of reset tokens, DefaultResetTokenTTL when zero
+	ResetTTL time.Duration
+	// Sessions revoked after every password change, optional
+	Sessions SessionRevoker
+
+	// sending reset links still being sent by ForgotPassword
+	sending sync.WaitGroup
+}
+
+// ForgotPassword sends a reset link to email when it belongs to a customer.
+// Unknown emails are not reported so the endpoint cannot enumerate accounts,
+// and the link is stored and sent in the background so both answer alike fast.
+func (uc *PasswordImpl) ForgotPassword(ctx context.Context, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.ForgotPassword")
+	defer func() { tracing.End(span, err) }()
+
+	customerUUID, err := uc.Repo.GetUUIDByEmail(ctx, email)
+	if err == entity.ErrNotFound {
+		logging.FromContext(ctx).Info("ForgotPassword: unknown email")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The request context ends with the response, only its logger is kept
+	background := logging.NewContext(context.Background(), logging.FromContext(ctx))
+	uc.sending.Add(1)
+	go func() {
+		defer uc.sending.Done()
+		if err := uc.sendResetLink(background, customerUUID, email); err != nil {
+			logging.FromContext(background).WithError(err).Error("ForgotPassword: reset token not sent")
+		}
+	}()
+	return nil
+}
+
+// sendResetLink stores a new reset token of customerUUID and mails its link
+func (uc *PasswordImpl) sendResetLink(ctx context.Context, customerUUID string, email string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.sendResetLink")
+	defer func() { tracing.End(span, err) }()
+
+	token, tokenHash, err := entity.GenerateToken()
+	if err != nil {
+		return err
+	}
+	ttl := uc.ResetTTL
+	if ttl == 0 {
+		ttl = DefaultResetTokenTTL
+	}
+	if err = uc.Repo.StoreResetToken(ctx, customerUUID, tokenHash, ttl); err != nil {
+		return err
+	}
+
+	err = uc.Notifier.Notify(ctx, notify.Message{
+		Channel: notify.Email,
+		To:      email,
+		Subject: "Reset your password",
+		Body:    "Use this link to choose a new password, it expires in " + ttl.String() + ": " + uc.ResetURL + "?token=" + token,
+	})
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("ForgotPassword: reset token sent")
+	return nil
+}
+
+// ResetPassword sets password for the customer owning token and spends it
+func (uc *PasswordImpl) ResetPassword(ctx context.Context, token string, password string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.ResetPassword")
+	defer func() { tracing.End(span, err) }()
+
+	tokenHash := entity.HashToken(token)
+
+	customerUUID, email, err := uc.Repo.FindResetToken(ctx, tokenHash)
+	if err != nil {
+		return err
+	}
+	// Policy first so a rejected password does not burn the token
+	if err = uc.Policy.Validate(password, email); err != nil {
+		return err
+	}
+
+	passwordHash, err := uc.Hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err = uc.Repo.ConsumeResetToken(ctx, tokenHash); err != nil {
+		return err
+	}
+	if err = uc.Repo.UpdatePassword(ctx, customerUUID, passwordHash); err != nil {
+		return err
+	}
+	uc.revokeCredentials(ctx, customerUUID)
+
+	metrics.PasswordResets.Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("ResetPassword: password reset")
+	return nil
+}
+
+// ChangePassword replaces the password of customerUUID once current is verified
+func (uc *PasswordImpl) ChangePassword(ctx context.Context, customerUUID string, current string, password string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.ChangePassword")
+	defer func() { tracing.End(span, err) }()
+
+	storedHash, email, err := uc.Repo.GetPasswordHash(ctx, customerUUID)
+	if err != nil {
+		return err
+	}
+	if !uc.Hasher.Verify(storedHash, current) {
+		logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Warn("ChangePassword: wrong current password")
+		return entity.ErrPasswordMismatch
+	}
+	if err = uc.Policy.Validate(password, email); err != nil {
+		return err
+	}
+
+	passwordHash, err := uc.Hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	if err = uc.Repo.UpdatePassword(ctx, customerUUID, passwordHash); err != nil {
+		return err
+	}
+	uc.revokeCredentials(ctx, customerUUID)
+
+	metrics.PasswordChanges.Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("ChangePassword: password changed")
+	return nil
+}
+
+// revokeCredentials ends pending reset tokens and sessions once the password
+// changed, failures are logged since the new password is already stored.
+func (uc *PasswordImpl) revokeCredentials(ctx context.Context, customerUUID string) {
+	if err := uc.Repo.RevokeResetTokens(ctx, customerUUID); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("revokeCredentials: reset tokens not revoked")
+	}
+	if uc.Sessions == nil {
+		return
+	}
+	if err := uc.Sessions.RevokeCustomerSessions(ctx, customerUUID); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("revokeCredentials: sessions not revoked")
+	}
+}
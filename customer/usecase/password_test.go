@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/notify"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const resetCustomerUUID = "039d69ee-f9cb-4a3d-87e4-6eb63c302579"
+
+func TestForgotPasswordSendsTokenWhoseHashIsStored(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("GetUUIDByEmail", "jhond@gmail.com").Return(resetCustomerUUID, nil)
+	mockRepo.On("StoreResetToken", resetCustomerUUID, mock.AnythingOfType("string"), DefaultResetTokenTTL).Return(nil)
+
+	var sent notify.Message
+	u := PasswordImpl{
+		Repo:     mockRepo,
+		ResetURL: "https://app.example.com/reset",
+		Notifier: notify.NotifierFunc(func(ctx context.Context, message notify.Message) error {
+			sent = message
+			return nil
+		}),
+	}
+
+	err := u.ForgotPassword(context.Background(), "jhond@gmail.com")
+	u.sending.Wait()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "jhond@gmail.com", sent.To)
+
+	token := sent.Body[strings.Index(sent.Body, "?token=")+len("?token="):]
+	assert.Equal(t, entity.HashToken(token), mockRepo.Calls[1].Arguments.String(1))
+}
+
+func TestForgotPasswordUnknownEmailIsSilent(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("GetUUIDByEmail", "nobody@gmail.com").Return("", entity.ErrNotFound)
+
+	u := PasswordImpl{
+		Repo: mockRepo,
+		Notifier: notify.NotifierFunc(func(ctx context.Context, message notify.Message) error {
+			t.Fatal("nothing must be sent")
+			return nil
+		}),
+	}
+
+	assert.NoError(t, u.ForgotPassword(context.Background(), "nobody@gmail.com"))
+	u.sending.Wait()
+	mockRepo.AssertNotCalled(t, "StoreResetToken", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResetPasswordSpendsTokenAndRehashes(t *testing.T) {
+
+	tokenHash := entity.HashToken("reset-token")
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("FindResetToken", tokenHash).Return(resetCustomerUUID, "jhond@gmail.com", nil)
+	mockRepo.On("ConsumeResetToken", tokenHash).Return(nil)
+	mockRepo.On("UpdatePassword", resetCustomerUUID, entity.EncryptPassword("a-new-password")).Return(nil)
+	mockRepo.On("RevokeResetTokens", resetCustomerUUID).Return(nil)
+
+	u := PasswordImpl{Repo: mockRepo, Hasher: entity.SHA1Hasher{}, Policy: entity.DefaultPasswordPolicy}
+
+	err := u.ResetPassword(context.Background(), "reset-token", "a-new-password")
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResetPasswordPolicyKeepsToken(t *testing.T) {
+
+	tokenHash := entity.HashToken("reset-token")
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("FindResetToken", tokenHash).Return(resetCustomerUUID, "jhond@gmail.com", nil)
+
+	u := PasswordImpl{Repo: mockRepo, Hasher: entity.SHA1Hasher{}, Policy: entity.DefaultPasswordPolicy}
+
+	err := u.ResetPassword(context.Background(), "reset-token", "short")
+
+	assert.IsType(t, &entity.PasswordPolicyError{}, err)
+	mockRepo.AssertNotCalled(t, "ConsumeResetToken", tokenHash)
+}
+
+// sessionsFunc adapts a function to SessionRevoker
+type sessionsFunc func(ctx context.Context, customerUUID string) error
+
+func (f sessionsFunc) RevokeCustomerSessions(ctx context.Context, customerUUID string) error {
+	return f(ctx, customerUUID)
+}
+
+func TestChangePasswordRevokesSessions(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("GetPasswordHash", resetCustomerUUID).Return(entity.EncryptPassword("old-password"), "jhond@gmail.com", nil)
+	mockRepo.On("UpdatePassword", resetCustomerUUID, entity.EncryptPassword("a-new-password")).Return(nil)
+	mockRepo.On("RevokeResetTokens", resetCustomerUUID).Return(nil)
+
+	var revoked string
+	u := PasswordImpl{
+		Repo:   mockRepo,
+		Hasher: entity.SHA1Hasher{},
+		Policy: entity.DefaultPasswordPolicy,
+		Sessions: sessionsFunc(func(ctx context.Context, customerUUID string) error {
+			revoked = customerUUID
+			return nil
+		}),
+	}
+
+	err := u.ChangePassword(context.Background(), resetCustomerUUID, "old-password", "a-new-password")
+
+	assert.NoError(t, err)
+	assert.Equal(t, resetCustomerUUID, revoked)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestChangePasswordChecksCurrentAndPolicy(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPasswordRepository)
+	mockRepo.On("GetPasswordHash", resetCustomerUUID).Return(entity.EncryptPassword("old-password"), "jhond@gmail.com", nil)
+
+	policy := entity.DefaultPasswordPolicy
+	policy.Breached = map[string]struct{}{"password123": {}}
+	u := PasswordImpl{Repo: mockRepo, Hasher: entity.SHA1Hasher{}, Policy: policy}
+
+	err := u.ChangePassword(context.Background(), resetCustomerUUID, "wrong", "a-new-password")
+	assert.Equal(t, entity.ErrPasswordMismatch, err)
+
+	err = u.ChangePassword(context.Background(), resetCustomerUUID, "old-password", "Password123")
+	assert.IsType(t, &entity.PasswordPolicyError{}, err)
+
+	err = u.ChangePassword(context.Background(), resetCustomerUUID, "old-password", "JhonD@gmail.com")
+	assert.IsType(t, &entity.PasswordPolicyError{}, err)
+
+	mockRepo.AssertNotCalled(t, "UpdatePassword", resetCustomerUUID, entity.EncryptPassword("Password123"))
+}
@@ -0,0 +1,112 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/notify"
+	"svc-customer/tracing"
+	"time"
+)
+
+// DefaultPhoneCodeTTL phone codes stop working after 5 minutes
+const DefaultPhoneCodeTTL = 5 * time.Minute
+
+// DefaultPhoneCodeAttempts guesses allowed per phone code
+const DefaultPhoneCodeAttempts = 5
+
+// PhoneVerificationImpl implementation
+type PhoneVerificationImpl struct {
+	Repo repository.PhoneVerificationRepository
+	SMS  notify.SMSSender
+	// CodeTTL lifetime of codes, DefaultPhoneCodeTTL when zero
+	CodeTTL time.Duration
+	// MaxAttempts guesses per code, DefaultPhoneCodeAttempts when zero
+	MaxAttempts int
+}
+
+// SendPhoneCode texts a new code to the customer phone, replacing any pending one
+func (uc *PhoneVerificationImpl) SendPhoneCode(ctx context.Context, customerUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.SendPhoneCode")
+	defer func() { tracing.End(span, err) }()
+
+	phone, verified, err := uc.Repo.GetPhone(ctx, customerUUID)
+	if err != nil {
+		return err
+	}
+	if verified {
+		return entity.ErrPhoneAlreadyVerified
+	}
+	if phone == "" {
+		return entity.ErrBadParamInput
+	}
+
+	code, codeHash, err := entity.GenerateOTP(customerUUID)
+	if err != nil {
+		return err
+	}
+	ttl := uc.CodeTTL
+	if ttl == 0 {
+		ttl = DefaultPhoneCodeTTL
+	}
+	if err = uc.Repo.StorePhoneCode(ctx, customerUUID, phone, codeHash, ttl); err != nil {
+		return err
+	}
+
+	if err = uc.SMS.SendSMS(ctx, phone, "Your verification code is "+code+", it expires in "+ttl.String()); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("SendPhoneCode: code sent")
+	return nil
+}
+
+// VerifyPhone marks the phone the pending code was sent to as verified.
+// Every guess counts, the code stops working after MaxAttempts.
+func (uc *PhoneVerificationImpl) VerifyPhone(ctx context.Context, customerUUID string, code string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.VerifyPhone")
+	defer func() { tracing.End(span, err) }()
+
+	if !validOTP(code) {
+		return entity.ErrVerificationCodeInvalid
+	}
+	attempts := uc.MaxAttempts
+	if attempts == 0 {
+		attempts = DefaultPhoneCodeAttempts
+	}
+	if err = uc.Repo.RegisterPhoneCodeAttempt(ctx, customerUUID, attempts); err != nil {
+		return err
+	}
+
+	phone, codeHash, err := uc.Repo.FindPhoneCode(ctx, customerUUID)
+	if err != nil {
+		return err
+	}
+	if !entity.OTPMatches(customerUUID, code, codeHash) {
+		logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Warn("VerifyPhone: wrong code")
+		return entity.ErrVerificationCodeInvalid
+	}
+	if err = uc.Repo.ConsumePhoneCode(ctx, customerUUID); err != nil {
+		return err
+	}
+	if err = uc.Repo.MarkPhoneVerified(ctx, customerUUID, phone); err != nil {
+		return err
+	}
+	metrics.PhonesVerified.Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("VerifyPhone: phone verified")
+	return nil
+}
+
+// validOTP exactly OTPDigits decimal digits
+func validOTP(code string) bool {
+	if len(code) != entity.OTPDigits {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package usecase
+
+import (
+	"context"
+	"strings"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/notify"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSendPhoneCodeStoresHashOfTextedCode(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPhoneVerificationRepository)
+	mockRepo.On("GetPhone", resetCustomerUUID).Return("56933375029", false, nil)
+	mockRepo.On("StorePhoneCode", resetCustomerUUID, "56933375029", mock.AnythingOfType("string"), DefaultPhoneCodeTTL).Return(nil)
+
+	sms := &notify.FakeSMS{}
+	u := PhoneVerificationImpl{Repo: mockRepo, SMS: sms}
+
+	assert.NoError(t, u.SendPhoneCode(context.Background(), resetCustomerUUID))
+
+	sent := sms.Messages()
+	assert.Len(t, sent, 1)
+	assert.Equal(t, "56933375029", sent[0].To)
+
+	code := strings.TrimPrefix(sent[0].Body, "Your verification code is ")[:entity.OTPDigits]
+	assert.True(t, validOTP(code))
+	assert.Equal(t, entity.HashOTP(resetCustomerUUID, code), mockRepo.Calls[1].Arguments.String(2))
+}
+
+func TestSendPhoneCodeAlreadyVerified(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPhoneVerificationRepository)
+	mockRepo.On("GetPhone", resetCustomerUUID).Return("56933375029", true, nil)
+
+	u := PhoneVerificationImpl{Repo: mockRepo, SMS: &notify.FakeSMS{}}
+
+	assert.Equal(t, entity.ErrPhoneAlreadyVerified, u.SendPhoneCode(context.Background(), resetCustomerUUID))
+}
+
+func TestVerifyPhoneCorrectCode(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPhoneVerificationRepository)
+	mockRepo.On("RegisterPhoneCodeAttempt", resetCustomerUUID, DefaultPhoneCodeAttempts).Return(nil)
+	mockRepo.On("FindPhoneCode", resetCustomerUUID).Return("56933375029", entity.HashOTP(resetCustomerUUID, "123456"), nil)
+	mockRepo.On("ConsumePhoneCode", resetCustomerUUID).Return(nil)
+	mockRepo.On("MarkPhoneVerified", resetCustomerUUID, "56933375029").Return(nil)
+
+	u := PhoneVerificationImpl{Repo: mockRepo}
+
+	assert.NoError(t, u.VerifyPhone(context.Background(), resetCustomerUUID, "123456"))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestVerifyPhoneWrongCodeCountsAttempt(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPhoneVerificationRepository)
+	mockRepo.On("RegisterPhoneCodeAttempt", resetCustomerUUID, 3).Return(nil)
+	mockRepo.On("FindPhoneCode", resetCustomerUUID).Return("56933375029", entity.HashOTP(resetCustomerUUID, "123456"), nil)
+
+	u := PhoneVerificationImpl{Repo: mockRepo, MaxAttempts: 3}
+
+	assert.Equal(t, entity.ErrVerificationCodeInvalid, u.VerifyPhone(context.Background(), resetCustomerUUID, "654321"))
+	mockRepo.AssertNotCalled(t, "ConsumePhoneCode", mock.Anything)
+	mockRepo.AssertNotCalled(t, "MarkPhoneVerified", mock.Anything, mock.Anything)
+}
+
+func TestVerifyPhoneAttemptsExhausted(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPhoneVerificationRepository)
+	mockRepo.On("RegisterPhoneCodeAttempt", resetCustomerUUID, DefaultPhoneCodeAttempts).Return(entity.ErrVerificationCodeInvalid)
+
+	u := PhoneVerificationImpl{Repo: mockRepo}
+
+	assert.Equal(t, entity.ErrVerificationCodeInvalid, u.VerifyPhone(context.Background(), resetCustomerUUID, "123456"))
+	mockRepo.AssertNotCalled(t, "FindPhoneCode", mock.Anything)
+}
+
+func TestVerifyPhoneMalformedCode(t *testing.T) {
+
+	mockRepo := new(mocks.MockedPhoneVerificationRepository)
+	u := PhoneVerificationImpl{Repo: mockRepo}
+
+	for _, code := range []string{"", "12345", "1234567", "12a456"} {
+		assert.Equal(t, entity.ErrVerificationCodeInvalid, u.VerifyPhone(context.Background(), resetCustomerUUID, code))
+	}
+	mockRepo.AssertNotCalled(t, "RegisterPhoneCodeAttempt", mock.Anything, mock.Anything)
+}
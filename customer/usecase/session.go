@@ -0,0 +1,213 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSessionTTL sessions end 30 days after login, refreshing does not extend them
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+// AccessTokenIssuer signs the short lived access token of a session
+type AccessTokenIssuer interface {
+	Issue(subject string, sessionID string) (string, time.Duration, error)
+}
+
+// SessionImpl implementation
+type SessionImpl struct {
+	Repo      repository.SessionRepository
+	Passwords repository.PasswordRepository
+	Hasher    entity.PasswordHasher
+	Tokens    AccessTokenIssuer
+	// MFA second factor checked after the password, skipped when nil
+	MFA MFAVerifier
+	// SessionTTL lifetime of sessions, DefaultSessionTTL when zero
+	SessionTTL time.Duration
+}
+
+// Login verifies email, password and, once enrolled, the second factor and opens a new session
+func (uc *SessionImpl) Login(ctx context.Context, request entity.LoginRequest, meta entity.SessionMeta) (_ *entity.TokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Login")
+	defer func() { tracing.End(span, err) }()
+
+	customerUUID, err := uc.Passwords.GetUUIDByEmail(ctx, request.Email)
+	if err == entity.ErrNotFound {
+		metrics.SessionEvents.WithLabelValues("login_failed").Inc()
+		return nil, entity.ErrInvalidLogin
+	}
+	if err != nil {
+		return nil, err
+	}
+	passwordHash, _, err := uc.Passwords.GetPasswordHash(ctx, customerUUID)
+	if err != nil {
+		return nil, err
+	}
+	if !uc.Hasher.Verify(passwordHash, request.Password) {
+		metrics.SessionEvents.WithLabelValues("login_failed").Inc()
+		logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Warn("Login: wrong password")
+		return nil, entity.ErrInvalidLogin
+	}
+	if err = uc.verifyMFA(ctx, customerUUID, request.Code); err != nil {
+		return nil, err
+	}
+
+	sessionUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, tokenHash, err := entity.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	ttl := uc.SessionTTL
+	if ttl == 0 {
+		ttl = DefaultSessionTTL
+	}
+	session := entity.Session{
+		SessionUUID:  sessionUUID.String(),
+		CustomerUUID: customerUUID,
+		UserAgent:    meta.UserAgent,
+		IP:           meta.IP,
+	}
+	if err = uc.Repo.CreateSession(ctx, session, tokenHash, ttl); err != nil {
+		return nil, err
+	}
+
+	metrics.SessionEvents.WithLabelValues("login").Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("Login: session created")
+	return uc.tokens(customerUUID, session.SessionUUID, refreshToken)
+}
+
+// Refresh swaps refreshToken for a new access and refresh token. A token
+// presented twice was stolen or replayed, its whole session is revoked.
+func (uc *SessionImpl) Refresh(ctx context.Context, refreshToken string, meta entity.SessionMeta) (_ *entity.TokenResponse, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Refresh")
+	defer func() { tracing.End(span, err) }()
+
+	if refreshToken == "" {
+		return nil, entity.ErrRefreshTokenInvalid
+	}
+	oldHash := entity.HashToken(refreshToken)
+
+	record, err := uc.Repo.FindRefreshToken(ctx, oldHash)
+	if err != nil {
+		return nil, err
+	}
+	if record.Used {
+		return nil, uc.revokeReused(ctx, record)
+	}
+
+	newToken, newHash, err := entity.GenerateToken()
+	if err != nil {
+		return nil, err
+	}
+	err = uc.Repo.RotateRefreshToken(ctx, record.SessionUUID, oldHash, newHash, meta)
+	if err == entity.ErrRefreshTokenReused {
+		return nil, uc.revokeReused(ctx, record)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.SessionEvents.WithLabelValues("refresh").Inc()
+	return uc.tokens(record.CustomerUUID, record.SessionUUID, newToken)
+}
+
+// FetchSessions active sessions of customerUUID
+func (uc *SessionImpl) FetchSessions(ctx context.Context, customerUUID string) (_ []*entity.Session, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.FetchSessions")
+	defer func() { tracing.End(span, err) }()
+
+	return uc.Repo.FetchSessions(ctx, customerUUID)
+}
+
+// RevokeSession ends one session of customerUUID
+func (uc *SessionImpl) RevokeSession(ctx context.Context, customerUUID string, sessionUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.RevokeSession")
+	defer func() { tracing.End(span, err) }()
+
+	if err = uc.Repo.RevokeSession(ctx, customerUUID, sessionUUID); err != nil {
+		return err
+	}
+	metrics.SessionEvents.WithLabelValues("revoked").Inc()
+	logging.FromContext(ctx).WithField("session_uuid", sessionUUID).Info("RevokeSession: session revoked")
+	return nil
+}
+
+// RevokeSessions ends every session of customerUUID
+func (uc *SessionImpl) RevokeSessions(ctx context.Context, customerUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.RevokeSessions")
+	defer func() { tracing.End(span, err) }()
+
+	if err = uc.Repo.RevokeSessions(ctx, customerUUID); err != nil {
+		return err
+	}
+	metrics.SessionEvents.WithLabelValues("revoked").Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("RevokeSessions: sessions revoked")
+	return nil
+}
+
+// RevokeCustomerSessions SessionRevoker hook run after password changes
+func (uc *SessionImpl) RevokeCustomerSessions(ctx context.Context, customerUUID string) error {
+	return uc.RevokeSessions(ctx, customerUUID)
+}
+
+// SessionActive auth.SessionChecker hook, access tokens stop working once their session ends
+func (uc *SessionImpl) SessionActive(ctx context.Context, sessionUUID string) (bool, error) {
+	return uc.Repo.SessionActive(ctx, sessionUUID)
+}
+
+// verifyMFA requires a valid second factor from customers that enrolled one
+func (uc *SessionImpl) verifyMFA(ctx context.Context, customerUUID string, code string) error {
+	if uc.MFA == nil {
+		return nil
+	}
+	enabled, err := uc.MFA.MFAEnabled(ctx, customerUUID)
+	if err != nil || !enabled {
+		return err
+	}
+	if code == "" {
+		return entity.ErrMFARequired
+	}
+	if err = uc.MFA.VerifyMFA(ctx, customerUUID, code); err != nil {
+		metrics.SessionEvents.WithLabelValues("login_failed").Inc()
+		return err
+	}
+	return nil
+}
+
+// revokeReused ends the session of a replayed refresh token
+func (uc *SessionImpl) revokeReused(ctx context.Context, record *entity.RefreshTokenRecord) error {
+	metrics.SessionEvents.WithLabelValues("reuse_detected").Inc()
+	logging.FromContext(ctx).
+		WithField("customer_uuid", record.CustomerUUID).
+		WithField("session_uuid", record.SessionUUID).
+		Warn("Refresh: refresh token reused, session revoked")
+
+	if err := uc.Repo.RevokeSession(ctx, record.CustomerUUID, record.SessionUUID); err != nil && err != entity.ErrNotFound {
+		return err
+	}
+	return entity.ErrRefreshTokenInvalid
+}
+
+// tokens access token for the session next to its new refresh token
+func (uc *SessionImpl) tokens(customerUUID string, sessionUUID string, refreshToken string) (*entity.TokenResponse, error) {
+	accessToken, expiresIn, err := uc.Tokens.Issue(customerUUID, sessionUUID)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(expiresIn.Seconds()),
+		RefreshToken: refreshToken,
+		SessionUUID:  sessionUUID,
+	}, nil
+}
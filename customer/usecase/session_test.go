@@ -0,0 +1,181 @@
+package usecase
+
+import (
+	"context"
+	"net/http/httptest"
+	"svc-customer/auth"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/customer/repository"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// staticIssuer signs nothing, the access token is the session UUID
+type staticIssuer struct{}
+
+func (staticIssuer) Issue(subject string, sessionID string) (string, time.Duration, error) {
+	return "access-" + sessionID, time.Minute, nil
+}
+
+func newSessionImpl() (*SessionImpl, *repository.MemorySessionRepository) {
+	passwords := new(mocks.MockedPasswordRepository)
+	passwords.On("GetUUIDByEmail", "jhond@gmail.com").Return(resetCustomerUUID, nil)
+	passwords.On("GetUUIDByEmail", "nobody@gmail.com").Return("", entity.ErrNotFound)
+	passwords.On("GetPasswordHash", resetCustomerUUID).Return(entity.EncryptPassword("monkey123"), "jhond@gmail.com", nil)
+
+	repo := repository.NewMemorySessionRepository()
+	return &SessionImpl{Repo: repo, Passwords: passwords, Hasher: entity.SHA1Hasher{}, Tokens: staticIssuer{}}, repo
+}
+
+func TestLoginCreatesSession(t *testing.T) {
+
+	u, _ := newSessionImpl()
+	meta := entity.SessionMeta{UserAgent: "curl/7.68", IP: "10.0.0.1"}
+
+	tokens, err := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, meta)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, 60, tokens.ExpiresIn)
+	assert.NotEmpty(t, tokens.RefreshToken)
+
+	sessions, err := u.FetchSessions(context.Background(), resetCustomerUUID)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, tokens.SessionUUID, sessions[0].SessionUUID)
+	assert.Equal(t, "10.0.0.1", sessions[0].IP)
+}
+
+func TestLoginRejectsWrongCredentials(t *testing.T) {
+
+	u, _ := newSessionImpl()
+
+	_, err := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "wrong"}, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrInvalidLogin, err)
+
+	_, err = u.Login(context.Background(), entity.LoginRequest{Email: "nobody@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrInvalidLogin, err)
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+
+	u, _ := newSessionImpl()
+
+	first, err := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	assert.NoError(t, err)
+
+	second, err := u.Refresh(context.Background(), first.RefreshToken, entity.SessionMeta{IP: "10.0.0.2"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.Equal(t, first.SessionUUID, second.SessionUUID)
+
+	third, err := u.Refresh(context.Background(), second.RefreshToken, entity.SessionMeta{})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, third.RefreshToken)
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+
+	u, _ := newSessionImpl()
+
+	first, _ := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	second, err := u.Refresh(context.Background(), first.RefreshToken, entity.SessionMeta{})
+	assert.NoError(t, err)
+
+	// The rotated token shows up again, e.g. replayed by an attacker
+	_, err = u.Refresh(context.Background(), first.RefreshToken, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrRefreshTokenInvalid, err)
+
+	// The legitimate latest token died with its session
+	_, err = u.Refresh(context.Background(), second.RefreshToken, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrRefreshTokenInvalid, err)
+
+	sessions, _ := u.FetchSessions(context.Background(), resetCustomerUUID)
+	assert.Empty(t, sessions)
+}
+
+func TestRevokeSessions(t *testing.T) {
+
+	u, _ := newSessionImpl()
+
+	first, _ := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	second, _ := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+
+	assert.NoError(t, u.RevokeSession(context.Background(), resetCustomerUUID, first.SessionUUID))
+	assert.Equal(t, entity.ErrNotFound, u.RevokeSession(context.Background(), resetCustomerUUID, first.SessionUUID))
+
+	_, err := u.Refresh(context.Background(), first.RefreshToken, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrRefreshTokenInvalid, err)
+
+	// Password changes end the remaining sessions through the SessionRevoker hook
+	var revoker SessionRevoker = u
+	assert.NoError(t, revoker.RevokeCustomerSessions(context.Background(), resetCustomerUUID))
+
+	_, err = u.Refresh(context.Background(), second.RefreshToken, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrRefreshTokenInvalid, err)
+}
+
+// withAccessTokens signs real access tokens for u and returns a check of one
+// against a JWTAuthenticator backed by u's sessions
+func withAccessTokens(u *SessionImpl) func(accessToken string) error {
+	u.Tokens = &auth.TokenIssuer{Method: jwt.SigningMethodHS256, Key: []byte("local-secret")}
+
+	authenticator := auth.NewJWTAuthenticator(auth.StaticKeys{"": []byte("local-secret")}, "", "")
+	authenticator.Sessions = u
+
+	return func(accessToken string) error {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		_, err := authenticator.Authenticate(req)
+		return err
+	}
+}
+
+func TestPasswordChangeEndsAccessTokens(t *testing.T) {
+
+	u, _ := newSessionImpl()
+	authenticate := withAccessTokens(u)
+
+	tokens, err := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	assert.NoError(t, err)
+	assert.NoError(t, authenticate(tokens.AccessToken))
+
+	var revoker SessionRevoker = u
+	assert.NoError(t, revoker.RevokeCustomerSessions(context.Background(), resetCustomerUUID))
+
+	assert.ErrorIs(t, authenticate(tokens.AccessToken), auth.ErrInvalidCredentials)
+}
+
+func TestRevokeSessionEndsAccessToken(t *testing.T) {
+
+	u, _ := newSessionImpl()
+	authenticate := withAccessTokens(u)
+
+	first, _ := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	second, _ := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+
+	assert.NoError(t, u.RevokeSession(context.Background(), resetCustomerUUID, first.SessionUUID))
+
+	assert.ErrorIs(t, authenticate(first.AccessToken), auth.ErrInvalidCredentials)
+	assert.NoError(t, authenticate(second.AccessToken))
+}
+
+func TestRefreshReuseEndsAccessTokens(t *testing.T) {
+
+	u, _ := newSessionImpl()
+	authenticate := withAccessTokens(u)
+
+	first, _ := u.Login(context.Background(), entity.LoginRequest{Email: "jhond@gmail.com", Password: "monkey123"}, entity.SessionMeta{})
+	rotated, err := u.Refresh(context.Background(), first.RefreshToken, entity.SessionMeta{})
+	assert.NoError(t, err)
+
+	// Replaying the spent token revokes the session, the stolen pair included
+	_, err = u.Refresh(context.Background(), first.RefreshToken, entity.SessionMeta{})
+	assert.Equal(t, entity.ErrRefreshTokenInvalid, err)
+
+	assert.ErrorIs(t, authenticate(first.AccessToken), auth.ErrInvalidCredentials)
+	assert.ErrorIs(t, authenticate(rotated.AccessToken), auth.ErrInvalidCredentials)
+}
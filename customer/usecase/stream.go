@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/auth"
+	"svc-customer/authz"
+	"svc-customer/events"
+	"svc-customer/logging"
+)
+
+// StreamImpl live customer events for Server-Sent Events subscribers
+type StreamImpl struct {
+	Broker *events.Broker
+	// Policy, when set, only delivers the events of customers the subscriber may read
+	Policy *authz.Policy
+}
+
+// Subscribe follows the broker from lastEventID, empty for new events only
+func (uc *StreamImpl) Subscribe(ctx context.Context, lastEventID string) (*events.Subscription, error) {
+	var filter func(events.Event) bool
+	principal, _ := auth.FromContext(ctx)
+	if uc.Policy != nil {
+		filter = func(event events.Event) bool {
+			return uc.Policy.Authorize(principal, authz.ActionEvents, event.CustomerUUID) == nil
+		}
+	}
+	subscription := uc.Broker.Subscribe(lastEventID, filter)
+
+	entry := logging.FromContext(ctx).WithField("last_event_id", lastEventID)
+	if principal != nil {
+		entry = entry.WithField("subject", principal.Subject)
+	}
+	entry.Debug("Subscribe: event stream opened")
+	return subscription, nil
+}
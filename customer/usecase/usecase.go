@@ -1,10 +1,20 @@
 package usecase
 
 import (
+	"context"
 	"owner/owner/repository"
 	"svc-customer/customer/entity"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/tracing"
 )
 
+// DefaultChangeLimit changes returned per page when the caller sets no limit
+const DefaultChangeLimit = 100
+
+// MaxChangeLimit largest page of the change feed
+const MaxChangeLimit = 1000
+
 // CustomerDTO Data Transfer Object structure for entity
 type CustomerDTO struct {
 	entity.Customer
@@ -13,29 +23,44 @@ type CustomerDTO struct {
 // GetCustomerImpl implementation
 type GetCustomerImpl struct {
 	Repo repository.Repository
+	// Verifier sends verification emails to new addresses, optional
+	Verifier EmailVerifier
 }
 
 // HealthCheck UseCase check database health check
-func (uc *GetCustomerImpl) HealthCheck() error {
-	err := uc.Repo.HealthCheck()
+func (uc *GetCustomerImpl) HealthCheck(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.HealthCheck")
+	defer func() { tracing.End(span, err) }()
+
+	err = uc.Repo.HealthCheck(ctx)
 	if err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("HealthCheck: database unaccessible")
 		return entity.ErrDatabaseError
 	}
 	return nil
 }
 
 // Store Create Customer inside Database by customer entity
-func (uc *GetCustomerImpl) Store(customer entity.Customer) error {
-	err := uc.Repo.Store(customer)
+func (uc *GetCustomerImpl) Store(ctx context.Context, customer entity.Customer) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Store")
+	defer func() { tracing.End(span, err) }()
+
+	err = uc.Repo.Store(ctx, customer)
 	if err != nil {
 		return err
 	}
+	metrics.CustomersCreated.Inc()
+	logging.FromContext(ctx).WithField("email", customer.Email).Info("Store: customer created")
+	uc.sendVerification(ctx, customer.Email)
 	return nil
 }
 
 // GetByUUID Get Customer Personal information from Database by ID usecase
-func (uc *GetCustomerImpl) GetByUUID(customerUUID string) (*entity.Customer, error) {
-	customer, err := uc.Repo.GetByUUID(customerUUID)
+func (uc *GetCustomerImpl) GetByUUID(ctx context.Context, customerUUID string) (_ *entity.Customer, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetByUUID")
+	defer func() { tracing.End(span, err) }()
+
+	customer, err := uc.Repo.GetByUUID(ctx, customerUUID)
 	if err != nil {
 		return nil, err
 	}
@@ -43,8 +68,11 @@ func (uc *GetCustomerImpl) GetByUUID(customerUUID string) (*entity.Customer, err
 }
 
 // Fetch Get Customer Personal information from Database by ID usecase
-func (uc *GetCustomerImpl) Fetch(page int, limit int) ([]*entity.Customer, int, int, int, error) {
-	customers, total, pages, page, err := uc.Repo.Fetch(page, limit)
+func (uc *GetCustomerImpl) Fetch(ctx context.Context, filter entity.CustomerFilter, page int, limit int) (_ []*entity.Customer, _ int, _ int, _ int, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Fetch")
+	defer func() { tracing.End(span, err) }()
+
+	customers, total, pages, page, err := uc.Repo.Fetch(ctx, filter, page, limit)
 	if err != nil {
 		return nil, total, pages, page, err
 	}
@@ -52,22 +80,106 @@ func (uc *GetCustomerImpl) Fetch(page int, limit int) ([]*entity.Customer, int,
 }
 
 // UpdateByUUID Get Customer Personal information from Database by ID usecase
-func (uc *GetCustomerImpl) UpdateByUUID(customer entity.Customer, customerUUID string) error {
-	err := uc.Repo.UpdateByUUID(customer, customerUUID)
+func (uc *GetCustomerImpl) UpdateByUUID(ctx context.Context, customer entity.Customer, customerUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.UpdateByUUID")
+	defer func() { tracing.End(span, err) }()
+
+	// Remember the current address, a different one has to be verified again
+	emailChanged := false
+	if uc.Verifier != nil && customer.Email != "" {
+		current, err := uc.Repo.GetByUUID(ctx, customerUUID)
+		if err != nil {
+			return err
+		}
+		emailChanged = current.Email != customer.Email
+	}
+
+	err = uc.Repo.UpdateByUUID(ctx, customer, customerUUID)
 	if err != nil {
 		if err == entity.ErrorNotFoundOnDB {
 			return entity.ErrNotFound
 		}
 		return err
 	}
+	metrics.CustomersUpdated.Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("UpdateByUUID: customer updated")
+	if emailChanged {
+		uc.sendVerification(ctx, customer.Email)
+	}
 	return nil
 }
 
 // DeleteByUUID Delete Customer from Database by ID usecase
-func (uc *GetCustomerImpl) DeleteByUUID(customerUUID string) error {
-	err := uc.Repo.DeleteByUUID(customerUUID)
+func (uc *GetCustomerImpl) DeleteByUUID(ctx context.Context, customerUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.DeleteByUUID")
+	defer func() { tracing.End(span, err) }()
+
+	err = uc.Repo.DeleteByUUID(ctx, customerUUID)
+	if err != nil {
+		return err
+	}
+	metrics.CustomersDeleted.Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("DeleteByUUID: customer deleted")
+	return nil
+}
+
+// RestoreByUUID undo the soft delete of a customer usecase
+func (uc *GetCustomerImpl) RestoreByUUID(ctx context.Context, customerUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.RestoreByUUID")
+	defer func() { tracing.End(span, err) }()
+
+	if !entity.IsValidUUID(customerUUID) {
+		return entity.ErrBadParamInput
+	}
+	err = uc.Repo.RestoreByUUID(ctx, customerUUID)
 	if err != nil {
 		return err
 	}
+	metrics.CustomersRestored.Inc()
+	logging.FromContext(ctx).WithField("customer_uuid", customerUUID).Info("RestoreByUUID: customer restored")
 	return nil
 }
+
+// Changes one page of customers changed after token, limit is clamped to MaxChangeLimit
+func (uc *GetCustomerImpl) Changes(ctx context.Context, token string, limit int) (_ *entity.ChangeFeed, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.Changes")
+	defer func() { tracing.End(span, err) }()
+
+	since, err := entity.DecodeChangeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultChangeLimit
+	}
+	if limit > MaxChangeLimit {
+		limit = MaxChangeLimit
+	}
+
+	// One extra row tells whether another page follows
+	changes, err := uc.Repo.Changes(ctx, since, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	feed := &entity.ChangeFeed{Changes: changes}
+	if len(changes) > limit {
+		feed.Changes, feed.HasMore = changes[:limit], true
+	}
+	// An empty page hands back the same position to poll again later
+	if len(feed.Changes) > 0 {
+		since = feed.Changes[len(feed.Changes)-1].Sequence
+	}
+	feed.NextToken = entity.EncodeChangeToken(since)
+	return feed, nil
+}
+
+// sendVerification the customer is already saved, a failed delivery is only
+// logged since the customer can ask for a new link.
+func (uc *GetCustomerImpl) sendVerification(ctx context.Context, email string) {
+	if uc.Verifier == nil {
+		return
+	}
+	if err := uc.Verifier.SendVerification(ctx, email); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("sendVerification: verification email not sent")
+	}
+}
@@ -0,0 +1,395 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/auth"
+	"svc-customer/authz"
+	"svc-customer/customer/entity"
+	"svc-customer/events"
+	"svc-customer/logging"
+)
+
+// AuthorizedUsecase enforces the authorization policy for the caller found in
+// the context before delegating to Next.
+type AuthorizedUsecase struct {
+	Next   Usecase
+	Policy *authz.Policy
+}
+
+// authorize evaluates action on target for the context principal
+func (uc *AuthorizedUsecase) authorize(ctx context.Context, action string, target string) error {
+	return authorize(ctx, uc.Policy, action, target)
+}
+
+// authorize maps policy decisions to the entity errors handlers understand
+func authorize(ctx context.Context, policy *authz.Policy, action string, target string) error {
+	principal, _ := auth.FromContext(ctx)
+
+	err := policy.Authorize(principal, action, target)
+	switch err {
+	case nil:
+		return nil
+	case authz.ErrUnauthenticated:
+		return entity.ErrUnauthorized
+	}
+
+	logging.FromContext(ctx).WithField("action", action).WithField("target", target).Warn("authorize: denied")
+	return entity.ErrForbidden
+}
+
+// HealthCheck no authorization, probes are public
+func (uc *AuthorizedUsecase) HealthCheck(ctx context.Context) error {
+	return uc.Next.HealthCheck(ctx)
+}
+
+// Store requires customers.create
+func (uc *AuthorizedUsecase) Store(ctx context.Context, customer entity.Customer) error {
+	if err := uc.authorize(ctx, authz.ActionCreate, ""); err != nil {
+		return err
+	}
+	return uc.Next.Store(ctx, customer)
+}
+
+// GetByUUID requires customers.get on customerUUID
+func (uc *AuthorizedUsecase) GetByUUID(ctx context.Context, customerUUID string) (*entity.Customer, error) {
+	if err := uc.authorize(ctx, authz.ActionGet, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.GetByUUID(ctx, customerUUID)
+}
+
+// Fetch requires customers.list, plus customers.list_deleted when deleted
+// customers are included
+func (uc *AuthorizedUsecase) Fetch(ctx context.Context, filter entity.CustomerFilter, page int, limit int) ([]*entity.Customer, int, int, int, error) {
+	if err := uc.authorize(ctx, authz.ActionList, ""); err != nil {
+		return nil, 0, 0, page, err
+	}
+	if filter.Deleted != entity.DeletedExclude {
+		if err := uc.authorize(ctx, authz.ActionListDeleted, ""); err != nil {
+			return nil, 0, 0, page, err
+		}
+	}
+	return uc.Next.Fetch(ctx, filter, page, limit)
+}
+
+// UpdateByUUID requires customers.update on customerUUID
+func (uc *AuthorizedUsecase) UpdateByUUID(ctx context.Context, customer entity.Customer, customerUUID string) error {
+	if err := uc.authorize(ctx, authz.ActionUpdate, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.UpdateByUUID(ctx, customer, customerUUID)
+}
+
+// DeleteByUUID requires customers.delete on customerUUID
+func (uc *AuthorizedUsecase) DeleteByUUID(ctx context.Context, customerUUID string) error {
+	if err := uc.authorize(ctx, authz.ActionDelete, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.DeleteByUUID(ctx, customerUUID)
+}
+
+// RestoreByUUID requires customers.restore on customerUUID
+func (uc *AuthorizedUsecase) RestoreByUUID(ctx context.Context, customerUUID string) error {
+	if err := uc.authorize(ctx, authz.ActionRestore, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.RestoreByUUID(ctx, customerUUID)
+}
+
+// Changes requires customers.changes
+func (uc *AuthorizedUsecase) Changes(ctx context.Context, token string, limit int) (*entity.ChangeFeed, error) {
+	if err := uc.authorize(ctx, authz.ActionChanges, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Changes(ctx, token, limit)
+}
+
+// AuthorizedStreamUsecase requires customers.events, the stream itself only
+// carries the customers the caller may read
+type AuthorizedStreamUsecase struct {
+	Next   StreamUsecase
+	Policy *authz.Policy
+}
+
+// Subscribe requires customers.events on the caller's own record at least
+func (uc *AuthorizedStreamUsecase) Subscribe(ctx context.Context, lastEventID string) (*events.Subscription, error) {
+	target := ""
+	if principal, ok := auth.FromContext(ctx); ok {
+		target = principal.Subject
+	}
+	if err := authorize(ctx, uc.Policy, authz.ActionEvents, target); err != nil {
+		return nil, err
+	}
+	return uc.Next.Subscribe(ctx, lastEventID)
+}
+
+// AuthorizedAuditUsecase restricts the audit trail to customers.history
+type AuthorizedAuditUsecase struct {
+	Next   AuditUsecase
+	Policy *authz.Policy
+}
+
+// History requires customers.history on customerUUID
+func (uc *AuthorizedAuditUsecase) History(ctx context.Context, customerUUID string, page int, limit int) (*entity.AuditPage, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionHistory, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.History(ctx, customerUUID, page, limit)
+}
+
+// AuthorizedErasureUsecase restricts erasure to customers.erase
+type AuthorizedErasureUsecase struct {
+	Next   ErasureUsecase
+	Policy *authz.Policy
+}
+
+// Erase requires customers.erase on customerUUID
+func (uc *AuthorizedErasureUsecase) Erase(ctx context.Context, customerUUID string, request entity.ErasureRequest) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionErase, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.Erase(ctx, customerUUID, request)
+}
+
+// AuthorizedExportUsecase restricts exports to customers.export
+type AuthorizedExportUsecase struct {
+	Next   ExportUsecase
+	Policy *authz.Policy
+}
+
+// Export requires customers.export on customerUUID
+func (uc *AuthorizedExportUsecase) Export(ctx context.Context, customerUUID string, format string) (*entity.ExportBundle, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionExport, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.Export(ctx, customerUUID, format)
+}
+
+// RequestExport requires customers.export on customerUUID
+func (uc *AuthorizedExportUsecase) RequestExport(ctx context.Context, customerUUID string, format string) (*entity.ExportJob, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionExport, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.RequestExport(ctx, customerUUID, format)
+}
+
+// GetExport requires customers.export on customerUUID
+func (uc *AuthorizedExportUsecase) GetExport(ctx context.Context, customerUUID string, exportUUID string) (*entity.ExportJob, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionExport, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.GetExport(ctx, customerUUID, exportUUID)
+}
+
+// DownloadExport requires customers.export on customerUUID
+func (uc *AuthorizedExportUsecase) DownloadExport(ctx context.Context, customerUUID string, exportUUID string) (*entity.ExportBundle, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionExport, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.DownloadExport(ctx, customerUUID, exportUUID)
+}
+
+// AuthorizedAPIKeyUsecase restricts API key management to apikeys.manage
+type AuthorizedAPIKeyUsecase struct {
+	Next   APIKeyUsecase
+	Policy *authz.Policy
+}
+
+// Create requires apikeys.manage
+func (uc *AuthorizedAPIKeyUsecase) Create(ctx context.Context, request entity.APIKeyRequest) (*entity.APIKeyResponse, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageAPIKeys, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Create(ctx, request)
+}
+
+// Fetch requires apikeys.manage
+func (uc *AuthorizedAPIKeyUsecase) Fetch(ctx context.Context) ([]*entity.APIKey, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageAPIKeys, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Fetch(ctx)
+}
+
+// Rotate requires apikeys.manage
+func (uc *AuthorizedAPIKeyUsecase) Rotate(ctx context.Context, keyUUID string) (*entity.APIKeyResponse, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageAPIKeys, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Rotate(ctx, keyUUID)
+}
+
+// Revoke requires apikeys.manage
+func (uc *AuthorizedAPIKeyUsecase) Revoke(ctx context.Context, keyUUID string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageAPIKeys, ""); err != nil {
+		return err
+	}
+	return uc.Next.Revoke(ctx, keyUUID)
+}
+
+// VerifyAPIKey no authorization, it is how callers become authenticated
+func (uc *AuthorizedAPIKeyUsecase) VerifyAPIKey(ctx context.Context, key string, ip string) (*auth.Principal, error) {
+	return uc.Next.VerifyAPIKey(ctx, key, ip)
+}
+
+// AuthorizedPasswordUsecase restricts password changes to the customer itself
+type AuthorizedPasswordUsecase struct {
+	Next   PasswordUsecase
+	Policy *authz.Policy
+}
+
+// ForgotPassword no authorization, the caller cannot log in
+func (uc *AuthorizedPasswordUsecase) ForgotPassword(ctx context.Context, email string) error {
+	return uc.Next.ForgotPassword(ctx, email)
+}
+
+// ResetPassword no authorization, the reset token is the credential
+func (uc *AuthorizedPasswordUsecase) ResetPassword(ctx context.Context, token string, password string) error {
+	return uc.Next.ResetPassword(ctx, token, password)
+}
+
+// ChangePassword requires customers.change_password on customerUUID
+func (uc *AuthorizedPasswordUsecase) ChangePassword(ctx context.Context, customerUUID string, current string, password string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionChangePassword, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.ChangePassword(ctx, customerUUID, current, password)
+}
+
+// AuthorizedPhoneVerificationUsecase restricts phone verification to the customer itself
+type AuthorizedPhoneVerificationUsecase struct {
+	Next   PhoneVerificationUsecase
+	Policy *authz.Policy
+}
+
+// SendPhoneCode requires customers.verify_phone on customerUUID
+func (uc *AuthorizedPhoneVerificationUsecase) SendPhoneCode(ctx context.Context, customerUUID string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionVerifyPhone, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.SendPhoneCode(ctx, customerUUID)
+}
+
+// VerifyPhone requires customers.verify_phone on customerUUID
+func (uc *AuthorizedPhoneVerificationUsecase) VerifyPhone(ctx context.Context, customerUUID string, code string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionVerifyPhone, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.VerifyPhone(ctx, customerUUID, code)
+}
+
+// AuthorizedSessionUsecase restricts session management to the customer itself or admins
+type AuthorizedSessionUsecase struct {
+	Next   SessionUsecase
+	Policy *authz.Policy
+}
+
+// Login no authorization, email and password are the credential
+func (uc *AuthorizedSessionUsecase) Login(ctx context.Context, request entity.LoginRequest, meta entity.SessionMeta) (*entity.TokenResponse, error) {
+	return uc.Next.Login(ctx, request, meta)
+}
+
+// Refresh no authorization, the refresh token is the credential
+func (uc *AuthorizedSessionUsecase) Refresh(ctx context.Context, refreshToken string, meta entity.SessionMeta) (*entity.TokenResponse, error) {
+	return uc.Next.Refresh(ctx, refreshToken, meta)
+}
+
+// FetchSessions requires customers.sessions on customerUUID
+func (uc *AuthorizedSessionUsecase) FetchSessions(ctx context.Context, customerUUID string) ([]*entity.Session, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageSessions, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.FetchSessions(ctx, customerUUID)
+}
+
+// RevokeSession requires customers.sessions on customerUUID
+func (uc *AuthorizedSessionUsecase) RevokeSession(ctx context.Context, customerUUID string, sessionUUID string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageSessions, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.RevokeSession(ctx, customerUUID, sessionUUID)
+}
+
+// RevokeSessions requires customers.sessions on customerUUID
+func (uc *AuthorizedSessionUsecase) RevokeSessions(ctx context.Context, customerUUID string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageSessions, customerUUID); err != nil {
+		return err
+	}
+	return uc.Next.RevokeSessions(ctx, customerUUID)
+}
+
+// AuthorizedMFAUsecase restricts second factor enrollment to the customer itself
+type AuthorizedMFAUsecase struct {
+	Next   MFAUsecase
+	Policy *authz.Policy
+}
+
+// EnrollTOTP requires customers.mfa on customerUUID
+func (uc *AuthorizedMFAUsecase) EnrollTOTP(ctx context.Context, customerUUID string) (*entity.TOTPEnrollment, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageMFA, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.EnrollTOTP(ctx, customerUUID)
+}
+
+// ConfirmTOTP requires customers.mfa on customerUUID
+func (uc *AuthorizedMFAUsecase) ConfirmTOTP(ctx context.Context, customerUUID string, code string) (*entity.RecoveryCodesResponse, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageMFA, customerUUID); err != nil {
+		return nil, err
+	}
+	return uc.Next.ConfirmTOTP(ctx, customerUUID, code)
+}
+
+// AuthorizedWebhookUsecase restricts webhook management to webhooks.manage
+type AuthorizedWebhookUsecase struct {
+	Next   WebhookUsecase
+	Policy *authz.Policy
+}
+
+// Create requires webhooks.manage
+func (uc *AuthorizedWebhookUsecase) Create(ctx context.Context, request entity.WebhookRequest) (*entity.WebhookResponse, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageWebhooks, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Create(ctx, request)
+}
+
+// Fetch requires webhooks.manage
+func (uc *AuthorizedWebhookUsecase) Fetch(ctx context.Context) ([]*entity.Webhook, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageWebhooks, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Fetch(ctx)
+}
+
+// Delete requires webhooks.manage
+func (uc *AuthorizedWebhookUsecase) Delete(ctx context.Context, webhookUUID string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	return uc.Next.Delete(ctx, webhookUUID)
+}
+
+// Deliveries requires webhooks.manage
+func (uc *AuthorizedWebhookUsecase) Deliveries(ctx context.Context, webhookUUID string) ([]*entity.WebhookDelivery, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageWebhooks, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Deliveries(ctx, webhookUUID)
+}
+
+// Delivery requires webhooks.manage
+func (uc *AuthorizedWebhookUsecase) Delivery(ctx context.Context, deliveryUUID string) (*entity.WebhookDelivery, error) {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageWebhooks, ""); err != nil {
+		return nil, err
+	}
+	return uc.Next.Delivery(ctx, deliveryUUID)
+}
+
+// Replay requires webhooks.manage
+func (uc *AuthorizedWebhookUsecase) Replay(ctx context.Context, deliveryUUID string) error {
+	if err := authorize(ctx, uc.Policy, authz.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+	return uc.Next.Replay(ctx, deliveryUUID)
+}
@@ -0,0 +1,152 @@
+package usecase
+
+import (
+	"context"
+	"svc-customer/auth"
+	"svc-customer/authz"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/events"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPolicy customers may only read themselves, admins may delete anyone
+var testPolicy = &authz.Policy{
+	Roles: map[string][]string{
+		"customer": {"customers:read:self"},
+		"support":  {"customers:list"},
+		"admin":    {"customers:read:any", "customers:list", "customers:delete", "customers:restore"},
+	},
+	Actions: map[string]authz.Rule{
+		authz.ActionGet:         {Self: []string{"customers:read:self"}, Any: []string{"customers:read:any"}},
+		authz.ActionList:        {Any: []string{"customers:list"}},
+		authz.ActionDelete:      {Any: []string{"customers:delete"}},
+		authz.ActionRestore:     {Any: []string{"customers:restore"}},
+		authz.ActionListDeleted: {Any: []string{"customers:restore"}},
+		authz.ActionEvents:      {Self: []string{"customers:read:self"}, Any: []string{"customers:read:any"}},
+	},
+}
+
+func TestAuthorizedUsecaseGetByUUIDSelf(t *testing.T) {
+
+	customerUUID := "039d69ee-f9cb-4a3d-87e4-6eb63c302579"
+
+	mockCase := new(mocks.MockedRepository)
+	mockCase.On("GetByUUID", customerUUID).Return(mocks.MockCustomer, nil)
+
+	u := AuthorizedUsecase{
+		Next:   &GetCustomerImpl{Repo: mockCase},
+		Policy: testPolicy,
+	}
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: customerUUID, Roles: []string{"customer"}})
+	cust, err := u.GetByUUID(ctx, customerUUID)
+
+	assert.NoError(t, err)
+	assert.NotNil(t, cust)
+	mockCase.AssertExpectations(t)
+}
+
+func TestAuthorizedUsecaseGetByUUIDOtherCustomer(t *testing.T) {
+
+	mockCase := new(mocks.MockedRepository)
+
+	u := AuthorizedUsecase{
+		Next:   &GetCustomerImpl{Repo: mockCase},
+		Policy: testPolicy,
+	}
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: "f48ac180-e8ad-4837-a3c3-66b0e96f19bf", Roles: []string{"customer"}})
+	cust, err := u.GetByUUID(ctx, "039d69ee-f9cb-4a3d-87e4-6eb63c302579")
+
+	assert.Equal(t, entity.ErrForbidden, err)
+	assert.Nil(t, cust)
+	mockCase.AssertNotCalled(t, "GetByUUID", "039d69ee-f9cb-4a3d-87e4-6eb63c302579")
+}
+
+func TestAuthorizedUsecaseDeleteByUUIDAnonymous(t *testing.T) {
+
+	mockCase := new(mocks.MockedRepository)
+
+	u := AuthorizedUsecase{
+		Next:   &GetCustomerImpl{Repo: mockCase},
+		Policy: testPolicy,
+	}
+
+	err := u.DeleteByUUID(context.Background(), "039d69ee-f9cb-4a3d-87e4-6eb63c302579")
+
+	assert.Equal(t, entity.ErrUnauthorized, err)
+}
+
+func TestAuthorizedUsecaseFetchDeletedRequiresRestore(t *testing.T) {
+
+	u := AuthorizedUsecase{
+		Next:   &GetCustomerImpl{Repo: new(mocks.MockedRepository)},
+		Policy: testPolicy,
+	}
+
+	support := auth.NewContext(context.Background(), &auth.Principal{Subject: "support-1", Roles: []string{"support"}})
+	_, _, _, _, err := u.Fetch(support, entity.CustomerFilter{}, 1, 10)
+	assert.NoError(t, err)
+	_, _, _, _, err = u.Fetch(support, entity.CustomerFilter{Deleted: entity.DeletedOnly}, 1, 10)
+	assert.Equal(t, entity.ErrForbidden, err)
+
+	admin := auth.NewContext(context.Background(), &auth.Principal{Subject: "admin-1", Roles: []string{"admin"}})
+	_, _, _, _, err = u.Fetch(admin, entity.CustomerFilter{Deleted: entity.DeletedInclude}, 1, 10)
+	assert.NoError(t, err)
+}
+
+func TestAuthorizedUsecaseRestoreByUUIDAdmin(t *testing.T) {
+
+	customerUUID := "039d69ee-f9cb-4a3d-87e4-6eb63c302579"
+
+	mockCase := new(mocks.MockedRepository)
+	mockCase.On("RestoreByUUID", customerUUID).Return(nil)
+
+	u := AuthorizedUsecase{
+		Next:   &GetCustomerImpl{Repo: mockCase},
+		Policy: testPolicy,
+	}
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: customerUUID, Roles: []string{"customer"}})
+	assert.Equal(t, entity.ErrForbidden, u.RestoreByUUID(ctx, customerUUID))
+	mockCase.AssertNotCalled(t, "RestoreByUUID", customerUUID)
+
+	ctx = auth.NewContext(context.Background(), &auth.Principal{Subject: "admin-1", Roles: []string{"admin"}})
+	assert.NoError(t, u.RestoreByUUID(ctx, customerUUID))
+	mockCase.AssertExpectations(t)
+}
+
+func TestAuthorizedStreamUsecaseOnlyDeliversOwnEvents(t *testing.T) {
+
+	customerUUID := "039d69ee-f9cb-4a3d-87e4-6eb63c302579"
+	broker := &events.Broker{}
+	u := AuthorizedStreamUsecase{
+		Next:   &StreamImpl{Broker: broker, Policy: testPolicy},
+		Policy: testPolicy,
+	}
+
+	ctx := auth.NewContext(context.Background(), &auth.Principal{Subject: customerUUID, Roles: []string{"customer"}})
+	subscription, err := u.Subscribe(ctx, "")
+	assert.NoError(t, err)
+	defer subscription.Close()
+
+	assert.NoError(t, broker.Publish(context.Background(), events.Event{ID: "event-1", CustomerUUID: "f48ac180-e8ad-4837-a3c3-66b0e96f19bf"}))
+	assert.NoError(t, broker.Publish(context.Background(), events.Event{ID: "event-2", CustomerUUID: customerUUID}))
+
+	assert.Len(t, subscription.Events(), 1)
+	assert.Equal(t, "event-2", (<-subscription.Events()).ID)
+}
+
+func TestAuthorizedStreamUsecaseRequiresPrincipal(t *testing.T) {
+
+	u := AuthorizedStreamUsecase{
+		Next:   &StreamImpl{Broker: &events.Broker{}},
+		Policy: testPolicy,
+	}
+
+	_, err := u.Subscribe(context.Background(), "")
+	assert.Equal(t, entity.ErrUnauthorized, err)
+}
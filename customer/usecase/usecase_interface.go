@@ -1,14 +1,104 @@
 package usecase
 
-import "svc-customer/customer/entity"
+import (
+	"context"
+	"svc-customer/auth"
+	"svc-customer/customer/entity"
+	"svc-customer/events"
+)
 
 // Usecase interface
 type Usecase interface {
-	HealthCheck() error
-	GetByUUID(customerUUID string) (*entity.Customer, error) // CustomerDTO
-	Fetch(page int, limit int) ([]*entity.Customer, int, int, int, error)
-	Store(customer entity.Customer) error
-	UpdateByUUID(customer entity.Customer, customerUUID string) error
-	DeleteByUUID(customerUUID string) error
+	HealthCheck(ctx context.Context) error
+	GetByUUID(ctx context.Context, customerUUID string) (*entity.Customer, error) // CustomerDTO
+	Fetch(ctx context.Context, filter entity.CustomerFilter, page int, limit int) ([]*entity.Customer, int, int, int, error)
+	Store(ctx context.Context, customer entity.Customer) error
+	UpdateByUUID(ctx context.Context, customer entity.Customer, customerUUID string) error
+	DeleteByUUID(ctx context.Context, customerUUID string) error
+	RestoreByUUID(ctx context.Context, customerUUID string) error
+	Changes(ctx context.Context, token string, limit int) (*entity.ChangeFeed, error)
 	// RequestCustomerToken(email string, password string) (CustomerDTO, error)
 }
+
+// StreamUsecase live customer events
+type StreamUsecase interface {
+	// Subscribe replays the buffered events after lastEventID, then follows new ones
+	Subscribe(ctx context.Context, lastEventID string) (*events.Subscription, error)
+}
+
+// AuditUsecase customer audit trail
+type AuditUsecase interface {
+	History(ctx context.Context, customerUUID string, page int, limit int) (*entity.AuditPage, error)
+}
+
+// ErasureUsecase right-to-erasure of customer personal data
+type ErasureUsecase interface {
+	Erase(ctx context.Context, customerUUID string, request entity.ErasureRequest) error
+}
+
+// KeyRotationUsecase re-encryption of customer PII after a master key rotation
+type KeyRotationUsecase interface {
+	Rotate(ctx context.Context, table string, afterID int64) (entity.KeyRotation, error)
+}
+
+// ExportUsecase data subject access exports
+type ExportUsecase interface {
+	Export(ctx context.Context, customerUUID string, format string) (*entity.ExportBundle, error)
+	RequestExport(ctx context.Context, customerUUID string, format string) (*entity.ExportJob, error)
+	GetExport(ctx context.Context, customerUUID string, exportUUID string) (*entity.ExportJob, error)
+	DownloadExport(ctx context.Context, customerUUID string, exportUUID string) (*entity.ExportBundle, error)
+}
+
+// APIKeyUsecase management and verification of service API keys
+type APIKeyUsecase interface {
+	Create(ctx context.Context, request entity.APIKeyRequest) (*entity.APIKeyResponse, error)
+	Fetch(ctx context.Context) ([]*entity.APIKey, error)
+	Rotate(ctx context.Context, keyUUID string) (*entity.APIKeyResponse, error)
+	Revoke(ctx context.Context, keyUUID string) error
+	VerifyAPIKey(ctx context.Context, key string, ip string) (*auth.Principal, error)
+}
+
+// PasswordUsecase password recovery and change
+type PasswordUsecase interface {
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token string, password string) error
+	ChangePassword(ctx context.Context, customerUUID string, current string, password string) error
+}
+
+// EmailVerificationUsecase proof of email ownership
+type EmailVerificationUsecase interface {
+	SendVerification(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+}
+
+// PhoneVerificationUsecase proof of phone ownership through one-time codes
+type PhoneVerificationUsecase interface {
+	SendPhoneCode(ctx context.Context, customerUUID string) error
+	VerifyPhone(ctx context.Context, customerUUID string, code string) error
+}
+
+// SessionUsecase customer logins and their long lived sessions
+type SessionUsecase interface {
+	Login(ctx context.Context, request entity.LoginRequest, meta entity.SessionMeta) (*entity.TokenResponse, error)
+	Refresh(ctx context.Context, refreshToken string, meta entity.SessionMeta) (*entity.TokenResponse, error)
+	FetchSessions(ctx context.Context, customerUUID string) ([]*entity.Session, error)
+	RevokeSession(ctx context.Context, customerUUID string, sessionUUID string) error
+	RevokeSessions(ctx context.Context, customerUUID string) error
+}
+
+// MFAUsecase TOTP second factor enrollment
+type MFAUsecase interface {
+	EnrollTOTP(ctx context.Context, customerUUID string) (*entity.TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, customerUUID string, code string) (*entity.RecoveryCodesResponse, error)
+}
+
+// WebhookUsecase management of webhook subscriptions and their delivery log
+type WebhookUsecase interface {
+	Create(ctx context.Context, request entity.WebhookRequest) (*entity.WebhookResponse, error)
+	Fetch(ctx context.Context) ([]*entity.Webhook, error)
+	Delete(ctx context.Context, webhookUUID string) error
+	Deliveries(ctx context.Context, webhookUUID string) ([]*entity.WebhookDelivery, error)
+	Delivery(ctx context.Context, deliveryUUID string) (*entity.WebhookDelivery, error)
+	Replay(ctx context.Context, deliveryUUID string) error
+}
@@ -1,6 +1,7 @@
 package usecase
 
 import (
+	"context"
 	"owner/owner/mocks"
 	"svc-customer/customer/entity"
 	"testing"
@@ -28,7 +29,7 @@ func TestUsecaseGetByUUIDValid(t *testing.T) {
 	}
 
 	// Execute
-	cust, err := u.GetByUUID(mockCustomer.CustomerUUID)
+	cust, err := u.GetByUUID(context.Background(), mockCustomer.CustomerUUID)
 
 	assert.NoError(t, err)
 	assert.NotNil(t, cust)
@@ -49,7 +50,7 @@ func TestUsecaseGetByUUIDInvalidUUID(t *testing.T) {
 	}
 
 	// Execute
-	cust, err := u.GetByUUID(customerUUID)
+	cust, err := u.GetByUUID(context.Background(), customerUUID)
 
 	// Validate Expected
 	assert.Equal(t, err, entity.ErrBadParamInput)
@@ -78,7 +79,7 @@ func TestUsecaseStoreValid(t *testing.T) {
 	}
 
 	// Execute
-	err := u.Store(mockCustomer)
+	err := u.Store(context.Background(), mockCustomer)
 
 	assert.NoError(t, err)
 }
@@ -96,7 +97,7 @@ func TestUsecaseStoreInvalid(t *testing.T) {
 	}
 
 	// Execute
-	err := u.Store(mockCustomer)
+	err := u.Store(context.Background(), mockCustomer)
 
 	// Validate Expects
 	assert.EqualError(t, entity.ErrSQLError, err.Error())
@@ -125,7 +126,7 @@ func TestUsecaseUpdateByUUIDValid(t *testing.T) {
 	}
 
 	// Execute
-	err := u.UpdateByUUID(mockCustomer, customerUUID)
+	err := u.UpdateByUUID(context.Background(), mockCustomer, customerUUID)
 
 	assert.NoError(t, err)
 }
@@ -153,7 +154,7 @@ func TestUsecaseUpdateByUUIDInvalid(t *testing.T) {
 	}
 
 	// Execute
-	err := u.UpdateByUUID(mockCustomer, "")
+	err := u.UpdateByUUID(context.Background(), mockCustomer, "")
 
 	assert.Error(t, entity.ErrNotFound, err.Error())
 }
@@ -181,7 +182,7 @@ func TestUsecaseUpdateByUUIDInvalidDB(t *testing.T) {
 	}
 
 	// Execute
-	err := u.UpdateByUUID(mockCustomer, "")
+	err := u.UpdateByUUID(context.Background(), mockCustomer, "")
 
 	assert.Error(t, entity.ErrNotFound, err.Error())
 }
@@ -201,7 +202,7 @@ func TestUsecaseDeleteByUUIDValid(t *testing.T) {
 	}
 
 	// Execute
-	err := u.DeleteByUUID(customerUUID)
+	err := u.DeleteByUUID(context.Background(), customerUUID)
 
 	// Validate Expected
 	assert.NoError(t, err)
@@ -221,7 +222,7 @@ func TestUsecaseDeleteByUUIDInvalid(t *testing.T) {
 	}
 
 	// Execute
-	err := u.DeleteByUUID("")
+	err := u.DeleteByUUID(context.Background(), "")
 
 	// Validate Expected
 	assert.EqualError(t, entity.ErrSQLError, err.Error())
@@ -254,7 +255,7 @@ func TestUsecaseFetchValid(t *testing.T) {
 	}
 
 	// Execute
-	cust, _, _, _, err := u.Fetch(1, 10)
+	cust, _, _, _, err := u.Fetch(context.Background(), entity.CustomerFilter{}, 1, 10)
 
 	assert.NoError(t, err)
 	assert.Equal(t, rows, cust)
@@ -271,7 +272,7 @@ func TestUsecaseHealthCheckValid(t *testing.T) {
 	}
 
 	// Execute
-	err := u.HealthCheck()
+	err := u.HealthCheck(context.Background())
 
 	assert.NoError(t, err)
 }
@@ -287,6 +288,50 @@ func TestUsecaseHealthCheckError(t *testing.T) {
 	}
 
 	// Execute
-	err := u.HealthCheck()
+	err := u.HealthCheck(context.Background())
 	assert.Error(t, entity.ErrDatabaseError, err)
 }
+
+func TestUsecaseChangesPagesBySequence(t *testing.T) {
+
+	mockCase := new(mocks.MockedRepository)
+	mockCase.On("Changes", int64(10), 3).Return([]*entity.CustomerChange{
+		{Sequence: 11, Operation: entity.ChangeInsert},
+		{Sequence: 12, Operation: entity.ChangeUpdate},
+		{Sequence: 13, Operation: entity.ChangeDelete},
+	}, nil)
+
+	u := GetCustomerImpl{Repo: mockCase}
+	feed, err := u.Changes(context.Background(), entity.EncodeChangeToken(10), 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, feed.Changes, 2)
+	assert.True(t, feed.HasMore)
+	since, err := entity.DecodeChangeToken(feed.NextToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), since)
+}
+
+func TestUsecaseChangesEmptyPageKeepsPosition(t *testing.T) {
+
+	mockCase := new(mocks.MockedRepository)
+	mockCase.On("Changes", int64(42), DefaultChangeLimit+1).Return([]*entity.CustomerChange{}, nil)
+
+	u := GetCustomerImpl{Repo: mockCase}
+	feed, err := u.Changes(context.Background(), entity.EncodeChangeToken(42), 0)
+
+	assert.NoError(t, err)
+	assert.False(t, feed.HasMore)
+	assert.Equal(t, entity.EncodeChangeToken(42), feed.NextToken)
+}
+
+func TestUsecaseChangesInvalidToken(t *testing.T) {
+
+	mockCase := new(mocks.MockedRepository)
+
+	u := GetCustomerImpl{Repo: mockCase}
+	_, err := u.Changes(context.Background(), "not a token", 10)
+
+	assert.Equal(t, entity.ErrBadParamInput, err)
+	assert.Empty(t, mockCase.Calls)
+}
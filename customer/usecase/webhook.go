@@ -0,0 +1,279 @@
+package usecase
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/repository"
+	"svc-customer/events"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/tracing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultWebhookAttempts attempts before a delivery is dead-lettered
+const DefaultWebhookAttempts = 8
+
+// DefaultWebhookBackoff pause after the first failed attempt, doubled per attempt
+const DefaultWebhookBackoff = 30 * time.Second
+
+// maxWebhookBackoff longest pause between attempts
+const maxWebhookBackoff = 6 * time.Hour
+
+// webhookBatchSize deliveries sent per dispatcher poll
+const webhookBatchSize = 50
+
+// webhookClaimLease time claimed deliveries are hidden from other
+// dispatchers, a batch at the 10s client timeout takes under 9 minutes
+const webhookClaimLease = 10 * time.Minute
+
+// webhookDeliveryLimit deliveries listed per webhook
+const webhookDeliveryLimit = 100
+
+// webhookEventTypes event types a webhook can subscribe to
+var webhookEventTypes = map[string]bool{
+	events.CustomerCreated:  true,
+	events.CustomerUpdated:  true,
+	events.CustomerDeleted:  true,
+	events.CustomerRestored: true,
+	events.CustomerErased:   true,
+}
+
+// HTTPDoer sends webhook requests, *http.Client in production
+type HTTPDoer interface {
+	Do(request *http.Request) (*http.Response, error)
+}
+
+// WebhookImpl implementation, it also queues events as an events.Publisher
+// and sends due deliveries through Dispatch
+type WebhookImpl struct {
+	Repo   repository.WebhookRepository
+	Client HTTPDoer
+	// MaxAttempts before dead-lettering, DefaultWebhookAttempts when zero
+	MaxAttempts int
+	// Backoff first retry pause, DefaultWebhookBackoff when zero
+	Backoff time.Duration
+	// Now clock of the signature timestamps, time.Now when nil
+	Now func() time.Time
+}
+
+// Create subscribes url to eventTypes and returns the signing secret, the only time it is visible
+func (uc *WebhookImpl) Create(ctx context.Context, request entity.WebhookRequest) (_ *entity.WebhookResponse, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.CreateWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	if !validWebhookRequest(request) {
+		return nil, entity.ErrBadParamInput
+	}
+	secret, err := entity.GenerateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+	webhookUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := entity.Webhook{WebhookUUID: webhookUUID.String(), URL: request.URL, EventTypes: request.EventTypes}
+	if err = uc.Repo.StoreWebhook(ctx, webhook, secret); err != nil {
+		return nil, err
+	}
+	stored, err := uc.Repo.GetWebhook(ctx, webhook.WebhookUUID)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).WithField("webhook_uuid", webhook.WebhookUUID).Info("CreateWebhook: webhook created")
+	return &entity.WebhookResponse{Secret: secret, Webhook: stored}, nil
+}
+
+// Fetch active webhooks without secrets
+func (uc *WebhookImpl) Fetch(ctx context.Context) (_ []*entity.Webhook, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.FetchWebhooks")
+	defer func() { tracing.End(span, err) }()
+
+	return uc.Repo.FetchWebhooks(ctx)
+}
+
+// Delete stops deliveries to webhookUUID
+func (uc *WebhookImpl) Delete(ctx context.Context, webhookUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.DeleteWebhook")
+	defer func() { tracing.End(span, err) }()
+
+	if err = uc.Repo.DeleteWebhook(ctx, webhookUUID); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).WithField("webhook_uuid", webhookUUID).Info("DeleteWebhook: webhook deleted")
+	return nil
+}
+
+// Deliveries latest deliveries of webhookUUID
+func (uc *WebhookImpl) Deliveries(ctx context.Context, webhookUUID string) (_ []*entity.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.FetchWebhookDeliveries")
+	defer func() { tracing.End(span, err) }()
+
+	if _, err = uc.Repo.GetWebhook(ctx, webhookUUID); err != nil {
+		return nil, err
+	}
+	return uc.Repo.FetchDeliveries(ctx, webhookUUID, webhookDeliveryLimit)
+}
+
+// Delivery one delivery with its attempt log
+func (uc *WebhookImpl) Delivery(ctx context.Context, deliveryUUID string) (_ *entity.WebhookDelivery, err error) {
+	ctx, span := tracing.Start(ctx, "usecase.GetWebhookDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	return uc.Repo.GetDelivery(ctx, deliveryUUID)
+}
+
+// Replay sends a delivery again, dead or delivered ones included
+func (uc *WebhookImpl) Replay(ctx context.Context, deliveryUUID string) (err error) {
+	ctx, span := tracing.Start(ctx, "usecase.ReplayWebhookDelivery")
+	defer func() { tracing.End(span, err) }()
+
+	if err = uc.Repo.ReplayDelivery(ctx, deliveryUUID); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).WithField("delivery_uuid", deliveryUUID).Info("ReplayWebhookDelivery: delivery queued again")
+	return nil
+}
+
+// Publish queues event for every webhook subscribed to its type, events.Publisher of the outbox relay
+func (uc *WebhookImpl) Publish(ctx context.Context, event events.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return uc.Repo.EnqueueDeliveries(ctx, event.ID, event.Type, string(body))
+}
+
+// Dispatch sends one batch of due deliveries and returns how many were delivered
+func (uc *WebhookImpl) Dispatch(ctx context.Context) (int, error) {
+	deliveries, err := uc.Repo.ClaimDueDeliveries(ctx, webhookBatchSize, webhookClaimLease)
+	if err != nil {
+		return 0, err
+	}
+	delivered := 0
+	for _, delivery := range deliveries {
+		attempt := uc.send(ctx, delivery)
+		status, retryIn := uc.outcome(delivery, attempt)
+		metrics.WebhookDeliveries.WithLabelValues(status).Inc()
+		if status == entity.WebhookDelivered {
+			delivered++
+		}
+		if status == entity.WebhookDead {
+			logging.FromContext(ctx).
+				WithField("delivery_uuid", delivery.DeliveryUUID).
+				WithField("webhook_uuid", delivery.WebhookUUID).
+				Warn("Dispatch: delivery dead-lettered")
+		}
+		if err := uc.Repo.RecordAttempt(ctx, delivery.DeliveryUUID, attempt, status, retryIn); err != nil {
+			return delivered, err
+		}
+	}
+	return delivered, nil
+}
+
+// RunDispatcher calls Dispatch every interval until ctx is cancelled
+func (uc *WebhookImpl) RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := uc.Dispatch(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("Dispatch: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// send POSTs the signed payload, any 2xx answer counts as delivered
+func (uc *WebhookImpl) send(ctx context.Context, delivery *entity.WebhookDelivery) entity.WebhookAttempt {
+	now := time.Now
+	if uc.Now != nil {
+		now = uc.Now
+	}
+	start := time.Now()
+	attempt := entity.WebhookAttempt{}
+
+	body := []byte(delivery.Payload)
+	request, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := now().Unix()
+	request = request.WithContext(ctx)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(entity.WebhookIDHeader, delivery.DeliveryUUID)
+	request.Header.Set(entity.WebhookEventHeader, delivery.EventType)
+	request.Header.Set(entity.WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	request.Header.Set(entity.WebhookSignatureHeader, entity.SignWebhook(delivery.Secret, timestamp, body))
+
+	response, err := uc.Client.Do(request)
+	attempt.DurationMs = int(time.Since(start) / time.Millisecond)
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()                                    //nolint
+	io.Copy(ioutil.Discard, io.LimitReader(response.Body, 64<<10)) //nolint
+
+	attempt.StatusCode = response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		attempt.Error = response.Status
+	}
+	return attempt
+}
+
+// outcome next status of delivery after attempt and the pause before retrying it
+func (uc *WebhookImpl) outcome(delivery *entity.WebhookDelivery, attempt entity.WebhookAttempt) (string, time.Duration) {
+	if attempt.Error == "" {
+		return entity.WebhookDelivered, 0
+	}
+	maxAttempts := uc.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultWebhookAttempts
+	}
+	if delivery.Attempts+1 >= maxAttempts {
+		return entity.WebhookDead, 0
+	}
+	backoff := uc.Backoff
+	if backoff == 0 {
+		backoff = DefaultWebhookBackoff
+	}
+	retryIn := backoff << uint(delivery.Attempts)
+	if retryIn <= 0 || retryIn > maxWebhookBackoff {
+		retryIn = maxWebhookBackoff
+	}
+	return entity.WebhookPending, retryIn
+}
+
+// validWebhookRequest absolute http(s) URL and known event types
+func validWebhookRequest(request entity.WebhookRequest) bool {
+	target, err := url.Parse(request.URL)
+	if err != nil || (target.Scheme != "https" && target.Scheme != "http") || target.Host == "" {
+		return false
+	}
+	if len(request.EventTypes) == 0 {
+		return false
+	}
+	for _, eventType := range request.EventTypes {
+		if !webhookEventTypes[eventType] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"svc-customer/customer/entity"
+	"svc-customer/customer/mocks"
+	"svc-customer/events"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// webhookNow fixed clock of the webhook tests
+var webhookNow = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newWebhookImpl() (*WebhookImpl, *mocks.MockedWebhookRepository) {
+	mockRepo := new(mocks.MockedWebhookRepository)
+	return &WebhookImpl{
+		Repo:   mockRepo,
+		Client: http.DefaultClient,
+		Now:    func() time.Time { return webhookNow },
+	}, mockRepo
+}
+
+func TestCreateWebhookReturnsSecretOnce(t *testing.T) {
+
+	u, mockRepo := newWebhookImpl()
+	mockRepo.On("StoreWebhook", mock.AnythingOfType("entity.Webhook"), mock.AnythingOfType("string")).Return(nil)
+	mockRepo.On("GetWebhook", mock.AnythingOfType("string")).Return(&entity.Webhook{URL: "https://partner.example.com/hooks"}, nil)
+
+	response, err := u.Create(context.Background(), entity.WebhookRequest{
+		URL:        "https://partner.example.com/hooks",
+		EventTypes: []string{events.CustomerCreated},
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, response.Secret, "whsec_")
+	assert.Equal(t, response.Secret, mockRepo.Calls[0].Arguments.String(1))
+}
+
+func TestCreateWebhookRejectsInvalidRequests(t *testing.T) {
+
+	u, mockRepo := newWebhookImpl()
+	for _, request := range []entity.WebhookRequest{
+		{URL: "ftp://partner.example.com", EventTypes: []string{events.CustomerCreated}},
+		{URL: "https://partner.example.com"},
+		{URL: "https://partner.example.com", EventTypes: []string{"CustomerRenamed"}},
+	} {
+		_, err := u.Create(context.Background(), request)
+		assert.Equal(t, entity.ErrBadParamInput, err)
+	}
+	mockRepo.AssertNotCalled(t, "StoreWebhook", mock.Anything, mock.Anything)
+}
+
+func TestWebhookPublishQueuesEvent(t *testing.T) {
+
+	u, mockRepo := newWebhookImpl()
+	event := events.Event{ID: "event-1", Type: events.CustomerUpdated, CustomerUUID: resetCustomerUUID, Payload: json.RawMessage(`{"name":"Jhon"}`)}
+	mockRepo.On("EnqueueDeliveries", "event-1", events.CustomerUpdated, mock.AnythingOfType("string")).Return(nil)
+
+	assert.NoError(t, u.Publish(context.Background(), event))
+	var queued events.Event
+	assert.NoError(t, json.Unmarshal([]byte(mockRepo.Calls[0].Arguments.String(2)), &queued))
+	assert.Equal(t, event.ID, queued.ID)
+	assert.Equal(t, resetCustomerUUID, queued.CustomerUUID)
+}
+
+func TestDispatchSignsDeliveries(t *testing.T) {
+
+	payload := `{"event_id":"event-1"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		timestamp, err := strconv.ParseInt(r.Header.Get(entity.WebhookTimestampHeader), 10, 64)
+		assert.NoError(t, err)
+		assert.Equal(t, webhookNow.Unix(), timestamp)
+		assert.Equal(t, entity.SignWebhook("whsec_test", timestamp, body), r.Header.Get(entity.WebhookSignatureHeader))
+		assert.Equal(t, "delivery-1", r.Header.Get(entity.WebhookIDHeader))
+		assert.Equal(t, events.CustomerCreated, r.Header.Get(entity.WebhookEventHeader))
+		assert.Equal(t, payload, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	u, mockRepo := newWebhookImpl()
+	mockRepo.On("ClaimDueDeliveries", webhookBatchSize, webhookClaimLease).Return([]*entity.WebhookDelivery{
+		{DeliveryUUID: "delivery-1", EventType: events.CustomerCreated, URL: server.URL, Secret: "whsec_test", Payload: payload},
+	}, nil)
+	mockRepo.On("RecordAttempt", "delivery-1", mock.AnythingOfType("entity.WebhookAttempt"), entity.WebhookDelivered, time.Duration(0)).Return(nil)
+
+	delivered, err := u.Dispatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delivered)
+	attempt := mockRepo.Calls[1].Arguments.Get(1).(entity.WebhookAttempt)
+	assert.Equal(t, http.StatusNoContent, attempt.StatusCode)
+	assert.Empty(t, attempt.Error)
+}
+
+func TestDispatchRetriesWithBackoff(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	u, mockRepo := newWebhookImpl()
+	mockRepo.On("ClaimDueDeliveries", webhookBatchSize, webhookClaimLease).Return([]*entity.WebhookDelivery{
+		{DeliveryUUID: "delivery-1", URL: server.URL, Secret: "whsec_test", Attempts: 2},
+	}, nil)
+	mockRepo.On("RecordAttempt", "delivery-1", mock.AnythingOfType("entity.WebhookAttempt"), entity.WebhookPending, 4*DefaultWebhookBackoff).Return(nil)
+
+	delivered, err := u.Dispatch(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delivered)
+	attempt := mockRepo.Calls[1].Arguments.Get(1).(entity.WebhookAttempt)
+	assert.Equal(t, http.StatusServiceUnavailable, attempt.StatusCode)
+	assert.NotEmpty(t, attempt.Error)
+}
+
+func TestDispatchDeadLettersAfterMaxAttempts(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	u, mockRepo := newWebhookImpl()
+	mockRepo.On("ClaimDueDeliveries", webhookBatchSize, webhookClaimLease).Return([]*entity.WebhookDelivery{
+		{DeliveryUUID: "delivery-1", URL: server.URL, Secret: "whsec_test", Attempts: DefaultWebhookAttempts - 1},
+	}, nil)
+	mockRepo.On("RecordAttempt", "delivery-1", mock.AnythingOfType("entity.WebhookAttempt"), entity.WebhookDead, time.Duration(0)).Return(nil)
+
+	_, err := u.Dispatch(context.Background())
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestWebhookBackoffIsCapped(t *testing.T) {
+
+	u, _ := newWebhookImpl()
+	u.MaxAttempts = 100
+	status, retryIn := u.outcome(&entity.WebhookDelivery{Attempts: 40}, entity.WebhookAttempt{Error: "timeout"})
+	assert.Equal(t, entity.WebhookPending, status)
+	assert.Equal(t, maxWebhookBackoff, retryIn)
+}
@@ -25,6 +25,15 @@
 //  Produces:
 //    - application/json
 //
+//  SecurityDefinitions:
+//    bearer:
+//      type: apiKey
+//      name: Authorization
+//      in: header
+//
+//  Security:
+//    - bearer: []
+//
 // swagger:meta
 package restapi
 
@@ -149,3 +158,22 @@ type swaggerResponseArray struct {
 		Data        []Swagger `json:"data"`
 	}
 }
+
+// swagger:response healthReport
+type healthReport struct {
+	// in: body
+	Body struct {
+		Status string        `json:"status"`
+		Checks []HealthCheck `json:"checks"`
+	}
+}
+
+// HealthCheck outcome of a single dependency check
+type HealthCheck struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	CheckedAt string  `json:"checked_at"`
+	Cached    bool    `json:"cached"`
+}
@@ -0,0 +1,97 @@
+// Package encrypt seals small secrets stored in the database with AES-256-GCM
+package encrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// KeySize AES-256 key length in bytes
+const KeySize = 32
+
+var (
+	// ErrNoKey the environment variable holding the key is not set
+	ErrNoKey = errors.New("encrypt: key not configured")
+
+	// ErrCiphertext the value was not sealed with this key or was altered
+	ErrCiphertext = errors.New("encrypt: cannot open ciphertext")
+)
+
+// Cipher seals and opens values, ciphertexts are base64 text safe for varchar columns
+type Cipher interface {
+	Seal(plaintext []byte) (string, error)
+	Open(ciphertext string) ([]byte, error)
+}
+
+// AESGCM Cipher with a random nonce prefixed to every ciphertext
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM cipher for a KeySize byte key
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encrypt: key must be %d bytes, got %d", KeySize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+// Seal encrypts and authenticates plaintext
+func (c *AESGCM) Seal(plaintext []byte) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("encrypt: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal
+func (c *AESGCM) Open(ciphertext string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return nil, ErrCiphertext
+	}
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return nil, ErrCiphertext
+	}
+	return plaintext, nil
+}
+
+// FromEnv cipher keyed by the base64 value of the environment variable name,
+// ErrNoKey when it is unset. Generate a key with `openssl rand -base64 32`.
+func FromEnv(name string) (*AESGCM, error) {
+	key, err := keyFromEnv(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewAESGCM(key)
+}
+
+// keyFromEnv base64 decoded value of the environment variable name
+func keyFromEnv(name string) ([]byte, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, ErrNoKey
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %s: %w", name, err)
+	}
+	return key, nil
+}
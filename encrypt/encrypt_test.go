@@ -0,0 +1,41 @@
+package encrypt_test
+
+import (
+	"bytes"
+	"svc-customer/encrypt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+
+	cipher, err := encrypt.NewAESGCM(bytes.Repeat([]byte{7}, encrypt.KeySize))
+	assert.NoError(t, err)
+
+	first, err := cipher.Seal([]byte("JBSWY3DPEHPK3PXP"))
+	assert.NoError(t, err)
+	second, _ := cipher.Seal([]byte("JBSWY3DPEHPK3PXP"))
+	assert.NotEqual(t, first, second, "nonces must differ")
+
+	plaintext, err := cipher.Open(first)
+	assert.NoError(t, err)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", string(plaintext))
+}
+
+func TestOpenRejectsOtherKeyAndTampering(t *testing.T) {
+
+	cipher, _ := encrypt.NewAESGCM(bytes.Repeat([]byte{7}, encrypt.KeySize))
+	other, _ := encrypt.NewAESGCM(bytes.Repeat([]byte{8}, encrypt.KeySize))
+
+	sealed, _ := cipher.Seal([]byte("secret"))
+
+	_, err := other.Open(sealed)
+	assert.Equal(t, encrypt.ErrCiphertext, err)
+
+	_, err = cipher.Open("not base64!")
+	assert.Equal(t, encrypt.ErrCiphertext, err)
+
+	_, err = encrypt.NewAESGCM([]byte("short"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,190 @@
+package encrypt
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// envelopePrefix marks values sealed by an Envelope, anything else is plain text
+const envelopePrefix = "enc:v1:"
+
+// maxDataKeyUses values sealed under one data key before a new one is
+// generated, far below the 2^32 random nonces AES-GCM allows per key
+const maxDataKeyUses = 1 << 24
+
+// Envelope encrypts values under data keys wrapped by a KeyProvider. Every
+// value carries the master key ID and its wrapped data key,
+// "enc:v1:<key id>:<wrapped data key>:<ciphertext>", so it opens as long as
+// the provider knows that master key.
+type Envelope struct {
+	keys KeyProvider
+
+	mu      sync.Mutex
+	sealing *dataKey
+	opened  map[string]*AESGCM
+}
+
+// dataKey data key new values are sealed with
+type dataKey struct {
+	keyID   string
+	wrapped string
+	cipher  *AESGCM
+	uses    int
+}
+
+// NewEnvelope envelope wrapping its data keys with keys
+func NewEnvelope(keys KeyProvider) *Envelope {
+	return &Envelope{keys: keys, opened: map[string]*AESGCM{}}
+}
+
+// Encrypt seals plaintext under a data key of the active master key
+func (e *Envelope) Encrypt(ctx context.Context, plaintext string) (string, error) {
+	key, err := e.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	sealed, err := key.cipher.Seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	return envelopePrefix + key.keyID + ":" + key.wrapped + ":" + sealed, nil
+}
+
+// Decrypt opens a value sealed by Encrypt, values without the envelope
+// prefix were stored before encryption was enabled and are returned as they are
+func (e *Envelope) Decrypt(ctx context.Context, value string) (string, error) {
+	if !strings.HasPrefix(value, envelopePrefix) {
+		return value, nil
+	}
+	keyID, wrapped, sealed, ok := parseEnvelope(value)
+	if !ok {
+		return "", ErrCiphertext
+	}
+	cipher, err := e.openKey(ctx, keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := cipher.Open(sealed)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plain text or sealed under a master
+// key other than the active one, empty values need nothing
+func (e *Envelope) NeedsRotation(value string) bool {
+	if value == "" {
+		return false
+	}
+	keyID, _, _, ok := parseEnvelope(value)
+	return !ok || keyID != e.keys.ActiveKeyID()
+}
+
+// dataKey data key of the active master key, replaced when the active key
+// changes or after maxDataKeyUses values
+func (e *Envelope) dataKey(ctx context.Context) (*dataKey, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	active := e.keys.ActiveKeyID()
+	if e.sealing != nil && e.sealing.keyID == active && e.sealing.uses < maxDataKeyUses {
+		e.sealing.uses++
+		return e.sealing, nil
+	}
+
+	plain := make([]byte, KeySize)
+	if _, err := rand.Read(plain); err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	wrapped, err := e.keys.WrapKey(ctx, active, plain)
+	if err != nil {
+		return nil, err
+	}
+	cipher, err := NewAESGCM(plain)
+	if err != nil {
+		return nil, err
+	}
+	e.sealing = &dataKey{keyID: active, wrapped: wrapped, cipher: cipher, uses: 1}
+	e.opened[active+":"+wrapped] = cipher
+	return e.sealing, nil
+}
+
+// openKey cipher of a wrapped data key, unwrapped once per process
+func (e *Envelope) openKey(ctx context.Context, keyID string, wrapped string) (*AESGCM, error) {
+	cacheKey := keyID + ":" + wrapped
+	e.mu.Lock()
+	cipher, ok := e.opened[cacheKey]
+	e.mu.Unlock()
+	if ok {
+		return cipher, nil
+	}
+
+	plain, err := e.keys.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if cipher, err = NewAESGCM(plain); err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	e.opened[cacheKey] = cipher
+	e.mu.Unlock()
+	return cipher, nil
+}
+
+// parseEnvelope splits an envelope value, the wrapped data key sits between
+// the first and the last ":" after the prefix
+func parseEnvelope(value string) (keyID string, wrapped string, sealed string, ok bool) {
+	if !strings.HasPrefix(value, envelopePrefix) {
+		return "", "", "", false
+	}
+	rest := value[len(envelopePrefix):]
+	first, last := strings.Index(rest, ":"), strings.LastIndex(rest, ":")
+	if first < 1 || last <= first+1 || last == len(rest)-1 {
+		return "", "", "", false
+	}
+	return rest[:first], rest[first+1 : last], rest[last+1:], true
+}
+
+// BlindIndex keyed HMAC-SHA256 of values, equal values give equal indexes so
+// encrypted columns can still be compared in SQL without revealing them
+type BlindIndex struct {
+	key []byte
+}
+
+// NewBlindIndex blind index for a KeySize byte key, which never rotates:
+// a new key means recomputing every index
+func NewBlindIndex(key []byte) (*BlindIndex, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("encrypt: index key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return &BlindIndex{key: key}, nil
+}
+
+// BlindIndexFromEnv blind index keyed by the base64 value of the environment
+// variable name, ErrNoKey when it is unset
+func BlindIndexFromEnv(name string) (*BlindIndex, error) {
+	key, err := keyFromEnv(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewBlindIndex(key)
+}
+
+// Sum hex index of value in column, case and surrounding spaces are ignored
+// like the case insensitive collation of the plain text columns did. The
+// column name keeps equal values of different columns apart.
+func (b *BlindIndex) Sum(column string, value string) string {
+	mac := hmac.New(sha256.New, b.key)
+	mac.Write([]byte(column))                                    //nolint
+	mac.Write([]byte{0})                                         //nolint
+	mac.Write([]byte(strings.ToLower(strings.TrimSpace(value)))) //nolint
+	return hex.EncodeToString(mac.Sum(nil))
+}
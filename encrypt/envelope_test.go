@@ -0,0 +1,95 @@
+package encrypt_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"svc-customer/encrypt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnvelopeRoundTripAndRotation(t *testing.T) {
+
+	ctx := context.Background()
+	old, err := encrypt.NewLocalKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, encrypt.KeySize)})
+	assert.NoError(t, err)
+	sealed, err := encrypt.NewEnvelope(old).Encrypt(ctx, "jdoe@gmail.com")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(sealed, "enc:v1:k1:"))
+
+	rotated, err := encrypt.NewLocalKeyProvider("k2", map[string][]byte{
+		"k1": bytes.Repeat([]byte{1}, encrypt.KeySize),
+		"k2": bytes.Repeat([]byte{2}, encrypt.KeySize),
+	})
+	assert.NoError(t, err)
+	envelope := encrypt.NewEnvelope(rotated)
+
+	plaintext, err := envelope.Decrypt(ctx, sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "jdoe@gmail.com", plaintext)
+	assert.True(t, envelope.NeedsRotation(sealed))
+	assert.True(t, envelope.NeedsRotation("jdoe@gmail.com"), "plain text is encrypted by a rotation")
+	assert.False(t, envelope.NeedsRotation(""))
+
+	resealed, err := envelope.Encrypt(ctx, plaintext)
+	assert.NoError(t, err)
+	assert.False(t, envelope.NeedsRotation(resealed))
+
+	plaintext, err = envelope.Decrypt(ctx, "legacy@gmail.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "legacy@gmail.com", plaintext)
+}
+
+func TestEnvelopeRejectsUnknownKeyAndTampering(t *testing.T) {
+
+	ctx := context.Background()
+	provider, _ := encrypt.NewLocalKeyProvider("k1", map[string][]byte{"k1": bytes.Repeat([]byte{1}, encrypt.KeySize)})
+	other, _ := encrypt.NewLocalKeyProvider("k9", map[string][]byte{"k9": bytes.Repeat([]byte{9}, encrypt.KeySize)})
+
+	sealed, _ := encrypt.NewEnvelope(provider).Encrypt(ctx, "264573076")
+
+	_, err := encrypt.NewEnvelope(other).Decrypt(ctx, sealed)
+	assert.Equal(t, encrypt.ErrUnknownKey, err)
+
+	_, err = encrypt.NewEnvelope(provider).Decrypt(ctx, "enc:v1:k1:broken")
+	assert.Equal(t, encrypt.ErrCiphertext, err)
+}
+
+func TestLoadKeyFile(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "keys")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint
+
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{3}, encrypt.KeySize))
+	path := filepath.Join(dir, "keys.json")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"active": "2020-06", "keys": {"2020-06": "`+key+`"}}`), 0600))
+
+	provider, err := encrypt.LoadKeyFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "2020-06", provider.ActiveKeyID())
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"active": "2020-07", "keys": {"2020-06": "`+key+`"}}`), 0600))
+	_, err = encrypt.LoadKeyFile(path)
+	assert.Error(t, err, "the active key must be in the file")
+}
+
+func TestBlindIndex(t *testing.T) {
+
+	index, err := encrypt.NewBlindIndex(bytes.Repeat([]byte{4}, encrypt.KeySize))
+	assert.NoError(t, err)
+
+	assert.Equal(t, index.Sum("email", "jdoe@gmail.com"), index.Sum("email", " JDoe@Gmail.com"))
+	assert.NotEqual(t, index.Sum("email", "jdoe@gmail.com"), index.Sum("email", "other@gmail.com"))
+	assert.NotEqual(t, index.Sum("dni", "264573076"), index.Sum("phone", "264573076"))
+	assert.Len(t, index.Sum("email", "jdoe@gmail.com"), 64)
+
+	_, err = encrypt.NewBlindIndex([]byte("short"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package encrypt
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrUnknownKey the key provider holds no master key with the requested ID
+var ErrUnknownKey = errors.New("encrypt: unknown master key")
+
+// KeyProvider wraps data keys under master keys it never hands out. A KMS
+// client implements it in production, LocalKeyProvider in development.
+type KeyProvider interface {
+	// ActiveKeyID master key new data keys are wrapped with
+	ActiveKeyID() string
+	// WrapKey encrypts dataKey under the master key keyID, the result is text
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) (string, error)
+	// UnwrapKey decrypts a data key returned by WrapKey for keyID
+	UnwrapKey(ctx context.Context, keyID string, wrapped string) ([]byte, error)
+}
+
+// keyFile document read by LoadKeyFile, keys are base64 KeySize byte values
+//
+//	{"active": "2020-06", "keys": {"2020-01": "...", "2020-06": "..."}}
+type keyFile struct {
+	Active string            `json:"active"`
+	Keys   map[string]string `json:"keys"`
+}
+
+// LocalKeyProvider KeyProvider keeping its master keys in process memory
+type LocalKeyProvider struct {
+	active string
+	keys   map[string]*AESGCM
+}
+
+// NewLocalKeyProvider provider wrapping new data keys with keys[active], key
+// IDs must not be empty nor contain ":"
+func NewLocalKeyProvider(active string, keys map[string][]byte) (*LocalKeyProvider, error) {
+	provider := &LocalKeyProvider{active: active, keys: map[string]*AESGCM{}}
+	for keyID, key := range keys {
+		if keyID == "" || strings.Contains(keyID, ":") {
+			return nil, fmt.Errorf("encrypt: invalid master key ID %q", keyID)
+		}
+		cipher, err := NewAESGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("encrypt: master key %s: %w", keyID, err)
+		}
+		provider.keys[keyID] = cipher
+	}
+	if _, ok := provider.keys[active]; !ok {
+		return nil, fmt.Errorf("encrypt: active master key %q: %w", active, ErrUnknownKey)
+	}
+	return provider, nil
+}
+
+// LoadKeyFile provider with the master keys of the JSON key file at path.
+// Rotating adds a key, makes it active and keeps the old one until every
+// value was re-encrypted.
+func LoadKeyFile(path string) (*LocalKeyProvider, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	var file keyFile
+	if err = json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("encrypt: %s: %w", path, err)
+	}
+	keys := map[string][]byte{}
+	for keyID, value := range file.Keys {
+		if keys[keyID], err = base64.StdEncoding.DecodeString(value); err != nil {
+			return nil, fmt.Errorf("encrypt: master key %s: %w", keyID, err)
+		}
+	}
+	return NewLocalKeyProvider(file.Active, keys)
+}
+
+// ActiveKeyID master key new data keys are wrapped with
+func (p *LocalKeyProvider) ActiveKeyID() string {
+	return p.active
+}
+
+// WrapKey seals dataKey with the master key keyID
+func (p *LocalKeyProvider) WrapKey(ctx context.Context, keyID string, dataKey []byte) (string, error) {
+	cipher, ok := p.keys[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	return cipher.Seal(dataKey)
+}
+
+// UnwrapKey opens a data key sealed by WrapKey
+func (p *LocalKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped string) ([]byte, error) {
+	cipher, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return cipher.Open(wrapped)
+}
@@ -0,0 +1,175 @@
+package events
+
+import (
+	"context"
+	"svc-customer/metrics"
+	"sync"
+)
+
+// DefaultBrokerHistory events kept for resuming subscribers
+const DefaultBrokerHistory = 1000
+
+// DefaultSubscriberBuffer events queued per subscriber before it is dropped
+const DefaultSubscriberBuffer = 64
+
+// Broker fans events out to in-process subscribers such as Server-Sent Events
+// streams. Publish never waits for a subscriber: one that falls
+// SubscriberBuffer events behind is dropped and resumes from the history with
+// its last event ID. The zero value is ready to use.
+type Broker struct {
+	// History events kept for resuming, DefaultBrokerHistory when zero
+	History int
+	// SubscriberBuffer queue of each subscriber, DefaultSubscriberBuffer when zero
+	SubscriberBuffer int
+
+	mu          sync.Mutex
+	history     []Event
+	next        uint64
+	positions   map[string]uint64
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription events published after Subscribe, plus the buffered backlog
+type Subscription struct {
+	// Backlog buffered events after the requested last event ID, oldest first
+	Backlog []Event
+	// Reset the requested event is no longer buffered, some events were missed
+	Reset bool
+
+	events chan Event
+	filter func(Event) bool
+	broker *Broker
+}
+
+// Events delivers new events, it is closed when the subscriber fell behind or
+// the subscription was closed
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close stops delivering events to the subscription
+func (s *Subscription) Close() {
+	s.broker.remove(s)
+}
+
+// Publish buffers event and hands it to every subscriber whose filter accepts
+// it, an event ID already buffered is ignored
+func (b *Broker) Publish(ctx context.Context, event Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.init()
+	if _, ok := b.positions[event.ID]; ok {
+		return nil
+	}
+	size := b.historySize()
+	if b.next >= uint64(size) {
+		delete(b.positions, b.history[b.next%uint64(size)].ID)
+	}
+	b.history[b.next%uint64(size)] = event
+	b.positions[event.ID] = b.next
+	b.next++
+
+	for subscription := range b.subscribers {
+		if subscription.filter != nil && !subscription.filter(event) {
+			continue
+		}
+		select {
+		case subscription.events <- event:
+		default:
+			// Too slow, it reconnects with Last-Event-ID and catches up from history
+			b.drop(subscription)
+			metrics.StreamDropped.Inc()
+		}
+	}
+	return nil
+}
+
+// Subscribe starts a subscription, lastEventID (empty for none) selects the
+// buffered events to replay. filter, when not nil, picks the events delivered.
+func (b *Broker) Subscribe(lastEventID string, filter func(Event) bool) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.init()
+	subscription := &Subscription{
+		events: make(chan Event, b.subscriberBuffer()),
+		filter: filter,
+		broker: b,
+	}
+	if lastEventID != "" {
+		position, ok := b.positions[lastEventID]
+		if !ok {
+			position, subscription.Reset = b.oldest(), true
+		} else {
+			position++
+		}
+		size := uint64(b.historySize())
+		for ; position < b.next; position++ {
+			event := b.history[position%size]
+			if filter == nil || filter(event) {
+				subscription.Backlog = append(subscription.Backlog, event)
+			}
+		}
+	}
+	b.subscribers[subscription] = struct{}{}
+	return subscription
+}
+
+// Subscribers number of active subscriptions
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
+
+// remove closes subscription unless it was already dropped
+func (b *Broker) remove(subscription *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.drop(subscription)
+}
+
+// drop closes subscription, b.mu must be held
+func (b *Broker) drop(subscription *Subscription) {
+	if _, ok := b.subscribers[subscription]; !ok {
+		return
+	}
+	delete(b.subscribers, subscription)
+	close(subscription.events)
+}
+
+// oldest position still buffered, b.mu must be held
+func (b *Broker) oldest() uint64 {
+	size := uint64(b.historySize())
+	if b.next <= size {
+		return 0
+	}
+	return b.next - size
+}
+
+// init allocates the buffers on first use, b.mu must be held
+func (b *Broker) init() {
+	if b.history != nil {
+		return
+	}
+	b.history = make([]Event, b.historySize())
+	b.positions = map[string]uint64{}
+	b.subscribers = map[*Subscription]struct{}{}
+}
+
+// historySize events kept for resuming
+func (b *Broker) historySize() int {
+	if b.History <= 0 {
+		return DefaultBrokerHistory
+	}
+	return b.History
+}
+
+// subscriberBuffer queue length of new subscriptions
+func (b *Broker) subscriberBuffer() int {
+	if b.SubscriberBuffer <= 0 {
+		return DefaultSubscriberBuffer
+	}
+	return b.SubscriberBuffer
+}
@@ -0,0 +1,95 @@
+package events
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func brokerEvent(i int, customerUUID string) Event {
+	return Event{ID: "event-" + strconv.Itoa(i), Type: CustomerUpdated, CustomerUUID: customerUUID}
+}
+
+func TestBrokerFansOutToSubscribers(t *testing.T) {
+
+	broker := &Broker{}
+	first := broker.Subscribe("", nil)
+	second := broker.Subscribe("", nil)
+
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(1, "customer-1")))
+
+	assert.Equal(t, "event-1", (<-first.Events()).ID)
+	assert.Equal(t, "event-1", (<-second.Events()).ID)
+	assert.Equal(t, 2, broker.Subscribers())
+
+	first.Close()
+	first.Close()
+	assert.Equal(t, 1, broker.Subscribers())
+}
+
+func TestBrokerResumesAfterLastEventID(t *testing.T) {
+
+	broker := &Broker{History: 3}
+	for i := 1; i <= 4; i++ {
+		assert.NoError(t, broker.Publish(context.Background(), brokerEvent(i, "customer-1")))
+	}
+
+	resumed := broker.Subscribe("event-2", nil)
+	assert.False(t, resumed.Reset)
+	assert.Equal(t, []Event{brokerEvent(3, "customer-1"), brokerEvent(4, "customer-1")}, resumed.Backlog)
+
+	// event-1 was evicted, everything still buffered is replayed
+	evicted := broker.Subscribe("event-1", nil)
+	assert.True(t, evicted.Reset)
+	assert.Len(t, evicted.Backlog, 3)
+	assert.Equal(t, "event-2", evicted.Backlog[0].ID)
+}
+
+func TestBrokerIgnoresRepublishedEvents(t *testing.T) {
+
+	broker := &Broker{}
+	subscription := broker.Subscribe("", nil)
+
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(1, "customer-1")))
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(1, "customer-1")))
+
+	assert.Len(t, subscription.Events(), 1)
+}
+
+func TestBrokerFiltersEvents(t *testing.T) {
+
+	broker := &Broker{}
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(1, "customer-2")))
+	own := func(event Event) bool { return event.CustomerUUID == "customer-1" }
+
+	subscription := broker.Subscribe("event-0", own)
+	assert.Empty(t, subscription.Backlog)
+
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(2, "customer-2")))
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(3, "customer-1")))
+
+	assert.Len(t, subscription.Events(), 1)
+	assert.Equal(t, "event-3", (<-subscription.Events()).ID)
+}
+
+func TestBrokerDropsSlowSubscriberWithoutBlocking(t *testing.T) {
+
+	broker := &Broker{SubscriberBuffer: 1}
+	slow := broker.Subscribe("", nil)
+
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(1, "customer-1")))
+	assert.NoError(t, broker.Publish(context.Background(), brokerEvent(2, "customer-1")))
+
+	assert.Equal(t, 0, broker.Subscribers())
+	event, ok := <-slow.Events()
+	assert.True(t, ok)
+	assert.Equal(t, "event-1", event.ID)
+	_, ok = <-slow.Events()
+	assert.False(t, ok)
+
+	// It catches up from the history
+	resumed := broker.Subscribe(event.ID, nil)
+	assert.Equal(t, "event-2", resumed.Backlog[0].ID)
+}
@@ -0,0 +1,150 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"svc-customer/logging"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Customer lifecycle event types
+const (
+	CustomerCreated  = "CustomerCreated"
+	CustomerUpdated  = "CustomerUpdated"
+	CustomerDeleted  = "CustomerDeleted"
+	CustomerRestored = "CustomerRestored"
+	CustomerErased   = "CustomerErased"
+)
+
+// Event change of one customer. Delivery is at-least-once, consumers drop
+// redeliveries by ID.
+type Event struct {
+	ID           string          `json:"event_id"`
+	Type         string          `json:"type"`
+	CustomerUUID string          `json:"customer_uuid"`
+	OccurredAt   time.Time       `json:"occurred_at"`
+	Payload      json.RawMessage `json:"payload"`
+}
+
+// New event of eventType about customerUUID, payload is encoded as JSON
+func New(eventType string, customerUUID string, payload interface{}) (Event, error) {
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return Event{}, err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, fmt.Errorf("events: %w", err)
+	}
+	return Event{
+		ID:           id.String(),
+		Type:         eventType,
+		CustomerUUID: customerUUID,
+		OccurredAt:   time.Now().UTC(),
+		Payload:      body,
+	}, nil
+}
+
+// Publisher hands events to other services, production deployments plug in
+// their broker, LogPublisher, FilePublisher and MemoryPublisher are for local runs.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// PublisherFunc adapts an ordinary function to the Publisher interface
+type PublisherFunc func(ctx context.Context, event Event) error
+
+// Publish calls f(ctx, event)
+func (f PublisherFunc) Publish(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
+
+// LogPublisher writes events to the logger instead of publishing them
+type LogPublisher struct{}
+
+// Publish logs event at info level
+func (LogPublisher) Publish(ctx context.Context, event Event) error {
+	logging.FromContext(ctx).
+		WithField("event_id", event.ID).
+		WithField("type", event.Type).
+		WithField("customer_uuid", event.CustomerUUID).
+		Info("events: event not published, logged only")
+	return nil
+}
+
+// FilePublisher appends events as JSON lines to Path
+type FilePublisher struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// Publish appends event to the file
+func (p *FilePublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	file, err := os.OpenFile(p.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("events: %w", err)
+	}
+	defer file.Close() //nolint
+
+	return json.NewEncoder(file).Encode(event)
+}
+
+// MemoryPublisher keeps events in memory, for tests and local runs
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+// Publish records event
+func (p *MemoryPublisher) Publish(ctx context.Context, event Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+	return nil
+}
+
+// Events published so far, oldest first
+func (p *MemoryPublisher) Events() []Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Event(nil), p.events...)
+}
+
+// FromEnv publisher selected by EVENT_PUBLISHER: "log" (default), "memory",
+// "file", which writes to EVENT_PUBLISHER_FILE (default events.jsonl), "kafka",
+// configured by KafkaConfigFromEnv, or "none", which returns nil so events
+// only reach webhooks and the event stream.
+func FromEnv() (Publisher, error) {
+	switch kind := os.Getenv("EVENT_PUBLISHER"); kind {
+	case "", "log":
+		return LogPublisher{}, nil
+	case "memory":
+		return &MemoryPublisher{}, nil
+	case "file":
+		path, ok := os.LookupEnv("EVENT_PUBLISHER_FILE")
+		if !ok {
+			path = "events.jsonl"
+		}
+		return &FilePublisher{Path: path}, nil
+	case "kafka":
+		config, err := KafkaConfigFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return NewKafkaPublisher(config)
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("events: unknown EVENT_PUBLISHER %q", kind)
+	}
+}
@@ -0,0 +1,299 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeOutbox in memory Outbox recording what the relay marked
+type fakeOutbox struct {
+	records   []Record
+	published []int64
+	failed    []int64
+	dead      []int64
+	owner     string
+}
+
+func (o *fakeOutbox) Claim(ctx context.Context, owner string, lease time.Duration) (bool, error) {
+	if o.owner != "" && o.owner != owner {
+		return false, nil
+	}
+	o.owner = owner
+	return true, nil
+}
+
+func (o *fakeOutbox) Pending(ctx context.Context, limit int) ([]Record, error) {
+	var pending []Record
+	for _, record := range o.records {
+		if !o.isPublished(record.ID) && !o.isDead(record.ID) && len(pending) < limit {
+			pending = append(pending, record)
+		}
+	}
+	return pending, nil
+}
+
+func (o *fakeOutbox) MarkPublished(ctx context.Context, id int64) error {
+	o.published = append(o.published, id)
+	return nil
+}
+
+func (o *fakeOutbox) MarkFailed(ctx context.Context, id int64, reason string, retryIn time.Duration) error {
+	o.failed = append(o.failed, id)
+	for i := range o.records {
+		if o.records[i].ID == id {
+			o.records[i].Attempts++
+		}
+	}
+	return nil
+}
+
+func (o *fakeOutbox) MarkDead(ctx context.Context, id int64, reason string) error {
+	o.dead = append(o.dead, id)
+	return nil
+}
+
+func (o *fakeOutbox) isDead(id int64) bool {
+	for _, dead := range o.dead {
+		if dead == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *fakeOutbox) isPublished(id int64) bool {
+	for _, published := range o.published {
+		if published == id {
+			return true
+		}
+	}
+	return false
+}
+
+func record(t *testing.T, id int64, eventType string, customerUUID string) Record {
+	event, err := New(eventType, customerUUID, map[string]string{"id": customerUUID})
+	assert.NoError(t, err)
+	return Record{ID: id, Event: event}
+}
+
+func TestNewEncodesPayload(t *testing.T) {
+
+	event, err := New(CustomerCreated, "c-1", map[string]string{"name": "Jhon"})
+	assert.NoError(t, err)
+	assert.Len(t, event.ID, 36)
+	assert.Equal(t, CustomerCreated, event.Type)
+	assert.JSONEq(t, `{"name":"Jhon"}`, string(event.Payload))
+}
+
+func TestRelayPublishesInOrder(t *testing.T) {
+
+	outbox := &fakeOutbox{records: []Record{
+		record(t, 1, CustomerCreated, "c-1"),
+		record(t, 2, CustomerUpdated, "c-1"),
+		record(t, 3, CustomerDeleted, "c-2"),
+	}}
+	publisher := &MemoryPublisher{}
+	relay := &Relay{Outbox: outbox, Publisher: publisher}
+
+	published, err := relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, published)
+	assert.Equal(t, []int64{1, 2, 3}, outbox.published)
+
+	sent := publisher.Events()
+	assert.Equal(t, CustomerCreated, sent[0].Type)
+	assert.Equal(t, CustomerUpdated, sent[1].Type)
+
+	published, err = relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+}
+
+func TestRelayHoldsBackCustomerAfterFailure(t *testing.T) {
+
+	outbox := &fakeOutbox{records: []Record{
+		record(t, 1, CustomerCreated, "c-1"),
+		record(t, 2, CustomerCreated, "c-2"),
+		record(t, 3, CustomerUpdated, "c-1"),
+	}}
+	down := true
+	publisher := PublisherFunc(func(ctx context.Context, event Event) error {
+		if down && event.CustomerUUID == "c-1" {
+			return errors.New("broker unavailable")
+		}
+		return nil
+	})
+	relay := &Relay{Outbox: outbox, Publisher: publisher}
+
+	published, err := relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []int64{2}, outbox.published)
+	assert.Equal(t, []int64{1}, outbox.failed)
+
+	down = false
+	published, err = relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, published)
+	assert.Equal(t, []int64{2, 1, 3}, outbox.published)
+}
+
+func TestRelayDeadLettersAfterMaxAttempts(t *testing.T) {
+
+	outbox := &fakeOutbox{records: []Record{
+		record(t, 1, CustomerCreated, "c-1"),
+		record(t, 2, CustomerUpdated, "c-1"),
+	}}
+	publisher := PublisherFunc(func(ctx context.Context, event Event) error {
+		if event.Type == CustomerCreated {
+			return errors.New("message too large")
+		}
+		return nil
+	})
+	relay := &Relay{Outbox: outbox, Publisher: publisher, MaxAttempts: 3}
+
+	for i := 0; i < 2; i++ {
+		published, err := relay.Flush(context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, 0, published)
+	}
+	assert.Equal(t, []int64{1, 1}, outbox.failed)
+
+	// The last attempt dead-letters the record and frees the customer's later ones
+	published, err := relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Equal(t, []int64{1}, outbox.dead)
+
+	published, err = relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []int64{2}, outbox.published)
+}
+
+func TestRelayOnlyPublishesWithTheLease(t *testing.T) {
+
+	outbox := &fakeOutbox{records: []Record{record(t, 1, CustomerCreated, "c-1")}}
+	first := &MemoryPublisher{}
+	second := &MemoryPublisher{}
+
+	published, err := (&Relay{Outbox: outbox, Publisher: first, Owner: "replica-1"}).Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, published)
+
+	outbox.records = append(outbox.records, record(t, 2, CustomerUpdated, "c-1"))
+	published, err = (&Relay{Outbox: outbox, Publisher: second, Owner: "replica-2"}).Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Empty(t, second.Events())
+	assert.Equal(t, []int64{1}, outbox.published)
+}
+
+// fakeFeed in memory Feed over records
+type fakeFeed struct {
+	records []Record
+}
+
+func (f *fakeFeed) LastID(ctx context.Context) (int64, error) {
+	var last int64
+	for _, record := range f.records {
+		if record.ID > last {
+			last = record.ID
+		}
+	}
+	return last, nil
+}
+
+func (f *fakeFeed) After(ctx context.Context, afterID int64, limit int) ([]Record, error) {
+	var after []Record
+	for _, record := range f.records {
+		if record.ID > afterID && len(after) < limit {
+			after = append(after, record)
+		}
+	}
+	sort.Slice(after, func(i, j int) bool { return after[i].ID < after[j].ID })
+	return after, nil
+}
+
+func TestTailStartsAtTheEndAndWaitsForGaps(t *testing.T) {
+
+	feed := &fakeFeed{records: []Record{record(t, 1, CustomerCreated, "c-1")}}
+	broker := &Broker{}
+	subscription := broker.Subscribe("", nil)
+	defer subscription.Close()
+	tail := &Tail{Feed: feed, Publisher: broker, GapWait: time.Hour}
+
+	handed, err := tail.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, handed, "records written before the tail started are not replayed")
+
+	// 2 is still uncommitted when 3 shows up
+	feed.records = append(feed.records, record(t, 3, CustomerCreated, "c-3"))
+	handed, err = tail.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, handed)
+
+	feed.records = append(feed.records, record(t, 2, CustomerCreated, "c-2"))
+	handed, err = tail.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, handed, "3 is read again after the gap")
+
+	handed, err = tail.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, handed)
+
+	received := []string{(<-subscription.Events()).CustomerUUID, (<-subscription.Events()).CustomerUUID}
+	assert.Equal(t, []string{"c-3", "c-2"}, received, "the broker drops the repeated event")
+	assert.Empty(t, subscription.Events())
+}
+
+func TestTailSkipsGapsAfterWaiting(t *testing.T) {
+
+	feed := &fakeFeed{}
+	tail := &Tail{Feed: feed, Publisher: &MemoryPublisher{}, GapWait: 50 * time.Millisecond}
+
+	_, err := tail.Flush(context.Background())
+	assert.NoError(t, err)
+
+	// 1 was rolled back and never appears
+	feed.records = append(feed.records, record(t, 2, CustomerCreated, "c-2"))
+	handed, err := tail.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, handed)
+
+	time.Sleep(100 * time.Millisecond)
+	handed, err = tail.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, handed)
+
+	handed, err = tail.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, handed)
+}
+
+func TestFilePublisherAppendsLines(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	publisher := &FilePublisher{Path: path}
+
+	assert.NoError(t, publisher.Publish(context.Background(), record(t, 1, CustomerCreated, "c-1").Event))
+	assert.NoError(t, publisher.Publish(context.Background(), record(t, 2, CustomerDeleted, "c-1").Event))
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+
+	var event Event
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, CustomerDeleted, event.Type)
+}
@@ -0,0 +1,257 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"time"
+
+	"github.com/Shopify/sarama"
+)
+
+// SchemaVersion version of Envelope, bumped on incompatible changes
+const SchemaVersion = 1
+
+// DefaultKafkaTopic topic of every event type without an override
+const DefaultKafkaTopic = "customer-events"
+
+// DefaultKafkaRetries producer retries before Publish fails, the relay retries again later
+const DefaultKafkaRetries = 5
+
+// DefaultKafkaRetryBackoff first retry pause, doubled per retry up to maxKafkaRetryBackoff
+const DefaultKafkaRetryBackoff = 100 * time.Millisecond
+
+// maxKafkaRetryBackoff longest pause between producer retries
+const maxKafkaRetryBackoff = 5 * time.Second
+
+// Envelope versioned JSON value of the Kafka messages
+type Envelope struct {
+	EventID       string          `json:"event_id"`
+	Type          string          `json:"type"`
+	SchemaVersion int             `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	CustomerUUID  string          `json:"customer_uuid"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// NewEnvelope wraps event, a password in the payload is dropped
+func NewEnvelope(event Event) (Envelope, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return Envelope{}, fmt.Errorf("events: %w", err)
+	}
+	delete(payload, "password")
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("events: %w", err)
+	}
+	return Envelope{
+		EventID:       event.ID,
+		Type:          event.Type,
+		SchemaVersion: SchemaVersion,
+		OccurredAt:    event.OccurredAt,
+		CustomerUUID:  event.CustomerUUID,
+		Payload:       body,
+	}, nil
+}
+
+// KafkaConfig KafkaPublisher settings
+type KafkaConfig struct {
+	Brokers  []string
+	ClientID string
+	// Topic of event types missing from Topics, DefaultKafkaTopic when empty
+	Topic  string
+	Topics map[string]string
+	// MaxRetries producer retries, DefaultKafkaRetries when zero
+	MaxRetries int
+	// RetryBackoff first retry pause, DefaultKafkaRetryBackoff when zero
+	RetryBackoff time.Duration
+}
+
+// KafkaConfigFromEnv reads KAFKA_BROKERS (comma separated, required),
+// KAFKA_CLIENT_ID, KAFKA_TOPIC, KAFKA_TOPICS ("CustomerDeleted=topic,..."),
+// KAFKA_MAX_RETRIES and KAFKA_RETRY_BACKOFF.
+func KafkaConfigFromEnv() (KafkaConfig, error) {
+	config := KafkaConfig{
+		ClientID: os.Getenv("KAFKA_CLIENT_ID"),
+		Topic:    os.Getenv("KAFKA_TOPIC"),
+		Topics:   map[string]string{},
+	}
+	for _, broker := range strings.Split(os.Getenv("KAFKA_BROKERS"), ",") {
+		if broker = strings.TrimSpace(broker); broker != "" {
+			config.Brokers = append(config.Brokers, broker)
+		}
+	}
+	if len(config.Brokers) == 0 {
+		return config, fmt.Errorf("events: KAFKA_BROKERS is required")
+	}
+	if value := os.Getenv("KAFKA_TOPICS"); value != "" {
+		for _, pair := range strings.Split(value, ",") {
+			parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return config, fmt.Errorf("events: invalid KAFKA_TOPICS entry %q", pair)
+			}
+			config.Topics[parts[0]] = parts[1]
+		}
+	}
+	if value, ok := os.LookupEnv("KAFKA_MAX_RETRIES"); ok {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return config, fmt.Errorf("events: invalid KAFKA_MAX_RETRIES %q", value)
+		}
+		config.MaxRetries = retries
+	}
+	if value, ok := os.LookupEnv("KAFKA_RETRY_BACKOFF"); ok {
+		backoff, err := time.ParseDuration(value)
+		if err != nil {
+			return config, fmt.Errorf("events: KAFKA_RETRY_BACKOFF: %w", err)
+		}
+		config.RetryBackoff = backoff
+	}
+	return config, nil
+}
+
+// KafkaPublisher produces events keyed by customer UUID, the hash partitioner
+// keeps every event of a customer on one partition and therefore in order.
+type KafkaPublisher struct {
+	Producer sarama.SyncProducer
+	// Client of Producer, Ping refreshes its metadata, skipped when nil
+	Client sarama.Client
+	Topic  string
+	Topics map[string]string
+}
+
+// NewKafkaPublisher connects a synchronous producer to config.Brokers
+func NewKafkaPublisher(config KafkaConfig) (*KafkaPublisher, error) {
+	client, err := sarama.NewClient(config.Brokers, SaramaConfig(config))
+	if err != nil {
+		return nil, fmt.Errorf("events: %w", err)
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("events: %w", err)
+	}
+	topic := config.Topic
+	if topic == "" {
+		topic = DefaultKafkaTopic
+	}
+	return &KafkaPublisher{Producer: producer, Client: client, Topic: topic, Topics: config.Topics}, nil
+}
+
+// SaramaConfig producer settings: acknowledged by every in-sync replica,
+// exponential retry backoff and one request in flight so retries cannot reorder.
+func SaramaConfig(config KafkaConfig) *sarama.Config {
+	retries := config.MaxRetries
+	if retries == 0 {
+		retries = DefaultKafkaRetries
+	}
+	backoff := config.RetryBackoff
+	if backoff == 0 {
+		backoff = DefaultKafkaRetryBackoff
+	}
+
+	saramaConfig := sarama.NewConfig()
+	if config.ClientID != "" {
+		saramaConfig.ClientID = config.ClientID
+	}
+	saramaConfig.Producer.RequiredAcks = sarama.WaitForAll
+	saramaConfig.Producer.Partitioner = sarama.NewHashPartitioner
+	saramaConfig.Producer.Return.Successes = true
+	saramaConfig.Producer.Retry.Max = retries
+	saramaConfig.Producer.Retry.BackoffFunc = func(attempt int, maxRetries int) time.Duration {
+		pause := backoff << uint(attempt)
+		if pause <= 0 || pause > maxKafkaRetryBackoff {
+			return maxKafkaRetryBackoff
+		}
+		return pause
+	}
+	saramaConfig.Net.MaxOpenRequests = 1
+	return saramaConfig
+}
+
+// Publish sends event and waits until the brokers acknowledged it
+func (p *KafkaPublisher) Publish(ctx context.Context, event Event) error {
+	envelope, err := NewEnvelope(event)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("events: %w", err)
+	}
+
+	topic := p.topic(event.Type)
+	start := time.Now()
+	partition, offset, err := p.Producer.SendMessage(&sarama.ProducerMessage{
+		Topic: topic,
+		Key:   sarama.StringEncoder(event.CustomerUUID),
+		Value: sarama.ByteEncoder(value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte("event_type"), Value: []byte(event.Type)},
+			{Key: []byte("schema_version"), Value: []byte(strconv.Itoa(SchemaVersion))},
+		},
+	})
+	metrics.KafkaPublishDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.KafkaMessages.WithLabelValues(topic, "failed").Inc()
+		return fmt.Errorf("events: %w", err)
+	}
+
+	metrics.KafkaMessages.WithLabelValues(topic, "delivered").Inc()
+	logging.FromContext(ctx).
+		WithField("event_id", event.ID).
+		WithField("topic", topic).
+		WithField("partition", partition).
+		WithField("offset", offset).
+		Debug("events: event delivered")
+	return nil
+}
+
+// Ping refreshes the metadata of the configured topics, registered as a
+// readiness check. Sarama does not take a context, ctx only bounds the wait.
+func (p *KafkaPublisher) Ping(ctx context.Context) error {
+	if p.Client == nil {
+		return nil
+	}
+	topics := []string{p.Topic}
+	for _, topic := range p.Topics {
+		topics = append(topics, topic)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- p.Client.RefreshMetadata(topics...) }()
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("events: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close flushes and closes the producer and its client
+func (p *KafkaPublisher) Close() error {
+	err := p.Producer.Close()
+	if p.Client != nil && !p.Client.Closed() {
+		if closeErr := p.Client.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// topic configured for eventType
+func (p *KafkaPublisher) topic(eventType string) string {
+	if topic, ok := p.Topics[eventType]; ok {
+		return topic
+	}
+	return p.Topic
+}
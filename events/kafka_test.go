@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/Shopify/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeProducer sarama.SyncProducer keeping the messages sent to it
+type fakeProducer struct {
+	sent []*sarama.ProducerMessage
+}
+
+func (p *fakeProducer) SendMessage(message *sarama.ProducerMessage) (int32, int64, error) {
+	p.sent = append(p.sent, message)
+	return 0, int64(len(p.sent)), nil
+}
+
+func (p *fakeProducer) SendMessages(messages []*sarama.ProducerMessage) error {
+	p.sent = append(p.sent, messages...)
+	return nil
+}
+
+func (p *fakeProducer) Close() error {
+	return nil
+}
+
+func TestKafkaPublisherEnvelopeAndKey(t *testing.T) {
+
+	producer := &fakeProducer{}
+	publisher := &KafkaPublisher{
+		Producer: producer,
+		Topic:    DefaultKafkaTopic,
+		Topics:   map[string]string{CustomerDeleted: "customer-deletions"},
+	}
+
+	event, err := New(CustomerDeleted, "c-1", map[string]string{"id": "c-1", "password": "secret"})
+	assert.NoError(t, err)
+	assert.NoError(t, publisher.Publish(context.Background(), event))
+
+	if assert.Len(t, producer.sent, 1) {
+		message := producer.sent[0]
+		assert.Equal(t, "customer-deletions", message.Topic)
+
+		key, _ := message.Key.Encode()
+		assert.Equal(t, "c-1", string(key))
+
+		value, _ := message.Value.Encode()
+		var envelope Envelope
+		assert.NoError(t, json.Unmarshal(value, &envelope))
+		assert.Equal(t, CustomerDeleted, envelope.Type)
+		assert.Equal(t, SchemaVersion, envelope.SchemaVersion)
+		assert.JSONEq(t, `{"id":"c-1"}`, string(envelope.Payload))
+	}
+}
+
+func TestKafkaPublisherFailure(t *testing.T) {
+
+	producer := mocks.NewSyncProducer(t, nil)
+	producer.ExpectSendMessageAndFail(errors.New("leader not available"))
+	publisher := &KafkaPublisher{Producer: producer, Topic: DefaultKafkaTopic}
+
+	event, err := New(CustomerCreated, "c-1", map[string]string{"id": "c-1"})
+	assert.NoError(t, err)
+	assert.Error(t, publisher.Publish(context.Background(), event))
+	assert.NoError(t, producer.Close())
+}
+
+func TestKafkaPublisherAgainstMockBroker(t *testing.T) {
+
+	broker := sarama.NewMockBroker(t, 1)
+	defer broker.Close()
+	// Produce v3 is what the default Kafka 1.0 protocol version sends
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(DefaultKafkaTopic, 0, broker.BrokerID()),
+		"ProduceRequest": sarama.NewMockProduceResponse(t).SetVersion(3),
+	})
+
+	publisher, err := NewKafkaPublisher(KafkaConfig{Brokers: []string{broker.Addr()}})
+	assert.NoError(t, err)
+	defer publisher.Close() //nolint
+
+	event, err := New(CustomerCreated, "c-1", map[string]string{"id": "c-1"})
+	assert.NoError(t, err)
+	assert.NoError(t, publisher.Publish(context.Background(), event))
+}
+
+func TestKafkaPublisherPing(t *testing.T) {
+
+	broker := sarama.NewMockBroker(t, 1)
+	broker.SetHandlerByMap(map[string]sarama.MockResponse{
+		"MetadataRequest": sarama.NewMockMetadataResponse(t).
+			SetBroker(broker.Addr(), broker.BrokerID()).
+			SetLeader(DefaultKafkaTopic, 0, broker.BrokerID()),
+	})
+
+	config := KafkaConfig{Brokers: []string{broker.Addr()}, MaxRetries: 1, RetryBackoff: time.Millisecond}
+	publisher, err := NewKafkaPublisher(config)
+	assert.NoError(t, err)
+	defer publisher.Close() //nolint
+
+	assert.NoError(t, publisher.Ping(context.Background()))
+
+	// Without a reachable broker the check fails within the caller's deadline
+	broker.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	assert.Error(t, publisher.Ping(ctx))
+}
@@ -0,0 +1,177 @@
+package events
+
+import (
+	"context"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultRelayInterval pause between outbox polls
+const DefaultRelayInterval = time.Second
+
+// DefaultRelayBatchSize events read from the outbox per poll
+const DefaultRelayBatchSize = 100
+
+// DefaultRelayLease time a replica holds the outbox after its last poll,
+// another one takes over once it expires
+const DefaultRelayLease = 30 * time.Second
+
+// DefaultRelayAttempts publish attempts before a record is dead-lettered
+const DefaultRelayAttempts = 20
+
+// DefaultRelayBackoff pause after the first failed attempt, doubled on each
+// further one up to maxRelayBackoff
+const DefaultRelayBackoff = time.Second
+
+// maxRelayBackoff longest pause between two attempts of a record
+const maxRelayBackoff = 10 * time.Minute
+
+// Record event waiting in the outbox
+type Record struct {
+	ID       int64
+	Event    Event
+	Attempts int
+}
+
+// Outbox events written in the same transaction as the change they describe
+type Outbox interface {
+	// Claim takes or renews the relay lease for owner, false while another
+	// owner holds it. Only the holder reads and publishes records.
+	Claim(ctx context.Context, owner string, lease time.Duration) (bool, error)
+	// Pending unpublished records that are due, oldest first. Records of a
+	// customer whose older record waits for a retry are left out so they
+	// neither overtake it nor fill the batch.
+	Pending(ctx context.Context, limit int) ([]Record, error)
+	MarkPublished(ctx context.Context, id int64) error
+	// MarkFailed counts a failed attempt, the record is due again after retryIn
+	MarkFailed(ctx context.Context, id int64, reason string, retryIn time.Duration) error
+	// MarkDead gives up on the record, the customer's later records go ahead
+	MarkDead(ctx context.Context, id int64, reason string) error
+}
+
+// Relay publishes outbox records at-least-once: a record is only marked
+// published after Publisher accepted it, so a crash in between publishes it again.
+// With several replicas only the one holding the outbox lease publishes, which
+// keeps each customer's events in order.
+type Relay struct {
+	Outbox    Outbox
+	Publisher Publisher
+	// Sink names the Publisher in metrics and logs
+	Sink string
+	// Interval pause between polls, DefaultRelayInterval when zero
+	Interval time.Duration
+	// BatchSize records per poll, DefaultRelayBatchSize when zero
+	BatchSize int
+	// Owner names this replica in the lease, a random UUID when empty
+	Owner string
+	// Lease outbox lease renewed on every poll, DefaultRelayLease when zero.
+	// Keep it well above Interval plus the time a batch takes to publish.
+	Lease time.Duration
+	// MaxAttempts before dead-lettering, DefaultRelayAttempts when zero
+	MaxAttempts int
+	// Backoff pause after the first failure, DefaultRelayBackoff when zero
+	Backoff time.Duration
+}
+
+// Run polls the outbox until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	interval := r.Interval
+	if interval == 0 {
+		interval = DefaultRelayInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Flush(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("Relay: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush publishes one batch of pending records and returns how many were
+// published, none while another replica holds the outbox. After a failure the
+// later records of the same customer wait until it was published or
+// dead-lettered so per-customer order holds, other customers are not held up.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	batchSize := r.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultRelayBatchSize
+	}
+	lease := r.Lease
+	if lease == 0 {
+		lease = DefaultRelayLease
+	}
+	if r.Owner == "" {
+		r.Owner = uuid.New().String()
+	}
+	held, err := r.Outbox.Claim(ctx, r.Owner, lease)
+	if err != nil || !held {
+		return 0, err
+	}
+
+	records, err := r.Outbox.Pending(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	blocked := map[string]bool{}
+	for _, record := range records {
+		if blocked[record.Event.CustomerUUID] {
+			continue
+		}
+		if err := r.Publisher.Publish(ctx, record.Event); err != nil {
+			blocked[record.Event.CustomerUUID] = true
+			if err := r.fail(ctx, record, err); err != nil {
+				return published, err
+			}
+			continue
+		}
+		if err := r.Outbox.MarkPublished(ctx, record.ID); err != nil {
+			return published, err
+		}
+		metrics.OutboxEvents.WithLabelValues(r.Sink, "published").Inc()
+		published++
+	}
+	return published, nil
+}
+
+// fail schedules the retry of a record Publisher rejected, or dead-letters it
+// after its last attempt
+func (r *Relay) fail(ctx context.Context, record Record, publishErr error) error {
+	maxAttempts := r.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultRelayAttempts
+	}
+	logger := logging.FromContext(ctx).
+		WithField("sink", r.Sink).
+		WithField("event_id", record.Event.ID).
+		WithField("attempts", record.Attempts+1)
+
+	if record.Attempts+1 >= maxAttempts {
+		metrics.OutboxEvents.WithLabelValues(r.Sink, "dead").Inc()
+		logger.Errorf("Relay: publish failed, record dead-lettered: %s", publishErr.Error())
+		return r.Outbox.MarkDead(ctx, record.ID, publishErr.Error())
+	}
+
+	backoff := r.Backoff
+	if backoff == 0 {
+		backoff = DefaultRelayBackoff
+	}
+	retryIn := backoff << uint(record.Attempts)
+	if retryIn <= 0 || retryIn > maxRelayBackoff {
+		retryIn = maxRelayBackoff
+	}
+	metrics.OutboxEvents.WithLabelValues(r.Sink, "failed").Inc()
+	logger.Warnf("Relay: publish failed: %s", publishErr.Error())
+	return r.Outbox.MarkFailed(ctx, record.ID, publishErr.Error(), retryIn)
+}
@@ -0,0 +1,110 @@
+package events
+
+import (
+	"context"
+	"svc-customer/logging"
+	"time"
+)
+
+// DefaultTailGapWait time a missing record ID is waited for before the tail
+// moves past it
+const DefaultTailGapWait = 5 * time.Second
+
+// Feed outbox records by ID whether published or not, read by every replica
+type Feed interface {
+	// LastID highest record ID, zero when the outbox is empty
+	LastID(ctx context.Context) (int64, error)
+	// After records with an ID above afterID, oldest first
+	After(ctx context.Context, afterID int64, limit int) ([]Record, error)
+}
+
+// Tail hands every outbox record written after it started to Publisher. Unlike
+// Relay it runs in every replica and claims nothing, so an in-process
+// Publisher such as Broker sees all events whichever replica wrote them.
+//
+// IDs are allocated before the writing transaction commits, so a record can
+// appear after higher ones. The tail re-reads from the first missing ID until
+// it shows up or GapWait passes, Publisher must ignore repeated event IDs.
+type Tail struct {
+	Feed      Feed
+	Publisher Publisher
+	// Interval pause between polls, DefaultRelayInterval when zero
+	Interval time.Duration
+	// BatchSize records per poll, DefaultRelayBatchSize when zero
+	BatchSize int
+	// GapWait wait for a missing ID, DefaultTailGapWait when zero
+	GapWait time.Duration
+
+	started  bool
+	cursor   int64
+	gapSince time.Time
+}
+
+// Run polls the feed until ctx is cancelled
+func (t *Tail) Run(ctx context.Context) {
+	interval := t.Interval
+	if interval == 0 {
+		interval = DefaultRelayInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := t.Flush(ctx); err != nil {
+			logging.FromContext(ctx).Errorf("Tail: %s", err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush hands one batch of records to Publisher and returns how many were
+// handed, records after a pending gap are handed again on the next poll
+func (t *Tail) Flush(ctx context.Context) (int, error) {
+	if !t.started {
+		last, err := t.Feed.LastID(ctx)
+		if err != nil {
+			return 0, err
+		}
+		t.cursor, t.started = last, true
+	}
+	batchSize := t.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultRelayBatchSize
+	}
+	gapWait := t.GapWait
+	if gapWait == 0 {
+		gapWait = DefaultTailGapWait
+	}
+
+	records, err := t.Feed.After(ctx, t.cursor, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	handed := 0
+	blocked := false
+	for _, record := range records {
+		if err := t.Publisher.Publish(ctx, record.Event); err != nil {
+			return handed, err
+		}
+		handed++
+		if blocked {
+			continue
+		}
+		if record.ID != t.cursor+1 {
+			if t.gapSince.IsZero() {
+				t.gapSince = time.Now()
+			}
+			if time.Since(t.gapSince) < gapWait {
+				blocked = true
+				continue
+			}
+		}
+		t.cursor, t.gapSince = record.ID, time.Time{}
+	}
+	return handed, nil
+}
@@ -0,0 +1,41 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+/*Liveness swagger:route GET /livez Liveness
+  Process is alive, never touches any dependency
+
+responses:
+   200: healthReport
+*/
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	write(w, http.StatusOK, Report{Status: StatusUp, Checks: []Result{}})
+}
+
+/*Readiness swagger:route GET /readyz Readiness
+  Every registered dependency (database, cache, broker) answers with status and latency
+
+responses:
+   200: healthReport
+   503: healthReport
+*/
+func (reg *Registry) Readiness(w http.ResponseWriter, r *http.Request) {
+	report := reg.Run(r.Context())
+
+	httpStatus := http.StatusOK
+	if report.Status != StatusUp {
+		httpStatus = http.StatusServiceUnavailable
+	}
+	write(w, httpStatus, report)
+}
+
+// write encodes report as JSON and disables caching on intermediaries
+func write(w http.ResponseWriter, httpStatus int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(httpStatus)
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status of a single dependency or of the whole service
+type Status string
+
+const (
+	// StatusUp dependency answered within its timeout
+	StatusUp Status = "UP"
+
+	// StatusDown dependency failed or timed out
+	StatusDown Status = "DOWN"
+)
+
+const (
+	// DefaultCacheTTL how long a check result is reused before probing again
+	DefaultCacheTTL = 5 * time.Second
+
+	// DefaultTimeout maximum time a single check may take
+	DefaultTimeout = 2 * time.Second
+)
+
+// Checker reports the health of a single dependency (database, cache, broker...)
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts an ordinary function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Result outcome of a single dependency check
+type Result struct {
+	Name      string    `json:"name"`
+	Status    Status    `json:"status"`
+	LatencyMS float64   `json:"latency_ms"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+	Cached    bool      `json:"cached"`
+}
+
+// Report aggregated readiness of every registered dependency
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// entry registered checker with its last known result
+type entry struct {
+	name    string
+	checker Checker
+
+	// mu serialises probes so concurrent readiness calls never stampede the dependency
+	mu     sync.Mutex
+	result Result
+	valid  bool
+}
+
+// Registry keeps every dependency checker and caches their results
+type Registry struct {
+	mu      sync.RWMutex
+	entries map[string]*entry
+
+	ttl     time.Duration
+	timeout time.Duration
+	now     func() time.Time
+}
+
+// NewRegistry creates an empty registry, results are cached for ttl and each check is bounded by timeout
+func NewRegistry(ttl time.Duration, timeout time.Duration) *Registry {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Registry{
+		entries: map[string]*entry{},
+		ttl:     ttl,
+		timeout: timeout,
+		now:     time.Now,
+	}
+}
+
+// Register adds (or replaces) a dependency checker under name
+func (reg *Registry) Register(name string, checker Checker) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	reg.entries[name] = &entry{name: name, checker: checker}
+}
+
+// Run executes every registered check concurrently, reusing cached results that are still fresh
+func (reg *Registry) Run(ctx context.Context) Report {
+	reg.mu.RLock()
+	entries := make([]*entry, 0, len(reg.entries))
+	for _, e := range reg.entries {
+		entries = append(entries, e)
+	}
+	reg.mu.RUnlock()
+
+	results := make([]Result, len(entries))
+
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e *entry) {
+			defer wg.Done()
+			results[i] = reg.check(ctx, e)
+		}(i, e)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := Report{Status: StatusUp, Checks: results}
+	for _, result := range results {
+		if result.Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// check runs a single checker unless a fresh cached result exists
+func (reg *Registry) check(ctx context.Context, e *entry) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.valid && reg.now().Sub(e.result.CheckedAt) < reg.ttl {
+		cached := e.result
+		cached.Cached = true
+		return cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reg.timeout)
+	defer cancel()
+
+	start := reg.now()
+	err := e.checker.Check(ctx)
+
+	result := Result{
+		Name:      e.name,
+		Status:    StatusUp,
+		LatencyMS: float64(reg.now().Sub(start).Microseconds()) / 1000,
+		CheckedAt: start,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+
+	e.result = result
+	e.valid = true
+	return result
+}
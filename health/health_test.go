@@ -0,0 +1,100 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"svc-customer/health"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryRunAllUp(t *testing.T) {
+
+	registry := health.NewRegistry(0, time.Second)
+	registry.Register("database", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+	registry.Register("cache", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	report := registry.Run(context.Background())
+
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Len(t, report.Checks, 2)
+	// Sorted by name
+	assert.Equal(t, "cache", report.Checks[0].Name)
+	assert.Equal(t, "database", report.Checks[1].Name)
+}
+
+func TestRegistryRunOneDown(t *testing.T) {
+
+	registry := health.NewRegistry(0, time.Second)
+	registry.Register("database", health.CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") }))
+	registry.Register("cache", health.CheckerFunc(func(ctx context.Context) error { return nil }))
+
+	report := registry.Run(context.Background())
+
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, health.StatusDown, report.Checks[1].Status)
+	assert.Equal(t, "connection refused", report.Checks[1].Error)
+}
+
+func TestRegistryRunCachesResults(t *testing.T) {
+
+	var calls int32
+
+	registry := health.NewRegistry(time.Minute, time.Second)
+	registry.Register("database", health.CheckerFunc(func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}))
+
+	first := registry.Run(context.Background())
+	second := registry.Run(context.Background())
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.False(t, first.Checks[0].Cached)
+	assert.True(t, second.Checks[0].Cached)
+}
+
+func TestRegistryRunTimeout(t *testing.T) {
+
+	registry := health.NewRegistry(0, 10*time.Millisecond)
+	registry.Register("broker", health.CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	report := registry.Run(context.Background())
+
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+}
+
+func TestLiveness(t *testing.T) {
+
+	rr := httptest.NewRecorder()
+	health.Liveness(rr, httptest.NewRequest("GET", "/livez", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "{\"status\":\"UP\",\"checks\":[]}\n", rr.Body.String())
+}
+
+func TestReadinessUnavailable(t *testing.T) {
+
+	registry := health.NewRegistry(0, time.Second)
+	registry.Register("database", health.CheckerFunc(func(ctx context.Context) error { return errors.New("down") }))
+
+	rr := httptest.NewRecorder()
+	registry.Readiness(rr, httptest.NewRequest("GET", "/readyz", nil))
+
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+	var report health.Report
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&report))
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, "database", report.Checks[0].Name)
+}
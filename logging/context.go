@@ -0,0 +1,40 @@
+package logging
+
+import (
+	"context"
+
+	mylog "github.com/sirupsen/logrus"
+)
+
+// contextKey private type so no other package can collide with our keys
+type contextKey int
+
+const (
+	loggerKey contextKey = iota
+	requestIDKey
+)
+
+// NewContext returns a copy of ctx carrying logger
+func NewContext(ctx context.Context, logger *mylog.Entry) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// FromContext request scoped logger, falls back to the standard logger.
+// The returned entry is bound to ctx so hooks (trace IDs) can read it.
+func FromContext(ctx context.Context) *mylog.Entry {
+	if logger, ok := ctx.Value(loggerKey).(*mylog.Entry); ok {
+		return logger.WithContext(ctx)
+	}
+	return mylog.WithContext(ctx)
+}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestID request ID stored in ctx, empty when there is none
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
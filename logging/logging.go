@@ -4,26 +4,64 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 
 	mylog "github.com/sirupsen/logrus"
 )
 
-// InitializeLogging asdas
-func InitializeLogging(logFileName string) {
+// Config logging output settings
+type Config struct {
+	// Format json (default) or text
+	Format string
+	// Level any logrus level name, info by default
+	Level string
+	// File optional file receiving a copy of every entry
+	File string
+}
 
-	var file, err = os.OpenFile(logFileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-	if err != nil {
-		fmt.Println("Could Not Open Log File : " + err.Error())
+// ConfigFromEnv reads LOG_FORMAT, LOG_LEVEL and LOG_FILE
+func ConfigFromEnv() Config {
+	return Config{
+		Format: os.Getenv("LOG_FORMAT"),
+		Level:  os.Getenv("LOG_LEVEL"),
+		File:   os.Getenv("LOG_FILE"),
 	}
+}
 
-	mulWrt := io.MultiWriter(os.Stdout, file)
-	mylog.SetOutput(mulWrt)
+// InitializeLogging configures the standard logger format, level and outputs,
+// PII fields are always masked whatever the format.
+func InitializeLogging(cfg Config) error {
+
+	level := mylog.InfoLevel
+	if cfg.Level != "" {
+		parsed, err := mylog.ParseLevel(cfg.Level)
+		if err != nil {
+			return err
+		}
+		level = parsed
+	}
+	mylog.SetLevel(level)
 
-	mylog.SetFormatter(&mylog.JSONFormatter{})
-	// // mylog.SetFormatter(&log.TextFormatter{})
-	mylog.SetFormatter(&mylog.TextFormatter{
-		ForceColors:   true, // Seems like automatic color detection doesn't work on windows terminals
-		FullTimestamp: true,
-	})
+	var formatter mylog.Formatter
+	switch strings.ToLower(cfg.Format) {
+	case "", "json":
+		formatter = &mylog.JSONFormatter{}
+	case "text":
+		formatter = &mylog.TextFormatter{FullTimestamp: true}
+	default:
+		return fmt.Errorf("unknown log format %q", cfg.Format)
+	}
+	mylog.SetFormatter(&MaskingFormatter{Formatter: formatter})
+
+	var output io.Writer = os.Stdout
+	if cfg.File != "" {
+		file, err := os.OpenFile(cfg.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			return fmt.Errorf("could not open log file: %w", err)
+		}
+		output = io.MultiWriter(os.Stdout, file)
+	}
+	mylog.SetOutput(output)
 
+	return nil
 }
@@ -0,0 +1,82 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"svc-customer/logging"
+	"testing"
+
+	mylog "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskEmail(t *testing.T) {
+	assert.Equal(t, "b***@gmail.com", logging.MaskEmail("baby@gmail.com"))
+	assert.Equal(t, "***", logging.MaskEmail("@cd"))
+}
+
+func TestMaskTail(t *testing.T) {
+	assert.Equal(t, "******076", logging.MaskTail("264573076"))
+	assert.Equal(t, "***", logging.MaskTail("123"))
+}
+
+func TestMaskingFormatterMasksPIIFields(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := mylog.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logging.MaskingFormatter{Formatter: &mylog.JSONFormatter{}})
+
+	fields := mylog.Fields{"email": "baby@gmail.com", "phone": "+130698569", "dni": "264573076", "name": "Bad"}
+	logger.WithFields(fields).Info("customer")
+
+	out := buf.String()
+	assert.Contains(t, out, `"email":"b***@gmail.com"`)
+	assert.Contains(t, out, `"phone":"*******569"`)
+	assert.Contains(t, out, `"dni":"******076"`)
+	assert.Contains(t, out, `"name":"Bad"`)
+
+	// Caller's fields are left untouched
+	assert.Equal(t, "baby@gmail.com", fields["email"])
+}
+
+func TestMaskingFormatterMasksPIIValues(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := mylog.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&logging.MaskingFormatter{Formatter: &mylog.JSONFormatter{}})
+
+	fields := mylog.Fields{"recipient": "+56933375029", "to": "baby@gmail.com", "body": "code sent to baby@gmail.com", "rows": "1234"}
+	logger.WithFields(fields).Info("SendPhoneCode: code sent to +56933375029")
+
+	out := buf.String()
+	assert.Contains(t, out, `"recipient":"*********029"`)
+	assert.Contains(t, out, `"to":"b***@gmail.com"`)
+	assert.Contains(t, out, `"body":"code sent to b***@gmail.com"`)
+	assert.Contains(t, out, `"rows":"1234"`)
+	assert.Contains(t, out, `"msg":"SendPhoneCode: code sent to *********029"`)
+	assert.NotContains(t, out, "56933375029")
+}
+
+func TestMaskText(t *testing.T) {
+	assert.Equal(t, "mail j***@gmail.com, dni ******076, row 42", logging.MaskText("mail jdoe@gmail.com, dni 264573076, row 42"))
+}
+
+func TestFromContextCarriesRequestLogger(t *testing.T) {
+
+	var buf bytes.Buffer
+	logger := mylog.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&mylog.JSONFormatter{})
+
+	ctx := logging.NewContext(context.Background(), logger.WithField("request_id", "abc-123"))
+	logging.FromContext(ctx).Info("hello")
+
+	assert.Contains(t, buf.String(), `"request_id":"abc-123"`)
+}
+
+func TestInitializeLoggingRejectsUnknownFormat(t *testing.T) {
+	err := logging.InitializeLogging(logging.Config{Format: "xml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"regexp"
+	"strings"
+
+	mylog "github.com/sirupsen/logrus"
+)
+
+// maskers PII field names and how their values are hidden, notification and
+// verification recipients may be either an email or a phone number
+var maskers = map[string]func(string) string{
+	"email":     MaskEmail,
+	"new_email": MaskEmail,
+	"phone":     MaskTail,
+	"new_phone": MaskTail,
+	"dni":       MaskTail,
+	"to":        MaskContact,
+	"recipient": MaskContact,
+	"password":  func(string) string { return "[REDACTED]" },
+}
+
+var (
+	// emailPattern email addresses embedded in free text
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// digitsPattern phone numbers and DNIs embedded in free text, shorter
+	// numbers such as counts and row IDs are kept
+	digitsPattern = regexp.MustCompile(`\+?\b\d{8,15}\b`)
+)
+
+// MaskingFormatter hides PII fields before delegating to the wrapped formatter
+type MaskingFormatter struct {
+	Formatter mylog.Formatter
+}
+
+// Format masks a copy of entry data and message so callers keep their
+// original fields. Known PII fields are masked by name, any other string
+// value and the message by the emails and phone numbers they contain.
+func (f *MaskingFormatter) Format(entry *mylog.Entry) ([]byte, error) {
+	masked := false
+	data := make(mylog.Fields, len(entry.Data))
+	for key, value := range entry.Data {
+		if str, isString := value.(string); isString {
+			mask, ok := maskers[strings.ToLower(key)]
+			if !ok {
+				mask = MaskText
+			}
+			if hidden := mask(str); hidden != str {
+				value = hidden
+				masked = true
+			}
+		}
+		data[key] = value
+	}
+	message := MaskText(entry.Message)
+
+	if !masked && message == entry.Message {
+		return f.Formatter.Format(entry)
+	}
+
+	clone := *entry
+	clone.Data = data
+	clone.Message = message
+	return f.Formatter.Format(&clone)
+}
+
+// MaskText masks every email and phone number found in text
+func MaskText(text string) string {
+	text = emailPattern.ReplaceAllStringFunc(text, MaskEmail)
+	return digitsPattern.ReplaceAllStringFunc(text, MaskTail)
+}
+
+// MaskContact masks value as an email when it has an @, as a phone otherwise
+func MaskContact(value string) string {
+	if strings.Contains(value, "@") {
+		return MaskEmail(value)
+	}
+	return MaskTail(value)
+}
+
+// MaskEmail keeps the first character and the domain: j***@gmail.com
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 1 {
+		return MaskTail(email)
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+// MaskTail keeps only the last 3 characters: ******076
+func MaskTail(value string) string {
+	const visible = 3
+	if len(value) <= visible {
+		return strings.Repeat("*", len(value))
+	}
+	return strings.Repeat("*", len(value)-visible) + value[len(value)-visible:]
+}
@@ -1,17 +1,31 @@
 package main
 
 import (
+	"context"
 	"customer/logging"
 	"net"
 	"net/http"
 	"os"
+	"svc-customer/auth"
+	"svc-customer/authz"
+	"svc-customer/customer/delivery/cli"
+	"svc-customer/customer/entity"
 	"svc-customer/customer/repository"
 	"svc-customer/customer/usecase"
 	"svc-customer/customerdelivery/web"
+	"svc-customer/encrypt"
+	"svc-customer/events"
+	"svc-customer/health"
+	"svc-customer/metrics"
+	"svc-customer/middleware"
+	"svc-customer/notify"
+	"svc-customer/ratelimit"
+	"svc-customer/tracing"
+	"time"
 
 	// _ "net/http/pprof"
 
-	"github.com/go-openapi/runtime/middleware"
+	openapi "github.com/go-openapi/runtime/middleware"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
@@ -19,7 +33,58 @@ import (
 
 func main() {
 
-	logging.InitializeLogging("vendor.log")
+	if err := logging.InitializeLogging(logging.ConfigFromEnv()); err != nil {
+		log.Errorf("Error configuring logging: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Distributed tracing, exporter configured through OTEL_* env variables
+	shutdownTracing, err := tracing.Init(context.Background())
+	if err != nil {
+		log.Errorf("Error starting tracing: %s\n", err)
+		os.Exit(1)
+	}
+	defer shutdownTracing(context.Background()) //nolint
+	log.AddHook(tracing.LogHook{})
+
+	// "apikey" subcommand manages service API keys and exits
+	if len(os.Args) > 1 && os.Args[1] == "apikey" {
+		db := repository.OpenConnection()
+		cmd := &cli.APIKeyCommand{
+			APIKeyUsecase: &usecase.APIKeyImpl{Repo: repository.NewMySQLAPIKeyRepository(db)},
+			Out:           os.Stdout,
+		}
+		err := cmd.Run(context.Background(), os.Args[2:])
+		_ = db.Close()
+		if err != nil {
+			log.Errorf("apikey: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// "rotate-keys" subcommand re-encrypts customer PII under the active master key and exits
+	if len(os.Args) > 1 && os.Args[1] == "rotate-keys" {
+		pii, err := repository.PIIFromEnv()
+		if err != nil {
+			log.Errorf("rotate-keys: %s\n", err)
+			os.Exit(1)
+		}
+		db := repository.OpenConnection()
+		customers := repository.NewMySQLCustomersRepository(db)
+		customers.PII = pii
+		cmd := &cli.RotateKeysCommand{
+			KeyRotationUsecase: &usecase.KeyRotationImpl{Repo: customers},
+			Out:                os.Stdout,
+		}
+		err = cmd.Run(context.Background(), os.Args[2:])
+		_ = db.Close()
+		if err != nil {
+			log.Errorf("rotate-keys: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	port := ":9000"
 	server, err := net.Listen("tcp", port)
@@ -33,35 +98,406 @@ func main() {
 	// Open Database Connection
 	db := repository.OpenConnection()
 
+	// Field-level encryption of dni, email and phone, keys from PII_KEYFILE and PII_INDEX_KEY
+	pii, err := repository.PIIFromEnv()
+	switch {
+	case err == encrypt.ErrNoKey:
+		log.Warn("No PII_KEYFILE, dni, email and phone are stored in plain text")
+	case err != nil:
+		log.Errorf("Error configuring PII encryption: %s\n", err)
+		os.Exit(1)
+	}
+
 	// mysql repository init
 	repo := repository.NewMySQLCustomersRepository(db)
+	repo.PII = pii
+	passwords := repository.NewMySQLPasswordRepository(db)
+	passwords.PII = pii
 
 	// Migrate the schema
 	defer repo.Close()
 
+	// Connection pool gauges
+	if err := metrics.RegisterDB(db, "customers"); err != nil {
+		log.Warnf("metrics: %s", err.Error())
+	}
+
+	// Authorization policy, roles and scopes per action live in config
+	policyFile, ok := os.LookupEnv("AUTHZ_POLICY_FILE")
+	if !ok {
+		policyFile = "config/policies.json"
+	}
+	policy, err := authz.LoadPolicy(policyFile)
+	if err != nil {
+		log.Errorf("Error loading authorization policy: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Emails with reset and verification links are delivered by the NOTIFIER
+	notifier, err := notify.FromEnv()
+	if err != nil {
+		log.Errorf("Error configuring notifier: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Email verification, links are sent on sign up and on every email change
+	emailVerifications := repository.NewMySQLEmailVerificationRepository(db)
+	emailVerifications.PII = pii
+	emailVerification := &usecase.EmailVerificationImpl{
+		Repo:      emailVerifications,
+		Notifier:  notifier,
+		VerifyURL: os.Getenv("VERIFY_EMAIL_URL"),
+	}
+	emailVerificationHandler := &web.EmailVerificationHandler{EmailVerificationUsecase: emailVerification}
+
+	// Outbound webhooks, the relay queues events and the dispatcher delivers them
+	webhookRepo := repository.NewMySQLWebhookRepository(db)
+	webhookRepo.PII = pii
+	webhooks := &usecase.WebhookImpl{
+		Repo:   webhookRepo,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+	webhookInterval := 5 * time.Second
+	if value, ok := os.LookupEnv("WEBHOOK_DISPATCH_INTERVAL"); ok {
+		if webhookInterval, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing WEBHOOK_DISPATCH_INTERVAL: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	go webhooks.RunDispatcher(context.Background(), webhookInterval)
+	webhookHandler := &web.WebhookHandler{
+		WebhookUsecase: &usecase.AuthorizedWebhookUsecase{Next: webhooks, Policy: policy},
+	}
+
+	auditHandler := &web.AuditHandler{
+		AuditUsecase: &usecase.AuthorizedAuditUsecase{
+			Next:   &usecase.AuditImpl{Repo: repository.NewMySQLAuditRepository(db)},
+			Policy: policy,
+		},
+	}
+
+	// Right to erasure, the purge erases customers deleted longer than ERASURE_RETENTION
+	erasureRepo := repository.NewMySQLErasureRepository(db)
+	erasureRepo.PII = pii
+	erasure := &usecase.ErasureImpl{
+		Repo: erasureRepo,
+		Mode: os.Getenv("ERASURE_MODE"),
+	}
+	if erasure.Mode != "" && !entity.IsValidErasureMode(erasure.Mode) {
+		log.Errorf("Error parsing ERASURE_MODE: unknown mode %q\n", erasure.Mode)
+		os.Exit(1)
+	}
+	if value, ok := os.LookupEnv("ERASURE_RETENTION"); ok {
+		if erasure.Retention, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing ERASURE_RETENTION: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	purgeInterval := time.Hour
+	if value, ok := os.LookupEnv("ERASURE_PURGE_INTERVAL"); ok {
+		if purgeInterval, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing ERASURE_PURGE_INTERVAL: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	go erasure.RunPurge(context.Background(), purgeInterval)
+	erasureHandler := &web.ErasureHandler{
+		ErasureUsecase: &usecase.AuthorizedErasureUsecase{Next: erasure, Policy: policy},
+	}
+
+	// Data subject access exports, large ones are generated by a background worker
+	exportJobs := repository.NewMySQLExportRepository(db)
+	exportJobs.PII = pii
+	exports := &usecase.ExportImpl{
+		Customers: repo,
+		Sessions:  repository.NewMySQLSessionRepository(db),
+		Audit:     repository.NewMySQLAuditRepository(db),
+		Jobs:      exportJobs,
+	}
+	if value, ok := os.LookupEnv("EXPORT_TTL"); ok {
+		if exports.TTL, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing EXPORT_TTL: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	exportInterval := 5 * time.Second
+	if value, ok := os.LookupEnv("EXPORT_WORKER_INTERVAL"); ok {
+		if exportInterval, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing EXPORT_WORKER_INTERVAL: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	go exports.RunExports(context.Background(), exportInterval)
+	exportHandler := &web.ExportHandler{
+		ExportUsecase: &usecase.AuthorizedExportUsecase{Next: exports, Policy: policy},
+	}
+
+	// Live event stream, every replica tails the outbox into its own broker so
+	// SSE subscribers see all events whichever replica wrote them
+	outbox := repository.NewMySQLOutboxRepository(db)
+	outbox.PII = pii
+	broker := &events.Broker{}
+	tail := &events.Tail{Feed: outbox, Publisher: broker}
+	streamHandler := &web.EventStreamHandler{
+		StreamUsecase: &usecase.AuthorizedStreamUsecase{
+			Next:   &usecase.StreamImpl{Broker: broker, Policy: policy},
+			Policy: policy,
+		},
+	}
+
+	// Customer events, every sink has its own relay publishing what the
+	// repository queued in the outbox, so a failing sink does not hold up the
+	// others. Webhooks are relayed without an external publisher too.
+	publisher, err := events.FromEnv()
+	if err != nil {
+		log.Errorf("Error configuring event publisher: %s\n", err)
+		os.Exit(1)
+	}
+	relays := []*events.Relay{{Outbox: outbox.Sink("webhooks"), Publisher: webhooks, Sink: "webhooks"}}
+	if publisher != nil {
+		relays = append(relays, &events.Relay{Outbox: outbox.Sink("publisher"), Publisher: publisher, Sink: "publisher"})
+	}
+	relayInterval := events.DefaultRelayInterval
+	if value, ok := os.LookupEnv("EVENT_RELAY_INTERVAL"); ok {
+		if relayInterval, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing EVENT_RELAY_INTERVAL: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, relay := range relays {
+		outbox.Sinks = append(outbox.Sinks, relay.Sink)
+	}
+	for _, relay := range relays {
+		relay.Interval = relayInterval
+		go relay.Run(context.Background())
+	}
+	tail.Interval = relayInterval
+	go tail.Run(context.Background())
+
 	// delivery/web interface
 	handler := &web.Handler{
-		GetCustomerUsecase: &usecase.GetCustomerImpl{
-			Repo: repo,
+		GetCustomerUsecase: &usecase.AuthorizedUsecase{
+			Next: &usecase.GetCustomerImpl{
+				Repo:     repository.NewInstrumentedRepository(repo),
+				Verifier: emailVerification,
+			},
+			Policy: policy,
+		},
+	}
+
+	// Service API keys, verified against the api_keys table
+	apiKeyUsecase := &usecase.AuthorizedAPIKeyUsecase{
+		Next:   &usecase.APIKeyImpl{Repo: repository.NewMySQLAPIKeyRepository(db)},
+		Policy: policy,
+	}
+	apiKeyHandler := &web.APIKeyHandler{APIKeyUsecase: apiKeyUsecase}
+
+	// Password recovery and change, reset links are delivered by the NOTIFIER
+	passwordPolicy, err := entity.PasswordPolicyFromEnv()
+	if err != nil {
+		log.Errorf("Error configuring password policy: %s\n", err)
+		os.Exit(1)
+	}
+	resetTTL := usecase.DefaultResetTokenTTL
+	if value, ok := os.LookupEnv("PASSWORD_RESET_TTL"); ok {
+		if resetTTL, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing PASSWORD_RESET_TTL: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	// Sessions with rotating refresh tokens, logins need a signing key
+	sessionTTL := usecase.DefaultSessionTTL
+	if value, ok := os.LookupEnv("SESSION_TTL"); ok {
+		if sessionTTL, err = time.ParseDuration(value); err != nil {
+			log.Errorf("Error parsing SESSION_TTL: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	// Two-factor authentication, TOTP secrets are encrypted with MFA_ENCRYPTION_KEY
+	mfa := &usecase.MFAImpl{
+		Repo:      repository.NewMySQLMFARepository(db),
+		Customers: repo,
+		Issuer:    os.Getenv("MFA_ISSUER"),
+	}
+	mfaCipher, err := encrypt.FromEnv("MFA_ENCRYPTION_KEY")
+	switch {
+	case err == encrypt.ErrNoKey:
+		log.Warn("No MFA_ENCRYPTION_KEY, TOTP enrollment is disabled and enrolled customers cannot log in")
+	case err != nil:
+		log.Errorf("Error configuring MFA encryption: %s\n", err)
+		os.Exit(1)
+	default:
+		mfa.Cipher = mfaCipher
+	}
+	mfaHandler := &web.MFAHandler{
+		MFAUsecase: &usecase.AuthorizedMFAUsecase{Next: mfa, Policy: policy},
+	}
+
+	sessions := &usecase.SessionImpl{
+		Repo:       repository.NewMySQLSessionRepository(db),
+		Passwords:  passwords,
+		Hasher:     entity.SHA1Hasher{},
+		MFA:        mfa,
+		SessionTTL: sessionTTL,
+	}
+	tokenIssuer, err := auth.TokenIssuerFromEnv()
+	switch {
+	case err == auth.ErrNoSigningKey:
+		log.Warn("No signing key, POST /sessions and /sessions/refresh are disabled")
+	case err != nil:
+		log.Errorf("Error configuring token issuer: %s\n", err)
+		os.Exit(1)
+	default:
+		sessions.Tokens = tokenIssuer
+	}
+	sessionHandler := &web.SessionHandler{
+		SessionUsecase: &usecase.AuthorizedSessionUsecase{Next: sessions, Policy: policy},
+	}
+
+	passwordHandler := &web.PasswordHandler{
+		PasswordUsecase: &usecase.AuthorizedPasswordUsecase{
+			Next: &usecase.PasswordImpl{
+				Repo:     passwords,
+				Hasher:   entity.SHA1Hasher{},
+				Policy:   passwordPolicy,
+				Notifier: notifier,
+				ResetURL: os.Getenv("PASSWORD_RESET_URL"),
+				ResetTTL: resetTTL,
+				Sessions: sessions,
+			},
+			Policy: policy,
+		},
+	}
+
+	// Phone verification, codes are texted through the NOTIFIER sms channel
+	phoneVerifications := repository.NewMySQLPhoneVerificationRepository(db)
+	phoneVerifications.PII = pii
+	phoneVerificationHandler := &web.PhoneVerificationHandler{
+		PhoneVerificationUsecase: &usecase.AuthorizedPhoneVerificationUsecase{
+			Next: &usecase.PhoneVerificationImpl{
+				Repo: phoneVerifications,
+				SMS:  &notify.NotifierSMS{Notifier: notifier},
+			},
+			Policy: policy,
 		},
 	}
 
+	// Bearer JWT authentication, keys from AUTH_* env variables, with
+	// X-API-Key as the fallback for service-to-service callers
+	jwtAuthenticator, err := auth.JWTAuthenticatorFromEnv()
+	if err != nil {
+		log.Errorf("Error configuring authentication: %s\n", err)
+		os.Exit(1)
+	}
+	if sessions.Tokens != nil {
+		// Access tokens of this service end with their session
+		jwtAuthenticator.Sessions = sessions
+	}
+	authenticator := auth.Chain{jwtAuthenticator, &auth.APIKeyAuthenticator{Verifier: apiKeyUsecase}}
+
+	// Proxies allowed to set X-Forwarded-For, used for API key IP allowlists
+	trustedProxies, err := middleware.ParseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Errorf("Error configuring trusted proxies: %s\n", err)
+		os.Exit(1)
+	}
+
+	// Rate limits and lockout, shared through Redis when REDIS_URL is set
+	rateLimitFile, ok := os.LookupEnv("RATELIMIT_POLICY_FILE")
+	if !ok {
+		rateLimitFile = "config/ratelimits.json"
+	}
+	rateLimitPolicy, err := ratelimit.LoadPolicy(rateLimitFile)
+	if err != nil {
+		log.Errorf("Error loading rate limit policy: %s\n", err)
+		os.Exit(1)
+	}
+	rateLimitStore, err := ratelimit.StoreFromEnv()
+	if err != nil {
+		log.Errorf("Error configuring rate limit store: %s\n", err)
+		os.Exit(1)
+	}
+	limiter := &ratelimit.Limiter{Store: rateLimitStore, Policy: rateLimitPolicy}
+	lockout := &ratelimit.Lockout{Store: rateLimitStore, Config: rateLimitPolicy.Lockout}
+
+	// Health probes, every dependency registers its own checker
+	registry := health.NewRegistry(health.DefaultCacheTTL, health.DefaultTimeout)
+	registry.Register("database", health.CheckerFunc(repo.Ping))
+	if redisStore, ok := rateLimitStore.(*ratelimit.RedisStore); ok {
+		registry.Register("redis", health.CheckerFunc(redisStore.Ping))
+	}
+	if kafka, ok := publisher.(*events.KafkaPublisher); ok {
+		registry.Register("kafka", health.CheckerFunc(kafka.Ping))
+	}
+
 	r := mux.NewRouter()
+	r.Use(tracing.Middleware)
+	metrics.Instrument(r)
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	r.HandleFunc("/livez", health.Liveness).Methods("GET")
+	r.HandleFunc("/readyz", registry.Readiness).Methods("GET")
 	r.HandleFunc("/healthcheck", handler.HealthCheck).Methods("GET")
-	r.HandleFunc("/", handler.Store).Methods("POST")
-	r.HandleFunc("/", handler.Fetch).Methods("GET")
-	r.HandleFunc("/{uuid}", handler.GetByUUID).Methods("GET")
-	r.HandleFunc("/{uuid}", handler.UpdateByUUID).Methods("PUT")
-	r.HandleFunc("/{uuid}", handler.DeleteByUUID).Methods("DELETE")
 
 	// Swagger Documentation
 	// handler for documentation
-	opts := middleware.RedocOpts{SpecURL: "/swagger.yaml"}
-	sh := middleware.Redoc(opts, nil)
+	opts := openapi.RedocOpts{SpecURL: "/swagger.yaml"}
+	sh := openapi.Redoc(opts, nil)
 
 	r.Handle("/docs", sh)
 	r.Handle("/swagger.yaml", http.FileServer(http.Dir("./")))
 
+	// Public customer routes, rate limited per client IP
+	public := r.NewRoute().Subrouter()
+	public.Use(limiter.Middleware)
+	public.HandleFunc("/password/forgot", passwordHandler.ForgotPassword).Methods("POST")
+	public.HandleFunc("/password/reset", passwordHandler.ResetPassword).Methods("POST")
+	public.HandleFunc("/verify-email", emailVerificationHandler.VerifyEmail).Methods("POST")
+	public.HandleFunc("/verify-email/resend", emailVerificationHandler.ResendVerification).Methods("POST")
+	if sessions.Tokens != nil {
+		// Lockout counts failed logins per client IP
+		public.Handle("/sessions", lockout.Middleware(http.HandlerFunc(sessionHandler.Login))).Methods("POST")
+		public.Handle("/sessions/refresh", lockout.Middleware(http.HandlerFunc(sessionHandler.Refresh))).Methods("POST")
+	}
+
+	// Customer API, every route below requires an authenticated caller.
+	// Registered last so /{uuid} never shadows the public routes above.
+	// Lockout counts rejected API keys and tokens, budgets apply per subject.
+	api := r.NewRoute().Subrouter()
+	api.Use(lockout.Guard)
+	api.Use(auth.Middleware(lockout.Authenticator(authenticator)))
+	api.Use(limiter.Middleware)
+	api.HandleFunc("/admin/api-keys", apiKeyHandler.CreateAPIKey).Methods("POST")
+	api.HandleFunc("/admin/api-keys", apiKeyHandler.FetchAPIKeys).Methods("GET")
+	api.HandleFunc("/admin/api-keys/{key_uuid}/rotate", apiKeyHandler.RotateAPIKey).Methods("POST")
+	api.HandleFunc("/admin/api-keys/{key_uuid}", apiKeyHandler.RevokeAPIKey).Methods("DELETE")
+	api.HandleFunc("/admin/webhooks", webhookHandler.CreateWebhook).Methods("POST")
+	api.HandleFunc("/admin/webhooks", webhookHandler.FetchWebhooks).Methods("GET")
+	api.HandleFunc("/admin/webhooks/{webhook_uuid}", webhookHandler.DeleteWebhook).Methods("DELETE")
+	api.HandleFunc("/admin/webhooks/{webhook_uuid}/deliveries", webhookHandler.FetchWebhookDeliveries).Methods("GET")
+	api.HandleFunc("/admin/webhook-deliveries/{delivery_uuid}", webhookHandler.GetWebhookDelivery).Methods("GET")
+	api.HandleFunc("/admin/webhook-deliveries/{delivery_uuid}/replay", webhookHandler.ReplayWebhookDelivery).Methods("POST")
+	api.HandleFunc("/", handler.Store).Methods("POST")
+	api.HandleFunc("/", handler.Fetch).Methods("GET")
+	api.HandleFunc("/changes", handler.Changes).Methods("GET")
+	api.HandleFunc("/events", streamHandler.StreamEvents).Methods("GET")
+	api.HandleFunc("/{uuid}", handler.GetByUUID).Methods("GET")
+	api.HandleFunc("/{uuid}", handler.UpdateByUUID).Methods("PUT")
+	api.HandleFunc("/{uuid}", handler.DeleteByUUID).Methods("DELETE")
+	api.HandleFunc("/{uuid}/restore", handler.RestoreByUUID).Methods("POST")
+	api.HandleFunc("/{uuid}/erase", erasureHandler.Erase).Methods("POST")
+	api.Handle("/{uuid}/password", lockout.Middleware(http.HandlerFunc(passwordHandler.ChangePassword))).Methods("POST")
+	api.HandleFunc("/{uuid}/phone/verify", phoneVerificationHandler.SendPhoneCode).Methods("POST")
+	api.HandleFunc("/{uuid}/phone/verify/confirm", phoneVerificationHandler.VerifyPhone).Methods("POST")
+	api.HandleFunc("/{uuid}/sessions", sessionHandler.FetchSessions).Methods("GET")
+	api.HandleFunc("/{uuid}/sessions", sessionHandler.RevokeSessions).Methods("DELETE")
+	api.HandleFunc("/{uuid}/sessions/{session_uuid}", sessionHandler.RevokeSession).Methods("DELETE")
+	api.HandleFunc("/{uuid}/history", auditHandler.History).Methods("GET")
+	api.HandleFunc("/{uuid}/export", exportHandler.Export).Methods("GET")
+	api.HandleFunc("/{uuid}/exports/{export_uuid}", exportHandler.GetExport).Methods("GET")
+	api.HandleFunc("/{uuid}/exports/{export_uuid}/download", exportHandler.DownloadExport).Methods("GET")
+	api.HandleFunc("/{uuid}/mfa/totp", mfaHandler.EnrollTOTP).Methods("POST")
+	api.HandleFunc("/{uuid}/mfa/totp/confirm", mfaHandler.ConfirmTOTP).Methods("POST")
+
 	// Profiler
 	// r.HandleFunc("/debug/pprof/", pprof.Index)
 	// r.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
@@ -74,13 +510,25 @@ func main() {
 	// r.Handle("/debug/pprof/block", pprof.Handler("block"))
 
 	log.Infof("Server running on port %s", port)
-	loggedRouter := handlers.LoggingHandler(os.Stdout, r)
 
-	headers := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"})
+	headers := handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", auth.APIKeyHeader, middleware.RequestIDHeader})
 	methods := handlers.AllowedMethods([]string{"GET", "POST", "PUT", "DELETE"})
 	origins := handlers.AllowedOrigins([]string{"*"})
+	exposed := handlers.ExposedHeaders([]string{"RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", middleware.RequestIDHeader})
+
+	// Outermost first: every later middleware sees the request ID and
+	// panics anywhere below Recover still get an access log line.
+	stack := middleware.Chain(
+		middleware.RequestID,
+		middleware.RealIP(trustedProxies),
+		middleware.AccessLog,
+		middleware.Recover,
+		middleware.BodyLimit(middleware.DefaultMaxBodyBytes),
+		handlers.CompressHandler,
+		handlers.CORS(headers, methods, origins, exposed),
+	)
 
-	err = http.ListenAndServe(port, handlers.CORS(headers, methods, origins)(loggedRouter))
+	err = http.ListenAndServe(port, stack(r))
 	if err != nil {
 		log.Errorf("Error starting server: %s\n", err)
 		os.Exit(1)
@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// statusRecorder captures the status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before passing it through
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Flush keeps streaming handlers working through the recorder
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unmatched route label of requests no route matches (404, 405)
+const Unmatched = "unmatched"
+
+// Instrument records every request router serves: matched routes through
+// Middleware, 404 and 405 answers under the Unmatched label since mux skips
+// middlewares when nothing matches.
+func Instrument(router *mux.Router) {
+	router.Use(Middleware)
+	router.NotFoundHandler = Middleware(http.NotFoundHandler())
+	router.MethodNotAllowedHandler = Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+}
+
+// Middleware records request count and latency labelled by the matched route
+// template (/{uuid}) instead of the raw path so cardinality stays bounded.
+// Install it with Instrument so the router's current route is set.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rec, r)
+
+		route := RouteTemplate(r)
+		status := strconv.Itoa(rec.status)
+
+		HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+		HTTPDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+	})
+}
+
+// RouteTemplate matched mux path template or Unmatched when there is none
+func RouteTemplate(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return tpl
+		}
+	}
+	return Unmatched
+}
@@ -0,0 +1,68 @@
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"svc-customer/metrics"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddlewareLabelsRouteTemplate(t *testing.T) {
+
+	router := mux.NewRouter()
+	router.HandleFunc("/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}).Methods("GET")
+	metrics.Instrument(router)
+	handler := router
+
+	before := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/{uuid}", "GET", "404"))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/039d69ee-f9cb-4a3d-87e4-6eb63c302579", nil))
+
+	after := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/{uuid}", "GET", "404"))
+	assert.Equal(t, before+1, after)
+}
+
+func TestMiddlewareCountsUnmatchedRequests(t *testing.T) {
+
+	router := mux.NewRouter()
+	api := router.PathPrefix("/admin").Subrouter()
+	api.HandleFunc("/webhooks", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+	metrics.Instrument(router)
+	handler := router
+
+	notFound := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(metrics.Unmatched, "GET", "404"))
+	notAllowed := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(metrics.Unmatched, "PUT", "405"))
+	matched := testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/admin/webhooks", "GET", "200"))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/nowhere", nil),
+		httptest.NewRequest("PUT", "/admin/webhooks", nil),
+		httptest.NewRequest("GET", "/admin/webhooks", nil),
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.Equal(t, notFound+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(metrics.Unmatched, "GET", "404")))
+	assert.Equal(t, notAllowed+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues(metrics.Unmatched, "PUT", "405")))
+	assert.Equal(t, matched+1, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/admin/webhooks", "GET", "200")))
+}
+
+func TestHandlerExposesTextFormat(t *testing.T) {
+
+	metrics.CustomersCreated.Inc()
+
+	rr := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.True(t, strings.Contains(rr.Body.String(), "customer_created_total"))
+	assert.False(t, strings.Contains(rr.Body.String(), "039d69ee"))
+}
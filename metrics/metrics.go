@@ -0,0 +1,245 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefix shared by every svc-customer metric
+const Namespace = "customer"
+
+// Registry holds every svc-customer collector, kept apart from the global
+// default registry so tests can create fresh instances.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequests total HTTP requests by route template, method and status code
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Total HTTP requests by route template, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration HTTP request latency by route template, method and status code
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by route template, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	// RepositoryDuration repository operation latency by operation name
+	RepositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "repository",
+		Name:      "operation_duration_seconds",
+		Help:      "Repository operation latency by operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	// RepositoryErrors repository operation failures by operation name
+	RepositoryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "repository",
+		Name:      "operation_errors_total",
+		Help:      "Repository operation failures by operation.",
+	}, []string{"operation"})
+
+	// CustomersCreated customers successfully stored
+	CustomersCreated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "created_total",
+		Help:      "Customers successfully created.",
+	})
+
+	// CustomersUpdated customers successfully updated
+	CustomersUpdated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "updated_total",
+		Help:      "Customers successfully updated.",
+	})
+
+	// CustomersDeleted customers successfully (soft) deleted
+	CustomersDeleted = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "deleted_total",
+		Help:      "Customers successfully deleted.",
+	})
+
+	// CustomersRestored soft-deleted customers successfully restored
+	CustomersRestored = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "restored_total",
+		Help:      "Deleted customers successfully restored.",
+	})
+
+	// CustomersErased customers whose personal data was erased, by erasure mode
+	CustomersErased = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "erased_total",
+		Help:      "Customers whose personal data was erased, by mode.",
+	}, []string{"mode"})
+
+	// CustomerExports data exports generated, sync on request or async by the worker
+	CustomerExports = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "exports_total",
+		Help:      "Customer data exports generated, by mode.",
+	}, []string{"mode"})
+
+	// PasswordResets passwords successfully reset through a reset token
+	PasswordResets = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "password_resets_total",
+		Help:      "Passwords successfully reset through a reset token.",
+	})
+
+	// PasswordChanges passwords successfully changed by their owner
+	PasswordChanges = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "password_changes_total",
+		Help:      "Passwords successfully changed by their owner.",
+	})
+
+	// EmailsVerified email addresses confirmed through a verification token
+	EmailsVerified = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "emails_verified_total",
+		Help:      "Email addresses confirmed through a verification token.",
+	})
+
+	// PhonesVerified phones confirmed through a one-time code
+	PhonesVerified = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "phones_verified_total",
+		Help:      "Phones confirmed through a one-time code.",
+	})
+
+	// SessionEvents logins, refreshes, detected refresh token reuse and revocations
+	SessionEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "session",
+		Name:      "events_total",
+		Help:      "Session logins, refreshes, detected refresh token reuse and revocations by event.",
+	}, []string{"event"})
+
+	// MFAEvents TOTP enrollments, confirmations and second factor checks
+	MFAEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "mfa",
+		Name:      "events_total",
+		Help:      "TOTP enrollments, confirmations, accepted and rejected second factors by event.",
+	}, []string{"event"})
+
+	// OutboxEvents outbox records handed to the event sinks by sink and result
+	OutboxEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "outbox",
+		Name:      "events_total",
+		Help:      "Outbox records handed to the event sinks by sink (publisher, webhooks) and result (published, failed, dead).",
+	}, []string{"sink", "result"})
+
+	// KafkaMessages customer events produced to Kafka by topic and result
+	KafkaMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "kafka",
+		Name:      "messages_total",
+		Help:      "Customer events produced to Kafka by topic and result (delivered, failed).",
+	}, []string{"topic", "result"})
+
+	// KafkaPublishDuration time until Kafka acknowledged an event, retries included
+	KafkaPublishDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Subsystem: "kafka",
+		Name:      "publish_duration_seconds",
+		Help:      "Time until Kafka acknowledged an event, retries included.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"topic"})
+
+	// WebhookDeliveries webhook delivery attempts by resulting status
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "Webhook delivery attempts by resulting status (delivered, pending, dead).",
+	}, []string{"status"})
+
+	// StreamSubscribers open Server-Sent Events streams
+	StreamSubscribers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: Namespace,
+		Subsystem: "stream",
+		Name:      "subscribers",
+		Help:      "Open Server-Sent Events streams of customer events.",
+	})
+
+	// StreamDropped subscribers dropped for falling behind the event stream
+	StreamDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "stream",
+		Name:      "dropped_total",
+		Help:      "Event stream subscribers dropped for falling behind.",
+	})
+
+	// APIKeyRequests API key authentication attempts by key name and result
+	APIKeyRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "apikey",
+		Name:      "requests_total",
+		Help:      "API key authentication attempts by key name and result (ok, invalid, ip_denied).",
+	}, []string{"key", "result"})
+
+	// RateLimited requests rejected with 429 by route template and reason
+	RateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Subsystem: "ratelimit",
+		Name:      "rejected_total",
+		Help:      "Requests rejected with 429 by route template and reason (budget, lockout).",
+	}, []string{"route", "reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		RepositoryDuration,
+		RepositoryErrors,
+		CustomersCreated,
+		CustomersUpdated,
+		CustomersDeleted,
+		CustomersRestored,
+		CustomersErased,
+		CustomerExports,
+		PasswordResets,
+		PasswordChanges,
+		EmailsVerified,
+		PhonesVerified,
+		SessionEvents,
+		MFAEvents,
+		OutboxEvents,
+		KafkaMessages,
+		KafkaPublishDuration,
+		WebhookDeliveries,
+		StreamSubscribers,
+		StreamDropped,
+		APIKeyRequests,
+		RateLimited,
+	)
+}
+
+// RegisterDB exposes sql.DBStats connection pool gauges for db under dbName
+func RegisterDB(db *sql.DB, dbName string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// Handler serves every registered collector in Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"svc-customer/logging"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AccessLog writes one structured entry per request with status, latency and response size
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := newResponseRecorder(w)
+
+		next.ServeHTTP(rec, r)
+
+		entry := logging.FromContext(r.Context()).WithFields(log.Fields{
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      rec.status,
+			"bytes":       rec.bytes,
+			"latency_ms":  float64(time.Since(start).Microseconds()) / 1000,
+			"remote_addr": r.RemoteAddr,
+			"user_agent":  r.UserAgent(),
+		})
+
+		switch {
+		case rec.status >= http.StatusInternalServerError:
+			entry.Error("access")
+		case rec.status >= http.StatusBadRequest:
+			entry.Warn("access")
+		default:
+			entry.Info("access")
+		}
+	})
+}
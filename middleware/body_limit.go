@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"svc-customer/problem"
+)
+
+// DefaultMaxBodyBytes request bodies above 1MB are rejected
+const DefaultMaxBodyBytes int64 = 1 << 20
+
+// BodyLimit rejects requests declaring a body larger than maxBytes with 413 and
+// caps the reader for chunked bodies that do not declare their size.
+func BodyLimit(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				problem.Write(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body must not exceed %d bytes", maxBytes))
+				return
+			}
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedForHeader header appended to by reverse proxies
+const ForwardedForHeader = "X-Forwarded-For"
+
+// clientIPKey private type so no other package can collide with our key
+type clientIPKey struct{}
+
+// RealIP resolves the caller address once per request. X-Forwarded-For is only
+// honoured when the direct peer is one of the trusted proxies, and then the
+// rightmost hop that is not itself a trusted proxy is taken.
+func RealIP(trusted []*net.IPNet) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, trusted)
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// ClientIP address resolved by RealIP, the direct peer when RealIP did not run
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteIP(r)
+}
+
+// ParseTrustedProxies comma separated IPs or CIDR ranges, as in TRUSTED_PROXIES
+func ParseTrustedProxies(value string) ([]*net.IPNet, error) {
+	trusted := []*net.IPNet{}
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxies: %w", err)
+		}
+		trusted = append(trusted, network)
+	}
+	return trusted, nil
+}
+
+// resolveClientIP walks X-Forwarded-For right to left while hops are trusted
+func resolveClientIP(r *http.Request, trusted []*net.IPNet) string {
+	ip := remoteIP(r)
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values(ForwardedForHeader), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return ip
+}
+
+// remoteIP host part of RemoteAddr
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// isTrusted reports whether ip belongs to one of the trusted networks
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
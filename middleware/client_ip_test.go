@@ -0,0 +1,50 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"svc-customer/middleware"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func clientIPFor(t *testing.T, trustedProxies string, remoteAddr string, forwardedFor string) string {
+	trusted, err := middleware.ParseTrustedProxies(trustedProxies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var seen string
+	handler := middleware.RealIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = middleware.ClientIP(r)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set(middleware.ForwardedForHeader, forwardedFor)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	return seen
+}
+
+func TestRealIPIgnoresForwardedForFromUntrustedPeer(t *testing.T) {
+
+	assert.Equal(t, "203.0.113.9", clientIPFor(t, "", "203.0.113.9:5555", "10.0.0.1"))
+	assert.Equal(t, "203.0.113.9", clientIPFor(t, "10.0.0.0/8", "203.0.113.9:5555", "198.51.100.7"))
+}
+
+func TestRealIPSkipsTrustedHops(t *testing.T) {
+
+	ip := clientIPFor(t, "10.0.0.0/8, 192.168.1.1", "10.0.0.2:5555", "1.2.3.4, 198.51.100.7, 192.168.1.1")
+
+	assert.Equal(t, "198.51.100.7", ip)
+}
+
+func TestParseTrustedProxiesRejectsGarbage(t *testing.T) {
+
+	_, err := middleware.ParseTrustedProxies("10.0.0.0/8,not-an-ip")
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,104 @@
+package middleware_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"svc-customer/middleware"
+	"svc-customer/problem"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChainOrder(t *testing.T) {
+
+	var order []string
+	tag := func(name string) middleware.Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	handler := middleware.Chain(tag("a"), tag("b"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	assert.Equal(t, []string{"a", "b", "handler"}, order)
+}
+
+func TestRecoverReturnsProblem(t *testing.T) {
+
+	handler := middleware.Chain(middleware.RequestID, middleware.Recover)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest("GET", "/039d69ee-f9cb-4a3d-87e4-6eb63c302579", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc-123")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, problem.ContentType, rr.Header().Get("Content-Type"))
+
+	var body problem.Problem
+	assert.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+	assert.Equal(t, http.StatusInternalServerError, body.Status)
+	assert.Equal(t, "abc-123", body.RequestID)
+}
+
+func TestAccessLogRecordsStatusAndSize(t *testing.T) {
+
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFormatter(&log.JSONFormatter{})
+	defer log.SetOutput(ioutil.Discard)
+
+	handler := middleware.AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+
+	assert.Contains(t, buf.String(), `"status":201`)
+	assert.Contains(t, buf.String(), `"bytes":5`)
+	assert.Contains(t, buf.String(), `"latency_ms"`)
+}
+
+func TestBodyLimitRejectsLargeBodies(t *testing.T) {
+
+	called := false
+	handler := middleware.BodyLimit(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "far too long"}`)))
+
+	assert.False(t, called)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+}
+
+func TestBodyLimitCapsUndeclaredBodies(t *testing.T) {
+
+	var readErr error
+	handler := middleware.BodyLimit(10)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = ioutil.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name": "far too long"}`))
+	req.ContentLength = -1
+
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Error(t, readErr)
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"svc-customer/logging"
+	"svc-customer/problem"
+)
+
+// Recover turns a handler panic into a logged stack trace and a 500 problem
+// response instead of dropping the connection.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := newResponseRecorder(w)
+
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			// net/http uses this sentinel to abort a response on purpose
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+
+			logging.FromContext(r.Context()).
+				WithField("panic", fmt.Sprint(err)).
+				WithField("stack", string(debug.Stack())).
+				Error("Recover: handler panicked")
+
+			if !rec.wroteHeader {
+				problem.Write(rec, r, http.StatusInternalServerError, "The server encountered an unexpected condition")
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
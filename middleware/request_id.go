@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"svc-customer/logging"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader header read from callers and echoed back on every response
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength longer incoming IDs are replaced to keep logs bounded
+const maxRequestIDLength = 128
+
+// RequestID takes X-Request-ID from the caller (or generates one), echoes it on
+// the response and stores a request scoped logger carrying it in the context.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, requestID)
+
+		ctx := logging.WithRequestID(r.Context(), requestID)
+		ctx = logging.NewContext(ctx, log.WithField("request_id", requestID))
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// validRequestID accepts only short printable ASCII values
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] < 0x21 || requestID[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
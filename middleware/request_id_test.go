@@ -0,0 +1,46 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"svc-customer/logging"
+	"svc-customer/middleware"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDKeepsIncomingHeader(t *testing.T) {
+
+	var seen string
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, "abc-123")
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Equal(t, "abc-123", seen)
+	assert.Equal(t, "abc-123", rr.Header().Get(middleware.RequestIDHeader))
+}
+
+func TestRequestIDGeneratesWhenMissingOrInvalid(t *testing.T) {
+
+	var seen string
+	handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = logging.RequestID(r.Context())
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set(middleware.RequestIDHeader, strings.Repeat("x", 500))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	assert.Len(t, seen, 36)
+	assert.Equal(t, seen, rr.Header().Get(middleware.RequestIDHeader))
+}
@@ -0,0 +1,23 @@
+--
+-- Table structure for table `api_keys`
+--
+-- Service-to-service credentials. Only the SHA-256 hash of the key is stored,
+-- `prefix` is the public part of the key used to look it up.
+--
+
+CREATE TABLE `api_keys` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `key_uuid` varchar(36) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `prefix` varchar(16) NOT NULL,
+  `key_hash` char(64) NOT NULL,
+  `permissions` text NOT NULL,
+  `allowed_ips` text NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `rotated_at` datetime DEFAULT NULL,
+  `last_used_at` datetime DEFAULT NULL,
+  `revoked_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `api_keys_key_uuid` (`key_uuid`),
+  UNIQUE KEY `api_keys_prefix` (`prefix`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,18 @@
+--
+-- Table structure for table `password_resets`
+--
+-- Single-use password reset tokens. Only the SHA-256 hash of the token is
+-- stored, a token is spent once `used_at` is set.
+--
+
+CREATE TABLE `password_resets` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `token_hash` char(64) NOT NULL,
+  `customer_uuid` varchar(255) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `used_at` datetime DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `password_resets_token_hash` (`token_hash`),
+  KEY `password_resets_customer_uuid` (`customer_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
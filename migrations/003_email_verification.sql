@@ -0,0 +1,22 @@
+--
+-- Email verification
+--
+-- `email_verified_at` is cleared whenever the email changes. Only the SHA-256
+-- hash of a verification token is stored, a token is spent once `used_at` is
+-- set and only verifies the exact address it was sent to.
+--
+
+ALTER TABLE `customers` ADD COLUMN `email_verified_at` datetime DEFAULT NULL AFTER `email`;
+
+CREATE TABLE `email_verifications` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `token_hash` char(64) NOT NULL,
+  `customer_uuid` varchar(255) NOT NULL,
+  `email` varchar(255) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `used_at` datetime DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `email_verifications_token_hash` (`token_hash`),
+  KEY `email_verifications_email` (`email`, `created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
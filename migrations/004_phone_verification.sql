@@ -0,0 +1,22 @@
+--
+-- Phone verification
+--
+-- `phone_verified_at` is cleared whenever the phone changes. Each customer
+-- has at most one pending code, stored as a SHA-256 hash bound to the
+-- customer UUID, and every guess counts in `attempts`.
+--
+
+ALTER TABLE `customers` ADD COLUMN `phone_verified_at` datetime DEFAULT NULL AFTER `phone`;
+
+CREATE TABLE `phone_verifications` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `customer_uuid` varchar(255) NOT NULL,
+  `phone` varchar(255) NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `attempts` int(11) NOT NULL DEFAULT 0,
+  `expires_at` datetime NOT NULL,
+  `used_at` datetime DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `phone_verifications_customer_uuid` (`customer_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,33 @@
+--
+-- Customer sessions and refresh tokens
+--
+-- A session is one login on one device. Its refresh tokens rotate on every
+-- use, only their SHA-256 hash is stored and a used token stays as
+-- evidence: presenting it again revokes the whole session.
+--
+
+CREATE TABLE `sessions` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `session_uuid` varchar(255) NOT NULL,
+  `customer_uuid` varchar(255) NOT NULL,
+  `user_agent` varchar(255) NOT NULL DEFAULT '',
+  `ip` varchar(45) NOT NULL DEFAULT '',
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `last_used_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `expires_at` datetime NOT NULL,
+  `revoked_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `sessions_session_uuid` (`session_uuid`),
+  KEY `sessions_customer_uuid` (`customer_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE `refresh_tokens` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `token_hash` char(64) NOT NULL,
+  `session_uuid` varchar(255) NOT NULL,
+  `used_at` datetime DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `refresh_tokens_token_hash` (`token_hash`),
+  KEY `refresh_tokens_session_uuid` (`session_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,29 @@
+--
+-- TOTP multi-factor authentication
+--
+-- `secret` is AES-256-GCM encrypted with MFA_ENCRYPTION_KEY. A factor only
+-- counts once `confirmed_at` is set, `last_used_step` refuses replayed codes.
+-- Recovery codes are stored as SHA-256 hashes and work once each.
+--
+
+CREATE TABLE `mfa_totp` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `customer_uuid` varchar(255) NOT NULL,
+  `secret` varchar(255) NOT NULL,
+  `confirmed_at` datetime DEFAULT NULL,
+  `last_used_step` bigint(20) NOT NULL DEFAULT 0,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `mfa_totp_customer_uuid` (`customer_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE `mfa_recovery_codes` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `customer_uuid` varchar(255) NOT NULL,
+  `code_hash` char(64) NOT NULL,
+  `used_at` datetime DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `mfa_recovery_codes_code_hash` (`code_hash`),
+  KEY `mfa_recovery_codes_customer_uuid` (`customer_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
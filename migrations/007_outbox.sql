@@ -0,0 +1,53 @@
+--
+-- Transactional outbox of customer lifecycle events
+--
+-- Rows are inserted in the same transaction as the customer change they
+-- describe. Every event sink (the external publisher, webhooks) has its own
+-- relay: records are queued once per sink in `outbox_sinks`, stamping
+-- `queued_at`, and each relay publishes its rows oldest first, so one sink
+-- failing neither holds up nor repeats the others. Failed publishes bump
+-- `attempts`, keep the last error for operators and push `next_attempt_at`
+-- back; after the last attempt the row turns `dead` and the customer's later
+-- events go ahead. Until then they wait behind it.
+-- Only the replica holding a sink's `outbox_relay` lease publishes to it, so
+-- replicas neither send the same record twice nor reorder a customer's events.
+--
+
+CREATE TABLE `outbox` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `event_uuid` char(36) NOT NULL,
+  `event_type` varchar(64) NOT NULL,
+  `customer_uuid` varchar(255) NOT NULL,
+  `payload` text NOT NULL,
+  `occurred_at` datetime(6) NOT NULL,
+  `queued_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `outbox_event_uuid` (`event_uuid`),
+  KEY `outbox_queued_at` (`queued_at`, `id`),
+  KEY `outbox_customer_uuid` (`customer_uuid`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE `outbox_sinks` (
+  `sink` varchar(32) NOT NULL,
+  `outbox_id` bigint(20) NOT NULL,
+  `customer_uuid` varchar(255) NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `attempts` int(11) NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+  `last_error` varchar(255) DEFAULT NULL,
+  `updated_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`sink`, `outbox_id`),
+  KEY `outbox_sinks_pending` (`sink`, `status`, `outbox_id`),
+  KEY `outbox_sinks_customer` (`sink`, `customer_uuid`, `status`, `outbox_id`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE `outbox_relay` (
+  `sink` varchar(32) NOT NULL,
+  `owner` varchar(64) NOT NULL,
+  `lease_until` datetime(6) NOT NULL,
+  PRIMARY KEY (`sink`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+INSERT INTO `outbox_relay` (`sink`, `owner`, `lease_until`) VALUES
+  ('publisher', '', '1970-01-01 00:00:00'),
+  ('webhooks', '', '1970-01-01 00:00:00');
@@ -0,0 +1,55 @@
+--
+-- Outbound webhooks
+--
+-- `webhook_subscriptions` lists partner endpoints with the event types they
+-- want and the secret signing their deliveries. Every matching event becomes
+-- one `webhook_deliveries` row, retried until delivered or dead after the
+-- last attempt, and every HTTP attempt is logged in `webhook_attempts`.
+-- A dispatcher claims due deliveries by stamping its `claim_token` and moving
+-- `next_attempt_at` past the claim lease, so replicas never send one twice.
+--
+
+CREATE TABLE `webhook_subscriptions` (
+  `id` int(11) NOT NULL AUTO_INCREMENT,
+  `webhook_uuid` varchar(36) NOT NULL,
+  `url` varchar(2048) NOT NULL,
+  `event_types` varchar(255) NOT NULL,
+  `secret` varchar(255) NOT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `deleted_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `webhook_subscriptions_webhook_uuid` (`webhook_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE `webhook_deliveries` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `delivery_uuid` varchar(36) NOT NULL,
+  `webhook_uuid` varchar(36) NOT NULL,
+  `event_uuid` char(36) NOT NULL,
+  `event_type` varchar(64) NOT NULL,
+  `payload` text NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `attempts` int(11) NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `last_status_code` int(11) DEFAULT NULL,
+  `last_error` varchar(255) DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `delivered_at` datetime DEFAULT NULL,
+  `claim_token` char(36) DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `webhook_deliveries_delivery_uuid` (`delivery_uuid`),
+  UNIQUE KEY `webhook_deliveries_event` (`webhook_uuid`, `event_uuid`),
+  KEY `webhook_deliveries_due` (`status`, `next_attempt_at`),
+  KEY `webhook_deliveries_claim` (`claim_token`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+CREATE TABLE `webhook_attempts` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `delivery_uuid` varchar(36) NOT NULL,
+  `status_code` int(11) DEFAULT NULL,
+  `error` varchar(255) DEFAULT NULL,
+  `duration_ms` int(11) NOT NULL,
+  `attempted_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (`id`),
+  KEY `webhook_attempts_delivery_uuid` (`delivery_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
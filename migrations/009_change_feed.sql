@@ -0,0 +1,26 @@
+--
+-- Customer change feed
+--
+-- Every write to a customer stamps the row with the next value of the single
+-- `change_sequence` counter and the kind of change. The counter row stays
+-- locked until the writing transaction ends, so sequences become visible in
+-- commit order and `GET /changes` can page through `change_seq` without
+-- skipping a change that commits late.
+--
+
+CREATE TABLE `change_sequence` (
+  `id` tinyint(4) NOT NULL,
+  `value` bigint(20) NOT NULL,
+  PRIMARY KEY (`id`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
+
+ALTER TABLE `customers`
+  ADD COLUMN `change_seq` bigint(20) NOT NULL DEFAULT 0,
+  ADD COLUMN `change_op` varchar(10) NOT NULL DEFAULT 'insert',
+  ADD KEY `customers_change_seq` (`change_seq`);
+
+-- Existing customers enter the feed in creation order
+UPDATE `customers` SET `change_seq` = `id`,
+  `change_op` = IF(`deleted_at` IS NOT NULL, 'delete', IF(`updated_at` IS NULL, 'insert', 'update'));
+
+INSERT INTO `change_sequence` (`id`, `value`) SELECT 1, COALESCE(MAX(`id`), 0) FROM `customers`;
@@ -0,0 +1,22 @@
+--
+-- Customer audit trail
+--
+-- One row per change of a customer record, inserted in the same transaction
+-- as the change. `changes` is a JSON array of {field, before, after} with
+-- passwords redacted. The service only ever inserts into this table.
+--
+
+CREATE TABLE `customer_audit` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `audit_uuid` char(36) NOT NULL,
+  `customer_uuid` varchar(255) NOT NULL,
+  `action` varchar(32) NOT NULL,
+  `actor` varchar(255) NOT NULL,
+  `actor_method` varchar(16) DEFAULT NULL,
+  `request_id` varchar(128) DEFAULT NULL,
+  `changes` text NOT NULL,
+  `created_at` datetime(6) NOT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `customer_audit_audit_uuid` (`audit_uuid`),
+  KEY `customer_audit_customer_uuid` (`customer_uuid`, `id`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
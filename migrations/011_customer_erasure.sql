@@ -0,0 +1,23 @@
+--
+-- Customer erasure
+--
+-- `erased_at` marks customers whose personal data was anonymised, they stay
+-- deleted and can no longer be restored. The purge job finds customers
+-- deleted longer than the retention period through `customers_deleted_at`.
+-- Hard deleted customers leave a row in `customer_tombstones` under the next
+-- change sequence, which `GET /changes` reports as a deletion.
+--
+
+ALTER TABLE `customers`
+  ADD COLUMN `erased_at` datetime DEFAULT NULL AFTER `deleted_at`,
+  ADD KEY `customers_deleted_at` (`deleted_at`, `erased_at`);
+
+CREATE TABLE `customer_tombstones` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `customer_uuid` varchar(255) NOT NULL,
+  `change_seq` bigint(20) NOT NULL,
+  `created_at` datetime NOT NULL,
+  `deleted_at` datetime NOT NULL,
+  PRIMARY KEY (`id`),
+  KEY `customer_tombstones_change_seq` (`change_seq`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
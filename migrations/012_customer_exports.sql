@@ -0,0 +1,25 @@
+--
+-- Asynchronous customer data exports
+--
+-- `GET /{uuid}/export?async=true` queues a pending row, a background worker
+-- stores the generated `bundle` and marks it ready. Bundles carry personal
+-- data: they are deleted once `expires_at` passes and when the customer is
+-- erased.
+--
+
+CREATE TABLE `customer_exports` (
+  `id` bigint(20) NOT NULL AUTO_INCREMENT,
+  `export_uuid` char(36) NOT NULL,
+  `customer_uuid` varchar(255) NOT NULL,
+  `format` varchar(8) NOT NULL,
+  `status` varchar(16) NOT NULL DEFAULT 'pending',
+  `bundle` longblob DEFAULT NULL,
+  `error` varchar(255) DEFAULT NULL,
+  `created_at` datetime NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  `completed_at` datetime DEFAULT NULL,
+  `expires_at` datetime DEFAULT NULL,
+  PRIMARY KEY (`id`),
+  UNIQUE KEY `customer_exports_export_uuid` (`export_uuid`),
+  KEY `customer_exports_status` (`status`, `id`),
+  KEY `customer_exports_customer_uuid` (`customer_uuid`)
+) ENGINE=InnoDB DEFAULT CHARSET=latin1;
@@ -0,0 +1,33 @@
+--
+-- Field-level encryption of customer PII
+--
+-- With PII_KEYFILE set `dni`, `email` and `phone` hold envelope ciphertexts,
+-- "enc:v1:<key id>:<wrapped data key>:<ciphertext>", that outgrow the plain
+-- text sizes. Lookups and uniqueness checks compare the HMAC blind indexes
+-- in `<column>_bidx` instead. Existing rows stay readable in plain text until
+-- `svc-customer rotate-keys` encrypts them.
+--
+-- The copies of these values outside `customers` are sealed with the same
+-- envelope: outbox and webhook delivery payloads, export bundles and the
+-- addresses verification tokens and codes were sent to. Resends of email
+-- tokens are throttled on `email_verifications.email_bidx`.
+--
+
+ALTER TABLE `customers`
+  MODIFY `dni` varchar(512) DEFAULT 'DNI',
+  MODIFY `email` varchar(512) NOT NULL,
+  MODIFY `phone` varchar(512) NOT NULL,
+  ADD COLUMN `dni_bidx` char(64) DEFAULT NULL AFTER `phone`,
+  ADD COLUMN `email_bidx` char(64) DEFAULT NULL AFTER `dni_bidx`,
+  ADD COLUMN `phone_bidx` char(64) DEFAULT NULL AFTER `email_bidx`,
+  ADD KEY `customers_dni_bidx` (`dni_bidx`),
+  ADD KEY `customers_email_bidx` (`email_bidx`),
+  ADD KEY `customers_phone_bidx` (`phone_bidx`);
+
+ALTER TABLE `email_verifications`
+  MODIFY `email` varchar(512) NOT NULL,
+  ADD COLUMN `email_bidx` char(64) DEFAULT NULL AFTER `email`,
+  ADD KEY `email_verifications_email_bidx` (`email_bidx`, `created_at`);
+
+ALTER TABLE `phone_verifications`
+  MODIFY `phone` varchar(512) NOT NULL;
@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"svc-customer/logging"
+	"sync"
+	"time"
+)
+
+// Channel medium a Message is delivered through
+type Channel string
+
+// Channels supported by Message
+const (
+	Email Channel = "email"
+	SMS   Channel = "sms"
+)
+
+// Message notification addressed to one customer
+type Message struct {
+	Channel Channel `json:"channel"`
+	// To email address or phone number, depending on Channel
+	To      string `json:"to"`
+	Subject string `json:"subject,omitempty"`
+	Body    string `json:"body"`
+}
+
+// Notifier delivers messages to customers, production deployments plug in
+// their email/SMS provider, LogNotifier and FileNotifier are for development.
+type Notifier interface {
+	Notify(ctx context.Context, message Message) error
+}
+
+// NotifierFunc adapts an ordinary function to the Notifier interface
+type NotifierFunc func(ctx context.Context, message Message) error
+
+// Notify calls f(ctx, message)
+func (f NotifierFunc) Notify(ctx context.Context, message Message) error {
+	return f(ctx, message)
+}
+
+// LogNotifier writes messages to the request logger, never use it in production
+// since bodies carry secrets such as reset links.
+type LogNotifier struct{}
+
+// Notify logs message at info level
+func (LogNotifier) Notify(ctx context.Context, message Message) error {
+	logging.FromContext(ctx).
+		WithField("channel", message.Channel).
+		WithField("to", message.To).
+		WithField("subject", message.Subject).
+		WithField("body", message.Body).
+		Info("notify: message not delivered, logged only")
+	return nil
+}
+
+// FileNotifier appends messages as JSON lines to Path, handy for local
+// end-to-end tests that need to read the delivered token back.
+type FileNotifier struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// fileEntry line written by FileNotifier
+type fileEntry struct {
+	Message
+	SentAt time.Time `json:"sent_at"`
+}
+
+// Notify appends message to the file
+func (n *FileNotifier) Notify(ctx context.Context, message Message) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	file, err := os.OpenFile(n.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	defer file.Close() //nolint
+
+	return json.NewEncoder(file).Encode(fileEntry{Message: message, SentAt: time.Now().UTC()})
+}
+
+// FromEnv notifier selected by NOTIFIER: "log" (default), "file", which
+// writes to NOTIFIER_FILE (default notifications.jsonl), or "smtp", which
+// relays through SMTP_ADDR as SMTP_FROM, authenticating when SMTP_USERNAME is set.
+func FromEnv() (Notifier, error) {
+	switch kind := os.Getenv("NOTIFIER"); kind {
+	case "", "log":
+		return LogNotifier{}, nil
+	case "file":
+		path, ok := os.LookupEnv("NOTIFIER_FILE")
+		if !ok {
+			path = "notifications.jsonl"
+		}
+		return &FileNotifier{Path: path}, nil
+	case "smtp":
+		notifier := &SMTPNotifier{Addr: os.Getenv("SMTP_ADDR"), From: os.Getenv("SMTP_FROM")}
+		if notifier.Addr == "" || notifier.From == "" {
+			return nil, fmt.Errorf("notify: SMTP_ADDR and SMTP_FROM are required")
+		}
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			host, _, err := net.SplitHostPort(notifier.Addr)
+			if err != nil {
+				return nil, fmt.Errorf("notify: %w", err)
+			}
+			notifier.Auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		return notifier, nil
+	default:
+		return nil, fmt.Errorf("notify: unknown NOTIFIER %q", kind)
+	}
+}
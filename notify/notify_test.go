@@ -0,0 +1,107 @@
+package notify_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"svc-customer/notify"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNotifierAppendsJSONLines(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	notifier := &notify.FileNotifier{Path: path}
+
+	assert.NoError(t, notifier.Notify(context.Background(), notify.Message{Channel: notify.Email, To: "a@b.co", Body: "first"}))
+	assert.NoError(t, notifier.Notify(context.Background(), notify.Message{Channel: notify.SMS, To: "+56900000000", Body: "second"}))
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close() //nolint
+
+	var bodies []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var message notify.Message
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &message))
+		bodies = append(bodies, message.Body)
+	}
+	assert.Equal(t, []string{"first", "second"}, bodies)
+}
+
+// fakeSMTP accepts one message on a local port and returns its DATA section
+func fakeSMTP(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make(chan string, 1)
+
+	go func() {
+		defer listener.Close() //nolint
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 fake ESMTP") //nolint
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); verb {
+			case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+				text.PrintfLine("250 OK") //nolint
+			case "DATA":
+				text.PrintfLine("354 go ahead") //nolint
+				body, _ := text.ReadDotBytes()
+				data <- string(body)
+				text.PrintfLine("250 queued") //nolint
+			case "QUIT":
+				text.PrintfLine("221 bye") //nolint
+				return
+			default:
+				text.PrintfLine("502 not implemented") //nolint
+			}
+		}
+	}()
+	return listener.Addr().String(), data
+}
+
+func TestSMTPNotifierSendsEmail(t *testing.T) {
+
+	addr, data := fakeSMTP(t)
+	notifier := &notify.SMTPNotifier{Addr: addr, From: "noreply@example.com"}
+
+	err := notifier.Notify(context.Background(), notify.Message{Channel: notify.Email, To: "a@b.co", Subject: "Verify", Body: "link"})
+	assert.NoError(t, err)
+
+	body := <-data
+	assert.Contains(t, body, "To: a@b.co")
+	assert.Contains(t, body, "Subject: Verify")
+	assert.Contains(t, body, "link")
+}
+
+func TestSMTPNotifierRejectsHeaderInjection(t *testing.T) {
+
+	notifier := &notify.SMTPNotifier{Addr: "127.0.0.1:1", From: "noreply@example.com"}
+
+	err := notifier.Notify(context.Background(), notify.Message{Channel: notify.Email, To: "a@b.co\r\nBcc: x@y.co", Body: "link"})
+	assert.Error(t, err)
+
+	err = notifier.Notify(context.Background(), notify.Message{Channel: notify.SMS, To: "+56900000000", Body: "code"})
+	assert.Error(t, err)
+}
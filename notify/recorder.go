@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"context"
+	"sync"
+)
+
+// Recorder keeps messages in memory instead of delivering them, for tests
+type Recorder struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Notify records message
+func (r *Recorder) Notify(ctx context.Context, message Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+// Messages recorded so far, oldest first
+func (r *Recorder) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Message(nil), r.messages...)
+}
@@ -0,0 +1,49 @@
+package notify
+
+import (
+	"context"
+	"svc-customer/logging"
+	"sync"
+)
+
+// SMSSender delivers text messages to phone numbers, production deployments
+// plug in their SMS provider.
+type SMSSender interface {
+	SendSMS(ctx context.Context, phone string, body string) error
+}
+
+// NotifierSMS sends text messages as SMS Messages through Notifier, so the
+// file notifier captures codes during local end-to-end tests.
+type NotifierSMS struct {
+	Notifier Notifier
+}
+
+// SendSMS notifies phone through the SMS channel
+func (s *NotifierSMS) SendSMS(ctx context.Context, phone string, body string) error {
+	return s.Notifier.Notify(ctx, Message{Channel: SMS, To: phone, Body: body})
+}
+
+// FakeSMS keeps text messages in memory instead of sending them, for tests
+// and local runs without an SMS provider.
+type FakeSMS struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// SendSMS records the message and logs that it was not delivered
+func (s *FakeSMS) SendSMS(ctx context.Context, phone string, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.messages = append(s.messages, Message{Channel: SMS, To: phone, Body: body})
+	logging.FromContext(ctx).Info("notify: fake SMS recorded, not delivered")
+	return nil
+}
+
+// Messages recorded so far, oldest first
+func (s *FakeSMS) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Message(nil), s.messages...)
+}
@@ -0,0 +1,40 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// SMTPNotifier delivers Email messages through an SMTP relay. Pointed at a
+// local catcher such as MailHog it doubles as the mail mock for end-to-end tests.
+type SMTPNotifier struct {
+	// Addr host:port of the relay
+	Addr string
+	From string
+	// Auth optional, nil for relays accepting unauthenticated mail
+	Auth smtp.Auth
+}
+
+// Notify sends message as a plain text email, other channels are rejected
+func (n *SMTPNotifier) Notify(ctx context.Context, message Message) error {
+	if message.Channel != Email {
+		return fmt.Errorf("notify: smtp cannot deliver %s messages", message.Channel)
+	}
+	if strings.ContainsAny(message.To+message.Subject, "\r\n") {
+		return fmt.Errorf("notify: invalid header value")
+	}
+
+	body := "From: " + n.From + "\r\n" +
+		"To: " + message.To + "\r\n" +
+		"Subject: " + message.Subject + "\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" +
+		message.Body + "\r\n"
+
+	if err := smtp.SendMail(n.Addr, n.Auth, n.From, []string{message.To}, []byte(body)); err != nil {
+		return fmt.Errorf("notify: %w", err)
+	}
+	return nil
+}
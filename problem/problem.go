@@ -0,0 +1,45 @@
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+	"svc-customer/logging"
+)
+
+// ContentType RFC 7807 media type
+const ContentType = "application/problem+json"
+
+// Problem RFC 7807 problem details body
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// New problem for httpStatus, title defaults to the standard status text
+func New(r *http.Request, httpStatus int, detail string) Problem {
+	return Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(httpStatus),
+		Status:    httpStatus,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: logging.RequestID(r.Context()),
+	}
+}
+
+// Write sends a problem response for httpStatus with detail
+func Write(w http.ResponseWriter, r *http.Request, httpStatus int, detail string) {
+	Send(w, New(r, httpStatus, detail))
+}
+
+// Send encodes p as the response body
+func Send(w http.ResponseWriter, p Problem) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_ = json.NewEncoder(w).Encode(p)
+}
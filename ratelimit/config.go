@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/go-redis/redis"
+)
+
+// redisKeyPrefix namespace of every key written to a shared Redis
+const redisKeyPrefix = "svc-customer:ratelimit:"
+
+// StoreFromEnv RedisStore when REDIS_URL (redis://:password@host:6379/0) is
+// set so replicas share budgets, an in-process MemoryStore otherwise.
+func StoreFromEnv() (Store, error) {
+	url, ok := os.LookupEnv("REDIS_URL")
+	if !ok || url == "" {
+		return NewMemoryStore(), nil
+	}
+	options, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: REDIS_URL: %w", err)
+	}
+	return NewRedisStore(redis.NewClient(options), redisKeyPrefix), nil
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"svc-customer/auth"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/middleware"
+	"svc-customer/problem"
+	"time"
+)
+
+// Lockout blocks callers after repeated failed credential checks
+type Lockout struct {
+	Store  Store
+	Config LockoutConfig
+}
+
+// Locked remaining lock time of key, zero when unlocked or the store fails
+func (l *Lockout) Locked(ctx context.Context, key string) time.Duration {
+	remaining, err := l.Store.Blocked(ctx, "lockout:"+key)
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("lockout: store unavailable")
+		return 0
+	}
+	return remaining
+}
+
+// Fail records a failed credential check for key and starts or extends its lock
+func (l *Lockout) Fail(ctx context.Context, key string) {
+	failures, err := l.Store.Increment(ctx, "lockout:"+key, l.Config.window())
+	if err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("lockout: store unavailable")
+		return
+	}
+	if block := l.Config.blockFor(failures); block > 0 {
+		if err := l.Store.Block(ctx, "lockout:"+key, block); err != nil {
+			logging.FromContext(ctx).WithError(err).Warn("lockout: store unavailable")
+			return
+		}
+		logging.FromContext(ctx).WithField("failures", failures).WithField("lock_seconds", int(block.Seconds())).Warn("lockout: caller locked")
+	}
+}
+
+// Succeed clears the failures recorded for key
+func (l *Lockout) Succeed(ctx context.Context, key string) {
+	if err := l.Store.Reset(ctx, "lockout:"+key); err != nil {
+		logging.FromContext(ctx).WithError(err).Warn("lockout: store unavailable")
+	}
+}
+
+// Middleware guards a credential checking route: 401 answers of next count as
+// failed attempts of the client IP, 2xx answers clear them, and locked
+// addresses are rejected with 429 before next runs.
+func (l *Lockout) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := "ip:" + middleware.ClientIP(r)
+		if l.reject(w, r, key) {
+			return
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		switch {
+		case rec.status == http.StatusUnauthorized:
+			l.Fail(r.Context(), key)
+		case rec.status >= 200 && rec.status < 300:
+			l.Succeed(r.Context(), key)
+		}
+	})
+}
+
+// Guard rejects locked client IPs with 429 without counting anything, the
+// failures come from Authenticator
+func (l *Lockout) Guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if l.reject(w, r, "ip:"+middleware.ClientIP(r)) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authenticator wraps next so rejected API keys and tokens count as failed
+// attempts of the client IP. Requests without credentials are not counted.
+func (l *Lockout) Authenticator(next auth.Authenticator) auth.Authenticator {
+	return auth.AuthenticatorFunc(func(r *http.Request) (*auth.Principal, error) {
+		principal, err := next.Authenticate(r)
+		if err != nil && err != auth.ErrNoCredentials {
+			l.Fail(r.Context(), "ip:"+middleware.ClientIP(r))
+		}
+		return principal, err
+	})
+}
+
+// reject answers 429 when key is locked
+func (l *Lockout) reject(w http.ResponseWriter, r *http.Request, key string) bool {
+	remaining := l.Locked(r.Context(), key)
+	if remaining <= 0 {
+		return false
+	}
+	metrics.RateLimited.WithLabelValues(metrics.RouteTemplate(r), "lockout").Inc()
+	w.Header().Set("Retry-After", ceilSeconds(remaining))
+	problem.Write(w, r, http.StatusTooManyRequests, "Too many failed attempts, retry later")
+	return true
+}
+
+// statusRecorder captures the status code written by the wrapped handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status code before passing it through
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Flush keeps streaming handlers working through the recorder
+func (rec *statusRecorder) Flush() {
+	if f, ok := rec.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,145 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval how often idle entries are dropped from a MemoryStore
+const sweepInterval = time.Minute
+
+// bucket token bucket state
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full time at which the bucket is full again and may be forgotten
+	full time.Time
+}
+
+// counter value expiring at expires
+type counter struct {
+	value   int
+	expires time.Time
+}
+
+// MemoryStore in-process Store, budgets are per replica
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	counters  map[string]*counter
+	blocks    map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore empty in-process store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:  map[string]*bucket{},
+		counters: map[string]*counter{},
+		blocks:   map[string]time.Time{},
+	}
+}
+
+// Take removes one token from the bucket at key
+func (s *MemoryStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.rate())
+	b.last = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	result := newResult(allowed, b.tokens, limit)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// Increment adds one to the counter at key, restarting its ttl
+func (s *MemoryStore) Increment(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expires) {
+		c = &counter{}
+		s.counters[key] = c
+	}
+	c.value++
+	c.expires = now.Add(ttl)
+	return c.value, nil
+}
+
+// Block marks key as blocked for d
+func (s *MemoryStore) Block(ctx context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.blocks[key] = time.Now().Add(d)
+	return nil
+}
+
+// Blocked remaining block time for key
+func (s *MemoryStore) Blocked(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.blocks[key]
+	if !ok {
+		return 0, nil
+	}
+	if remaining := until.Sub(time.Now()); remaining > 0 {
+		return remaining, nil
+	}
+	delete(s.blocks, key)
+	return 0, nil
+}
+
+// Reset forgets every counter and block stored at key
+func (s *MemoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.buckets, key)
+	delete(s.counters, key)
+	delete(s.blocks, key)
+	return nil
+}
+
+// sweep drops full buckets and expired counters and blocks, caller holds mu
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, c := range s.counters {
+		if !now.Before(c.expires) {
+			delete(s.counters, key)
+		}
+	}
+	for key, until := range s.blocks {
+		if !now.Before(until) {
+			delete(s.blocks, key)
+		}
+	}
+}
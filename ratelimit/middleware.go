@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"svc-customer/auth"
+	"svc-customer/logging"
+	"svc-customer/metrics"
+	"svc-customer/middleware"
+	"svc-customer/problem"
+	"time"
+)
+
+// Limiter applies the policy budgets to every request
+type Limiter struct {
+	Store  Store
+	Policy *Policy
+}
+
+// ClientKey authenticated subject (users and API keys) or the client IP for
+// anonymous callers.
+func ClientKey(r *http.Request) string {
+	if principal, ok := auth.FromContext(r.Context()); ok {
+		return "sub:" + principal.Subject
+	}
+	return "ip:" + middleware.ClientIP(r)
+}
+
+// Middleware takes one token per request from the bucket of the caller on the
+// matched route and answers 429 once it is empty. Register it with router.Use
+// after authentication so callers are told apart by subject. Store failures
+// let the request through.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := metrics.RouteTemplate(r)
+		limit := l.Policy.For(r.Method, route)
+
+		result, err := l.Store.Take(r.Context(), r.Method+" "+route+"|"+ClientKey(r), limit)
+		if err != nil {
+			logging.FromContext(r.Context()).WithError(err).Warn("ratelimit: store unavailable, request allowed")
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", ceilSeconds(result.Reset))
+
+		if !result.Allowed {
+			metrics.RateLimited.WithLabelValues(route, "budget").Inc()
+			w.Header().Set("Retry-After", ceilSeconds(result.RetryAfter))
+			problem.Write(w, r, http.StatusTooManyRequests, "Rate limit exceeded, retry later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ceilSeconds whole seconds for headers, rounded up so clients never retry early
+func ceilSeconds(d time.Duration) string {
+	return fmt.Sprintf("%d", int64(math.Ceil(d.Seconds())))
+}
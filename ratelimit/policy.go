@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// LockoutConfig progressive lockout after repeated failed credential checks:
+// once MaxFailures happen within WindowSeconds the caller is blocked for
+// BaseSeconds, doubling with every further failure up to MaxSeconds.
+type LockoutConfig struct {
+	MaxFailures   int `json:"max_failures"`
+	WindowSeconds int `json:"window_seconds"`
+	BaseSeconds   int `json:"base_seconds"`
+	MaxSeconds    int `json:"max_seconds"`
+}
+
+// Policy per route budgets keyed by "METHOD /template", Default for the rest
+type Policy struct {
+	Default Limit            `json:"default"`
+	Routes  map[string]Limit `json:"routes"`
+	Lockout LockoutConfig    `json:"lockout"`
+}
+
+// LoadPolicy reads a JSON rate limit policy file
+func LoadPolicy(path string) (*Policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ratelimit: %w", err)
+	}
+	defer file.Close() //nolint
+
+	policy := &Policy{}
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(policy); err != nil {
+		return nil, fmt.Errorf("ratelimit: %s: %w", path, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("ratelimit: %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+// For budget applying to method on the route template
+func (p *Policy) For(method string, route string) Limit {
+	if limit, ok := p.Routes[method+" "+route]; ok {
+		return limit
+	}
+	return p.Default
+}
+
+// window failures older than this are forgotten
+func (c LockoutConfig) window() time.Duration {
+	return time.Duration(c.WindowSeconds) * time.Second
+}
+
+// blockFor lock duration after failures, zero below MaxFailures
+func (c LockoutConfig) blockFor(failures int) time.Duration {
+	if c.MaxFailures <= 0 || failures < c.MaxFailures {
+		return 0
+	}
+	block := time.Duration(c.BaseSeconds) * time.Second
+	max := time.Duration(c.MaxSeconds) * time.Second
+	for i := c.MaxFailures; i < failures && block < max; i++ {
+		block *= 2
+	}
+	if block > max {
+		block = max
+	}
+	return block
+}
+
+// validate rejects budgets that would block every request
+func (p *Policy) validate() error {
+	if err := p.Default.validate(); err != nil {
+		return fmt.Errorf("default: %w", err)
+	}
+	for route, limit := range p.Routes {
+		if err := limit.validate(); err != nil {
+			return fmt.Errorf("%s: %w", route, err)
+		}
+	}
+	return nil
+}
+
+// validate both fields must be positive
+func (l Limit) validate() error {
+	if l.RequestsPerMinute <= 0 || l.Burst <= 0 {
+		return fmt.Errorf("requests_per_minute and burst must be positive")
+	}
+	return nil
+}
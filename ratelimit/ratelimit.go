@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit token bucket budget, Burst requests at once refilled at RequestsPerMinute
+type Limit struct {
+	RequestsPerMinute int `json:"requests_per_minute"`
+	Burst             int `json:"burst"`
+}
+
+// rate tokens added per second
+func (l Limit) rate() float64 {
+	return float64(l.RequestsPerMinute) / 60
+}
+
+// Result outcome of taking one token from a bucket
+type Result struct {
+	Allowed bool
+	// Limit bucket capacity
+	Limit int
+	// Remaining whole tokens left after this request
+	Remaining int
+	// Reset time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter time until the next token, zero when allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps bucket and lockout state, MemoryStore for a single replica and
+// RedisStore when several replicas must share budgets.
+type Store interface {
+	// Take removes one token from the bucket at key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// Increment adds one to the counter at key, restarting its ttl, and returns the new value
+	Increment(ctx context.Context, key string, ttl time.Duration) (int, error)
+	// Block marks key as blocked for d
+	Block(ctx context.Context, key string, d time.Duration) error
+	// Blocked remaining block time for key, zero when not blocked
+	Blocked(ctx context.Context, key string) (time.Duration, error)
+	// Reset forgets every counter and block stored at key
+	Reset(ctx context.Context, key string) error
+}
+
+// newResult derives headers data from the tokens left in a bucket
+func newResult(allowed bool, tokens float64, limit Limit) Result {
+	rate := limit.rate()
+	result := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / rate),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) / rate)
+	}
+	return result
+}
+
+// seconds converts a float number of seconds into a Duration
+func seconds(s float64) time.Duration {
+	if s <= 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,192 @@
+package ratelimit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"svc-customer/auth"
+	"svc-customer/ratelimit"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadPolicy uses the policy shipped with the service so config mistakes fail here
+func loadPolicy(t *testing.T) *ratelimit.Policy {
+	policy, err := ratelimit.LoadPolicy("../config/ratelimits.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestPolicyRouteBudgets(t *testing.T) {
+
+	policy := loadPolicy(t)
+
+	assert.Equal(t, 5, policy.For("POST", "/").Burst)
+	assert.Equal(t, policy.Default, policy.For("GET", "/{uuid}"))
+}
+
+func TestMemoryStoreEmptiesBucket(t *testing.T) {
+
+	store := ratelimit.NewMemoryStore()
+	limit := ratelimit.Limit{RequestsPerMinute: 1, Burst: 2}
+
+	first, _ := store.Take(context.Background(), "k", limit)
+	second, _ := store.Take(context.Background(), "k", limit)
+	third, _ := store.Take(context.Background(), "k", limit)
+
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 1, first.Remaining)
+	assert.True(t, second.Allowed)
+	assert.False(t, third.Allowed)
+	assert.InDelta(t, time.Minute.Seconds(), third.RetryAfter.Seconds(), 1)
+
+	other, _ := store.Take(context.Background(), "other", limit)
+	assert.True(t, other.Allowed)
+}
+
+func TestLimiterAnswers429PerSubject(t *testing.T) {
+
+	policy := &ratelimit.Policy{Default: ratelimit.Limit{RequestsPerMinute: 1, Burst: 1}}
+	limiter := &ratelimit.Limiter{Store: ratelimit.NewMemoryStore(), Policy: policy}
+
+	r := mux.NewRouter()
+	r.Use(limiter.Middleware)
+	r.HandleFunc("/{uuid}", func(w http.ResponseWriter, r *http.Request) {}).Methods("GET")
+
+	call := func(subject string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/039d69ee-f9cb-4a3d-87e4-6eb63c302579", nil)
+		req = req.WithContext(auth.NewContext(req.Context(), &auth.Principal{Subject: subject}))
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	ok := call("billing")
+	assert.Equal(t, http.StatusOK, ok.Code)
+	assert.Equal(t, "1", ok.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", ok.Header().Get("RateLimit-Remaining"))
+
+	limited := call("billing")
+	assert.Equal(t, http.StatusTooManyRequests, limited.Code)
+	assert.Equal(t, "60", limited.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, call("notifications").Code)
+}
+
+func TestLockoutIsProgressive(t *testing.T) {
+
+	lockout := &ratelimit.Lockout{
+		Store:  ratelimit.NewMemoryStore(),
+		Config: ratelimit.LockoutConfig{MaxFailures: 3, WindowSeconds: 900, BaseSeconds: 60, MaxSeconds: 150},
+	}
+	ctx := context.Background()
+
+	lockout.Fail(ctx, "ip:10.0.0.1")
+	lockout.Fail(ctx, "ip:10.0.0.1")
+	assert.Zero(t, lockout.Locked(ctx, "ip:10.0.0.1"))
+
+	lockout.Fail(ctx, "ip:10.0.0.1")
+	assert.InDelta(t, 60, lockout.Locked(ctx, "ip:10.0.0.1").Seconds(), 1)
+
+	lockout.Fail(ctx, "ip:10.0.0.1")
+	assert.InDelta(t, 120, lockout.Locked(ctx, "ip:10.0.0.1").Seconds(), 1)
+
+	lockout.Fail(ctx, "ip:10.0.0.1")
+	assert.InDelta(t, 150, lockout.Locked(ctx, "ip:10.0.0.1").Seconds(), 1)
+
+	lockout.Succeed(ctx, "ip:10.0.0.1")
+	assert.Zero(t, lockout.Locked(ctx, "ip:10.0.0.1"))
+}
+
+func TestLockoutMiddlewareCountsUnauthorized(t *testing.T) {
+
+	lockout := &ratelimit.Lockout{
+		Store:  ratelimit.NewMemoryStore(),
+		Config: ratelimit.LockoutConfig{MaxFailures: 2, WindowSeconds: 900, BaseSeconds: 60, MaxSeconds: 3600},
+	}
+	handler := lockout.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	codes := []int{}
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "203.0.113.9:5555"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests}, codes)
+}
+
+func TestLockoutMiddlewareSuccessClearsFailures(t *testing.T) {
+
+	lockout := &ratelimit.Lockout{
+		Store:  ratelimit.NewMemoryStore(),
+		Config: ratelimit.LockoutConfig{MaxFailures: 2, WindowSeconds: 900, BaseSeconds: 60, MaxSeconds: 3600},
+	}
+	handler := lockout.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("password") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	codes := []int{}
+	for _, password := range []string{"wrong", "secret", "wrong", "wrong"} {
+		req := httptest.NewRequest("POST", "/sessions?password="+password, nil)
+		req.RemoteAddr = "203.0.113.9:5555"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+
+	// The login in between reset the count, the lock starts at the 2nd failure after it
+	assert.Equal(t, []int{http.StatusUnauthorized, http.StatusCreated, http.StatusUnauthorized, http.StatusUnauthorized}, codes)
+	assert.NotZero(t, lockout.Locked(context.Background(), "ip:203.0.113.9"))
+}
+
+func TestLockoutAuthenticatorCountsRejectedCredentials(t *testing.T) {
+
+	lockout := &ratelimit.Lockout{
+		Store:  ratelimit.NewMemoryStore(),
+		Config: ratelimit.LockoutConfig{MaxFailures: 2, WindowSeconds: 900, BaseSeconds: 60, MaxSeconds: 3600},
+	}
+	authenticator := lockout.Authenticator(auth.AuthenticatorFunc(func(r *http.Request) (*auth.Principal, error) {
+		switch r.Header.Get(auth.APIKeyHeader) {
+		case "":
+			return nil, auth.ErrNoCredentials
+		case "valid":
+			return &auth.Principal{Subject: "apikey:1"}, nil
+		}
+		return nil, auth.ErrInvalidCredentials
+	}))
+	handler := lockout.Guard(auth.Middleware(authenticator)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Handler 401s are not credential checks of the lockout
+		w.WriteHeader(http.StatusUnauthorized)
+	})))
+
+	codes := []int{}
+	for _, key := range []string{"", "", "valid", "valid", "wrong", "wrong", "valid"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "203.0.113.9:5555"
+		if key != "" {
+			req.Header.Set(auth.APIKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		codes = append(codes, rr.Code)
+	}
+
+	assert.Equal(t, []int{
+		http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized, http.StatusUnauthorized,
+		http.StatusUnauthorized, http.StatusUnauthorized, http.StatusTooManyRequests,
+	}, codes)
+}
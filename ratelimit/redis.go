@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// takeScript refills and takes from a token bucket atomically using the Redis
+// clock so replicas with skewed clocks share one budget.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1]) or burst
+local ts = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate) + 1000)
+return {allowed, tostring(tokens)}
+`)
+
+// RedisStore Store shared by every replica through Redis
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore store namespacing every key under prefix
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Take removes one token from the bucket at key
+func (s *RedisStore) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	ratePerMS := limit.rate() / 1000
+	reply, err := takeScript.Run(s.client.WithContext(ctx), []string{s.prefix + "bucket:" + key}, ratePerMS, limit.Burst).Result()
+	if err != nil {
+		return Result{}, err
+	}
+
+	values, ok := reply.([]interface{})
+	if !ok || len(values) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected take reply %v", reply)
+	}
+	allowed, _ := values[0].(int64)
+	tokens, err := strconv.ParseFloat(fmt.Sprint(values[1]), 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return newResult(allowed == 1, tokens, limit), nil
+}
+
+// Increment adds one to the counter at key, restarting its ttl
+func (s *RedisStore) Increment(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	pipe := s.client.WithContext(ctx).TxPipeline()
+	incr := pipe.Incr(s.prefix + "count:" + key)
+	pipe.PExpire(s.prefix+"count:"+key, ttl)
+	if _, err := pipe.Exec(); err != nil {
+		return 0, err
+	}
+	return int(incr.Val()), nil
+}
+
+// Block marks key as blocked for d
+func (s *RedisStore) Block(ctx context.Context, key string, d time.Duration) error {
+	return s.client.WithContext(ctx).Set(s.prefix+"block:"+key, 1, d).Err()
+}
+
+// Blocked remaining block time for key
+func (s *RedisStore) Blocked(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.WithContext(ctx).PTTL(s.prefix + "block:" + key).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL answers negative values for missing keys
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Reset forgets every counter and block stored at key
+func (s *RedisStore) Reset(ctx context.Context, key string) error {
+	return s.client.WithContext(ctx).Del(s.prefix+"bucket:"+key, s.prefix+"count:"+key, s.prefix+"block:"+key).Err()
+}
+
+// Ping checks Redis is reachable, registered as a readiness check
+func (s *RedisStore) Ping(ctx context.Context) error {
+	return s.client.WithContext(ctx).Ping().Err()
+}
@@ -0,0 +1,57 @@
+package totp_test
+
+import (
+	"encoding/base32"
+	"strings"
+	"svc-customer/totp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// rfcSecret RFC 6238 appendix B SHA1 seed "12345678901234567890"
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeMatchesRFC6238Vectors(t *testing.T) {
+
+	// Last 6 digits of the 8 digit SHA1 vectors
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := totp.Code(rfcSecret, totp.Step(time.Unix(unix, 0)))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+func TestValidateAcceptsSkewAndReturnsStep(t *testing.T) {
+
+	now := time.Unix(1111111111, 0)
+	previous, _ := totp.Code(rfcSecret, totp.Step(now)-1)
+
+	step, ok := totp.Validate(rfcSecret, previous, now, 1)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now)-1, step)
+
+	_, ok = totp.Validate(rfcSecret, previous, now, 0)
+	assert.False(t, ok)
+
+	_, ok = totp.Validate(rfcSecret, "12345", now, 1)
+	assert.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+
+	uri := totp.URI("svc-customer", "jhond@gmail.com", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/svc-customer:jhond@gmail.com?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=svc-customer")
+}
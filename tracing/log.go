@@ -0,0 +1,28 @@
+package tracing
+
+import (
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// LogHook adds trace_id and span_id to every entry logged with log.WithContext(ctx)
+type LogHook struct{}
+
+// Levels hook applies to every level
+func (LogHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire copies the active span identifiers into the entry fields
+func (LogHook) Fire(entry *log.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	spanContext := trace.SpanContextFromContext(entry.Context)
+	if !spanContext.IsValid() {
+		return nil
+	}
+	entry.Data["trace_id"] = spanContext.TraceID().String()
+	entry.Data["span_id"] = spanContext.SpanID().String()
+	return nil
+}
@@ -0,0 +1,37 @@
+package tracing
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	quotedLiteral  = regexp.MustCompile(`'(?:[^'\\]|\\.)*'|"(?:[^"\\]|\\.)*"`)
+	numericLiteral = regexp.MustCompile(`\b\d+(?:\.\d+)?\b`)
+	whitespace     = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeStatement replaces every literal with ? so neither customer data
+// nor generated values (UUIDs, password hashes) end up in span attributes.
+func SanitizeStatement(query string) string {
+	query = quotedLiteral.ReplaceAllString(query, "?")
+	query = numericLiteral.ReplaceAllString(query, "?")
+	return strings.TrimSpace(whitespace.ReplaceAllString(query, " "))
+}
+
+// StartSQL begins a client span for a single SQL statement
+func StartSQL(ctx context.Context, operation string, query string) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, "db."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "mysql"),
+			attribute.String("db.operation", operation),
+			attribute.String("db.statement", SanitizeStatement(query)),
+		),
+	)
+}
@@ -0,0 +1,99 @@
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName tracer name used by every svc-customer span
+const InstrumentationName = "svc-customer"
+
+// DefaultServiceName reported as service.name when OTEL_SERVICE_NAME is unset
+const DefaultServiceName = "svc-customer"
+
+// Init configures the global tracer provider and W3C propagators from env:
+//
+//	OTEL_TRACES_EXPORTER         otlp | stdout | none (default none, otlp when an endpoint is set)
+//	OTEL_EXPORTER_OTLP_ENDPOINT  collector endpoint, read by the OTLP exporter
+//	OTEL_SERVICE_NAME            service.name resource attribute
+//
+// The returned func flushes pending spans and must be called on shutdown.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	exporterName, ok := os.LookupEnv("OTEL_TRACES_EXPORTER")
+	if !ok {
+		exporterName = "none"
+		if _, endpoint := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint {
+			exporterName = "otlp"
+		}
+	}
+
+	var exporter sdktrace.SpanExporter
+	var err error
+
+	switch exporterName {
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		// Spans are still created so trace IDs reach the logs, they are just not exported.
+		provider := sdktrace.NewTracerProvider(sdktrace.WithResource(serviceResource()))
+		otel.SetTracerProvider(provider)
+		return provider.Shutdown, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewProvider(exporter)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// NewProvider tracer provider batching spans into exporter, tests pass an in-memory exporter
+func NewProvider(exporter sdktrace.SpanExporter) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(serviceResource()),
+	)
+}
+
+// serviceResource resource describing this service
+func serviceResource() *resource.Resource {
+	name, ok := os.LookupEnv("OTEL_SERVICE_NAME")
+	if !ok || name == "" {
+		name = DefaultServiceName
+	}
+	return resource.NewSchemaless(attribute.String("service.name", name))
+}
+
+// Start begins a span named name using the global tracer provider
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records err on span (when present) and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
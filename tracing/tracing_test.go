@@ -0,0 +1,85 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"svc-customer/tracing"
+	"testing"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// setup installs an in-memory exporter as the global tracer provider
+func setup(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	return exporter
+}
+
+func TestSanitizeStatement(t *testing.T) {
+
+	query := `INSERT INTO customers (customer_uuid, name, password)
+	          VALUES ("039d69ee-f9cb-4a3d-87e4-6eb63c302579", ?, 'a94a8fe5cc') LIMIT 1`
+
+	expected := "INSERT INTO customers (customer_uuid, name, password) VALUES (?, ?, ?) LIMIT ?"
+	assert.Equal(t, expected, tracing.SanitizeStatement(query))
+}
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+
+	exporter := setup(t)
+
+	router := mux.NewRouter()
+	router.Use(tracing.Middleware)
+	router.HandleFunc("/{uuid}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.StartSQL(r.Context(), "GetByUUID", "SELECT name FROM customers WHERE customer_uuid = 'x'")
+		tracing.End(span, nil)
+	}).Methods("GET")
+
+	req := httptest.NewRequest("GET", "/039d69ee-f9cb-4a3d-87e4-6eb63c302579", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 2)
+
+	sql, server := spans[0], spans[1]
+	assert.Equal(t, "GET /{uuid}", server.Name)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext.TraceID().String())
+	assert.Equal(t, server.SpanContext.SpanID(), sql.Parent.SpanID())
+	assert.Equal(t, "db.GetByUUID", sql.Name)
+	assert.NotEmpty(t, rr.Header().Get("traceparent"))
+}
+
+func TestLogHookInjectsTraceID(t *testing.T) {
+
+	setup(t)
+
+	var buf bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&buf)
+	logger.SetFormatter(&log.JSONFormatter{})
+	logger.AddHook(tracing.LogHook{})
+
+	ctx, span := tracing.Start(context.Background(), "test")
+	defer span.End()
+
+	logger.WithContext(ctx).Info("hello")
+
+	traceID := trace.SpanContextFromContext(ctx).TraceID().String()
+	assert.Contains(t, buf.String(), `"trace_id":"`+traceID+`"`)
+}